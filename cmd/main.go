@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 
 	"github.com/nahwinrajan/testswpro/generated"
@@ -11,13 +12,19 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 )
 
+// number of workers running the background jobs, e.g. estate recalculation
+const jobWorkers = 4
+
 func main() {
 	e := echo.New()
 
 	server := newServer()
+	// TODO: stop the workers along with graceful shutdown
+	go server.RunJobs(context.Background(), jobWorkers)
 
-	generated.RegisterHandlers(e, server)
+	generated.RegisterHandlers(handler.NewRouter(e), server)
 	e.Use(middleware.Logger())
+	e.Use(newValidator().Middleware)
 	// TODO: ideally we want to add configuration for
 	// cors (unless we are only accessible from within cluster)
 	// metrics gathering
@@ -29,7 +36,28 @@ func main() {
 
 func newServer() *handler.Server {
 	dbDsn := os.Getenv("DATABASE_URL")
+	// everything is lost on restart, for demos and local development without postgres
+	if dbDsn == repository.MemoryDSN {
+		return handler.New(repository.NewMemory())
+	}
 	repo := repository.New(dbDsn)
 
 	return handler.New(repo)
 }
+
+func newValidator() *handler.Validator {
+	spec, err := generated.GetSwagger()
+	if err != nil {
+		panic(err)
+	}
+
+	// responses are checked too in test mode, e.g. by the api tests
+	validator, err := handler.NewValidator(spec, handler.ValidatorOptions{
+		ValidateResponses: os.Getenv("VALIDATE_RESPONSES") == "true",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return validator
+}
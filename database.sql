@@ -4,8 +4,12 @@
 -- 2. How you choose the data types and keys.
 -- 3. How you name the fields.
 -- In this assignment we will use PostgreSQL as the database.
+--
+-- The script can run again on a database created by an earlier version of it, it only adds
+-- what is missing. Estates stored before their stats status was tracked are marked pending
+-- and recalculated in the background, which also stores their route steps.
 
-CREATE TABLE "estates" (
+CREATE TABLE IF NOT EXISTS "estates" (
   "estate_id" text PRIMARY KEY,
   "width" int DEFAULT 0,
   "length" int DEFAULT 0,
@@ -15,13 +19,39 @@ CREATE TABLE "estates" (
   "median" int DEFAULT 0,
   "patrol_distance" int DEFAULT 0,
   "patrol_route" text DEFAULT '',
+  "origin_lat" double precision,
+  "origin_lon" double precision,
+  "bearing" double precision NOT NULL DEFAULT 0,
+  "stats_status" varchar(8) NOT NULL DEFAULT 'fresh',
+  "plot_size" int NOT NULL DEFAULT 10,
+  "monitor_clearance" int NOT NULL DEFAULT 1,
+  "cruise_altitude_min" int NOT NULL DEFAULT 0,
+  "max_altitude" int NOT NULL DEFAULT 120,
+  "zone_count" int NOT NULL DEFAULT 0,
+  "boundary" text NOT NULL DEFAULT '',
   "created_at" timestamp DEFAULT (now()),
-  "updated_at" timestamp NOT NULL DEFAULT (now()),
+  "updated_at" timestamp NOT NULL DEFAULT (now())
 );
 
-CREATE TABLE "trees" (
+-- columns added since the estates table was first created
+ALTER TABLE "estates" ADD COLUMN IF NOT EXISTS "origin_lat" double precision;
+ALTER TABLE "estates" ADD COLUMN IF NOT EXISTS "origin_lon" double precision;
+ALTER TABLE "estates" ADD COLUMN IF NOT EXISTS "bearing" double precision NOT NULL DEFAULT 0;
+ALTER TABLE "estates" ADD COLUMN IF NOT EXISTS "plot_size" int NOT NULL DEFAULT 10;
+ALTER TABLE "estates" ADD COLUMN IF NOT EXISTS "monitor_clearance" int NOT NULL DEFAULT 1;
+ALTER TABLE "estates" ADD COLUMN IF NOT EXISTS "cruise_altitude_min" int NOT NULL DEFAULT 0;
+ALTER TABLE "estates" ADD COLUMN IF NOT EXISTS "max_altitude" int NOT NULL DEFAULT 120;
+ALTER TABLE "estates" ADD COLUMN IF NOT EXISTS "zone_count" int NOT NULL DEFAULT 0;
+ALTER TABLE "estates" ADD COLUMN IF NOT EXISTS "boundary" text NOT NULL DEFAULT '';
+-- the estates already stored are pending, only the new ones start fresh
+ALTER TABLE "estates" ADD COLUMN IF NOT EXISTS "stats_status" varchar(8) NOT NULL DEFAULT 'pending';
+ALTER TABLE "estates" ALTER COLUMN "stats_status" SET DEFAULT 'fresh';
+
+CREATE INDEX IF NOT EXISTS "idx_estates_created_at" ON "estates" ("created_at", "estate_id");
+
+CREATE TABLE IF NOT EXISTS "trees" (
   "tree_id" text PRIMARY KEY,
-  "estate_id" text,
+  "estate_id" text REFERENCES "estates" ("estate_id"),
   "x" int NOT NULL,
   "y" int NOT NULL,
   "height" int NOT NULL,
@@ -30,6 +60,63 @@ CREATE TABLE "trees" (
   UNIQUE ("estate_id", "x", "y")
 );
 
-CREATE INDEX "idx_trees_estate_x_y" ON "trees" ("estate_id", "x", "y");
+CREATE INDEX IF NOT EXISTS "idx_trees_estate_x_y" ON "trees" ("estate_id", "x", "y");
+
+CREATE INDEX IF NOT EXISTS "idx_trees_estate_y_x" ON "trees" ("estate_id", "y", "x");
+
+CREATE INDEX IF NOT EXISTS "idx_trees_estate_height" ON "trees" ("estate_id", "height");
+
+CREATE TABLE IF NOT EXISTS "route_steps" (
+  "estate_id" text NOT NULL REFERENCES "estates" ("estate_id"),
+  "step" int NOT NULL,
+  "x" int NOT NULL,
+  "y" int NOT NULL,
+  "direction" varchar(2) NOT NULL,
+  "step_distance" int NOT NULL,
+  "current_distance" int NOT NULL,
+  "altitude" int NOT NULL,
+  PRIMARY KEY ("estate_id", "step")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_route_steps_estate_distance" ON "route_steps" ("estate_id", "current_distance");
+
+CREATE TABLE IF NOT EXISTS "zones" (
+  "zone_id" text PRIMARY KEY,
+  "estate_id" text NOT NULL REFERENCES "estates" ("estate_id"),
+  "kind" varchar(16) NOT NULL,
+  "min_altitude" int NOT NULL DEFAULT 0,
+  "polygon" text NOT NULL,
+  "created_at" timestamp NOT NULL DEFAULT (now()),
+  "updated_at" timestamp NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX IF NOT EXISTS "idx_zones_estate_created_at" ON "zones" ("estate_id", "created_at", "zone_id");
+
+CREATE TABLE IF NOT EXISTS "jobs" (
+  "job_id" text PRIMARY KEY,
+  "kind" varchar(32) NOT NULL,
+  "estate_id" text NOT NULL REFERENCES "estates" ("estate_id"),
+  "attempts" int NOT NULL DEFAULT 0,
+  "run_at" timestamp NOT NULL DEFAULT (now()),
+  "last_error" text,
+  "created_at" timestamp NOT NULL DEFAULT (now()),
+  UNIQUE ("kind", "estate_id")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_jobs_run_at" ON "jobs" ("run_at");
+
+CREATE TABLE IF NOT EXISTS "dead_jobs" (
+  "job_id" text PRIMARY KEY,
+  "kind" varchar(32) NOT NULL,
+  "estate_id" text NOT NULL,
+  "attempts" int NOT NULL,
+  "last_error" text,
+  "created_at" timestamp NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX IF NOT EXISTS "idx_dead_jobs_estate" ON "dead_jobs" ("estate_id");
 
-ALTER TABLE "trees" ADD FOREIGN KEY ("estate_id") REFERENCES "estates" ("estate_id");
+-- a recalculation of every pending estate which has none yet, e.g. the estates just migrated
+INSERT INTO "jobs" ("job_id", "kind", "estate_id")
+SELECT gen_random_uuid()::text, 'recalculate_estate', "estate_id" FROM "estates" WHERE "stats_status" = 'pending'
+ON CONFLICT ("kind", "estate_id") DO NOTHING;
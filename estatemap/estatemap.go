@@ -0,0 +1,168 @@
+// Package estatemap draw the estate plots, its trees shaded by height and the
+// patrol route as SVG or PNG. Big estates are downsampled into cells of several
+// plots so the picture never holds the whole Width x Length plots.
+package estatemap
+
+import (
+	"errors"
+	"math"
+
+	"github.com/nahwinrajan/testswpro/mission"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+const (
+	// MaxCells is the maximum number of cells drawn on each axis
+	MaxCells = 256
+
+	// picture is at most around maxPixels on each axis
+	maxPixels   = 1024
+	maxCellSize = 32
+
+	directionVU = "vu" // up adjusting for tree height
+	directionVD = "vd" // down adjusting for tree height
+)
+
+var ErrInvalidViewport = errors.New("invalid viewport")
+
+// Viewport is the plots drawn, inclusive on both ends
+type Viewport struct {
+	FromX, FromY int
+	ToX, ToY     int
+}
+
+// Point is a position in cell unit, the center of the first cell is (0.5,0.5)
+// and rows go upward like the estate y axis.
+type Point struct {
+	X, Y float64
+}
+
+// Marker is the vertical move of the drone over a tree in the cell
+type Marker struct {
+	Col, Row int
+	Up       bool
+}
+
+// Map is the estate viewport downsampled into Cols x Rows cells of Scale x Scale plots.
+type Map struct {
+	Viewport   Viewport
+	Scale      int
+	Cols, Rows int
+	// the tallest tree on each cell row by row, 0 for cell without tree
+	Heights []int
+	Route   []Point
+	Markers []Marker
+}
+
+// New lay the trees and the patrol route of the estate inside the viewport on the map,
+// a zero viewport is the whole estate.
+func New(estate repository.Estate, trees []repository.Tree, routeSteps []repository.RouteStep, viewport Viewport) (Map, error) {
+	if viewport == (Viewport{}) {
+		viewport = Viewport{FromX: 1, FromY: 1, ToX: estate.Width, ToY: estate.Length}
+	}
+	if viewport.FromX < 1 || viewport.FromY < 1 ||
+		viewport.ToX > estate.Width || viewport.ToY > estate.Length ||
+		viewport.FromX > viewport.ToX || viewport.FromY > viewport.ToY {
+		return Map{}, ErrInvalidViewport
+	}
+
+	plotsX := viewport.ToX - viewport.FromX + 1
+	plotsY := viewport.ToY - viewport.FromY + 1
+	scale := (max(plotsX, plotsY) + MaxCells - 1) / MaxCells
+
+	m := Map{
+		Viewport: viewport,
+		Scale:    scale,
+		Cols:     (plotsX + scale - 1) / scale,
+		Rows:     (plotsY + scale - 1) / scale,
+	}
+	m.Heights = make([]int, m.Cols*m.Rows)
+
+	for _, tree := range trees {
+		col, row, ok := m.cell(tree.X, tree.Y)
+		if !ok {
+			continue
+		}
+		m.Heights[row*m.Cols+col] = max(m.Heights[row*m.Cols+col], tree.Height)
+	}
+
+	// one marker per cell, climbing wins over descending
+	markers := make(map[[2]int]int)
+	for _, routeStep := range routeSteps {
+		if routeStep.Direction != directionVU && routeStep.Direction != directionVD {
+			continue
+		}
+
+		col, row, ok := m.cell(routeStep.X, routeStep.Y)
+		if !ok {
+			continue
+		}
+
+		key := [2]int{col, row}
+		idx, marked := markers[key]
+		if !marked {
+			markers[key] = len(m.Markers)
+			m.Markers = append(m.Markers, Marker{Col: col, Row: row, Up: routeStep.Direction == directionVU})
+			continue
+		}
+		m.Markers[idx].Up = m.Markers[idx].Up || routeStep.Direction == directionVU
+	}
+
+	// the route outside of the viewport is folded on its border, the route only
+	// moves along rows and columns so what is inside the viewport stays the same.
+	for _, waypoint := range mission.Path(estate, routeSteps) {
+		point, clamped := m.clamp(m.point(waypoint.X, waypoint.Y))
+
+		last := len(m.Route) - 1
+		switch {
+		case last >= 0 && m.Route[last] == point:
+			continue
+		case (scale > 1 || clamped) && last >= 1 && m.Route[last-1] == point:
+			// downsampled or folded rows go back and forth over the same line
+			continue
+		}
+		m.Route = append(m.Route, point)
+	}
+
+	return m, nil
+}
+
+// CellSize return the size of a cell in pixel
+func (m Map) CellSize() int {
+	return max(1, min(maxCellSize, maxPixels/max(m.Cols, m.Rows)))
+}
+
+// cell return the cell of plot (x,y), ok is false when the plot is outside of the viewport
+func (m Map) cell(x, y int) (col, row int, ok bool) {
+	if x < m.Viewport.FromX || x > m.Viewport.ToX || y < m.Viewport.FromY || y > m.Viewport.ToY {
+		return 0, 0, false
+	}
+
+	return (x - m.Viewport.FromX) / m.Scale, (y - m.Viewport.FromY) / m.Scale, true
+}
+
+// point return the center of plot (x,y) in cell unit,
+// downsampled plots are on the center of their cell.
+func (m Map) point(x, y int) Point {
+	if m.Scale == 1 {
+		return Point{
+			X: float64(x-m.Viewport.FromX) + 0.5,
+			Y: float64(y-m.Viewport.FromY) + 0.5,
+		}
+	}
+
+	col := math.Floor(float64(x-m.Viewport.FromX) / float64(m.Scale))
+	row := math.Floor(float64(y-m.Viewport.FromY) / float64(m.Scale))
+
+	return Point{X: col + 0.5, Y: row + 0.5}
+}
+
+// clamp move the point outside of the map on its border, half a cell away from the cells
+func (m Map) clamp(point Point) (Point, bool) {
+	clamped := Point{
+		X: math.Max(-0.5, math.Min(float64(m.Cols)+0.5, point.X)),
+		Y: math.Max(-0.5, math.Min(float64(m.Rows)+0.5, point.Y)),
+	}
+
+	return clamped, clamped != point
+}
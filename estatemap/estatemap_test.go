@@ -0,0 +1,155 @@
+package estatemap
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// 3x2 estate with a tree on the second plot of each row
+func mappedEstate() (repository.Estate, []repository.Tree, []repository.RouteStep) {
+	estate := repository.Estate{ID: "estate_id", Width: 3, Length: 2}
+	trees := []repository.Tree{
+		{ID: "tree_id_1", X: 2, Y: 1, Height: 5},
+		{ID: "tree_id_2", X: 2, Y: 2, Height: 3},
+	}
+	routeSteps := []repository.RouteStep{
+		{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 10},
+		{Step: 2, X: 2, Y: 1, Direction: "vu", StepDistance: 6, CurrentDistance: 16, Altitude: 6},
+		{Step: 3, X: 2, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 26, Altitude: 6},
+		{Step: 4, X: 3, Y: 2, Direction: "sn", StepDistance: 10, CurrentDistance: 36, Altitude: 6},
+		{Step: 5, X: 3, Y: 2, Direction: "we", StepDistance: 10, CurrentDistance: 46, Altitude: 6},
+		{Step: 6, X: 2, Y: 2, Direction: "vd", StepDistance: 2, CurrentDistance: 48, Altitude: 4},
+		{Step: 7, X: 2, Y: 2, Direction: "we", StepDistance: 10, CurrentDistance: 58, Altitude: 4},
+	}
+
+	return estate, trees, routeSteps
+}
+
+func TestNew(t *testing.T) {
+	estate, trees, routeSteps := mappedEstate()
+
+	tests := []struct {
+		name        string
+		estate      repository.Estate
+		viewport    Viewport
+		expectedMap Map
+		expectedErr error
+	}{
+		{
+			name:     "Whole estate",
+			estate:   estate,
+			viewport: Viewport{},
+			expectedMap: Map{
+				Viewport: Viewport{FromX: 1, FromY: 1, ToX: 3, ToY: 2},
+				Scale:    1,
+				Cols:     3,
+				Rows:     2,
+				Heights:  []int{0, 5, 0, 0, 3, 0},
+				Route: []Point{
+					{X: 0.5, Y: 0.5}, {X: 1.5, Y: 0.5}, {X: 2.5, Y: 0.5},
+					{X: 2.5, Y: 1.5}, {X: 1.5, Y: 1.5}, {X: 0.5, Y: 1.5},
+				},
+				Markers: []Marker{{Col: 1, Row: 0, Up: true}, {Col: 1, Row: 1, Up: false}},
+			},
+		},
+		{
+			name:     "Tile",
+			estate:   estate,
+			viewport: Viewport{FromX: 2, FromY: 2, ToX: 3, ToY: 2},
+			expectedMap: Map{
+				Viewport: Viewport{FromX: 2, FromY: 2, ToX: 3, ToY: 2},
+				Scale:    1,
+				Cols:     2,
+				Rows:     1,
+				Heights:  []int{3, 0},
+				Route: []Point{
+					{X: -0.5, Y: -0.5}, {X: 0.5, Y: -0.5}, {X: 1.5, Y: -0.5},
+					{X: 1.5, Y: 0.5}, {X: 0.5, Y: 0.5}, {X: -0.5, Y: 0.5},
+				},
+				Markers: []Marker{{Col: 0, Row: 0, Up: false}},
+			},
+		},
+		{
+			name:     "Downsampled",
+			estate:   repository.Estate{ID: "estate_id", Width: 600, Length: 2},
+			viewport: Viewport{},
+			expectedMap: Map{
+				Viewport: Viewport{FromX: 1, FromY: 1, ToX: 600, ToY: 2},
+				Scale:    3,
+				Cols:     200,
+				Rows:     1,
+				Heights:  append([]int{5}, make([]int, 199)...),
+				Route:    []Point{{X: 0.5, Y: 0.5}},
+				Markers:  []Marker{{Col: 0, Row: 0, Up: true}},
+			},
+		},
+		{
+			name:        "Viewport outside of the estate",
+			estate:      estate,
+			viewport:    Viewport{FromX: 1, FromY: 1, ToX: 4, ToY: 2},
+			expectedErr: ErrInvalidViewport,
+		},
+		{
+			name:        "Viewport from after to",
+			estate:      estate,
+			viewport:    Viewport{FromX: 3, FromY: 1, ToX: 2, ToY: 2},
+			expectedErr: ErrInvalidViewport,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := New(tc.estate, trees, routeSteps, tc.viewport)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMap, m)
+		})
+	}
+}
+
+func TestSVG(t *testing.T) {
+	estate, trees, routeSteps := mappedEstate()
+	m, err := New(estate, trees, routeSteps, Viewport{})
+	require.NoError(t, err)
+
+	svg := string(m.SVG())
+	require.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="96" height="64"`))
+	require.Contains(t, svg, `<title>plots (1,1) to (3,2), 1 x 1 plots per cell</title>`)
+
+	// the tallest tree is the darkest, the first row is at the bottom
+	require.Contains(t, svg, `<rect x="32" y="32" width="32" height="32" fill="#005a32"><title>5</title></rect>`)
+	require.Contains(t, svg, `<rect x="32" y="0" width="32" height="32" fill="#c7e9c0"><title>3</title></rect>`)
+	require.Contains(t, svg, `points="16,48 48,48 80,48 80,16 48,16 16,16"`)
+	require.Equal(t, 2, strings.Count(svg, "<polygon "))
+}
+
+func TestPNG(t *testing.T) {
+	estate, trees, routeSteps := mappedEstate()
+	m, err := New(estate, trees, routeSteps, Viewport{})
+	require.NoError(t, err)
+
+	data, err := m.PNG()
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, 96, img.Bounds().Dx())
+	require.Equal(t, 64, img.Bounds().Dy())
+
+	// corner of the plot without tree nor route is soil
+	r, g, b, _ := img.At(70, 2).RGBA()
+	require.Equal(t, [3]uint32{uint32(colorSoil.R) * 0x101, uint32(colorSoil.G) * 0x101, uint32(colorSoil.B) * 0x101}, [3]uint32{r, g, b})
+
+	// corner of the tallest tree
+	r, g, b, _ = img.At(34, 62).RGBA()
+	require.Equal(t, [3]uint32{uint32(colorTall.R) * 0x101, uint32(colorTall.G) * 0x101, uint32(colorTall.B) * 0x101}, [3]uint32{r, g, b})
+}
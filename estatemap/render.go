@@ -0,0 +1,215 @@
+package estatemap
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+)
+
+var (
+	colorSoil    = color.RGBA{R: 245, G: 240, B: 225, A: 255}
+	colorGrid    = color.RGBA{R: 208, G: 200, B: 176, A: 255}
+	colorLow     = color.RGBA{R: 199, G: 233, B: 192, A: 255}
+	colorTall    = color.RGBA{R: 0, G: 90, B: 50, A: 255}
+	colorRoute   = color.RGBA{R: 214, G: 39, B: 40, A: 255}
+	colorClimb   = color.RGBA{R: 31, G: 119, B: 180, A: 255}
+	colorDescend = color.RGBA{R: 255, G: 127, B: 14, A: 255}
+)
+
+// grid lines are only drawn when the cells are big enough to tell apart
+const minGridCellSize = 4
+
+// heightRange return the shortest and the tallest tree on the map
+func (m Map) heightRange() (minHeight, maxHeight int) {
+	for _, height := range m.Heights {
+		if height == 0 {
+			continue
+		}
+		if minHeight == 0 || height < minHeight {
+			minHeight = height
+		}
+		maxHeight = max(maxHeight, height)
+	}
+
+	return minHeight, maxHeight
+}
+
+// treeColor shade the tree from light (the shortest) to dark (the tallest)
+func treeColor(height, minHeight, maxHeight int) color.RGBA {
+	ratio := 1.0
+	if maxHeight > minHeight {
+		ratio = float64(height-minHeight) / float64(maxHeight-minHeight)
+	}
+
+	mix := func(low, tall uint8) uint8 {
+		return uint8(math.Round(float64(low) + (float64(tall)-float64(low))*ratio))
+	}
+
+	return color.RGBA{R: mix(colorLow.R, colorTall.R), G: mix(colorLow.G, colorTall.G), B: mix(colorLow.B, colorTall.B), A: 255}
+}
+
+// pixel return the picture position of the map point, the first row is at the bottom
+func (m Map) pixel(point Point, cellSize int) (float64, float64) {
+	return point.X * float64(cellSize), (float64(m.Rows) - point.Y) * float64(cellSize)
+}
+
+// triangle return the corners of the marker in the cell, pointing up when climbing
+func (m Map) triangle(marker Marker, cellSize int) [3][2]float64 {
+	x, y := m.pixel(Point{X: float64(marker.Col) + 0.5, Y: float64(marker.Row) + 0.5}, cellSize)
+	r := float64(cellSize) * 0.3
+	if marker.Up {
+		return [3][2]float64{{x, y - r}, {x - r, y + r}, {x + r, y + r}}
+	}
+
+	return [3][2]float64{{x, y + r}, {x - r, y - r}, {x + r, y - r}}
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// SVG draw the map as SVG picture
+func (m Map) SVG() []byte {
+	cellSize := m.CellSize()
+	width, height := m.Cols*cellSize, m.Rows*cellSize
+
+	var strb strings.Builder
+	fmt.Fprintf(&strb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	fmt.Fprintf(&strb, `<title>plots (%d,%d) to (%d,%d), %d x %d plots per cell</title>`+"\n",
+		m.Viewport.FromX, m.Viewport.FromY, m.Viewport.ToX, m.Viewport.ToY, m.Scale, m.Scale)
+	fmt.Fprintf(&strb, `<rect width="%d" height="%d" fill="%s"/>`+"\n", width, height, hexColor(colorSoil))
+
+	minHeight, maxHeight := m.heightRange()
+	strb.WriteString(`<g class="trees">` + "\n")
+	for idx, treeHeight := range m.Heights {
+		if treeHeight == 0 {
+			continue
+		}
+
+		col, row := idx%m.Cols, idx/m.Cols
+		fmt.Fprintf(&strb, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"><title>%d</title></rect>`+"\n",
+			col*cellSize, (m.Rows-row-1)*cellSize, cellSize, cellSize, hexColor(treeColor(treeHeight, minHeight, maxHeight)), treeHeight)
+	}
+	strb.WriteString("</g>\n")
+
+	if cellSize >= minGridCellSize {
+		fmt.Fprintf(&strb, `<g class="grid" stroke="%s" stroke-width="1">`+"\n", hexColor(colorGrid))
+		for col := 1; col < m.Cols; col++ {
+			fmt.Fprintf(&strb, `<line x1="%d" y1="0" x2="%d" y2="%d"/>`+"\n", col*cellSize, col*cellSize, height)
+		}
+		for row := 1; row < m.Rows; row++ {
+			fmt.Fprintf(&strb, `<line x1="0" y1="%d" x2="%d" y2="%d"/>`+"\n", row*cellSize, width, row*cellSize)
+		}
+		strb.WriteString("</g>\n")
+	}
+
+	if len(m.Route) > 0 {
+		points := make([]string, 0, len(m.Route))
+		for _, point := range m.Route {
+			x, y := m.pixel(point, cellSize)
+			points = append(points, fmt.Sprintf("%g,%g", x, y))
+		}
+		fmt.Fprintf(&strb, `<polyline class="route" fill="none" stroke="%s" stroke-width="%d" points="%s"/>`+"\n",
+			hexColor(colorRoute), max(1, cellSize/8), strings.Join(points, " "))
+	}
+
+	strb.WriteString(`<g class="markers">` + "\n")
+	for _, marker := range m.Markers {
+		fill := colorDescend
+		if marker.Up {
+			fill = colorClimb
+		}
+
+		corners := m.triangle(marker, cellSize)
+		fmt.Fprintf(&strb, `<polygon points="%g,%g %g,%g %g,%g" fill="%s"/>`+"\n",
+			corners[0][0], corners[0][1], corners[1][0], corners[1][1], corners[2][0], corners[2][1], hexColor(fill))
+	}
+	strb.WriteString("</g>\n</svg>\n")
+
+	return []byte(strb.String())
+}
+
+// PNG draw the map as PNG picture
+func (m Map) PNG() ([]byte, error) {
+	cellSize := m.CellSize()
+	img := image.NewRGBA(image.Rect(0, 0, m.Cols*cellSize, m.Rows*cellSize))
+
+	fill := func(rect image.Rectangle, c color.RGBA) {
+		rect = rect.Intersect(img.Bounds())
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+
+	fill(img.Bounds(), colorSoil)
+
+	minHeight, maxHeight := m.heightRange()
+	for idx, treeHeight := range m.Heights {
+		if treeHeight == 0 {
+			continue
+		}
+
+		col, row := idx%m.Cols, m.Rows-idx/m.Cols-1
+		fill(image.Rect(col*cellSize, row*cellSize, (col+1)*cellSize, (row+1)*cellSize), treeColor(treeHeight, minHeight, maxHeight))
+	}
+
+	if cellSize >= minGridCellSize {
+		for col := 1; col < m.Cols; col++ {
+			fill(image.Rect(col*cellSize, 0, col*cellSize+1, img.Bounds().Max.Y), colorGrid)
+		}
+		for row := 1; row < m.Rows; row++ {
+			fill(image.Rect(0, row*cellSize, img.Bounds().Max.X, row*cellSize+1), colorGrid)
+		}
+	}
+
+	// route segments are stamped with a square pen along the line
+	pen := max(1, cellSize/8)
+	for idx := 1; idx < len(m.Route); idx++ {
+		x0, y0 := m.pixel(m.Route[idx-1], cellSize)
+		x1, y1 := m.pixel(m.Route[idx], cellSize)
+
+		steps := int(math.Ceil(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))))
+		for step := 0; step <= steps; step++ {
+			ratio := 0.0
+			if steps > 0 {
+				ratio = float64(step) / float64(steps)
+			}
+			x := int(x0 + (x1-x0)*ratio - float64(pen)/2)
+			y := int(y0 + (y1-y0)*ratio - float64(pen)/2)
+			fill(image.Rect(x, y, x+pen, y+pen), colorRoute)
+		}
+	}
+
+	for _, marker := range m.Markers {
+		c := colorDescend
+		if marker.Up {
+			c = colorClimb
+		}
+
+		corners := m.triangle(marker, cellSize)
+		minY := int(math.Floor(math.Min(corners[0][1], corners[1][1])))
+		maxY := int(math.Ceil(math.Max(corners[0][1], corners[1][1])))
+		for y := minY; y <= maxY; y++ {
+			// the triangle narrows linearly from its base to its tip
+			ratio := (float64(y) - corners[0][1]) / (corners[1][1] - corners[0][1])
+			if ratio < 0 || ratio > 1 {
+				continue
+			}
+			halfWidth := (corners[2][0] - corners[1][0]) / 2 * ratio
+			fill(image.Rect(int(corners[0][0]-halfWidth), y, int(corners[0][0]+halfWidth)+1, y+1), c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
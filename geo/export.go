@@ -0,0 +1,246 @@
+package geo
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+const (
+	featureBoundary    = "boundary"
+	featureTree        = "tree"
+	featurePatrolRoute = "patrol_route"
+
+	kmlNamespace             = "http://www.opengis.net/kml/2.2"
+	kmlAltitudeModeGround    = "relativeToGround"
+	kmlAltitudeModeClamp     = "clampToGround"
+	kmlTreePlacemarkNameForm = "tree (%d,%d)"
+)
+
+// Tree is the tree planted on plot (x,y) at Position
+type Tree struct {
+	ID       string
+	X, Y     int
+	Height   int
+	Position Point
+}
+
+// PathPoint is the drone at Position, altitude is in meters above the ground
+type PathPoint struct {
+	Position Point
+	Altitude int
+}
+
+// Estate is everything of the estate exported to GIS, the boundary is a closed ring
+type Estate struct {
+	ID             string
+	Width          int
+	Length         int
+	Boundary       []Point
+	Trees          []Tree
+	PatrolDistance int
+	PatrolRoute    []PathPoint
+}
+
+// *** GeoJSON ***
+// https://datatracker.ietf.org/doc/html/rfc7946
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// EncodeGeoJSON write the estate as GeoJSON feature collection, the boundary
+// polygon, a point for every tree and the patrol route line string with the
+// drone altitude as the third coordinate.
+func EncodeGeoJSON(estate Estate) ([]byte, error) {
+	features := make([]geoJSONFeature, 0, len(estate.Trees)+2)
+
+	boundary := make([][]float64, 0, len(estate.Boundary))
+	for _, point := range estate.Boundary {
+		boundary = append(boundary, []float64{point.Longitude, point.Latitude})
+	}
+	features = append(features, geoJSONFeature{
+		Type: "Feature",
+		Geometry: geoJSONGeometry{
+			Type:        "Polygon",
+			Coordinates: [][][]float64{boundary},
+		},
+		Properties: map[string]any{
+			"kind":      featureBoundary,
+			"estate_id": estate.ID,
+			"width":     estate.Width,
+			"length":    estate.Length,
+		},
+	})
+
+	for _, tree := range estate.Trees {
+		features = append(features, geoJSONFeature{
+			Type: "Feature",
+			Geometry: geoJSONGeometry{
+				Type:        "Point",
+				Coordinates: []float64{tree.Position.Longitude, tree.Position.Latitude},
+			},
+			Properties: map[string]any{
+				"kind":    featureTree,
+				"tree_id": tree.ID,
+				"x":       tree.X,
+				"y":       tree.Y,
+				"height":  tree.Height,
+			},
+		})
+	}
+
+	if len(estate.PatrolRoute) > 0 {
+		route := make([][]float64, 0, len(estate.PatrolRoute))
+		for _, point := range estate.PatrolRoute {
+			route = append(route, []float64{point.Position.Longitude, point.Position.Latitude, float64(point.Altitude)})
+		}
+		features = append(features, geoJSONFeature{
+			Type: "Feature",
+			Geometry: geoJSONGeometry{
+				Type:        "LineString",
+				Coordinates: route,
+			},
+			Properties: map[string]any{
+				"kind":     featurePatrolRoute,
+				"distance": estate.PatrolDistance,
+			},
+		})
+	}
+
+	return json.Marshal(geoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	})
+}
+
+// *** KML ***
+// https://developers.google.com/kml/documentation/kmlreference
+
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	XMLNS      string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+	Folders    []kmlFolder    `xml:"Document>Folder"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name         string         `xml:"name"`
+	ExtendedData []kmlData      `xml:"ExtendedData>Data,omitempty"`
+	Point        *kmlPoint      `xml:"Point,omitempty"`
+	LineString   *kmlLineString `xml:"LineString,omitempty"`
+	Polygon      *kmlPolygon    `xml:"Polygon,omitempty"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	Extrude      int    `xml:"extrude"`
+	AltitudeMode string `xml:"altitudeMode"`
+	Coordinates  string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	AltitudeMode string `xml:"altitudeMode"`
+	Coordinates  string `xml:"coordinates"`
+}
+
+type kmlPolygon struct {
+	AltitudeMode string `xml:"altitudeMode"`
+	Coordinates  string `xml:"outerBoundaryIs>LinearRing>coordinates"`
+}
+
+// EncodeKML write the estate as KML document, the boundary polygon, a folder of
+// tree points standing at their height and the patrol route line string.
+func EncodeKML(estate Estate) ([]byte, error) {
+	boundary := make([]string, 0, len(estate.Boundary))
+	for _, point := range estate.Boundary {
+		boundary = append(boundary, kmlCoordinate(point, 0))
+	}
+
+	doc := kmlDocument{
+		XMLNS: kmlNamespace,
+		Name:  "estate " + estate.ID,
+		Placemarks: []kmlPlacemark{{
+			Name: featureBoundary,
+			ExtendedData: []kmlData{
+				{Name: "width", Value: fmt.Sprint(estate.Width)},
+				{Name: "length", Value: fmt.Sprint(estate.Length)},
+			},
+			Polygon: &kmlPolygon{
+				AltitudeMode: kmlAltitudeModeClamp,
+				Coordinates:  strings.Join(boundary, " "),
+			},
+		}},
+	}
+
+	trees := kmlFolder{
+		Name:       featureTree,
+		Placemarks: make([]kmlPlacemark, 0, len(estate.Trees)),
+	}
+	for _, tree := range estate.Trees {
+		trees.Placemarks = append(trees.Placemarks, kmlPlacemark{
+			Name: fmt.Sprintf(kmlTreePlacemarkNameForm, tree.X, tree.Y),
+			ExtendedData: []kmlData{
+				{Name: "tree_id", Value: tree.ID},
+				{Name: "height", Value: fmt.Sprint(tree.Height)},
+			},
+			Point: &kmlPoint{
+				Extrude:      1,
+				AltitudeMode: kmlAltitudeModeGround,
+				Coordinates:  kmlCoordinate(tree.Position, tree.Height),
+			},
+		})
+	}
+	doc.Folders = []kmlFolder{trees}
+
+	if len(estate.PatrolRoute) > 0 {
+		route := make([]string, 0, len(estate.PatrolRoute))
+		for _, point := range estate.PatrolRoute {
+			route = append(route, kmlCoordinate(point.Position, point.Altitude))
+		}
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			Name: featurePatrolRoute,
+			ExtendedData: []kmlData{
+				{Name: "distance", Value: fmt.Sprint(estate.PatrolDistance)},
+			},
+			LineString: &kmlLineString{
+				AltitudeMode: kmlAltitudeModeGround,
+				Coordinates:  strings.Join(route, " "),
+			},
+		})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+func kmlCoordinate(point Point, altitude int) string {
+	return fmt.Sprintf("%.8f,%.8f,%d", point.Longitude, point.Latitude, altitude)
+}
@@ -0,0 +1,109 @@
+package geo
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func exportedEstate() Estate {
+	return Estate{
+		ID:     "estate_id",
+		Width:  2,
+		Length: 1,
+		Boundary: []Point{
+			{Latitude: 0, Longitude: 0},
+			{Latitude: 0, Longitude: 2},
+			{Latitude: 1, Longitude: 2},
+			{Latitude: 1, Longitude: 0},
+			{Latitude: 0, Longitude: 0},
+		},
+		Trees: []Tree{
+			{ID: "tree_id", X: 2, Y: 1, Height: 5, Position: Point{Latitude: 0.5, Longitude: 1.5}},
+		},
+		PatrolDistance: 32,
+		PatrolRoute: []PathPoint{
+			{Position: Point{Latitude: 0.5, Longitude: 0.5}, Altitude: 0},
+			{Position: Point{Latitude: 0.5, Longitude: 1.5}, Altitude: 6},
+			{Position: Point{Latitude: 0.5, Longitude: 1.5}, Altitude: 0},
+		},
+	}
+}
+
+func TestEncodeGeoJSON(t *testing.T) {
+	data, err := EncodeGeoJSON(exportedEstate())
+	require.NoError(t, err)
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	require.NoError(t, json.Unmarshal(data, &collection))
+
+	require.Equal(t, "FeatureCollection", collection.Type)
+	require.Len(t, collection.Features, 3)
+
+	boundary := collection.Features[0]
+	require.Equal(t, "Polygon", boundary.Geometry.Type)
+	require.JSONEq(t, `[[[0,0],[2,0],[2,1],[0,1],[0,0]]]`, string(boundary.Geometry.Coordinates))
+	require.Equal(t, "boundary", boundary.Properties["kind"])
+
+	tree := collection.Features[1]
+	require.Equal(t, "Point", tree.Geometry.Type)
+	require.JSONEq(t, `[1.5,0.5]`, string(tree.Geometry.Coordinates))
+	require.Equal(t, float64(5), tree.Properties["height"])
+
+	route := collection.Features[2]
+	require.Equal(t, "LineString", route.Geometry.Type)
+	require.JSONEq(t, `[[0.5,0.5,0],[1.5,0.5,6],[1.5,0.5,0]]`, string(route.Geometry.Coordinates))
+	require.Equal(t, float64(32), route.Properties["distance"])
+}
+
+func TestEncodeGeoJSONWithoutRoute(t *testing.T) {
+	estate := exportedEstate()
+	estate.Trees = nil
+	estate.PatrolRoute = nil
+
+	data, err := EncodeGeoJSON(estate)
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(data), `"Feature"`))
+}
+
+func TestEncodeKML(t *testing.T) {
+	data, err := EncodeKML(exportedEstate())
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(data), xml.Header))
+
+	var doc kmlDocument
+	require.NoError(t, xml.Unmarshal(data, &doc))
+
+	require.Equal(t, "estate estate_id", doc.Name)
+	require.Len(t, doc.Placemarks, 2)
+
+	boundary := doc.Placemarks[0]
+	require.Equal(t, "boundary", boundary.Name)
+	require.Equal(t,
+		"0.00000000,0.00000000,0 2.00000000,0.00000000,0 2.00000000,1.00000000,0 0.00000000,1.00000000,0 0.00000000,0.00000000,0",
+		boundary.Polygon.Coordinates,
+	)
+
+	route := doc.Placemarks[1]
+	require.Equal(t, "patrol_route", route.Name)
+	require.Equal(t, "0.50000000,0.50000000,0 1.50000000,0.50000000,6 1.50000000,0.50000000,0", route.LineString.Coordinates)
+
+	require.Len(t, doc.Folders, 1)
+	require.Len(t, doc.Folders[0].Placemarks, 1)
+	tree := doc.Folders[0].Placemarks[0]
+	require.Equal(t, "tree (2,1)", tree.Name)
+	require.Equal(t, "1.50000000,0.50000000,5", tree.Point.Coordinates)
+	require.Equal(t, []kmlData{{Name: "tree_id", Value: "tree_id"}, {Name: "height", Value: "5"}}, tree.ExtendedData)
+}
@@ -0,0 +1,54 @@
+// Package geo place the estate plots on the earth and export the estate
+// (boundary, trees and patrol route) as GeoJSON or KML.
+package geo
+
+import "math"
+
+// WGS84 equatorial radius in meters, plenty accurate for an estate wide offsets
+const earthRadius = 6378137.0
+
+// Point is WGS84 coordinate in degrees
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Grid lay the estate plots on the earth. Origin is the center of plot (1,1),
+// y axis (the rows) goes toward Bearing degrees clockwise from the north and
+// x axis (the columns) goes 90 degrees clockwise from the y axis, bearing 0
+// means x goes to the east and y goes to the north.
+type Grid struct {
+	Origin   Point
+	Bearing  float64
+	PlotSize float64
+}
+
+// Position return the coordinate of position (x,y) in plot unit, plot centers
+// are on whole numbers and plot edges are on halves.
+func (g Grid) Position(x, y float64) Point {
+	alongX := (x - 1) * g.PlotSize
+	alongY := (y - 1) * g.PlotSize
+
+	bearing := g.Bearing * math.Pi / 180
+	east := alongX*math.Cos(bearing) + alongY*math.Sin(bearing)
+	north := -alongX*math.Sin(bearing) + alongY*math.Cos(bearing)
+
+	return Point{
+		Latitude:  g.Origin.Latitude + (north/earthRadius)*180/math.Pi,
+		Longitude: g.Origin.Longitude + (east/(earthRadius*math.Cos(g.Origin.Latitude*math.Pi/180)))*180/math.Pi,
+	}
+}
+
+// Boundary return the closed ring around the width x length plots,
+// counterclockwise when bearing is 0.
+func (g Grid) Boundary(width, length int) []Point {
+	right, top := float64(width)+0.5, float64(length)+0.5
+
+	return []Point{
+		g.Position(0.5, 0.5),
+		g.Position(right, 0.5),
+		g.Position(right, top),
+		g.Position(0.5, top),
+		g.Position(0.5, 0.5),
+	}
+}
@@ -0,0 +1,83 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 10 meters in degree on the equator
+const plotDegree = 8.983152841195214e-05
+
+func TestGridPosition(t *testing.T) {
+	tests := []struct {
+		name          string
+		grid          Grid
+		x, y          float64
+		expectedPoint Point
+	}{
+		{
+			name:          "Origin plot",
+			grid:          Grid{Origin: Point{Latitude: -6.2, Longitude: 106.8}, PlotSize: 10},
+			x:             1,
+			y:             1,
+			expectedPoint: Point{Latitude: -6.2, Longitude: 106.8},
+		},
+		{
+			name:          "Bearing north, x goes east and y goes north",
+			grid:          Grid{PlotSize: 10},
+			x:             2,
+			y:             3,
+			expectedPoint: Point{Latitude: 2 * plotDegree, Longitude: plotDegree},
+		},
+		{
+			name:          "Bearing east, x goes south and y goes east",
+			grid:          Grid{Bearing: 90, PlotSize: 10},
+			x:             2,
+			y:             3,
+			expectedPoint: Point{Latitude: -plotDegree, Longitude: 2 * plotDegree},
+		},
+		{
+			name:          "Bearing south, x goes west and y goes south",
+			grid:          Grid{Bearing: 180, PlotSize: 10},
+			x:             2,
+			y:             3,
+			expectedPoint: Point{Latitude: -2 * plotDegree, Longitude: -plotDegree},
+		},
+		{
+			name:          "Plot edge",
+			grid:          Grid{PlotSize: 10},
+			x:             0.5,
+			y:             0.5,
+			expectedPoint: Point{Latitude: -plotDegree / 2, Longitude: -plotDegree / 2},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			point := tc.grid.Position(tc.x, tc.y)
+
+			require.InDelta(t, tc.expectedPoint.Latitude, point.Latitude, 1e-12)
+			require.InDelta(t, tc.expectedPoint.Longitude, point.Longitude, 1e-12)
+		})
+	}
+}
+
+func TestGridBoundary(t *testing.T) {
+	grid := Grid{PlotSize: 10}
+
+	boundary := grid.Boundary(2, 1)
+	expected := []Point{
+		{Latitude: -plotDegree / 2, Longitude: -plotDegree / 2},
+		{Latitude: -plotDegree / 2, Longitude: 1.5 * plotDegree},
+		{Latitude: plotDegree / 2, Longitude: 1.5 * plotDegree},
+		{Latitude: plotDegree / 2, Longitude: -plotDegree / 2},
+		{Latitude: -plotDegree / 2, Longitude: -plotDegree / 2},
+	}
+
+	require.Len(t, boundary, len(expected))
+	for idx, point := range boundary {
+		require.InDelta(t, expected[idx].Latitude, point.Latitude, 1e-12)
+		require.InDelta(t, expected[idx].Longitude, point.Longitude, 1e-12)
+	}
+}
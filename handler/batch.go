@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+// the batch limit is the one of api.yml, whatever the format of the body
+var (
+	maxTreesPerBatch = specBodyMaxItems("/estate/{id}/trees:batch", http.MethodPost)
+	// room for a kilobyte per tree, way more than a tree takes in any of the formats
+	maxBatchBytes = maxTreesPerBatch << 10
+)
+
+const (
+	mimeApplicationNDJSON = "application/x-ndjson"
+	mimeTextCSV           = "text/csv"
+
+	msgRowInvalidFormat = "invalid value or format"
+	msgRowBadHeight     = "bad height"
+	msgRowOutOfBounds   = "out of bounds"
+	msgRowDuplicatePlot = "duplicate plot"
+)
+
+var (
+	errUnsupportedContentType = errors.New("unsupported content type")
+	errInvalidTreeRows        = errors.New("invalid tree rows")
+	// errTooManyTrees is returned as soon as the body has more than maxTreesPerBatch rows,
+	// the rest of the body is not read
+	errTooManyTrees = fmt.Errorf("too many trees in payload, max %d", maxTreesPerBatch)
+)
+
+// treeRow is a single tree of the batch, row is 1 index base as surveyors count it
+type treeRow struct {
+	row  int
+	tree generated.CreateTreeRequestBody
+}
+
+// parseTreeRows read trees from the batch body according to its content type,
+// rows that can not be read are reported as row errors instead of failing the whole batch.
+func parseTreeRows(contentType string, body io.Reader) ([]treeRow, []generated.TreeRowError, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, nil, errUnsupportedContentType
+	}
+
+	switch mediaType {
+	case "application/json":
+		return parseJSONTreeRows(body)
+	case mimeApplicationNDJSON:
+		return parseNDJSONTreeRows(body)
+	case mimeTextCSV:
+		return parseCSVTreeRows(body)
+	}
+
+	return nil, nil, errUnsupportedContentType
+}
+
+// parseJSONTreeRows read the array one tree at a time, so a body with too many trees
+// is rejected without reading all of it
+func parseJSONTreeRows(body io.Reader) ([]treeRow, []generated.TreeRowError, error) {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != json.Delim('[') {
+		return nil, nil, errors.New("payload is not a json array")
+	}
+
+	rows := make([]treeRow, 0)
+	rowErrs := make([]generated.TreeRowError, 0)
+	for idx := 0; decoder.More(); idx++ {
+		if idx >= maxTreesPerBatch {
+			return nil, nil, errTooManyTrees
+		}
+
+		var rawTree json.RawMessage
+		if err := decoder.Decode(&rawTree); err != nil {
+			return nil, nil, err
+		}
+
+		var tree generated.CreateTreeRequestBody
+		if err := json.Unmarshal(rawTree, &tree); err != nil {
+			rowErrs = append(rowErrs, invalidFormatRowError(idx+1))
+			continue
+		}
+		rows = append(rows, treeRow{row: idx + 1, tree: tree})
+	}
+
+	// the closing bracket
+	_, err = decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rows, rowErrs, nil
+}
+
+func parseNDJSONTreeRows(body io.Reader) ([]treeRow, []generated.TreeRowError, error) {
+	rows := make([]treeRow, 0)
+	rowErrs := make([]generated.TreeRowError, 0)
+
+	scanner := bufio.NewScanner(body)
+	for row := 1; scanner.Scan(); row++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(rows)+len(rowErrs) >= maxTreesPerBatch {
+			return nil, nil, errTooManyTrees
+		}
+
+		var tree generated.CreateTreeRequestBody
+		if err := json.Unmarshal(line, &tree); err != nil {
+			rowErrs = append(rowErrs, invalidFormatRowError(row))
+			continue
+		}
+		rows = append(rows, treeRow{row: row, tree: tree})
+	}
+
+	return rows, rowErrs, scanner.Err()
+}
+
+func parseCSVTreeRows(body io.Reader) ([]treeRow, []generated.TreeRowError, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// columns can be in any order as long as all of them are there
+	columns := map[string]int{"x": -1, "y": -1, "height": -1}
+	for idx, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; ok {
+			columns[name] = idx
+		}
+	}
+	for name, idx := range columns {
+		if idx < 0 {
+			return nil, nil, fmt.Errorf("missing column %s in header", name)
+		}
+	}
+
+	rows := make([]treeRow, 0)
+	rowErrs := make([]generated.TreeRowError, 0)
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rows)+len(rowErrs) >= maxTreesPerBatch {
+			return nil, nil, errTooManyTrees
+		}
+
+		values := make(map[string]int, len(columns))
+		for name, idx := range columns {
+			if idx >= len(record) {
+				break
+			}
+
+			value, err := strconv.Atoi(strings.TrimSpace(record[idx]))
+			if err != nil {
+				break
+			}
+			values[name] = value
+		}
+
+		if len(values) != len(columns) {
+			rowErrs = append(rowErrs, invalidFormatRowError(row))
+			continue
+		}
+
+		rows = append(rows, treeRow{
+			row: row,
+			tree: generated.CreateTreeRequestBody{
+				X:      values["x"],
+				Y:      values["y"],
+				Height: values["height"],
+			},
+		})
+	}
+
+	return rows, rowErrs, nil
+}
+
+// validateTreeRows check every row the same way a single tree is validated,
+// plots must be inside the estate and not planted yet nor twice in the batch.
+func validateTreeRows(estate repository.Estate, plantedTrees []repository.Tree, rows []treeRow) []generated.TreeRowError {
+	rowErrs := make([]generated.TreeRowError, 0)
+
+	// plot (x,y) to the row planting it, 0 for trees that were already there
+	plots := make(map[[2]int]int, len(plantedTrees)+len(rows))
+	for _, tree := range plantedTrees {
+		plots[[2]int{tree.X, tree.Y}] = 0
+	}
+	shape := newEstateShape(estate)
+
+	for _, row := range rows {
+		rowErr := generated.TreeRowError{Row: row.row}
+		plot := [2]int{row.tree.X, row.tree.Y}
+		plantedRow, planted := plots[plot]
+
+		switch {
+		case row.tree.Height < treeHeightMin || row.tree.Height > treeHeightMax:
+			rowErr.Field, rowErr.Code = rowField("height"), generated.ProblemCodeOutOfRange
+			rowErr.Message = fmt.Sprintf("%s: height must be between %d and %d", msgRowBadHeight, treeHeightMin, treeHeightMax)
+		case row.tree.X < 1 || row.tree.X > estate.Width:
+			rowErr.Field, rowErr.Code = rowField("x"), generated.ProblemCodeTreeOutOfBounds
+			rowErr.Message = fmt.Sprintf("%s: x must be between 1 and %d", msgRowOutOfBounds, estate.Width)
+		case row.tree.Y < 1 || row.tree.Y > estate.Length:
+			rowErr.Field, rowErr.Code = rowField("y"), generated.ProblemCodeTreeOutOfBounds
+			rowErr.Message = fmt.Sprintf("%s: y must be between 1 and %d", msgRowOutOfBounds, estate.Length)
+		case !shape.contains(row.tree.X, row.tree.Y):
+			rowErr.Field, rowErr.Code = rowField("x"), generated.ProblemCodeTreeOutOfBounds
+			rowErr.Message = fmt.Sprintf("%s: plot (%d,%d) is outside of the estate boundary", msgRowOutOfBounds, row.tree.X, row.tree.Y)
+		case planted && plantedRow == 0:
+			rowErr.Code = generated.ProblemCodePlotOccupied
+			rowErr.Message = fmt.Sprintf("%s: plot (%d,%d) already has a tree", msgRowDuplicatePlot, row.tree.X, row.tree.Y)
+		case planted:
+			rowErr.Code = generated.ProblemCodePlotOccupied
+			rowErr.Message = fmt.Sprintf("%s: plot (%d,%d) is also on row %d", msgRowDuplicatePlot, row.tree.X, row.tree.Y, plantedRow)
+		default:
+			plots[plot] = row.row
+			continue
+		}
+
+		rowErrs = append(rowErrs, rowErr)
+	}
+
+	return rowErrs
+}
+
+// invalidFormatRowError tells the row can not be read as a tree at all
+func invalidFormatRowError(row int) generated.TreeRowError {
+	return generated.TreeRowError{Row: row, Code: generated.ProblemCodeInvalidBody, Message: msgRowInvalidFormat}
+}
+
+// respondInvalidRows respond with 400 for the rows at fault out of lenRows. The problem has
+// the code of the rows when all of them share it, e.g. PLOT_OCCUPIED for duplicate plots.
+func respondInvalidRows(ectx echo.Context, lenRows int, rowErrs []generated.TreeRowError) error {
+	code := rowErrs[0].Code
+	for _, rowErr := range rowErrs {
+		if rowErr.Code != code {
+			code = generated.ProblemCodeValidationFailed
+		}
+	}
+
+	return respondBatchProblem(ectx, http.StatusBadRequest, code, fmt.Sprintf("%d of %d rows are invalid", len(rowErrs), lenRows), rowErrs)
+}
+
+// respondBatchProblem respond with status for the batch, rowErrs is empty when the batch
+// as a whole is at fault
+func respondBatchProblem(ectx echo.Context, status int, code generated.ProblemCode, detail string, rowErrs []generated.TreeRowError) error {
+	problem := newProblem(status, code, detail)
+	return respondProblem(ectx, status, generated.CreateTreesBatchErrorResponse{
+		Type:    problem.Type,
+		Title:   problem.Title,
+		Status:  problem.Status,
+		Detail:  problem.Detail,
+		Code:    problem.Code,
+		Message: problem.Message,
+		Errors:  rowErrs,
+	})
+}
+
+// rowField is the field of the row at fault
+func rowField(name string) *string {
+	return &name
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTreeRows(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     string
+		body            string
+		bodyReader      io.Reader
+		expectedRows    []treeRow
+		expectedRowErrs []generated.TreeRowError
+		expectedErr     bool
+		expectedErrIs   error
+	}{
+		{
+			name:        "JSON array",
+			contentType: "application/json; charset=UTF-8",
+			body:        `[{"x": 1, "y": 2, "height": 10}, {"x": "a", "y": 2, "height": 10}, {"x": 3, "y": 2, "height": 12}]`,
+			expectedRows: []treeRow{
+				{row: 1, tree: generated.CreateTreeRequestBody{X: 1, Y: 2, Height: 10}},
+				{row: 3, tree: generated.CreateTreeRequestBody{X: 3, Y: 2, Height: 12}},
+			},
+			expectedRowErrs: []generated.TreeRowError{
+				{Row: 2, Code: generated.ProblemCodeInvalidBody, Message: msgRowInvalidFormat},
+			},
+		},
+		{
+			name:        "JSON not an array",
+			contentType: "application/json",
+			body:        `{"x": 1, "y": 2, "height": 10}`,
+			expectedErr: true,
+		},
+		{
+			name:        "NDJSON",
+			contentType: "application/x-ndjson",
+			body:        "{\"x\": 1, \"y\": 2, \"height\": 10}\n\n{\"x\": 2\n{\"x\": 3, \"y\": 2, \"height\": 12}\n",
+			expectedRows: []treeRow{
+				{row: 1, tree: generated.CreateTreeRequestBody{X: 1, Y: 2, Height: 10}},
+				{row: 4, tree: generated.CreateTreeRequestBody{X: 3, Y: 2, Height: 12}},
+			},
+			expectedRowErrs: []generated.TreeRowError{
+				{Row: 3, Code: generated.ProblemCodeInvalidBody, Message: msgRowInvalidFormat},
+			},
+		},
+		{
+			name:        "CSV with columns in any order",
+			contentType: "text/csv",
+			body:        "height, X, y\n10,1,2\nten,2,2\n12,3\n12,3,2\n",
+			expectedRows: []treeRow{
+				{row: 1, tree: generated.CreateTreeRequestBody{X: 1, Y: 2, Height: 10}},
+				{row: 4, tree: generated.CreateTreeRequestBody{X: 3, Y: 2, Height: 12}},
+			},
+			expectedRowErrs: []generated.TreeRowError{
+				{Row: 2, Code: generated.ProblemCodeInvalidBody, Message: msgRowInvalidFormat},
+				{Row: 3, Code: generated.ProblemCodeInvalidBody, Message: msgRowInvalidFormat},
+			},
+		},
+		{
+			// the header is not a row, the fourth line of the body is row 3
+			name:        "CSV rows numbered after the header",
+			contentType: "text/csv",
+			body:        "x,y,height\n1,1,10\n2,1,12\n3,one,14\n",
+			expectedRows: []treeRow{
+				{row: 1, tree: generated.CreateTreeRequestBody{X: 1, Y: 1, Height: 10}},
+				{row: 2, tree: generated.CreateTreeRequestBody{X: 2, Y: 1, Height: 12}},
+			},
+			expectedRowErrs: []generated.TreeRowError{
+				{Row: 3, Code: generated.ProblemCodeInvalidBody, Message: msgRowInvalidFormat},
+			},
+		},
+		{
+			name:        "CSV without height column",
+			contentType: "text/csv",
+			body:        "x,y\n1,2\n",
+			expectedErr: true,
+		},
+		{
+			name:        "Unsupported content type",
+			contentType: "application/xml",
+			body:        "<trees></trees>",
+			expectedErr: true,
+		},
+		// the bodies never end, the parser must stop on its own
+		{
+			name:          "JSON too many trees",
+			contentType:   "application/json",
+			bodyReader:    &endlessReader{head: "[", row: `{"x": 1, "y": 2, "height": 10},`},
+			expectedErr:   true,
+			expectedErrIs: errTooManyTrees,
+		},
+		{
+			name:          "NDJSON too many trees",
+			contentType:   "application/x-ndjson",
+			bodyReader:    &endlessReader{row: "{\"x\": 1, \"y\": 2, \"height\": 10}\n"},
+			expectedErr:   true,
+			expectedErrIs: errTooManyTrees,
+		},
+		{
+			name:          "CSV too many trees",
+			contentType:   "text/csv",
+			bodyReader:    &endlessReader{head: "x,y,height\n", row: "1,2,10\n"},
+			expectedErr:   true,
+			expectedErrIs: errTooManyTrees,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := tc.bodyReader
+			if body == nil {
+				body = strings.NewReader(tc.body)
+			}
+			rows, rowErrs, err := parseTreeRows(tc.contentType, body)
+
+			if tc.expectedErr {
+				require.Error(t, err)
+				if tc.expectedErrIs != nil {
+					require.ErrorIs(t, err, tc.expectedErrIs)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedRows, rows)
+			require.Equal(t, tc.expectedRowErrs, rowErrs)
+		})
+	}
+}
+
+// endlessReader read head then row over and over, it never ends
+type endlessReader struct {
+	head, row string
+	read      int
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		var text string
+		if r.read < len(r.head) {
+			text = r.head[r.read:]
+		} else {
+			text = r.row[(r.read-len(r.head))%len(r.row):]
+		}
+
+		copied := copy(p[n:], text)
+		n += copied
+		r.read += copied
+	}
+
+	return n, nil
+}
+
+func TestValidateTreeRows(t *testing.T) {
+	estate := repository.Estate{
+		Width:  5,
+		Length: 3,
+	}
+	plantedTrees := []repository.Tree{
+		{X: 1, Y: 1, Height: 10},
+	}
+
+	rows := []treeRow{
+		{row: 1, tree: generated.CreateTreeRequestBody{X: 2, Y: 1, Height: 10}},
+		{row: 2, tree: generated.CreateTreeRequestBody{X: 1, Y: 1, Height: 10}},
+		{row: 3, tree: generated.CreateTreeRequestBody{X: 2, Y: 1, Height: 12}},
+		{row: 4, tree: generated.CreateTreeRequestBody{X: 6, Y: 1, Height: 12}},
+		{row: 5, tree: generated.CreateTreeRequestBody{X: 3, Y: 4, Height: 12}},
+		{row: 6, tree: generated.CreateTreeRequestBody{X: 3, Y: 3, Height: 31}},
+		{row: 7, tree: generated.CreateTreeRequestBody{X: 3, Y: 3, Height: 30}},
+	}
+
+	rowErrs := validateTreeRows(estate, plantedTrees, rows)
+	require.Equal(t, []generated.TreeRowError{
+		{Row: 2, Code: generated.ProblemCodePlotOccupied, Message: "duplicate plot: plot (1,1) already has a tree"},
+		{Row: 3, Code: generated.ProblemCodePlotOccupied, Message: "duplicate plot: plot (2,1) is also on row 1"},
+		{Row: 4, Field: rowField("x"), Code: generated.ProblemCodeTreeOutOfBounds, Message: "out of bounds: x must be between 1 and 5"},
+		{Row: 5, Field: rowField("y"), Code: generated.ProblemCodeTreeOutOfBounds, Message: "out of bounds: y must be between 1 and 3"},
+		{Row: 6, Field: rowField("height"), Code: generated.ProblemCodeOutOfRange, Message: "bad height: height must be between 1 and 30"},
+	}, rowErrs)
+
+	// the triangle leaves the corners of row 3 out
+	estate.Boundary = []repository.Plot{{X: 1, Y: 1}, {X: 5, Y: 1}, {X: 3, Y: 3}}
+	rowErrs = validateTreeRows(estate, plantedTrees, []treeRow{
+		{row: 1, tree: generated.CreateTreeRequestBody{X: 3, Y: 3, Height: 10}},
+		{row: 2, tree: generated.CreateTreeRequestBody{X: 4, Y: 3, Height: 10}},
+	})
+	require.Equal(t, []generated.TreeRowError{
+		{Row: 2, Field: rowField("x"), Code: generated.ProblemCodeTreeOutOfBounds, Message: "out of bounds: plot (4,3) is outside of the estate boundary"},
+	}, rowErrs)
+}
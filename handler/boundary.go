@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+// estateShape tells the plots of the estate row by row, see repository.Estate.Boundary
+type estateShape struct {
+	width, length int
+	// rows are the runs of the estate on every row from row 1, nil when the estate is the whole rectangle
+	rows [][]plotRun
+}
+
+func newEstateShape(estate repository.Estate) estateShape {
+	shape := estateShape{width: estate.Width, length: estate.Length}
+	if len(estate.Boundary) > 0 {
+		shape.rows = polygonRowRuns(estate.Boundary, estate.Length)
+	}
+
+	return shape
+}
+
+// contains tells plot (x,y) is in the estate
+func (shape estateShape) contains(x, y int) bool {
+	if x < 1 || x > shape.width || y < 1 || y > shape.length {
+		return false
+	}
+	if shape.rows == nil {
+		return true
+	}
+
+	for _, run := range shape.rows[y-1] {
+		if x >= run.from && x <= run.to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// outside return the runs of row y out of the estate, sorted by their first column
+func (shape estateShape) outside(y int) []plotRun {
+	if shape.rows == nil || y < 1 || y > shape.length {
+		return nil
+	}
+
+	var runs []plotRun
+	from := 1
+	for _, run := range shape.rows[y-1] {
+		if run.from > from {
+			runs = append(runs, plotRun{from: from, to: run.from - 1})
+		}
+		from = max(from, run.to+1)
+	}
+	if from <= shape.width {
+		runs = append(runs, plotRun{from: from, to: shape.width})
+	}
+
+	return runs
+}
+
+// insideBoundary tells plot (x,y) of the estate is inside its boundary, it only goes through the
+// boundary once, newEstateShape is faster to check many plots
+func insideBoundary(estate repository.Estate, x, y int) bool {
+	if len(estate.Boundary) == 0 {
+		return true
+	}
+
+	for _, run := range polygonRuns(estate.Boundary, y) {
+		if x >= run.from && x <= run.to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// polygonRowRuns return the runs of polygonRuns merged on every row from row 1 to row length.
+// Only the edges crossing the row are gone through instead of every edge of the polygon,
+// the polygon around row ranges has two vertices per row.
+func polygonRowRuns(polygon []repository.Plot, length int) [][]plotRun {
+	type edge struct{ a, b repository.Plot }
+	edges := make([]edge, len(polygon))
+	for i, a := range polygon {
+		edges[i] = edge{a: a, b: polygon[(i+1)%len(polygon)]}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		return min(edges[i].a.Y, edges[i].b.Y) < min(edges[j].a.Y, edges[j].b.Y)
+	})
+
+	rows := make([][]plotRun, length)
+	active := make([]edge, 0)
+	next := 0
+	for y := 1; y <= length; y++ {
+		for next < len(edges) && min(edges[next].a.Y, edges[next].b.Y) <= y {
+			active = append(active, edges[next])
+			next++
+		}
+
+		var row rowCrossings
+		crossing := active[:0]
+		for _, e := range active {
+			if max(e.a.Y, e.b.Y) < y {
+				continue
+			}
+			crossing = append(crossing, e)
+			row.edge(e.a, e.b, y)
+		}
+		active = crossing
+
+		rows[y-1] = mergeRuns(row.runs())
+	}
+
+	return rows
+}
+
+// estateBoundary return the boundary polygon of the estate of width x length the payload tells,
+// nil when the estate is the whole rectangle. Row ranges are stored as the polygon going up the
+// last column of every row and back down the first one, the plots which center is inside it are
+// exactly the plots of the ranges. Every field at fault is told for the client to highlight all of them.
+func estateBoundary(width, length int, payload generated.EstateBoundary) ([]repository.Plot, []generated.FieldError) {
+	fieldErrs := make([]generated.FieldError, 0)
+	outOfBounds := fmt.Sprintf("the boundary must be inside the estate, columns 1 to %d and rows 1 to %d", width, length)
+
+	switch {
+	case payload.Polygon != nil && payload.Rows != nil:
+		fieldErrs = append(fieldErrs, fieldError("boundary", generated.ProblemCodeValidationFailed, "either polygon or rows tells the plots of the estate, not both"))
+	case payload.Polygon != nil:
+		polygon := make([]repository.Plot, 0, len(*payload.Polygon))
+		for _, vertex := range *payload.Polygon {
+			polygon = append(polygon, repository.Plot{X: vertex.X, Y: vertex.Y})
+		}
+		if !boundaryInside(width, length, polygon) {
+			fieldErrs = append(fieldErrs, fieldError("boundary.polygon", generated.ProblemCodeOutOfRange, outOfBounds))
+		}
+
+		return polygon, fieldErrs
+	case payload.Rows != nil:
+		rows := make([]generated.EstateRowRange, len(*payload.Rows))
+		copy(rows, *payload.Rows)
+		sort.Slice(rows, func(i, j int) bool {
+			return rows[i].Y < rows[j].Y
+		})
+
+		for i, row := range rows {
+			switch {
+			case row.FromX > row.ToX:
+				fieldErrs = append(fieldErrs, fieldError("boundary.rows", generated.ProblemCodeValidationFailed, fmt.Sprintf("row %d from_x must not be after to_x", row.Y)))
+			case row.ToX > width || row.Y > length:
+				fieldErrs = append(fieldErrs, fieldError("boundary.rows", generated.ProblemCodeOutOfRange, outOfBounds))
+			case i > 0 && row.Y == rows[i-1].Y:
+				fieldErrs = append(fieldErrs, fieldError("boundary.rows", generated.ProblemCodeValidationFailed, fmt.Sprintf("rows must tell one column range per row, row %d is told twice", row.Y)))
+			case i > 0 && row.Y > rows[i-1].Y+1:
+				fieldErrs = append(fieldErrs, fieldError("boundary.rows", generated.ProblemCodeValidationFailed, fmt.Sprintf("rows must be next to each other, row %d is missing", rows[i-1].Y+1)))
+			default:
+				continue
+			}
+
+			return nil, fieldErrs
+		}
+
+		polygon := make([]repository.Plot, 0, 2*len(rows))
+		for _, row := range rows {
+			polygon = append(polygon, repository.Plot{X: row.ToX, Y: row.Y})
+		}
+		for i := len(rows) - 1; i >= 0; i-- {
+			polygon = append(polygon, repository.Plot{X: rows[i].FromX, Y: rows[i].Y})
+		}
+
+		return polygon, fieldErrs
+	}
+
+	return nil, fieldErrs
+}
+
+// boundaryInside tells every vertex of the boundary polygon is inside the estate of width x length
+func boundaryInside(width, length int, polygon []repository.Plot) bool {
+	for _, vertex := range polygon {
+		if vertex.X < 1 || vertex.X > width || vertex.Y < 1 || vertex.Y > length {
+			return false
+		}
+	}
+
+	return true
+}
+
+// boundaryResponse return the vertices of the boundary, nil when the estate is the whole rectangle
+func boundaryResponse(polygon []repository.Plot) *[]generated.PlotPosition {
+	if len(polygon) == 0 {
+		return nil
+	}
+
+	vertices := make([]generated.PlotPosition, 0, len(polygon))
+	for _, vertex := range polygon {
+		vertices = append(vertices, generated.PlotPosition{X: vertex.X, Y: vertex.Y})
+	}
+
+	return &vertices
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstateBoundary(t *testing.T) {
+	tests := []struct {
+		name             string
+		payload          generated.EstateBoundary
+		expectedBoundary []repository.Plot
+		expectedMessages []string
+	}{
+		{
+			name: "Polygon",
+			payload: generated.EstateBoundary{Polygon: &[]generated.ZoneVertex{
+				{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 5},
+			}},
+			expectedBoundary: []repository.Plot{{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 5}},
+		},
+		{
+			name: "Rows in any order",
+			payload: generated.EstateBoundary{Rows: &[]generated.EstateRowRange{
+				{Y: 3, FromX: 4, ToX: 6},
+				{Y: 2, FromX: 1, ToX: 10},
+				{Y: 4, FromX: 5, ToX: 5},
+			}},
+			expectedBoundary: []repository.Plot{{X: 10, Y: 2}, {X: 6, Y: 3}, {X: 5, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 3}, {X: 1, Y: 2}},
+		},
+		{
+			name: "Whole rectangle",
+		},
+		{
+			name: "Both polygon and rows",
+			payload: generated.EstateBoundary{
+				Polygon: &[]generated.ZoneVertex{{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 5}},
+				Rows:    &[]generated.EstateRowRange{{Y: 1, FromX: 1, ToX: 10}},
+			},
+			expectedMessages: []string{"either polygon or rows tells the plots of the estate, not both"},
+		},
+		{
+			name: "Polygon outside of the estate",
+			payload: generated.EstateBoundary{Polygon: &[]generated.ZoneVertex{
+				{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 6},
+			}},
+			expectedBoundary: []repository.Plot{{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 6}},
+			expectedMessages: []string{"the boundary must be inside the estate, columns 1 to 10 and rows 1 to 5"},
+		},
+		{
+			name:             "Reversed row",
+			payload:          generated.EstateBoundary{Rows: &[]generated.EstateRowRange{{Y: 1, FromX: 6, ToX: 4}}},
+			expectedMessages: []string{"row 1 from_x must not be after to_x"},
+		},
+		{
+			name:             "Row outside of the estate",
+			payload:          generated.EstateBoundary{Rows: &[]generated.EstateRowRange{{Y: 6, FromX: 1, ToX: 4}}},
+			expectedMessages: []string{"the boundary must be inside the estate, columns 1 to 10 and rows 1 to 5"},
+		},
+		{
+			name: "Row told twice",
+			payload: generated.EstateBoundary{Rows: &[]generated.EstateRowRange{
+				{Y: 1, FromX: 1, ToX: 4},
+				{Y: 1, FromX: 6, ToX: 8},
+			}},
+			expectedMessages: []string{"rows must tell one column range per row, row 1 is told twice"},
+		},
+		{
+			name: "Row missing",
+			payload: generated.EstateBoundary{Rows: &[]generated.EstateRowRange{
+				{Y: 1, FromX: 1, ToX: 4},
+				{Y: 3, FromX: 1, ToX: 4},
+			}},
+			expectedMessages: []string{"rows must be next to each other, row 2 is missing"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			boundary, fieldErrs := estateBoundary(10, 5, tc.payload)
+
+			messages := make([]string, 0, len(fieldErrs))
+			for _, fieldErr := range fieldErrs {
+				messages = append(messages, fieldErr.Message)
+			}
+			if tc.expectedMessages == nil {
+				tc.expectedMessages = []string{}
+			}
+			require.Equal(t, tc.expectedMessages, messages)
+			if len(fieldErrs) == 0 || tc.expectedBoundary != nil {
+				require.Equal(t, tc.expectedBoundary, boundary)
+			}
+		})
+	}
+}
+
+func TestEstateShape(t *testing.T) {
+	// the polygon around the row ranges has exactly the plots of the ranges,
+	// whichever way the rows or the columns are gone through
+	ranges := []generated.EstateRowRange{
+		{Y: 2, FromX: 3, ToX: 9},
+		{Y: 3, FromX: 7, ToX: 10},
+		{Y: 4, FromX: 1, ToX: 2},
+		{Y: 5, FromX: 1, ToX: 10},
+		{Y: 6, FromX: 5, ToX: 5},
+	}
+	boundary, fieldErrs := estateBoundary(10, 7, generated.EstateBoundary{Rows: &ranges})
+	require.Empty(t, fieldErrs)
+
+	estate := repository.Estate{Width: 10, Length: 7, Boundary: boundary}
+	for _, s := range allSweeps() {
+		patrolEstate := s.patrolEstate(estate)
+		shape := newEstateShape(patrolEstate)
+
+		for y := 1; y <= estate.Length; y++ {
+			for x := 1; x <= estate.Width; x++ {
+				expected := false
+				for _, r := range ranges {
+					expected = expected || (r.Y == y && x >= r.FromX && x <= r.ToX)
+				}
+
+				patrolX, patrolY := s.toPatrol(estate, x, y)
+				require.Equal(t, expected, shape.contains(patrolX, patrolY), "sweep %v plot (%d,%d)", s, x, y)
+				require.Equal(t, expected, insideBoundary(patrolEstate, patrolX, patrolY), "sweep %v plot (%d,%d)", s, x, y)
+			}
+		}
+	}
+
+	shape := newEstateShape(estate)
+	require.Equal(t, []plotRun{{from: 1, to: 10}}, shape.outside(1))
+	require.Equal(t, []plotRun{{from: 1, to: 2}, {from: 10, to: 10}}, shape.outside(2))
+	require.Equal(t, []plotRun{{from: 3, to: 10}}, shape.outside(4))
+	require.Nil(t, shape.outside(5))
+	require.Nil(t, newEstateShape(repository.Estate{Width: 10, Length: 7}).outside(1))
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/geo"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+// dronePlanQuery is the drone plan asked for by the query params of GetEstateIdDronePlan
+type dronePlanQuery struct {
+	// modes and sweeps to patrol the estate with, the shortest plan is picked among them
+	modes  []flightMode
+	sweeps []sweep
+	// grid lay the estate on the earth, only with format
+	grid geo.Grid
+	// homeX and homeY is where the sorties take off and land, only with battery
+	homeX, homeY int
+}
+
+// dronePlanParams tells the drone plan asked for by params and the params at fault, every
+// param is in the limits of api.yml, only their values on the estate and their combination
+// are left to check
+func dronePlanParams(estate repository.Estate, params generated.GetEstateIdDronePlanParams) (dronePlanQuery, []generated.FieldError) {
+	query := dronePlanQuery{
+		modes: []flightMode{flightModeCanopyFollow},
+		homeX: 1,
+		homeY: 1,
+	}
+
+	var err error
+	fieldErrs := make([]generated.FieldError, 0)
+	if params.MaxDistance != nil && *params.MaxDistance < maxDistanceMin {
+		fieldErrs = append(fieldErrs, fieldError("max_distance", generated.ProblemCodeInvalidParameter, fmt.Sprintf("max_distance must be at least %d", maxDistanceMin)))
+	}
+	if params.Mode != nil {
+		query.modes, err = parseFlightModes(*params.Mode)
+		if err != nil {
+			fieldErrs = append(fieldErrs, fieldError("mode", generated.ProblemCodeInvalidParameter,
+				fmt.Sprintf("mode must be canopy-follow, fixed-altitude, row-max, lookahead-N (N between 1 and %d) or compare", flightModeLookaheadMax)))
+		}
+	}
+	var orientation, corner string
+	if params.Sweep != nil {
+		orientation = string(*params.Sweep)
+	}
+	if params.StartCorner != nil {
+		corner = string(*params.StartCorner)
+	}
+	query.sweeps, err = parseSweeps(orientation, corner)
+	switch err {
+	case errUnknownSweep:
+		fieldErrs = append(fieldErrs, fieldError("sweep", generated.ProblemCodeInvalidParameter, "sweep must be rows, columns or auto"))
+	case errUnknownStartCorner:
+		fieldErrs = append(fieldErrs, fieldError("start_corner", generated.ProblemCodeInvalidParameter, "start_corner must be sw, se, nw or ne"))
+	case errStartCornerAutoSweep:
+		fieldErrs = append(fieldErrs, fieldError("start_corner", generated.ProblemCodeInvalidParameter, "start_corner is not allowed with sweep auto"))
+	}
+	if params.Drones != nil {
+		switch {
+		case len(query.modes) > 1 || len(query.sweeps) > 1 || params.Format != nil:
+			fieldErrs = append(fieldErrs, fieldError("drones", generated.ProblemCodeInvalidParameter, "drones is not allowed with mode compare, sweep auto or format"))
+		case len(query.sweeps) == 1 && (*params.Drones < 1 || *params.Drones > query.sweeps[0].patrolEstate(estate).Length):
+			// the drones split the rows of the sweep
+			fieldErrs = append(fieldErrs, fieldError("drones", generated.ProblemCodeInvalidParameter,
+				fmt.Sprintf("drones must be between 1 and %d, the rows of the sweep", query.sweeps[0].patrolEstate(estate).Length)))
+		}
+	}
+	if params.Battery != nil {
+		if params.HomeX != nil {
+			query.homeX = *params.HomeX
+		}
+		if params.HomeY != nil {
+			query.homeY = *params.HomeY
+		}
+
+		switch {
+		case params.Drones != nil || params.Format != nil:
+			fieldErrs = append(fieldErrs, fieldError("battery", generated.ProblemCodeInvalidParameter, "battery is not allowed with drones or format"))
+		case *params.Battery < 1:
+			fieldErrs = append(fieldErrs, fieldError("battery", generated.ProblemCodeInvalidParameter, "battery must be at least 1"))
+		}
+		if query.homeX < 1 || query.homeX > estate.Width {
+			fieldErrs = append(fieldErrs, fieldError("home_x", generated.ProblemCodeInvalidParameter, fmt.Sprintf("home_x must be between 1 and %d", estate.Width)))
+		}
+		if query.homeY < 1 || query.homeY > estate.Length {
+			fieldErrs = append(fieldErrs, fieldError("home_y", generated.ProblemCodeInvalidParameter, fmt.Sprintf("home_y must be between 1 and %d", estate.Length)))
+		}
+	}
+	if len(fieldErrs) > 0 || params.Format == nil {
+		return query, fieldErrs
+	}
+
+	// the mission is only laid on the earth for a plan worth exporting
+	query.grid, fieldErrs = missionGrid(estate, params)
+
+	return query, fieldErrs
+}
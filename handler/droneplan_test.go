@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/geo"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDronePlanParams(t *testing.T) {
+	strParam := func(value string) *string {
+		return &value
+	}
+	intParam := func(value int) *int {
+		return &value
+	}
+	floatParam := func(value float64) *float64 {
+		return &value
+	}
+	sweepParam := func(value generated.GetEstateIdDronePlanParamsSweep) *generated.GetEstateIdDronePlanParamsSweep {
+		return &value
+	}
+	formatParam := func(value generated.GetEstateIdDronePlanParamsFormat) *generated.GetEstateIdDronePlanParamsFormat {
+		return &value
+	}
+	estate := repository.Estate{ID: "valid_estate_id", Width: 5, Length: 3}
+
+	tests := []struct {
+		name           string
+		params         generated.GetEstateIdDronePlanParams
+		expectedQuery  dronePlanQuery
+		expectedFields []string
+	}{
+		{
+			name:   "Defaults",
+			params: generated.GetEstateIdDronePlanParams{MaxDistance: intParam(0)},
+			expectedQuery: dronePlanQuery{
+				modes:  []flightMode{flightModeCanopyFollow},
+				sweeps: []sweep{sweepDefault},
+				homeX:  1,
+				homeY:  1,
+			},
+		},
+		{
+			name:   "Flight mode and sweep",
+			params: generated.GetEstateIdDronePlanParams{Mode: strParam("lookahead-2"), Sweep: sweepParam(generated.GetEstateIdDronePlanParamsSweepColumns)},
+			expectedQuery: dronePlanQuery{
+				modes:  []flightMode{{name: flightModeNameLookahead, lookahead: 2}},
+				sweeps: []sweep{{orientation: "columns", corner: "sw"}},
+				homeX:  1,
+				homeY:  1,
+			},
+		},
+		{
+			name:   "Battery from home",
+			params: generated.GetEstateIdDronePlanParams{Battery: intParam(100), HomeX: intParam(5), HomeY: intParam(3)},
+			expectedQuery: dronePlanQuery{
+				modes:  []flightMode{flightModeCanopyFollow},
+				sweeps: []sweep{sweepDefault},
+				homeX:  5,
+				homeY:  3,
+			},
+		},
+		{
+			name:   "Mission laid on the earth",
+			params: generated.GetEstateIdDronePlanParams{Format: formatParam(generated.QgcPlan), OriginLat: floatParam(-6.2), OriginLon: floatParam(106.8), PlotSize: floatParam(10)},
+			expectedQuery: dronePlanQuery{
+				modes:  []flightMode{flightModeCanopyFollow},
+				sweeps: []sweep{sweepDefault},
+				grid:   geo.Grid{Origin: geo.Point{Latitude: -6.2, Longitude: 106.8}, PlotSize: 10},
+				homeX:  1,
+				homeY:  1,
+			},
+		},
+		{
+			name:           "Negative max distance and unknown mode",
+			params:         generated.GetEstateIdDronePlanParams{MaxDistance: intParam(-1), Mode: strParam("hover")},
+			expectedFields: []string{"max_distance", "mode"},
+		},
+		{
+			name:           "Drones comparing flight modes",
+			params:         generated.GetEstateIdDronePlanParams{Drones: intParam(2), Mode: strParam("compare")},
+			expectedFields: []string{"drones"},
+		},
+		{
+			// the sweep has 3 rows to split
+			name:           "More drones than rows",
+			params:         generated.GetEstateIdDronePlanParams{Drones: intParam(4)},
+			expectedFields: []string{"drones"},
+		},
+		{
+			name:           "Battery with drones and home outside of the estate",
+			params:         generated.GetEstateIdDronePlanParams{Battery: intParam(100), Drones: intParam(2), HomeX: intParam(6), HomeY: intParam(0)},
+			expectedFields: []string{"battery", "home_x", "home_y"},
+		},
+		{
+			name:           "Mission without origin",
+			params:         generated.GetEstateIdDronePlanParams{Format: formatParam(generated.MavlinkWaypoints)},
+			expectedFields: []string{"origin_lat", "origin_lon"},
+		},
+		{
+			// the mission is only checked once the plan is worth exporting
+			name:           "Mission with drones",
+			params:         generated.GetEstateIdDronePlanParams{Format: formatParam(generated.MavlinkWaypoints), Drones: intParam(2)},
+			expectedFields: []string{"drones"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			query, fieldErrs := dronePlanParams(estate, tc.params)
+
+			fields := make([]string, 0, len(fieldErrs))
+			for _, fieldErr := range fieldErrs {
+				require.Equal(t, generated.ProblemCodeInvalidParameter, fieldErr.Code)
+				fields = append(fields, fieldErr.Field)
+			}
+			if len(tc.expectedFields) > 0 {
+				require.Equal(t, tc.expectedFields, fields)
+				return
+			}
+
+			require.Empty(t, fields)
+			require.Equal(t, tc.expectedQuery, query)
+		})
+	}
+}
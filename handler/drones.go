@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/mission"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+// respondDronePlans respond the route of every drone patrolling the estate in parallel
+func (srv *Server) respondDronePlans(
+	ectx echo.Context,
+	estate repository.Estate,
+	mode flightMode,
+	s sweep,
+	drones int,
+	maxDistance *int,
+) error {
+	plans, err := srv.dronePlans(ectx.Request().Context(), estate, mode, s, drones)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[DronePlan] failed to patrol estate_id:%s with %d drones, err:%s", estate.ID, drones, err)
+		return respondError(ectx, err)
+	}
+
+	modeName, planSweep := mode.String(), sweepResponse(s)
+	resp := generated.EstateDronePlanResponse{Mode: &modeName, Sweep: &planSweep}
+	droneRoutes := make([]generated.DronePlanDrone, 0, len(plans))
+	for i, plan := range plans {
+		start, end := plan.ends(estate)
+		droneRoute := generated.DronePlanDrone{
+			Drone:    i + 1,
+			Distance: plan.distance,
+			Start:    generated.PlotPosition{X: start.X, Y: start.Y},
+			End:      generated.PlotPosition{X: end.X, Y: end.Y},
+			Steps:    dronePlanSteps(plan.steps(estate)),
+		}
+		if maxDistance != nil {
+			restX, restY := srv.rest(estate, plan, *maxDistance)
+			droneRoute.Rest = &generated.PlotPosition{X: restX, Y: restY}
+		}
+		droneRoutes = append(droneRoutes, droneRoute)
+
+		// the drones fly in parallel, the patrol lasts as long as the longest route
+		resp.Distance = max(resp.Distance, plan.distance)
+	}
+	resp.Drones = &droneRoutes
+
+	return ectx.JSON(http.StatusOK, resp)
+}
+
+// dronePlans patrol the estate with the drones in parallel in the flight mode along the sweep,
+// every drone patrols its own strip of consecutive rows of the patrol. The strips are balanced
+// by the route distance of their rows, vertical moves included, not by their area.
+func (srv *Server) dronePlans(
+	ctx context.Context,
+	estate repository.Estate,
+	mode flightMode,
+	s sweep,
+	drones int,
+) ([]flightPlan, error) {
+	trees, err := srv.repository.GetAllTreesInEstate(ctx, estate.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	estate, err = srv.estateZones(ctx, estate)
+	if err != nil {
+		return nil, err
+	}
+
+	patrolEstate := s.patrolEstate(estate)
+	patrolTrees := s.patrolTrees(estate, trees)
+
+	// route distance of every row when a single drone patrols the whole estate
+	rowDistances := make([]int, patrolEstate.Length)
+	if len(trees) > 0 {
+		_, _, _, _, routeSteps, err := srv.patrolAs(patrolEstate, patrolTrees, mode)
+		if err != nil {
+			return nil, err
+		}
+		for _, routeStep := range routeSteps {
+			rowDistances[routeStep.Y-1] += routeStep.StepDistance
+		}
+	}
+
+	plans := make([]flightPlan, 0, drones)
+	firstRow := 1
+	for _, lastRow := range partitionRows(rowDistances, drones) {
+		plan := flightPlan{mode: mode, sweep: s, firstRow: firstRow, lastRow: lastRow}
+
+		// trees of the strip on the rows of the strip
+		var stripTrees []repository.Tree
+		for _, tree := range patrolTrees {
+			if tree.Y >= firstRow && tree.Y <= lastRow {
+				tree.Y -= firstRow - 1
+				stripTrees = append(stripTrees, tree)
+			}
+		}
+
+		// nothing to monitor in a strip without trees, the drone stays on the ground
+		if len(stripTrees) > 0 {
+			_, _, _, plan.distance, plan.routeSteps, err = srv.patrolAs(plan.patrolEstate(estate), stripTrees, mode)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		plans = append(plans, plan)
+		firstRow = lastRow + 1
+	}
+
+	return plans, nil
+}
+
+// partitionRows split the rows into parts of consecutive rows with the longest part
+// as short as possible, distances is the distance of every row. It return the last row
+// of every part, parts is at most the number of rows.
+func partitionRows(distances []int, parts int) []int {
+	// longest is between the longest row and every row in a single part
+	var longest, total int
+	for _, distance := range distances {
+		longest = max(longest, distance)
+		total += distance
+	}
+
+	fits := func(partDistance int) bool {
+		count, sum := 1, 0
+		for _, distance := range distances {
+			if sum+distance > partDistance {
+				count++
+				sum = 0
+			}
+			sum += distance
+		}
+		return count <= parts
+	}
+
+	for high := total; longest < high; {
+		mid := (longest + high) / 2
+		if fits(mid) {
+			high = mid
+		} else {
+			longest = mid + 1
+		}
+	}
+
+	lastRows := make([]int, 0, parts)
+	var sum int
+	for i, distance := range distances {
+		// every part left needs a row at least
+		partsLeft := parts - len(lastRows) - 1
+		if i > 0 && (sum+distance > longest || len(distances)-i == partsLeft) {
+			lastRows = append(lastRows, i)
+			sum = 0
+		}
+		sum += distance
+	}
+
+	return append(lastRows, len(distances))
+}
+
+// ends return the plot of the estate the drone takes off from and lands on
+func (plan flightPlan) ends(estate repository.Estate) (start, end mission.Waypoint) {
+	waypoints := plan.path(estate)
+	if len(waypoints) == 0 {
+		start.X, start.Y = plan.fromPatrol(estate, 1, 1)
+		return start, start
+	}
+
+	return waypoints[0], waypoints[len(waypoints)-1]
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionRows(t *testing.T) {
+	tests := []struct {
+		name             string
+		distances        []int
+		parts            int
+		expectedLastRows []int
+	}{
+		{
+			name:             "Single part",
+			distances:        []int{30, 40, 30},
+			parts:            1,
+			expectedLastRows: []int{3},
+		},
+		{
+			name:             "Part per row",
+			distances:        []int{30, 40, 30},
+			parts:            3,
+			expectedLastRows: []int{1, 2, 3},
+		},
+		{
+			name:             "Balanced by distance not by rows",
+			distances:        []int{80, 20, 20, 20, 20},
+			parts:            2,
+			expectedLastRows: []int{1, 5},
+		},
+		{
+			name:             "Tall rows in the middle",
+			distances:        []int{30, 30, 60, 60, 30, 30},
+			parts:            2,
+			expectedLastRows: []int{3, 6},
+		},
+		{
+			name:             "Every part has a row",
+			distances:        []int{0, 0, 0, 0},
+			parts:            3,
+			expectedLastRows: []int{2, 3, 4},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedLastRows, partitionRows(tc.distances, tc.parts))
+		})
+	}
+}
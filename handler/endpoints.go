@@ -1,45 +1,61 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/mission"
+	"github.com/nahwinrajan/testswpro/repository"
 )
 
 func (srv *Server) PostEstate(ectx echo.Context) error {
 	var payload generated.CreateEstateRequestBody
-	var respBadReq generated.ErrorResponse
-	respBadReq.Message = "invalid value or format"
 
 	defer ectx.Request().Body.Close()
 	err := ectx.Bind(&payload)
 	if err != nil {
 		// TODO: change log level according log level company guideline (info, error, etc)
 		ectx.Logger().Errorf("[CreateEstate] failed read payload, err:%s", err)
-		return ectx.JSON(http.StatusBadRequest, respBadReq)
+		return respondBadRequest(ectx, generated.ProblemCodeInvalidBody, "payload is not a valid estate")
 	}
 
-	// validation
-	switch {
-	case payload.Width < 1 || payload.Width > 50000:
-		// TODO: change log level according log level company guideline (info, error, etc)
-		ectx.Logger().Errorf("[CreateEstate] invalid value width:%+v, payload:%+v", payload.Width, payload)
-		return ectx.JSON(http.StatusBadRequest, respBadReq)
-	case payload.Length < 1 || payload.Length > 50000:
+	// the payload is in the limits of api.yml, see Validator
+	estate := repository.Estate{
+		Width:  payload.Width,
+		Length: payload.Length,
+		Drone:  applyDroneProfile(repository.DefaultDroneProfile(), payload.Drone),
+	}
+	if payload.Origin != nil {
+		estate.OriginLat = &payload.Origin.Latitude
+		estate.OriginLon = &payload.Origin.Longitude
+	}
+	if payload.Bearing != nil {
+		estate.Bearing = *payload.Bearing
+	}
+
+	fieldErrs := droneFieldErrors(estate.Drone)
+	if payload.Boundary != nil {
+		var boundaryErrs []generated.FieldError
+		estate.Boundary, boundaryErrs = estateBoundary(estate.Width, estate.Length, *payload.Boundary)
+		fieldErrs = append(fieldErrs, boundaryErrs...)
+	}
+	if len(fieldErrs) > 0 {
 		// TODO: change log level according log level company guideline (info, error, etc)
-		ectx.Logger().Errorf("[CreateEstate] invalid value length:%+v", payload.Length)
-		return ectx.JSON(http.StatusBadRequest, respBadReq)
+		ectx.Logger().Errorf("[CreateEstate] invalid values:%+v, payload:%+v", fieldErrs, payload)
+		return respondInvalidFields(ectx, fieldErrs)
 	}
 
-	estateID, err := srv.repository.InsertEstate(ectx.Request().Context(), payload.Width, payload.Length)
+	estateID, err := srv.repository.InsertEstate(ectx.Request().Context(), estate)
 	if err != nil {
 		// TODO: change log level according log level company guideline (info, error, etc)
 		ectx.Logger().Errorf("[CreateEstate] failed to insert payload:%+v, err:%s", payload, err)
-		// the problem statement does not specify this error, nor in api docs..
-		// but it is possibility, should have been InternalServer error but..
-		respBadReq.Message = "failed to create resource"
-		return ectx.JSON(http.StatusBadRequest, respBadReq)
+		return respondError(ectx, err)
 	}
 
 	var resp generated.CreateEstateResponse
@@ -49,15 +65,12 @@ func (srv *Server) PostEstate(ectx echo.Context) error {
 
 func (srv *Server) PostEstateIdTree(ectx echo.Context, id string) error {
 	var payload generated.CreateTreeRequestBody
-	var respBadReq generated.ErrorResponse
-	respBadReq.Message = "invalid value or format"
 
 	// id is estateID
 	if len(id) == 0 {
 		// TODO: change log level according log level company guideline (info, error, etc)
 		ectx.Logger().Errorf("[CreateTree] param estate_id not passed")
-		respBadReq.Message = "resource not found"
-		return ectx.JSON(http.StatusNotFound, respBadReq)
+		return respondNotFound(ectx)
 	}
 
 	estate, err := srv.repository.GetEstateByID(
@@ -65,8 +78,9 @@ func (srv *Server) PostEstateIdTree(ectx echo.Context, id string) error {
 		id,
 	)
 	if err != nil {
-		respBadReq.Message = "resource not found"
-		return ectx.JSON(http.StatusNotFound, respBadReq)
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[CreateTree] failed retrieve information estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
 	}
 
 	defer ectx.Request().Body.Close()
@@ -74,65 +88,173 @@ func (srv *Server) PostEstateIdTree(ectx echo.Context, id string) error {
 	if err != nil {
 		// TODO: change log level according log level company guideline (info, error, etc)
 		ectx.Logger().Errorf("[CreateTree] failed to read payload, err:%s", err)
-		return ectx.JSON(http.StatusBadRequest, respBadReq)
+		return respondBadRequest(ectx, generated.ProblemCodeInvalidBody, "payload is not a valid tree")
 	}
 
-	switch {
-	case payload.Height < 1 || payload.Height > 30:
+	// the payload is in the limits of api.yml (see Validator), the plot must be in the estate too
+	fieldErrs := treeFieldErrors(estate, payload.X, payload.Y)
+	if len(fieldErrs) > 0 {
 		// TODO: change log level according log level company guideline (info, error, etc)
-		ectx.Logger().Errorf("[CreateTree] invalid value height:%+v", payload.Height)
-		return ectx.JSON(http.StatusBadRequest, respBadReq)
-	case payload.X < 1 || payload.X > estate.Width:
-		// TODO: change log level according log level company guideline (info, error, etc)
-		ectx.Logger().Errorf("[CreateTree] invalid value x:%+v", payload.X)
-		return ectx.JSON(http.StatusBadRequest, respBadReq)
-	case payload.Y < 1 || payload.Y > estate.Length:
+		ectx.Logger().Errorf("[CreateTree] invalid values:%+v, payload:%+v", fieldErrs, payload)
+		return respondInvalidFields(ectx, fieldErrs)
+	}
+
+	// one tree of the estate after another for the metadata to count every tree
+	var resp generated.CreateTreeResponse
+	err = srv.changeEstateTrees(ectx, "[CreateTree]", id, func(txSrv *Server, estate repository.Estate) (before, after *repository.Tree, err error) {
+		if payload.X > estate.Width || payload.Y > estate.Length || !insideBoundary(estate, payload.X, payload.Y) {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[CreateTree] estate_id:%s resized or reshaped meanwhile, plot (%d,%d) is outside", id, payload.X, payload.Y)
+			return nil, nil, fmt.Errorf("%w: %w: plot (%d,%d)", repository.ErrConflict, errPlotOutside, payload.X, payload.Y)
+		}
+
+		strTreeID, err := txSrv.repository.InsertTree(
+			ectx.Request().Context(),
+			id,
+			payload.X,
+			payload.Y,
+			payload.Height,
+		)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[CreateTree] failed to insert payload:%+v, err:%s", payload, err)
+			return nil, nil, err
+		}
+
+		resp.Id = strTreeID
+
+		// only the neighbouring trees of the new tree are needed to update the estate metadata
+		return nil, &repository.Tree{
+			ID:       strTreeID,
+			EstateID: id,
+			X:        payload.X,
+			Y:        payload.Y,
+			Height:   payload.Height,
+		}, nil
+	})
+	if errors.Is(err, errPlotOutside) {
+		return respondErrorAs(ectx, err, generated.ProblemCodeTreeOutOfBounds, msgPlotOutside)
+	}
+	if err != nil {
+		return respondErrorAs(ectx, err, generated.ProblemCodePlotOccupied, msgPlotTaken)
+	}
+
+	return ectx.JSON(http.StatusCreated, resp)
+}
+
+func (srv *Server) PostEstateIdTreesBatch(ectx echo.Context, id string) error {
+	// id is estateID
+	if len(id) == 0 {
 		// TODO: change log level according log level company guideline (info, error, etc)
-		ectx.Logger().Errorf("[CreateTree] invalid value y:%+v", payload.Y)
-		return ectx.JSON(http.StatusBadRequest, respBadReq)
+		ectx.Logger().Errorf("[CreateTreesBatch] param estate_id not passed")
+		return respondNotFound(ectx)
 	}
 
-	strTreeID, err := srv.repository.InsertTree(
+	_, err := srv.repository.GetEstateByID(
 		ectx.Request().Context(),
 		id,
-		payload.X,
-		payload.Y,
-		payload.Height,
 	)
-	// TODO: cater for Internal Server error
 	if err != nil {
 		// TODO: change log level according log level company guideline (info, error, etc)
-		ectx.Logger().Errorf("[CreateTree] failed to insert payload:%+v, err:%s", payload, err)
-		return ectx.JSON(http.StatusBadRequest, respBadReq)
+		ectx.Logger().Errorf("[CreateTreesBatch] failed to read estate_id:%+v, err:%s", id, err)
+		return respondError(ectx, err)
 	}
 
-	var resp generated.CreateTreeResponse
-	resp.Id = strTreeID
+	defer ectx.Request().Body.Close()
+	body := http.MaxBytesReader(ectx.Response(), ectx.Request().Body, int64(maxBatchBytes))
+	rows, rowErrs, err := parseTreeRows(ectx.Request().Header.Get(echo.HeaderContentType), body)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[CreateTreesBatch] failed to read payload, err:%s", err)
+	}
+
+	// the rows failing validation are among the parsed rows, count them once
+	lenRows := len(rows) + len(rowErrs)
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errUnsupportedContentType):
+		return respondBatchProblem(ectx, http.StatusUnsupportedMediaType, generated.ProblemCodeUnsupportedMediaType, err.Error(), []generated.TreeRowError{})
+	case errors.Is(err, errTooManyTrees):
+		return respondBatchProblem(ectx, http.StatusBadRequest, generated.ProblemCodeInvalidBody, err.Error(), []generated.TreeRowError{})
+	case errors.As(err, &maxBytesErr):
+		return respondBatchProblem(ectx, http.StatusBadRequest, generated.ProblemCodeInvalidBody, fmt.Sprintf("payload too large, max %d bytes", maxBatchBytes), []generated.TreeRowError{})
+	case err != nil:
+		return respondBatchProblem(ectx, http.StatusBadRequest, generated.ProblemCodeInvalidBody, "payload is not a valid batch of trees", []generated.TreeRowError{})
+	case lenRows < 1:
+		return respondBatchProblem(ectx, http.StatusBadRequest, generated.ProblemCodeInvalidBody, "no trees in payload", []generated.TreeRowError{})
+	}
 
-	// TODO: calculate estate metadata (i.e: count, max, min, median, distance, routes)
-	err = srv.calculateEstateMetadata(ectx.Request().Context(), id)
+	// the trees are validated against the trees planted right before them, the estate
+	// metadata is recalculated in the background
+	var treeIDs []string
+	err = srv.withEstateLock(ectx.Request().Context(), id, func(txSrv *Server, estate repository.Estate) error {
+		plantedTrees, err := txSrv.repository.GetAllTreesInEstate(ectx.Request().Context(), id)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[CreateTreesBatch] failed to read trees estate_id:%+v, err:%s", id, err)
+			return err
+		}
+
+		rowErrs = append(rowErrs, validateTreeRows(estate, plantedTrees, rows)...)
+		if len(rowErrs) > 0 {
+			return errInvalidTreeRows
+		}
+
+		trees := make([]repository.Tree, 0, len(rows))
+		for _, row := range rows {
+			trees = append(trees, repository.Tree{
+				EstateID: id,
+				X:        row.tree.X,
+				Y:        row.tree.Y,
+				Height:   row.tree.Height,
+			})
+		}
+
+		treeIDs, err = txSrv.repository.InsertTrees(ectx.Request().Context(), id, trees)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[CreateTreesBatch] failed to insert trees, err:%s", err)
+			return err
+		}
+
+		for idx := range trees {
+			trees[idx].ID = treeIDs[idx]
+		}
+
+		// patrolling the whole estate takes a while, recalculate it once for the whole batch
+		err = txSrv.repository.EnqueueRecalculation(ectx.Request().Context(), id)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[CreateTreesBatch] failed to enqueue recalculation estate_id:%s, err:%s", id, err)
+		}
+
+		return err
+	})
+	if errors.Is(err, errInvalidTreeRows) {
+		sort.Slice(rowErrs, func(i, j int) bool {
+			return rowErrs[i].Row < rowErrs[j].Row
+		})
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[CreateTreesBatch] invalid rows:%d of %d", len(rowErrs), lenRows)
+		return respondInvalidRows(ectx, lenRows, rowErrs)
+	}
 	if err != nil {
-		// TODO: what should we do ?
-		// ideally, we have this on background, with multiple retry and then
-		// if still fail output alert to slack, manual rectify issue, and re-trigger calculation
-		ectx.Logger().Errorf("[CreateTree] failed to calculate stats and distance, err:%s", err)
-		srv.repository.DeleteTree(ectx.Request().Context(), strTreeID)
-		return ectx.JSON(http.StatusBadRequest, respBadReq)
+		return respondErrorAs(ectx, err, generated.ProblemCodePlotOccupied, msgPlotTaken)
 	}
 
+	var resp generated.CreateTreesBatchResponse
+	resp.Ids = treeIDs
+
 	return ectx.JSON(http.StatusCreated, resp)
 }
 
 func (srv *Server) GetEstateIdStats(ectx echo.Context, id string) error {
-	var respBadReq generated.ErrorResponse
-	respBadReq.Message = "invalid value or format"
-
 	// id is estateID
 	if len(id) == 0 {
 		// TODO: change log level according log level company guideline (info, error, etc)
 		ectx.Logger().Errorf("[GetEstateStats] param estate_id not passed")
-		respBadReq.Message = "resource not found"
-		return ectx.JSON(http.StatusNotFound, respBadReq)
+		return respondNotFound(ectx)
 	}
 
 	estate, err := srv.repository.GetEstateByID(
@@ -142,8 +264,7 @@ func (srv *Server) GetEstateIdStats(ectx echo.Context, id string) error {
 	if err != nil {
 		// TODO: change log level according log level company guideline (info, error, etc)
 		ectx.Logger().Errorf("[GetEstateStats] failed to read estate_id:%+v, err:%s", id, err)
-		respBadReq.Message = "resource not found"
-		return ectx.JSON(http.StatusNotFound, respBadReq)
+		return respondError(ectx, err)
 	}
 
 	var resp generated.EstateStatsResponse
@@ -151,20 +272,17 @@ func (srv *Server) GetEstateIdStats(ectx echo.Context, id string) error {
 	resp.Min = estate.Min
 	resp.Median = estate.Median
 	resp.Max = estate.Max
+	resp.StatsStatus = generated.StatsStatus(estate.StatsStatus)
 
 	return ectx.JSON(http.StatusOK, resp)
 }
 
-func (srv *Server) GetEstateIdDronePlan(ectx echo.Context, id string) error {
-	var respBadReq generated.ErrorResponse
-	respBadReq.Message = "invalid value or format"
-
+func (srv *Server) GetEstateIdDronePlan(ectx echo.Context, id string, params generated.GetEstateIdDronePlanParams) error {
 	// id is estateID
 	if len(id) == 0 {
 		// TODO: change log level according log level company guideline (info, error, etc)
 		ectx.Logger().Errorf("[DronePlan] param estate_id not passed")
-		respBadReq.Message = "resource not found"
-		return ectx.JSON(http.StatusNotFound, respBadReq)
+		return respondNotFound(ectx)
 	}
 
 	estate, err := srv.repository.GetEstateByID(
@@ -172,100 +290,246 @@ func (srv *Server) GetEstateIdDronePlan(ectx echo.Context, id string) error {
 		id,
 	)
 	if err != nil {
-		respBadReq.Message = "resource not found"
 		// TODO: change log level according log level company guideline (info, error, etc)
 		ectx.Logger().Errorf("[DronePlan] failed retrieve information estate_id:%s, err:%s", id, err)
-		return ectx.JSON(http.StatusNotFound, respBadReq)
+		return respondError(ectx, err)
 	}
 
 	var resp generated.EstateDronePlanResponse
 	resp.Distance = estate.PatrolDistance
 
-	// // if the request without query param
-	// strMaxDistance := ectx.QueryParam("max_distance")
-	// if len(strMaxDistance) == 0 {
-	// 	// TODO: change log level according log level company guideline (info, error, etc)
-	// 	ectx.Logger().Errorf("[DronePlan] max_distance not passed")
-	// 	return ectx.JSON(http.StatusOK, resp)
-	// }
-
-	// maxDistance, err := strconv.Atoi(strMaxDistance)
-	// if err != nil {
-	// 	// TODO: change log level according log level company guideline (info, error, etc)
-	// 	ectx.Logger().Errorf("[DronePlan] invalid param max_distance: %s, err:%s", strMaxDistance, err)
-	// 	return ectx.JSON(http.StatusBadRequest, respBadReq)
-	// }
-
-	// resp.Rest.X, resp.Rest.Y, err = srv.calculateMaxDistance(estate, maxDistance)
-	// if err != nil {
-	// 	// TODO: should be Internal Server Error, but its not on problem spec
-	// 	// TODO: change log level according log level company guideline (info, error, etc)
-	// 	ectx.Logger().Errorf("[calculateMaxDistance] return error: %s", err)
-	// 	return ectx.JSON(http.StatusBadRequest, respBadReq)
-	// }
+	// if the request without query param
+	if params.MaxDistance == nil && params.Format == nil && params.Mode == nil && params.Sweep == nil &&
+		params.StartCorner == nil && params.Drones == nil && params.Battery == nil {
+		return ectx.JSON(http.StatusOK, resp)
+	}
+
+	query, fieldErrs := dronePlanParams(estate, params)
+	if len(fieldErrs) > 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[DronePlan] invalid params:%+v", fieldErrs)
+		return respondInvalidFields(ectx, fieldErrs)
+	}
+
+	if params.Drones != nil {
+		return srv.respondDronePlans(ectx, estate, query.modes[0], query.sweeps[0], *params.Drones, params.MaxDistance)
+	}
+
+	// plan is the route asked, the shortest one when comparing flight modes or sweeps
+	plan := flightPlan{mode: flightModeCanopyFollow, sweep: sweepDefault, distance: estate.PatrolDistance}
+	if len(query.modes) == 1 && query.modes[0] == flightModeCanopyFollow && len(query.sweeps) == 1 && query.sweeps[0] == sweepDefault {
+		plan.routeSteps, err = srv.patrolRoute(ectx.Request().Context(), estate)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[DronePlan] failed to read patrol route estate_id:%s, err:%s", id, err)
+			return respondError(ectx, err)
+		}
+
+		// incremental recalculation does not keep the patrol route, rebuild it
+		if len(plan.routeSteps) == 0 && estate.Count > 0 {
+			estate, _, plan.routeSteps, err = srv.rebuildPatrolRoute(ectx.Request().Context(), estate.ID)
+			if err != nil {
+				// TODO: change log level according log level company guideline (info, error, etc)
+				ectx.Logger().Errorf("[DronePlan] failed to rebuild patrol route estate_id:%s, err:%s", id, err)
+				return respondError(ectx, err)
+			}
+			plan.distance = estate.PatrolDistance
+		}
+	} else {
+		// only the canopy-follow patrol row by row from plot (1,1) is stored,
+		// the other flight modes and sweeps patrol the estate on demand
+		plans, err := srv.flightPlans(ectx.Request().Context(), estate, query.modes, query.sweeps)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[DronePlan] failed to patrol estate_id:%s in mode %s, err:%s", id, query.modes[0], err)
+			return respondError(ectx, err)
+		}
+
+		plan = plans[0]
+		if len(plans) > 1 {
+			summaries := make([]generated.DronePlanSummary, 0, len(plans))
+			for _, plan := range plans {
+				summaries = append(summaries, generated.DronePlanSummary{
+					Mode:     plan.mode.String(),
+					Sweep:    sweepResponse(plan.sweep),
+					Distance: plan.distance,
+				})
+			}
+			resp.Plans = &summaries
+		}
+	}
+	resp.Distance = plan.distance
+
+	if params.Format != nil {
+		droneMission, err := mission.FromPath(plan.path(estate), query.grid)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[DronePlan] failed to build mission estate_id:%s, err:%s", id, err)
+			return respondBadRequest(ectx, generated.ProblemCodeInvalidParameter, err.Error())
+		}
+
+		return writeMission(ectx, id, *params.Format, droneMission)
+	}
+
+	if params.Mode != nil || params.Sweep != nil || params.StartCorner != nil {
+		modeName, planSweep, steps := plan.mode.String(), sweepResponse(plan.sweep), dronePlanSteps(plan.steps(estate))
+		resp.Mode, resp.Sweep, resp.Steps = &modeName, &planSweep, &steps
+	}
+
+	if params.Battery != nil {
+		// the transits fly around the zones of the estate
+		estate, err = srv.estateZones(ectx.Request().Context(), estate)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[DronePlan] failed to read zones estate_id:%s, err:%s", id, err)
+			return respondError(ectx, err)
+		}
+
+		sorties, minBattery, err := planSorties(estate, plan, *params.Battery, query.homeX, query.homeY)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[DronePlan] home (%d,%d) of estate_id:%s, err:%s", query.homeX, query.homeY, id, err)
+			return respondInvalidFields(ectx, []generated.FieldError{
+				fieldError("home_x", generated.ProblemCodeInvalidParameter, err.Error()),
+				fieldError("home_y", generated.ProblemCodeInvalidParameter, err.Error()),
+			})
+		}
+		if minBattery > *params.Battery {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[DronePlan] battery %d too small for estate_id:%s, needs %d", *params.Battery, id, minBattery)
+			return respondInvalidFields(ectx, []generated.FieldError{
+				fieldError("battery", generated.ProblemCodeInvalidParameter,
+					fmt.Sprintf("battery must be at least %d to fly every route step from home", minBattery)),
+			})
+		}
+
+		droneSorties := dronePlanSorties(plan, sorties)
+		resp.Sorties = &droneSorties
+	}
+
+	if params.MaxDistance != nil {
+		restX, restY := srv.rest(estate, plan, *params.MaxDistance)
+		resp.Rest = &generated.PlotPosition{
+			X: restX,
+			Y: restY,
+		}
+	}
+
+	return ectx.JSON(http.StatusOK, resp)
+}
+
+func (srv *Server) GetEstateIdDronePlanSteps(ectx echo.Context, id string, params generated.GetEstateIdDronePlanStepsParams) error {
+	// id is estateID
+	if len(id) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[DronePlanSteps] param estate_id not passed")
+		return respondNotFound(ectx)
+	}
+
+	// cursor is the last step of the previous page
+	var afterStep int
+	if params.Cursor != nil {
+		var err error
+		afterStep, err = strconv.Atoi(*params.Cursor)
+		if err != nil || afterStep < 0 {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[DronePlanSteps] invalid param cursor: %s", *params.Cursor)
+			return respondInvalidFields(ectx, []generated.FieldError{
+				fieldError("cursor", generated.ProblemCodeInvalidParameter, "cursor is not one of a previous page"),
+			})
+		}
+	}
+
+	limit := routeStepsPageLimitDefault
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	fromDistance, toDistance := 0, math.MaxInt32
+	if params.FromDistance != nil {
+		fromDistance = *params.FromDistance
+	}
+	if params.ToDistance != nil {
+		toDistance = *params.ToDistance
+	}
+
+	// validation
+	fieldErrs := make([]generated.FieldError, 0)
+	if limit < routeStepsPageLimitMin || limit > routeStepsPageLimitMax {
+		fieldErrs = append(fieldErrs, fieldError("limit", generated.ProblemCodeInvalidParameter, fmt.Sprintf("limit must be between %d and %d", routeStepsPageLimitMin, routeStepsPageLimitMax)))
+	}
+	if fromDistance < 0 || toDistance < 0 || fromDistance > toDistance {
+		fieldErrs = append(fieldErrs, fieldError("from_distance", generated.ProblemCodeInvalidParameter, "from_distance and to_distance must be at least 0, from_distance not after to_distance"))
+	}
+	if len(fieldErrs) > 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[DronePlanSteps] invalid params:%+v", fieldErrs)
+		return respondInvalidFields(ectx, fieldErrs)
+	}
+
+	estate, err := srv.repository.GetEstateByID(
+		ectx.Request().Context(),
+		id,
+	)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[DronePlanSteps] failed retrieve information estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	// one more step than asked tells whether there is a next page
+	routeSteps, err := srv.patrolRoutePage(ectx.Request().Context(), estate, afterStep, fromDistance, toDistance, limit+1)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[DronePlanSteps] failed to read patrol route estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	var resp generated.EstateDronePlanStepsResponse
+	if len(routeSteps) > limit {
+		routeSteps = routeSteps[:limit]
+		nextCursor := strconv.Itoa(routeSteps[limit-1].Step)
+		resp.NextCursor = &nextCursor
+	}
+
+	resp.Steps = dronePlanSteps(routeSteps)
 
 	return ectx.JSON(http.StatusOK, resp)
 }
 
-// NOTE: this version return the (x,y) where (x,y) is the closest distance to max distance OR exact match to maxDistance
-// unfortunately there are edge cases where the difference can be right at the border (under 10m), plot(x,y) and the next move is vertical for tree height,
-// func (srv *Server) calculateMaxDistance(estate repository.Estate, maxDistance int) (restX, restY int, err error) {
-// 	if maxDistance >= estate.PatrolDistance {
-// 		restX = estate.Width
-// 		restY = estate.Length
-// 		return
-// 	}
-
-// 	strRoutes := strings.Split(estate.PatrolRoute, ";")
-// 	lenRoutes := len(strRoutes)
-// 	// postX, postY, currDistance := make([]int, 0, lenRoutes), make([]int, 0, lenRoutes), make([]int, 0, lenRoutes)
-// 	// directions := make([]string, 0, lenRoutes)
-// 	// var idx int
-// 	var x, y, distance int
-// 	for i := 0; i < lenRoutes; i++ {
-// 		// stepStrFormat: step_#,x,y,direction,step_distance,current_distance
-// 		strs := strings.Split(strRoutes[i], ",")
-
-// 		x, err = strconv.Atoi(strs[1])
-// 		if err != nil {
-// 			return
-// 		}
-
-// 		y, err = strconv.Atoi(strs[2])
-// 		if err != nil {
-// 			return
-// 		}
-
-// 		distance, err = strconv.Atoi(strs[5])
-// 		if err != nil {
-// 			return
-// 		}
-
-// 		// early exit if we found the target
-// 		switch {
-// 		case distance == maxDistance:
-// 			return x, y, nil
-// 		case distance <= maxDistance:
-// 			// otherwise keep tracking the closest distance traveled to max distance
-// 			restX = x
-// 			restY = y
-// 			// idx = i
-// 		}
-
-// 		// postX = append(postX, x)
-// 		// postY = append(postY, y)
-// 		// currDistance = append(currDistance, distance)
-// 		// directions = append(directions, strs[3])
-// 	}
-
-// 	// TODO find the last mile: detect if the next step is vertical or horizontal
-// 	// we've gon through the routes and no exact match
-// 	// find exactly where the drone ran out of distance
-// 	// stepStrFormat: step_#,x,y,direction,step_distance,current_distance
-// 	// stepStrFormat = "%d,%d,%d,%s,%d,%d"
-
-// 	// we should have update restX, restY to closest position without it running out of battery
-// 	// there are edge cases next step distance more than allocated remaining max_distance (i.e:
-// 	// climb 23m to monitor yet distance left 3m; move to next plot which is 10m but remaining distance 1m)
-// 	return
-// }
+// dronePlanSorties tells the sorties flying the route steps of the plan, numbered from 1
+func dronePlanSorties(plan flightPlan, sorties []sortie) []generated.DronePlanSortie {
+	droneSorties := make([]generated.DronePlanSortie, 0, len(sorties))
+	for i, s := range sorties {
+		droneSorties = append(droneSorties, generated.DronePlanSortie{
+			Sortie:    i + 1,
+			Start:     generated.PlotPosition{X: s.start.X, Y: s.start.Y},
+			End:       generated.PlotPosition{X: s.end.X, Y: s.end.Y},
+			FirstStep: plan.routeSteps[s.firstStep].Step,
+			LastStep:  plan.routeSteps[s.lastStep].Step,
+			Outbound:  s.outbound,
+			Route:     s.route,
+			Return:    s.inbound,
+			Distance:  s.distance(),
+		})
+	}
+
+	return droneSorties
+}
+
+func dronePlanSteps(routeSteps []repository.RouteStep) []generated.DronePlanStep {
+	steps := make([]generated.DronePlanStep, 0, len(routeSteps))
+	for _, routeStep := range routeSteps {
+		steps = append(steps, generated.DronePlanStep{
+			Step:               routeStep.Step,
+			X:                  routeStep.X,
+			Y:                  routeStep.Y,
+			Direction:          routeStep.Direction,
+			StepDistance:       routeStep.StepDistance,
+			CumulativeDistance: routeStep.CurrentDistance,
+			Altitude:           routeStep.Altitude,
+		})
+	}
+
+	return steps
+}
@@ -2,10 +2,14 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/labstack/echo/v4"
@@ -16,13 +20,21 @@ import (
 )
 
 func TestPostEstate(t *testing.T) {
+	floatParam := func(value float64) *float64 {
+		return &value
+	}
+	intParam := func(value int) *int {
+		return &value
+	}
+
 	tests := []struct {
-		name          string
-		payload       generated.CreateEstateRequestBody
-		mockRepoErr   error
-		callRepoLayer bool
-		expectedCode  int
-		expectedID    string
+		name           string
+		payload        generated.CreateEstateRequestBody
+		mockRepoErr    error
+		callRepoLayer  bool
+		expectedEstate repository.Estate
+		expectedCode   int
+		expectedID     string
 	}{
 		{
 			name: "Positive Flow",
@@ -30,32 +42,93 @@ func TestPostEstate(t *testing.T) {
 				Width:  100,
 				Length: 200,
 			},
+			mockRepoErr:    nil,
+			callRepoLayer:  true,
+			expectedEstate: repository.Estate{Width: 100, Length: 200, Drone: repository.DefaultDroneProfile()},
+			expectedCode:   http.StatusCreated,
+			expectedID:     "mocked_estate_id",
+		},
+		{
+			name: "Positive Flow - Geo-referenced",
+			payload: generated.CreateEstateRequestBody{
+				Width:   100,
+				Length:  200,
+				Origin:  &generated.GeoPoint{Latitude: -6.2, Longitude: 106.8},
+				Bearing: floatParam(45),
+			},
 			mockRepoErr:   nil,
 			callRepoLayer: true,
-			expectedCode:  http.StatusCreated,
-			expectedID:    "mocked_estate_id",
+			expectedEstate: repository.Estate{
+				Width:     100,
+				Length:    200,
+				OriginLat: floatParam(-6.2),
+				OriginLon: floatParam(106.8),
+				Bearing:   45,
+				Drone:     repository.DefaultDroneProfile(),
+			},
+			expectedCode: http.StatusCreated,
+			expectedID:   "mocked_estate_id",
 		},
 		{
-			name: "Invalid Payload - Width is zero",
+			name: "Positive Flow - Drone profile",
 			payload: generated.CreateEstateRequestBody{
-				Width:  0,
+				Width:  100,
+				Length: 200,
+				Drone:  &generated.DroneProfileRequestBody{PlotSize: intParam(5), CruiseAltitudeMin: intParam(20)},
+			},
+			mockRepoErr:   nil,
+			callRepoLayer: true,
+			expectedEstate: repository.Estate{
+				Width:  100,
 				Length: 200,
+				Drone:  repository.DroneProfile{PlotSize: 5, MonitorClearance: 1, CruiseAltitudeMin: 20, MaxAltitude: 120},
+			},
+			expectedCode: http.StatusCreated,
+			expectedID:   "mocked_estate_id",
+		},
+		{
+			name: "Positive Flow - Boundary rows",
+			payload: generated.CreateEstateRequestBody{
+				Width:  5,
+				Length: 3,
+				Boundary: &generated.EstateBoundary{Rows: &[]generated.EstateRowRange{
+					{Y: 2, FromX: 1, ToX: 5},
+					{Y: 1, FromX: 2, ToX: 4},
+					{Y: 3, FromX: 3, ToX: 3},
+				}},
 			},
 			mockRepoErr:   nil,
+			callRepoLayer: true,
+			expectedEstate: repository.Estate{
+				Width:    5,
+				Length:   3,
+				Drone:    repository.DefaultDroneProfile(),
+				Boundary: []repository.Plot{{X: 4, Y: 1}, {X: 5, Y: 2}, {X: 3, Y: 3}, {X: 3, Y: 3}, {X: 1, Y: 2}, {X: 2, Y: 1}},
+			},
+			expectedCode: http.StatusCreated,
+			expectedID:   "mocked_estate_id",
+		},
+		{
+			name: "Boundary outside of the estate",
+			payload: generated.CreateEstateRequestBody{
+				Width:  5,
+				Length: 3,
+				Boundary: &generated.EstateBoundary{Polygon: &[]generated.ZoneVertex{
+					{X: 1, Y: 1}, {X: 6, Y: 1}, {X: 3, Y: 3},
+				}},
+			},
 			callRepoLayer: false,
 			expectedCode:  http.StatusBadRequest,
-			expectedID:    "",
 		},
 		{
-			name: "Invalid Payload - Length is zero",
+			name: "Drone cruising above its ceiling",
 			payload: generated.CreateEstateRequestBody{
 				Width:  100,
-				Length: 0,
+				Length: 200,
+				Drone:  &generated.DroneProfileRequestBody{CruiseAltitudeMin: intParam(50), MaxAltitude: intParam(40)},
 			},
-			mockRepoErr:   nil,
 			callRepoLayer: false,
 			expectedCode:  http.StatusBadRequest,
-			expectedID:    "",
 		},
 		{
 			name: "Repository Error",
@@ -63,10 +136,11 @@ func TestPostEstate(t *testing.T) {
 				Width:  100,
 				Length: 200,
 			},
-			mockRepoErr:   errors.New("repository error"),
-			callRepoLayer: true,
-			expectedCode:  http.StatusBadRequest,
-			expectedID:    "",
+			mockRepoErr:    errors.New("repository error"),
+			callRepoLayer:  true,
+			expectedEstate: repository.Estate{Width: 100, Length: 200, Drone: repository.DefaultDroneProfile()},
+			expectedCode:   http.StatusInternalServerError,
+			expectedID:     "",
 		},
 	}
 
@@ -95,7 +169,7 @@ func TestPostEstate(t *testing.T) {
 
 			if tc.callRepoLayer {
 				mockRepo.EXPECT().
-					InsertEstate(gomock.Any(), tc.payload.Width, tc.payload.Length).
+					InsertEstate(gomock.Any(), tc.expectedEstate).
 					Return(tc.expectedID, tc.mockRepoErr).
 					Times(1)
 			}
@@ -134,10 +208,11 @@ func TestGetEstateIdStats(t *testing.T) {
 			callRepoLayer: true,
 			expectedCode:  http.StatusOK,
 			expectedStats: generated.EstateStatsResponse{
-				Count:  10,
-				Min:    5,
-				Median: 15,
-				Max:    20,
+				Count:       10,
+				Min:         5,
+				Median:      15,
+				Max:         20,
+				StatsStatus: generated.Pending,
 			},
 			expectedMessage: "",
 		},
@@ -155,9 +230,9 @@ func TestGetEstateIdStats(t *testing.T) {
 			id:              "valid_estate_id",
 			mockRepoErr:     errors.New("repository error"),
 			callRepoLayer:   true,
-			expectedCode:    http.StatusNotFound,
+			expectedCode:    http.StatusInternalServerError,
 			expectedStats:   generated.EstateStatsResponse{},
-			expectedMessage: "resource not found",
+			expectedMessage: "internal server error",
 		},
 	}
 
@@ -177,10 +252,11 @@ func TestGetEstateIdStats(t *testing.T) {
 
 			if tc.callRepoLayer {
 				expectedEstate := repository.Estate{
-					Count:  10,
-					Min:    5,
-					Median: 15,
-					Max:    20,
+					Count:       10,
+					Min:         5,
+					Median:      15,
+					Max:         20,
+					StatsStatus: repository.StatsStatusPending,
 				}
 				mockRepo.EXPECT().
 					GetEstateByID(gomock.Any(), tc.id).
@@ -212,11 +288,17 @@ func TestGetEstateIdStats(t *testing.T) {
 }
 
 func TestGetEstateIdDronePlan(t *testing.T) {
+	maxDistance := func(distance int) *int {
+		return &distance
+	}
+
 	tests := []struct {
 		name            string
 		id              string
+		params          generated.GetEstateIdDronePlanParams
 		mockRepoErr     error
 		callRepoLayer   bool
+		rebuildRoute    bool
 		expectedCode    int
 		expectedPlan    generated.EstateDronePlanResponse
 		expectedMessage string
@@ -228,10 +310,47 @@ func TestGetEstateIdDronePlan(t *testing.T) {
 			callRepoLayer: true,
 			expectedCode:  http.StatusOK,
 			expectedPlan: generated.EstateDronePlanResponse{
-				Distance: 5000,
+				Distance: 64,
 			},
 			expectedMessage: "",
 		},
+		{
+			name:          "Positive Flow - With max distance",
+			id:            "valid_estate_id",
+			params:        generated.GetEstateIdDronePlanParams{MaxDistance: maxDistance(30)},
+			mockRepoErr:   nil,
+			callRepoLayer: true,
+			expectedCode:  http.StatusOK,
+			expectedPlan: generated.EstateDronePlanResponse{
+				Distance: 64,
+				Rest:     &generated.PlotPosition{X: 3, Y: 1},
+			},
+			expectedMessage: "",
+		},
+		{
+			name:          "Positive Flow - Rebuild route cleared by incremental recalculation",
+			id:            "valid_estate_id",
+			params:        generated.GetEstateIdDronePlanParams{MaxDistance: maxDistance(30)},
+			mockRepoErr:   nil,
+			callRepoLayer: true,
+			rebuildRoute:  true,
+			expectedCode:  http.StatusOK,
+			expectedPlan: generated.EstateDronePlanResponse{
+				Distance: 64,
+				Rest:     &generated.PlotPosition{X: 3, Y: 1},
+			},
+			expectedMessage: "",
+		},
+		{
+			name:            "Negative max distance",
+			id:              "valid_estate_id",
+			params:          generated.GetEstateIdDronePlanParams{MaxDistance: maxDistance(-1)},
+			mockRepoErr:     nil,
+			callRepoLayer:   true,
+			expectedCode:    http.StatusBadRequest,
+			expectedPlan:    generated.EstateDronePlanResponse{},
+			expectedMessage: "max_distance must be at least 0",
+		},
 		{
 			name:            "Empty ID",
 			id:              "",
@@ -246,9 +365,9 @@ func TestGetEstateIdDronePlan(t *testing.T) {
 			id:              "valid_estate_id",
 			mockRepoErr:     errors.New("repository error"),
 			callRepoLayer:   true,
-			expectedCode:    http.StatusNotFound,
+			expectedCode:    http.StatusInternalServerError,
 			expectedPlan:    generated.EstateDronePlanResponse{},
-			expectedMessage: "resource not found",
+			expectedMessage: "internal server error",
 		},
 	}
 
@@ -268,7 +387,40 @@ func TestGetEstateIdDronePlan(t *testing.T) {
 
 			if tc.callRepoLayer {
 				expectedEstate := repository.Estate{
-					PatrolDistance: 5000,
+					Width:          5,
+					Length:         1,
+					PatrolDistance: 64,
+					PatrolRoute:    "1,1,1,ew,0,10;2,2,1,vu,6,16;3,2,1,ew,6,26;4,3,1,vd,2,28;5,3,1,ew,2,38;6,4,1,vu,1,39;7,4,1,ew,1,49;8,5,1,ew,1,59;",
+				}
+				if tc.params.MaxDistance != nil && *tc.params.MaxDistance >= 0 {
+					// estate patrolled before route steps are stored on their own
+					mockRepo.EXPECT().
+						GetRouteSteps(gomock.Any(), gomock.Any()).
+						Return([]repository.RouteStep{}, nil).
+						Times(1)
+				}
+				if tc.rebuildRoute {
+					expectedEstate.ID = tc.id
+					expectedEstate.Count = 3
+					expectedEstate.PatrolRoute = ""
+					// the trees and the route are read again under the lock, only the route is stored
+					expectEstateLock(mockRepo, expectedEstate)
+					mockRepo.EXPECT().
+						GetAllTreesInEstate(gomock.Any(), tc.id).
+						Return([]repository.Tree{
+							{X: 2, Y: 1, Height: 5},
+							{X: 3, Y: 1, Height: 3},
+							{X: 4, Y: 1, Height: 4},
+						}, nil).
+						Times(1)
+					mockRepo.EXPECT().
+						GetRouteSteps(gomock.Any(), tc.id).
+						Return([]repository.RouteStep{}, nil).
+						Times(1)
+					mockRepo.EXPECT().
+						UpdateRouteSteps(gomock.Any(), tc.id, gomock.Len(8)).
+						Return(nil).
+						Times(1)
 				}
 				mockRepo.EXPECT().
 					GetEstateByID(gomock.Any(), tc.id).
@@ -277,7 +429,7 @@ func TestGetEstateIdDronePlan(t *testing.T) {
 			}
 
 			c := e.NewContext(req, rec)
-			err := srv.GetEstateIdDronePlan(c, tc.id)
+			err := srv.GetEstateIdDronePlan(c, tc.id, tc.params)
 			require.NoError(t, err)
 
 			require.Equal(t, tc.expectedCode, rec.Code)
@@ -299,103 +451,1393 @@ func TestGetEstateIdDronePlan(t *testing.T) {
 	}
 }
 
-// func TestPostEstateIdTree(t *testing.T) {
-// 	tests := []struct {
-// 		name               string
-// 		id                 string
-// 		payload            generated.CreateTreeRequestBody
-// 		expectedEstate     repository.Estate
-// 		callRepoInsertTree bool
-// 		callCalculate      bool
-// 		mockInsertTreeErr  error
-// 		mockCalculateErr   error
-// 		expectedCode       int
-// 		expectError        error
-// 		expectedTreeID     string
-// 	}{
-// 		{
-// 			name: "Positive Flow",
-// 			id:   "valid_estate_id",
-// 			payload: generated.CreateTreeRequestBody{
-// 				X:      5,
-// 				Y:      10,
-// 				Height: 15,
-// 			},
-// 			expectedEstate: repository.Estate{
-// 				ID:     "valid_estate_id",
-// 				Width:  20,
-// 				Length: 30,
-// 			},
-// 			callRepoInsertTree: true,
-// 			callCalculate:      true,
-// 			mockInsertTreeErr:  nil,
-// 			mockCalculateErr:   nil,
-// 			expectedCode:       http.StatusCreated,
-// 			expectError:        nil,
-// 			expectedTreeID:     "mocked_tree_id",
-// 		},
-// 		// Add more test cases for negative scenarios if needed
-// 	}
-
-// 	for _, tc := range tests {
-// 		t.Run(tc.name, func(t *testing.T) {
-// 			e := echo.New()
-
-// 			ctrl := gomock.NewController(t)
-// 			defer ctrl.Finish()
-
-// 			mockRepo := repository.NewMockRepositorier(ctrl)
-
-// 			srv := Server{
-// 				repository: mockRepo,
-// 			}
-
-// 			payloadBytes, err := json.Marshal(tc.payload)
-// 			require.NoError(t, err)
-
-// 			req := httptest.NewRequest(http.MethodPost, "/estate/"+tc.id+"/tree", bytes.NewBuffer(payloadBytes))
-// 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
-
-// 			rec := httptest.NewRecorder()
-
-// 			if tc.id != "" {
-// 				mockRepo.EXPECT().
-// 					GetEstateByID(gomock.Any(), tc.id).
-// 					Return(tc.expectedEstate, nil).MaxTimes(2)
-// 			}
-
-// 			if tc.callRepoInsertTree {
-// 				mockRepo.EXPECT().
-// 					InsertTree(gomock.Any(), tc.id, tc.payload.X, tc.payload.Y, tc.payload.Height).
-// 					Return(tc.expectedTreeID, tc.mockInsertTreeErr).
-// 					Times(1)
-// 			}
-
-// 			if tc.callRepoInsertTree && tc.mockInsertTreeErr != nil {
-// 				mockRepo.EXPECT().
-// 					DeleteTree(gomock.Any(), tc.expectedTreeID).
-// 					Return(nil).
-// 					Times(1)
-// 			} else if tc.callRepoInsertTree && tc.mockInsertTreeErr == nil {
-// 				if tc.callCalculate {
-// 					mockRepo.EXPECT().GetAllTreesInEstate(gomock.Any(), tc.id).Return(gomock.Any(), nil)
-// 					mockRepo.EXPECT().UpdateEstate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(gomock.Any()).Return(nil)
-// 				}
-// 			}
-
-// 			c := e.NewContext(req, rec)
-// 			err = srv.PostEstateIdTree(c, tc.id)
-// 			require.Equal(t, tc.expectError, err)
-
-// 			require.Equal(t, tc.expectedCode, rec.Code)
-
-// 			if tc.expectedCode == http.StatusCreated {
-// 				var resp generated.CreateTreeResponse
-// 				err = json.Unmarshal(rec.Body.Bytes(), &resp)
-// 				require.NoError(t, err)
-
-// 				require.Equal(t, tc.expectedTreeID, resp.Id)
-// 			}
-// 		})
-// 	}
-// }
+func TestGetEstateIdDronePlanFlightModes(t *testing.T) {
+	strParam := func(value string) *string {
+		return &value
+	}
+	intParam := func(value int) *int {
+		return &value
+	}
+
+	// the drone climbs and descends over every other tree when following the canopy
+	estate := repository.Estate{ID: "valid_estate_id", Width: 5, Length: 1, Count: 5, PatrolDistance: 90}
+	trees := []repository.Tree{
+		{X: 1, Y: 1, Height: 10},
+		{X: 2, Y: 1, Height: 2},
+		{X: 3, Y: 1, Height: 10},
+		{X: 4, Y: 1, Height: 2},
+		{X: 5, Y: 1, Height: 3},
+	}
+
+	tests := []struct {
+		name             string
+		params           generated.GetEstateIdDronePlanParams
+		trees            []repository.Tree
+		callGetTrees     bool
+		callGetRoute     bool
+		expectedCode     int
+		expectedDistance int
+		expectedMode     string
+		expectedSteps    int
+		expectedRest     *generated.PlotPosition
+		expectedPlans    *[]generated.DronePlanSummary
+		expectedMessage  string
+	}{
+		{
+			name:             "Positive Flow - Canopy follow is the stored route",
+			params:           generated.GetEstateIdDronePlanParams{Mode: strParam("canopy-follow")},
+			callGetRoute:     true,
+			expectedCode:     http.StatusOK,
+			expectedDistance: 90,
+			expectedMode:     "canopy-follow",
+			expectedSteps:    10,
+		},
+		{
+			name:             "Positive Flow - Row max",
+			params:           generated.GetEstateIdDronePlanParams{Mode: strParam("row-max")},
+			trees:            trees,
+			callGetTrees:     true,
+			expectedCode:     http.StatusOK,
+			expectedDistance: 72,
+			expectedMode:     "row-max",
+			expectedSteps:    10,
+		},
+		{
+			name:             "Positive Flow - Lookahead with max distance",
+			params:           generated.GetEstateIdDronePlanParams{Mode: strParam("lookahead-2"), MaxDistance: intParam(45)},
+			trees:            trees,
+			callGetTrees:     true,
+			expectedCode:     http.StatusOK,
+			expectedDistance: 72,
+			expectedMode:     "lookahead-2",
+			expectedSteps:    10,
+			expectedRest:     &generated.PlotPosition{X: 4, Y: 1},
+		},
+		{
+			name:             "Positive Flow - Compare",
+			params:           generated.GetEstateIdDronePlanParams{Mode: strParam("compare")},
+			trees:            trees,
+			callGetTrees:     true,
+			expectedCode:     http.StatusOK,
+			expectedDistance: 72,
+			expectedMode:     "fixed-altitude",
+			expectedSteps:    10,
+			expectedPlans: &[]generated.DronePlanSummary{
+				{Mode: "fixed-altitude", Sweep: generated.Sweep{Orientation: "rows", StartCorner: "sw"}, Distance: 72},
+				{Mode: "row-max", Sweep: generated.Sweep{Orientation: "rows", StartCorner: "sw"}, Distance: 72},
+				{Mode: "lookahead-3", Sweep: generated.Sweep{Orientation: "rows", StartCorner: "sw"}, Distance: 72},
+				{Mode: "canopy-follow", Sweep: generated.Sweep{Orientation: "rows", StartCorner: "sw"}, Distance: 90},
+			},
+		},
+		{
+			name:             "Positive Flow - Estate without trees",
+			params:           generated.GetEstateIdDronePlanParams{Mode: strParam("fixed-altitude")},
+			callGetTrees:     true,
+			expectedCode:     http.StatusOK,
+			expectedDistance: 0,
+			expectedMode:     "fixed-altitude",
+			expectedSteps:    0,
+		},
+		{
+			name:            "Lookahead without trees ahead",
+			params:          generated.GetEstateIdDronePlanParams{Mode: strParam("lookahead-0")},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "mode must be canopy-follow, fixed-altitude, row-max, lookahead-N (N between 1 and 1000) or compare",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			mockRepo.EXPECT().GetEstateByID(gomock.Any(), "valid_estate_id").Return(estate, nil).Times(1)
+			if tc.callGetTrees {
+				mockRepo.EXPECT().GetAllTreesInEstate(gomock.Any(), "valid_estate_id").Return(tc.trees, nil).Times(1)
+			}
+			if tc.callGetRoute {
+				_, _, _, _, routeSteps, err := srv.patrol(estate, trees)
+				require.NoError(t, err)
+				mockRepo.EXPECT().GetRouteSteps(gomock.Any(), "valid_estate_id").Return(routeSteps, nil).Times(1)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/valid_estate_id/drone-plan", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdDronePlan(c, "valid_estate_id", tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode != http.StatusOK {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+				return
+			}
+
+			var resp generated.EstateDronePlanResponse
+			err = json.Unmarshal(rec.Body.Bytes(), &resp)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedDistance, resp.Distance)
+			require.NotNil(t, resp.Mode)
+			require.Equal(t, tc.expectedMode, *resp.Mode)
+			require.NotNil(t, resp.Steps)
+			require.Len(t, *resp.Steps, tc.expectedSteps)
+			if tc.expectedSteps > 0 {
+				// the drone lands from the altitude of its last step
+				lastStep := (*resp.Steps)[tc.expectedSteps-1]
+				require.Equal(t, tc.expectedDistance, lastStep.CumulativeDistance+lastStep.Altitude)
+			}
+			require.Equal(t, tc.expectedRest, resp.Rest)
+			require.Equal(t, tc.expectedPlans, resp.Plans)
+		})
+	}
+}
+
+func TestGetEstateIdDronePlanSweeps(t *testing.T) {
+	strParam := func(value string) *string {
+		return &value
+	}
+	intParam := func(value int) *int {
+		return &value
+	}
+	sweepParam := func(value generated.GetEstateIdDronePlanParamsSweep) *generated.GetEstateIdDronePlanParamsSweep {
+		return &value
+	}
+	cornerParam := func(value generated.GetEstateIdDronePlanParamsStartCorner) *generated.GetEstateIdDronePlanParamsStartCorner {
+		return &value
+	}
+
+	// the middle column is tall, row by row the drone climbs and descends over it on every row
+	estate := repository.Estate{ID: "valid_estate_id", Width: 3, Length: 2, Count: 6, PatrolDistance: 110}
+	trees := []repository.Tree{
+		{X: 1, Y: 1, Height: 1},
+		{X: 2, Y: 1, Height: 10},
+		{X: 3, Y: 1, Height: 1},
+		{X: 1, Y: 2, Height: 1},
+		{X: 2, Y: 2, Height: 10},
+		{X: 3, Y: 2, Height: 1},
+	}
+
+	tests := []struct {
+		name             string
+		params           generated.GetEstateIdDronePlanParams
+		expectedCode     int
+		expectedDistance int
+		expectedMode     string
+		expectedSweep    generated.Sweep
+		expectedSteps    []generated.DronePlanStep
+		expectedRest     *generated.PlotPosition
+		expectedPlans    *[]generated.DronePlanSummary
+		expectedMessage  string
+	}{
+		{
+			name:             "Positive Flow - Rows from the north east corner",
+			params:           generated.GetEstateIdDronePlanParams{StartCorner: cornerParam(generated.GetEstateIdDronePlanParamsStartCornerNe)},
+			expectedCode:     http.StatusOK,
+			expectedDistance: 110,
+			expectedMode:     "canopy-follow",
+			expectedSweep:    generated.Sweep{Orientation: "rows", StartCorner: "ne"},
+		},
+		{
+			name:             "Positive Flow - Columns",
+			params:           generated.GetEstateIdDronePlanParams{Sweep: sweepParam(generated.GetEstateIdDronePlanParamsSweepColumns), MaxDistance: intParam(25)},
+			expectedCode:     http.StatusOK,
+			expectedDistance: 102,
+			expectedMode:     "canopy-follow",
+			expectedSweep:    generated.Sweep{Orientation: "columns", StartCorner: "sw"},
+			expectedRest:     &generated.PlotPosition{X: 1, Y: 2},
+		},
+		{
+			name: "Positive Flow - Columns from the south east corner",
+			params: generated.GetEstateIdDronePlanParams{
+				Sweep:       sweepParam(generated.GetEstateIdDronePlanParamsSweepColumns),
+				StartCorner: cornerParam(generated.GetEstateIdDronePlanParamsStartCornerSe),
+			},
+			expectedCode:     http.StatusOK,
+			expectedDistance: 102,
+			expectedMode:     "canopy-follow",
+			expectedSweep:    generated.Sweep{Orientation: "columns", StartCorner: "se"},
+			// column by column from plot (3,1), the column change steps are on the column it moves into
+			expectedSteps: []generated.DronePlanStep{
+				{Step: 1, X: 3, Y: 1, Direction: "vu", Altitude: 2, StepDistance: 2, CumulativeDistance: 2},
+				{Step: 2, X: 3, Y: 1, Direction: "sn", Altitude: 2, StepDistance: 10, CumulativeDistance: 12},
+				{Step: 3, X: 3, Y: 2, Direction: "--", Altitude: 2, StepDistance: 0, CumulativeDistance: 12},
+				{Step: 4, X: 3, Y: 2, Direction: "sn", Altitude: 2, StepDistance: 10, CumulativeDistance: 22},
+				{Step: 5, X: 2, Y: 2, Direction: "we", Altitude: 2, StepDistance: 10, CumulativeDistance: 32},
+				{Step: 6, X: 2, Y: 2, Direction: "vu", Altitude: 11, StepDistance: 9, CumulativeDistance: 41},
+				{Step: 7, X: 2, Y: 2, Direction: "ns", Altitude: 11, StepDistance: 10, CumulativeDistance: 51},
+				{Step: 8, X: 2, Y: 1, Direction: "--", Altitude: 11, StepDistance: 0, CumulativeDistance: 51},
+				{Step: 9, X: 2, Y: 1, Direction: "ns", Altitude: 11, StepDistance: 10, CumulativeDistance: 61},
+				{Step: 10, X: 1, Y: 1, Direction: "we", Altitude: 11, StepDistance: 10, CumulativeDistance: 71},
+				{Step: 11, X: 1, Y: 1, Direction: "vd", Altitude: 2, StepDistance: 9, CumulativeDistance: 80},
+				{Step: 12, X: 1, Y: 1, Direction: "sn", Altitude: 2, StepDistance: 10, CumulativeDistance: 90},
+				{Step: 13, X: 1, Y: 2, Direction: "--", Altitude: 2, StepDistance: 0, CumulativeDistance: 90},
+				{Step: 14, X: 1, Y: 2, Direction: "sn", Altitude: 2, StepDistance: 10, CumulativeDistance: 100},
+			},
+		},
+		{
+			name:             "Positive Flow - Auto is the shortest sweep",
+			params:           generated.GetEstateIdDronePlanParams{Sweep: sweepParam(generated.GetEstateIdDronePlanParamsSweepAuto)},
+			expectedCode:     http.StatusOK,
+			expectedDistance: 102,
+			expectedMode:     "canopy-follow",
+			expectedSweep:    generated.Sweep{Orientation: "columns", StartCorner: "sw"},
+		},
+		{
+			name: "Positive Flow - Auto comparing flight modes",
+			params: generated.GetEstateIdDronePlanParams{
+				Mode:  strParam("compare"),
+				Sweep: sweepParam(generated.GetEstateIdDronePlanParamsSweepAuto),
+			},
+			expectedCode:     http.StatusOK,
+			expectedDistance: 92,
+			expectedMode:     "fixed-altitude",
+			expectedSweep:    generated.Sweep{Orientation: "rows", StartCorner: "sw"},
+			expectedPlans: &[]generated.DronePlanSummary{
+				{Mode: "fixed-altitude", Sweep: generated.Sweep{Orientation: "rows", StartCorner: "sw"}, Distance: 92},
+				{Mode: "row-max", Sweep: generated.Sweep{Orientation: "rows", StartCorner: "sw"}, Distance: 92},
+				{Mode: "lookahead-3", Sweep: generated.Sweep{Orientation: "rows", StartCorner: "sw"}, Distance: 92},
+				{Mode: "canopy-follow", Sweep: generated.Sweep{Orientation: "columns", StartCorner: "sw"}, Distance: 102},
+			},
+		},
+		{
+			name: "Start corner with auto sweep",
+			params: generated.GetEstateIdDronePlanParams{
+				Sweep:       sweepParam(generated.GetEstateIdDronePlanParamsSweepAuto),
+				StartCorner: cornerParam(generated.GetEstateIdDronePlanParamsStartCornerNw),
+			},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "start_corner is not allowed with sweep auto",
+		},
+		{
+			name:            "Unknown sweep",
+			params:          generated.GetEstateIdDronePlanParams{Sweep: sweepParam("diagonal")},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "sweep must be rows, columns or auto",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			mockRepo.EXPECT().GetEstateByID(gomock.Any(), "valid_estate_id").Return(estate, nil).Times(1)
+			if tc.expectedCode == http.StatusOK {
+				mockRepo.EXPECT().GetAllTreesInEstate(gomock.Any(), "valid_estate_id").Return(trees, nil).Times(1)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/valid_estate_id/drone-plan", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdDronePlan(c, "valid_estate_id", tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode != http.StatusOK {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+				return
+			}
+
+			var resp generated.EstateDronePlanResponse
+			err = json.Unmarshal(rec.Body.Bytes(), &resp)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedDistance, resp.Distance)
+			require.NotNil(t, resp.Mode)
+			require.Equal(t, tc.expectedMode, *resp.Mode)
+			require.NotNil(t, resp.Sweep)
+			require.Equal(t, tc.expectedSweep, *resp.Sweep)
+			require.NotNil(t, resp.Steps)
+			if tc.expectedSteps != nil {
+				require.Equal(t, tc.expectedSteps, *resp.Steps)
+			}
+			require.Equal(t, tc.expectedRest, resp.Rest)
+			require.Equal(t, tc.expectedPlans, resp.Plans)
+		})
+	}
+}
+
+func TestGetEstateIdDronePlanDrones(t *testing.T) {
+	strParam := func(value string) *string {
+		return &value
+	}
+	intParam := func(value int) *int {
+		return &value
+	}
+	sweepParam := func(value generated.GetEstateIdDronePlanParamsSweep) *generated.GetEstateIdDronePlanParamsSweep {
+		return &value
+	}
+
+	// the first row is tall, its drone patrols fewer rows than the others
+	estate := repository.Estate{ID: "valid_estate_id", Width: 2, Length: 4, Count: 5}
+	trees := []repository.Tree{
+		{X: 1, Y: 1, Height: 30},
+		{X: 2, Y: 1, Height: 30},
+		{X: 1, Y: 2, Height: 1},
+		{X: 2, Y: 3, Height: 1},
+		{X: 1, Y: 4, Height: 1},
+	}
+
+	tests := []struct {
+		name             string
+		params           generated.GetEstateIdDronePlanParams
+		expectedCode     int
+		expectedDistance int
+		expectedSweep    generated.Sweep
+		expectedDrones   []generated.DronePlanDrone
+		expectedSteps    []int
+		expectedMessage  string
+	}{
+		{
+			name:             "Positive Flow - Strips of rows",
+			params:           generated.GetEstateIdDronePlanParams{Drones: intParam(2)},
+			expectedCode:     http.StatusOK,
+			expectedDistance: 112,
+			expectedSweep:    generated.Sweep{Orientation: "rows", StartCorner: "sw"},
+			expectedDrones: []generated.DronePlanDrone{
+				{Drone: 1, Distance: 112, Start: generated.PlotPosition{X: 1, Y: 1}, End: generated.PlotPosition{X: 1, Y: 2}},
+				{Drone: 2, Distance: 54, Start: generated.PlotPosition{X: 1, Y: 3}, End: generated.PlotPosition{X: 1, Y: 4}},
+			},
+			expectedSteps: []int{8, 7},
+		},
+		{
+			name: "Positive Flow - Strips of columns with max distance",
+			params: generated.GetEstateIdDronePlanParams{
+				Drones:      intParam(2),
+				Sweep:       sweepParam(generated.GetEstateIdDronePlanParamsSweepColumns),
+				MaxDistance: intParam(45),
+			},
+			expectedCode:     http.StatusOK,
+			expectedDistance: 102,
+			expectedSweep:    generated.Sweep{Orientation: "columns", StartCorner: "sw"},
+			expectedDrones: []generated.DronePlanDrone{
+				{Drone: 1, Distance: 102, Start: generated.PlotPosition{X: 1, Y: 1}, End: generated.PlotPosition{X: 1, Y: 4}, Rest: &generated.PlotPosition{X: 1, Y: 2}},
+				{Drone: 2, Distance: 102, Start: generated.PlotPosition{X: 2, Y: 1}, End: generated.PlotPosition{X: 2, Y: 4}, Rest: &generated.PlotPosition{X: 2, Y: 2}},
+			},
+			expectedSteps: []int{7, 6},
+		},
+		{
+			name:            "More drones than rows",
+			params:          generated.GetEstateIdDronePlanParams{Drones: intParam(5)},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "drones must be between 1 and 4, the rows of the sweep",
+		},
+		{
+			name:            "More drones than columns",
+			params:          generated.GetEstateIdDronePlanParams{Drones: intParam(3), Sweep: sweepParam(generated.GetEstateIdDronePlanParamsSweepColumns)},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "drones must be between 1 and 2, the rows of the sweep",
+		},
+		{
+			name:            "Drones comparing flight modes",
+			params:          generated.GetEstateIdDronePlanParams{Drones: intParam(2), Mode: strParam("compare")},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "drones is not allowed with mode compare, sweep auto or format",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			mockRepo.EXPECT().GetEstateByID(gomock.Any(), "valid_estate_id").Return(estate, nil).Times(1)
+			if tc.expectedCode == http.StatusOK {
+				mockRepo.EXPECT().GetAllTreesInEstate(gomock.Any(), "valid_estate_id").Return(trees, nil).Times(1)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/valid_estate_id/drone-plan", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdDronePlan(c, "valid_estate_id", tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode != http.StatusOK {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+				return
+			}
+
+			var resp generated.EstateDronePlanResponse
+			err = json.Unmarshal(rec.Body.Bytes(), &resp)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedDistance, resp.Distance)
+			require.NotNil(t, resp.Mode)
+			require.Equal(t, "canopy-follow", *resp.Mode)
+			require.NotNil(t, resp.Sweep)
+			require.Equal(t, tc.expectedSweep, *resp.Sweep)
+			require.Nil(t, resp.Steps)
+			require.NotNil(t, resp.Drones)
+			require.Len(t, *resp.Drones, len(tc.expectedDrones))
+			for idx, drone := range *resp.Drones {
+				require.Len(t, drone.Steps, tc.expectedSteps[idx])
+				drone.Steps = nil
+				require.Equal(t, tc.expectedDrones[idx], drone)
+			}
+		})
+	}
+}
+
+func TestGetEstateIdDronePlanSorties(t *testing.T) {
+	intParam := func(value int) *int {
+		return &value
+	}
+
+	estate := repository.Estate{ID: "valid_estate_id", Width: 4, Length: 2, Count: 3, PatrolDistance: 110}
+	trees := []repository.Tree{
+		{X: 1, Y: 1, Height: 4},
+		{X: 3, Y: 1, Height: 9},
+		{X: 2, Y: 2, Height: 4},
+	}
+
+	tests := []struct {
+		name            string
+		params          generated.GetEstateIdDronePlanParams
+		callGetRoute    bool
+		expectedCode    int
+		expectedSorties []generated.DronePlanSortie
+		expectedMessage string
+	}{
+		{
+			name:         "Positive Flow - Sorties of the patrol route",
+			params:       generated.GetEstateIdDronePlanParams{Battery: intParam(100)},
+			callGetRoute: true,
+			expectedCode: http.StatusOK,
+			expectedSorties: []generated.DronePlanSortie{
+				{Sortie: 1, Start: generated.PlotPosition{X: 1, Y: 1}, End: generated.PlotPosition{X: 4, Y: 1}, FirstStep: 1, LastStep: 6, Outbound: 0, Route: 50, Return: 40, Distance: 90},
+				{Sortie: 2, Start: generated.PlotPosition{X: 4, Y: 1}, End: generated.PlotPosition{X: 2, Y: 2}, FirstStep: 7, LastStep: 8, Outbound: 40, Route: 30, Return: 25, Distance: 95},
+				{Sortie: 3, Start: generated.PlotPosition{X: 2, Y: 2}, End: generated.PlotPosition{X: 1, Y: 2}, FirstStep: 9, LastStep: 11, Outbound: 25, Route: 25, Return: 25, Distance: 75},
+			},
+		},
+		{
+			name:         "Positive Flow - Home on another plot",
+			params:       generated.GetEstateIdDronePlanParams{Battery: intParam(200), HomeY: intParam(2)},
+			callGetRoute: true,
+			expectedCode: http.StatusOK,
+			expectedSorties: []generated.DronePlanSortie{
+				{Sortie: 1, Start: generated.PlotPosition{X: 1, Y: 1}, End: generated.PlotPosition{X: 1, Y: 2}, FirstStep: 1, LastStep: 11, Outbound: 30, Route: 105, Return: 5, Distance: 140},
+			},
+		},
+		{
+			name:            "Battery too small for a route step",
+			params:          generated.GetEstateIdDronePlanParams{Battery: intParam(50)},
+			callGetRoute:    true,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "battery must be at least 92 to fly every route step from home",
+		},
+		{
+			name:            "Home outside of the estate",
+			params:          generated.GetEstateIdDronePlanParams{Battery: intParam(100), HomeX: intParam(5)},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "home_x must be between 1 and 4",
+		},
+		{
+			name:            "Battery with drones",
+			params:          generated.GetEstateIdDronePlanParams{Battery: intParam(100), Drones: intParam(2)},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "battery is not allowed with drones or format",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			mockRepo.EXPECT().GetEstateByID(gomock.Any(), "valid_estate_id").Return(estate, nil).Times(1)
+			if tc.callGetRoute {
+				_, _, _, _, routeSteps, err := srv.patrol(estate, trees)
+				require.NoError(t, err)
+				mockRepo.EXPECT().GetRouteSteps(gomock.Any(), "valid_estate_id").Return(routeSteps, nil).Times(1)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/valid_estate_id/drone-plan", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdDronePlan(c, "valid_estate_id", tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode != http.StatusOK {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+				return
+			}
+
+			var resp generated.EstateDronePlanResponse
+			err = json.Unmarshal(rec.Body.Bytes(), &resp)
+			require.NoError(t, err)
+
+			require.Equal(t, estate.PatrolDistance, resp.Distance)
+			require.NotNil(t, resp.Sorties)
+			require.Equal(t, tc.expectedSorties, *resp.Sorties)
+		})
+	}
+}
+
+func TestGetEstateIdDronePlanMission(t *testing.T) {
+	floatParam := func(value float64) *float64 {
+		return &value
+	}
+	formatParam := func(format generated.GetEstateIdDronePlanParamsFormat) *generated.GetEstateIdDronePlanParamsFormat {
+		return &format
+	}
+
+	tests := []struct {
+		name                string
+		params              generated.GetEstateIdDronePlanParams
+		geoReferenced       bool
+		callRoute           bool
+		expectedCode        int
+		expectedContentType string
+		expectedDisposition string
+		expectedBodyPrefix  string
+	}{
+		{
+			name: "Positive Flow - MAVLink waypoints",
+			params: generated.GetEstateIdDronePlanParams{
+				Format:    formatParam(generated.MavlinkWaypoints),
+				OriginLat: floatParam(-6.2),
+				OriginLon: floatParam(106.8),
+			},
+			callRoute:           true,
+			expectedCode:        http.StatusOK,
+			expectedContentType: echo.MIMETextPlainCharsetUTF8,
+			expectedDisposition: `attachment; filename="estate-valid_estate_id.waypoints"`,
+			expectedBodyPrefix:  "QGC WPL 110\n0\t1\t0\t16\t0\t0\t0\t0\t-6.20000000\t106.80000000\t0\t1\n1\t0\t3\t22\t",
+		},
+		{
+			name: "Positive Flow - QGroundControl plan",
+			params: generated.GetEstateIdDronePlanParams{
+				Format:    formatParam(generated.QgcPlan),
+				OriginLat: floatParam(-6.2),
+				OriginLon: floatParam(106.8),
+				PlotSize:  floatParam(5),
+			},
+			callRoute:           true,
+			expectedCode:        http.StatusOK,
+			expectedContentType: echo.MIMEApplicationJSON,
+			expectedDisposition: `attachment; filename="estate-valid_estate_id.plan"`,
+			expectedBodyPrefix:  "{\n  \"fileType\": \"Plan\"",
+		},
+		{
+			name: "Positive Flow - Estate origin",
+			params: generated.GetEstateIdDronePlanParams{
+				Format: formatParam(generated.MavlinkWaypoints),
+			},
+			geoReferenced:       true,
+			callRoute:           true,
+			expectedCode:        http.StatusOK,
+			expectedContentType: echo.MIMETextPlainCharsetUTF8,
+			expectedDisposition: `attachment; filename="estate-valid_estate_id.waypoints"`,
+			expectedBodyPrefix:  "QGC WPL 110\n0\t1\t0\t16\t0\t0\t0\t0\t-6.10000000\t106.70000000\t0\t1\n1\t0\t3\t22\t",
+		},
+		{
+			name: "Missing origin",
+			params: generated.GetEstateIdDronePlanParams{
+				Format: formatParam(generated.QgcPlan),
+			},
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: mimeApplicationProblemJSON,
+			expectedBodyPrefix:  `{"code":"INVALID_PARAMETER","detail":"origin_lat is required, origin_lon is required"`,
+		},
+		{
+			name: "Unknown format",
+			params: generated.GetEstateIdDronePlanParams{
+				Format:    formatParam("kml"),
+				OriginLat: floatParam(-6.2),
+				OriginLon: floatParam(106.8),
+			},
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: mimeApplicationProblemJSON,
+			expectedBodyPrefix:  `{"code":"INVALID_PARAMETER","detail":"unknown format kml"`,
+		},
+		{
+			name: "Invalid plot size",
+			params: generated.GetEstateIdDronePlanParams{
+				Format:    formatParam(generated.MavlinkWaypoints),
+				OriginLat: floatParam(-6.2),
+				OriginLon: floatParam(106.8),
+				PlotSize:  floatParam(0),
+			},
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: mimeApplicationProblemJSON,
+			expectedBodyPrefix:  `{"code":"INVALID_PARAMETER","detail":"plot_size must be greater than 0"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/valid_estate_id/drone-plan", nil)
+			rec := httptest.NewRecorder()
+
+			estate := repository.Estate{
+				ID:             "valid_estate_id",
+				Width:          5,
+				Length:         1,
+				Count:          3,
+				PatrolDistance: 64,
+				PatrolRoute:    "1,1,1,ew,0,10;2,2,1,vu,6,16;3,2,1,ew,6,26;4,3,1,vd,2,28;5,3,1,ew,2,38;6,4,1,vu,1,39;7,4,1,ew,1,49;8,5,1,ew,1,59;",
+			}
+			if tc.geoReferenced {
+				estate.OriginLat = floatParam(-6.1)
+				estate.OriginLon = floatParam(106.7)
+			}
+			mockRepo.EXPECT().
+				GetEstateByID(gomock.Any(), "valid_estate_id").
+				Return(estate, nil).
+				Times(1)
+			if tc.callRoute {
+				// estate patrolled before route steps are stored on their own
+				mockRepo.EXPECT().
+					GetRouteSteps(gomock.Any(), "valid_estate_id").
+					Return([]repository.RouteStep{}, nil).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdDronePlan(c, "valid_estate_id", tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+			require.Equal(t, tc.expectedContentType, rec.Header().Get(echo.HeaderContentType))
+			require.Equal(t, tc.expectedDisposition, rec.Header().Get(echo.HeaderContentDisposition))
+			require.True(t, strings.HasPrefix(rec.Body.String(), tc.expectedBodyPrefix), rec.Body.String())
+		})
+	}
+}
+
+func TestGetEstateIdDronePlanSteps(t *testing.T) {
+	intParam := func(value int) *int {
+		return &value
+	}
+	strParam := func(value string) *string {
+		return &value
+	}
+
+	storedEstate := repository.Estate{
+		ID:             "valid_estate_id",
+		Width:          5,
+		Length:         1,
+		Count:          3,
+		PatrolDistance: 64,
+	}
+	legacyEstate := storedEstate
+	legacyEstate.PatrolRoute = "1,1,1,ew,0,10;2,2,1,vu,6,16;3,2,1,ew,6,26;4,3,1,vd,2,28;5,3,1,ew,2,38;6,4,1,vu,1,39;7,4,1,ew,1,49;8,5,1,ew,1,59;"
+
+	storedSteps := []repository.RouteStep{
+		{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 10},
+		{Step: 2, X: 2, Y: 1, Direction: "vu", StepDistance: 6, CurrentDistance: 16, Altitude: 6},
+		{Step: 3, X: 2, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 26, Altitude: 6},
+	}
+
+	tests := []struct {
+		name             string
+		id               string
+		params           generated.GetEstateIdDronePlanStepsParams
+		estate           repository.Estate
+		mockGetEstateErr error
+		callGetEstate    bool
+		callPage         bool
+		pageAfterStep    int
+		pageLimit        int
+		pageSteps        []repository.RouteStep
+		callGetRoute     bool
+		rebuildRoute     bool
+		expectedCode     int
+		expectedResp     generated.EstateDronePlanStepsResponse
+		expectedMessage  string
+	}{
+		{
+			name:          "Positive Flow - First page",
+			id:            "valid_estate_id",
+			params:        generated.GetEstateIdDronePlanStepsParams{Limit: intParam(2)},
+			estate:        storedEstate,
+			callGetEstate: true,
+			callPage:      true,
+			pageAfterStep: 0,
+			pageLimit:     3,
+			pageSteps:     storedSteps,
+			expectedCode:  http.StatusOK,
+			expectedResp: generated.EstateDronePlanStepsResponse{
+				Steps: []generated.DronePlanStep{
+					{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CumulativeDistance: 10},
+					{Step: 2, X: 2, Y: 1, Direction: "vu", StepDistance: 6, CumulativeDistance: 16, Altitude: 6},
+				},
+				NextCursor: strParam("2"),
+			},
+		},
+		{
+			name:          "Positive Flow - Last page",
+			id:            "valid_estate_id",
+			params:        generated.GetEstateIdDronePlanStepsParams{Cursor: strParam("2"), Limit: intParam(2)},
+			estate:        storedEstate,
+			callGetEstate: true,
+			callPage:      true,
+			pageAfterStep: 2,
+			pageLimit:     3,
+			pageSteps:     storedSteps[2:],
+			expectedCode:  http.StatusOK,
+			expectedResp: generated.EstateDronePlanStepsResponse{
+				Steps: []generated.DronePlanStep{
+					{Step: 3, X: 2, Y: 1, Direction: "ew", StepDistance: 10, CumulativeDistance: 26, Altitude: 6},
+				},
+			},
+		},
+		{
+			name: "Positive Flow - Legacy route within distance range",
+			id:   "valid_estate_id",
+			params: generated.GetEstateIdDronePlanStepsParams{
+				FromDistance: intParam(20),
+				ToDistance:   intParam(30),
+			},
+			estate:        legacyEstate,
+			callGetEstate: true,
+			callGetRoute:  true,
+			expectedCode:  http.StatusOK,
+			expectedResp: generated.EstateDronePlanStepsResponse{
+				Steps: []generated.DronePlanStep{
+					{Step: 3, X: 2, Y: 1, Direction: "ew", StepDistance: 10, CumulativeDistance: 26, Altitude: 6},
+					{Step: 4, X: 3, Y: 1, Direction: "vd", StepDistance: 2, CumulativeDistance: 28, Altitude: 4},
+					{Step: 5, X: 3, Y: 1, Direction: "ew", StepDistance: 10, CumulativeDistance: 38, Altitude: 4},
+				},
+			},
+		},
+		{
+			name:          "Positive Flow - Rebuild route cleared by incremental recalculation",
+			id:            "valid_estate_id",
+			params:        generated.GetEstateIdDronePlanStepsParams{Limit: intParam(1)},
+			estate:        storedEstate,
+			callGetEstate: true,
+			callPage:      true,
+			pageAfterStep: 0,
+			pageLimit:     2,
+			pageSteps:     []repository.RouteStep{},
+			callGetRoute:  true,
+			rebuildRoute:  true,
+			expectedCode:  http.StatusOK,
+			expectedResp: generated.EstateDronePlanStepsResponse{
+				Steps: []generated.DronePlanStep{
+					{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CumulativeDistance: 10},
+				},
+				NextCursor: strParam("1"),
+			},
+		},
+		{
+			name:            "Invalid cursor",
+			id:              "valid_estate_id",
+			params:          generated.GetEstateIdDronePlanStepsParams{Cursor: strParam("abc")},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "cursor is not one of a previous page",
+		},
+		{
+			name:            "Limit too big",
+			id:              "valid_estate_id",
+			params:          generated.GetEstateIdDronePlanStepsParams{Limit: intParam(1001)},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "limit must be between 1 and 1000",
+		},
+		{
+			name: "Distance range reversed",
+			id:   "valid_estate_id",
+			params: generated.GetEstateIdDronePlanStepsParams{
+				FromDistance: intParam(30),
+				ToDistance:   intParam(20),
+			},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "from_distance and to_distance must be at least 0, from_distance not after to_distance",
+		},
+		{
+			name:            "Empty ID",
+			id:              "",
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+		{
+			name:             "Repository Error",
+			id:               "valid_estate_id",
+			mockGetEstateErr: errors.New("repository error"),
+			callGetEstate:    true,
+			expectedCode:     http.StatusInternalServerError,
+			expectedMessage:  "internal server error",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/"+tc.id+"/drone-plan/steps", nil)
+			rec := httptest.NewRecorder()
+
+			if tc.callGetEstate {
+				mockRepo.EXPECT().
+					GetEstateByID(gomock.Any(), tc.id).
+					Return(tc.estate, tc.mockGetEstateErr).
+					Times(1)
+			}
+			if tc.callPage {
+				mockRepo.EXPECT().
+					GetRouteStepsPage(gomock.Any(), tc.id, tc.pageAfterStep, 0, math.MaxInt32, tc.pageLimit).
+					Return(tc.pageSteps, nil).
+					Times(1)
+			}
+			if tc.callGetRoute {
+				mockRepo.EXPECT().
+					GetRouteSteps(gomock.Any(), tc.id).
+					Return([]repository.RouteStep{}, nil).
+					Times(1)
+			}
+			if tc.rebuildRoute {
+				// the trees and the route are read again under the lock, only the route is stored
+				expectEstateLock(mockRepo, tc.estate)
+				mockRepo.EXPECT().
+					GetAllTreesInEstate(gomock.Any(), tc.id).
+					Return([]repository.Tree{
+						{X: 2, Y: 1, Height: 5},
+						{X: 3, Y: 1, Height: 3},
+						{X: 4, Y: 1, Height: 4},
+					}, nil).
+					Times(1)
+				mockRepo.EXPECT().
+					GetRouteSteps(gomock.Any(), tc.id).
+					Return([]repository.RouteStep{}, nil).
+					Times(1)
+				mockRepo.EXPECT().
+					UpdateRouteSteps(gomock.Any(), tc.id, gomock.Len(8)).
+					Return(nil).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdDronePlanSteps(c, tc.id, tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				var resp generated.EstateDronePlanStepsResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedResp, resp)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestPostEstateIdTreesBatch(t *testing.T) {
+	estate := repository.Estate{
+		ID:     "valid_estate_id",
+		Width:  5,
+		Length: 1,
+	}
+
+	tests := []struct {
+		name              string
+		id                string
+		contentType       string
+		body              string
+		mockGetEstateErr  error
+		callGetAllTrees   bool
+		plantedTrees      []repository.Tree
+		callInsertTrees   bool
+		mockInsertErr     error
+		callEnqueue       bool
+		mockEnqueueErr    error
+		expectedCode      int
+		expectedIDs       []string
+		expectedProblem   generated.ProblemCode
+		expectedDetail    string
+		expectedRowErrors []generated.TreeRowError
+	}{
+		{
+			name:            "Positive Flow - CSV",
+			id:              "valid_estate_id",
+			contentType:     "text/csv",
+			body:            "x,y,height\n2,1,5\n3,1,3\n",
+			callGetAllTrees: true,
+			callInsertTrees: true,
+			callEnqueue:     true,
+			expectedCode:    http.StatusCreated,
+			expectedIDs:     []string{"tree_id_1", "tree_id_2"},
+		},
+		{
+			name:            "Enqueue error",
+			id:              "valid_estate_id",
+			contentType:     "text/csv",
+			body:            "x,y,height\n2,1,5\n3,1,3\n",
+			callGetAllTrees: true,
+			callInsertTrees: true,
+			callEnqueue:     true,
+			mockEnqueueErr:  errors.New("repository error"),
+			expectedCode:    http.StatusInternalServerError,
+			expectedIDs:     []string{"tree_id_1", "tree_id_2"},
+		},
+		{
+			name:            "Invalid rows",
+			id:              "valid_estate_id",
+			contentType:     echo.MIMEApplicationJSON,
+			body:            `[{"x": 4, "y": 1, "height": 4}, {"x": 9, "y": 1, "height": 4}, {"x": 2, "y": 1, "height": 3}]`,
+			callGetAllTrees: true,
+			plantedTrees:    []repository.Tree{{ID: "tree_id_0", X: 2, Y: 1, Height: 5}},
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeValidationFailed,
+			expectedDetail:  "2 of 3 rows are invalid",
+			expectedRowErrors: []generated.TreeRowError{
+				{Row: 2, Field: rowField("x"), Code: generated.ProblemCodeTreeOutOfBounds, Message: "out of bounds: x must be between 1 and 5"},
+				{Row: 3, Code: generated.ProblemCodePlotOccupied, Message: "duplicate plot: plot (2,1) already has a tree"},
+			},
+		},
+		{
+			// the malformed row is not parsed, the out of bounds one is, both count once
+			name:            "Invalid and malformed rows",
+			id:              "valid_estate_id",
+			contentType:     "text/csv",
+			body:            "x,y,height\n2,1,5\nten,1,3\n9,1,4\n",
+			callGetAllTrees: true,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeValidationFailed,
+			expectedDetail:  "2 of 3 rows are invalid",
+			expectedRowErrors: []generated.TreeRowError{
+				{Row: 2, Code: generated.ProblemCodeInvalidBody, Message: msgRowInvalidFormat},
+				{Row: 3, Field: rowField("x"), Code: generated.ProblemCodeTreeOutOfBounds, Message: "out of bounds: x must be between 1 and 5"},
+			},
+		},
+		{
+			name:              "Empty payload",
+			id:                "valid_estate_id",
+			contentType:       echo.MIMEApplicationJSON,
+			body:              `[]`,
+			expectedCode:      http.StatusBadRequest,
+			expectedProblem:   generated.ProblemCodeInvalidBody,
+			expectedRowErrors: []generated.TreeRowError{},
+		},
+		{
+			name:              "Too many trees",
+			id:                "valid_estate_id",
+			contentType:       "text/csv",
+			body:              "x,y,height\n" + strings.Repeat("2,1,5\n", maxTreesPerBatch+1),
+			expectedCode:      http.StatusBadRequest,
+			expectedProblem:   generated.ProblemCodeInvalidBody,
+			expectedDetail:    "too many trees in payload, max 10000",
+			expectedRowErrors: []generated.TreeRowError{},
+		},
+		{
+			name:              "Payload too large",
+			id:                "valid_estate_id",
+			contentType:       echo.MIMEApplicationJSON,
+			body:              `[{"x": 2, "y": 1, "height": 5, "note": "` + strings.Repeat("a", maxBatchBytes) + `"}]`,
+			expectedCode:      http.StatusBadRequest,
+			expectedProblem:   generated.ProblemCodeInvalidBody,
+			expectedDetail:    "payload too large, max 10240000 bytes",
+			expectedRowErrors: []generated.TreeRowError{},
+		},
+		{
+			name:              "Unsupported content type",
+			id:                "valid_estate_id",
+			contentType:       echo.MIMEApplicationXML,
+			body:              `<trees></trees>`,
+			expectedCode:      http.StatusUnsupportedMediaType,
+			expectedProblem:   generated.ProblemCodeUnsupportedMediaType,
+			expectedRowErrors: []generated.TreeRowError{},
+		},
+		{
+			name:            "Insert error",
+			id:              "valid_estate_id",
+			contentType:     "application/x-ndjson",
+			body:            "{\"x\": 2, \"y\": 1, \"height\": 5}\n{\"x\": 3, \"y\": 1, \"height\": 3}\n",
+			callGetAllTrees: true,
+			callInsertTrees: true,
+			mockInsertErr:   fmt.Errorf("%w: tree already exists at the specified location (2,1)", repository.ErrConflict),
+			expectedCode:    http.StatusConflict,
+		},
+		{
+			name:             "Estate not found",
+			id:               "invalid_estate_id",
+			contentType:      "text/csv",
+			body:             "x,y,height\n2,1,5\n",
+			mockGetEstateErr: repository.ErrNotFound,
+			expectedCode:     http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/estate/"+tc.id+"/trees:batch", bytes.NewBufferString(tc.body))
+			req.Header.Set(echo.HeaderContentType, tc.contentType)
+			rec := httptest.NewRecorder()
+
+			mockRepo.EXPECT().
+				GetEstateByID(gomock.Any(), tc.id).
+				Return(estate, tc.mockGetEstateErr).
+				Times(1)
+
+			if tc.callGetAllTrees {
+				expectEstateLock(mockRepo, estate)
+				mockRepo.EXPECT().
+					GetAllTreesInEstate(gomock.Any(), tc.id).
+					Return(tc.plantedTrees, nil).
+					Times(1)
+			}
+
+			if tc.callInsertTrees {
+				mockRepo.EXPECT().
+					InsertTrees(gomock.Any(), tc.id, gomock.Len(2)).
+					Return(tc.expectedIDs, tc.mockInsertErr).
+					Times(1)
+			}
+
+			if tc.callEnqueue {
+				mockRepo.EXPECT().
+					EnqueueRecalculation(gomock.Any(), tc.id).
+					Return(tc.mockEnqueueErr).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.PostEstateIdTreesBatch(c, tc.id)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			switch tc.expectedCode {
+			case http.StatusCreated:
+				var resp generated.CreateTreesBatchResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedIDs, resp.Ids)
+			case http.StatusBadRequest:
+				var respErr generated.CreateTreesBatchErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, mimeApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+				require.Equal(t, tc.expectedProblem, respErr.Code)
+				if tc.expectedDetail != "" {
+					require.Equal(t, tc.expectedDetail, respErr.Detail)
+				}
+				require.Equal(t, tc.expectedRowErrors, respErr.Errors)
+			}
+		})
+	}
+}
+
+func TestPostEstateIdTree(t *testing.T) {
+	estate := repository.Estate{
+		ID:             "valid_estate_id",
+		Width:          5,
+		Length:         1,
+		Count:          1,
+		Min:            5,
+		Max:            5,
+		Median:         5,
+		PatrolDistance: 62,
+		StatsStatus:    repository.StatsStatusFresh,
+	}
+	pendingEstate := estate
+	pendingEstate.StatsStatus = repository.StatsStatusPending
+	prevTree := repository.Tree{ID: "prev_tree_id", EstateID: "valid_estate_id", X: 2, Y: 1, Height: 5}
+
+	tests := []struct {
+		name              string
+		payload           string
+		readEstate        *repository.Estate
+		mockGetEstateErr  error
+		lockedEstate      *repository.Estate
+		callInsertTree    bool
+		mockInsertTreeErr error
+		callCalculate     bool
+		mockCalculateErr  error
+		callEnqueue       bool
+		expectedCode      int
+		expectedTreeID    string
+		expectedMessage   string
+		expectedProblem   generated.ProblemCode
+		expectedFields    []string
+	}{
+		{
+			// the stats are kept fresh right away, no recalculation is enqueued
+			name:           "Positive Flow",
+			payload:        `{"x": 3, "y": 1, "height": 8}`,
+			callInsertTree: true,
+			callCalculate:  true,
+			expectedCode:   http.StatusCreated,
+			expectedTreeID: "mocked_tree_id",
+		},
+		{
+			name:             "Calculation failed, tree stored anyway",
+			payload:          `{"x": 3, "y": 1, "height": 8}`,
+			callInsertTree:   true,
+			callCalculate:    true,
+			mockCalculateErr: errors.New("repository error"),
+			callEnqueue:      true,
+			expectedCode:     http.StatusCreated,
+			expectedTreeID:   "mocked_tree_id",
+		},
+		{
+			name:           "Stats pending, recalculated in background",
+			payload:        `{"x": 3, "y": 1, "height": 8}`,
+			lockedEstate:   &pendingEstate,
+			callInsertTree: true,
+			callEnqueue:    true,
+			expectedCode:   http.StatusCreated,
+			expectedTreeID: "mocked_tree_id",
+		},
+		{
+			name:              "Insert failed",
+			payload:           `{"x": 3, "y": 1, "height": 8}`,
+			callInsertTree:    true,
+			mockInsertTreeErr: fmt.Errorf("%w: tree already exists at the specified location", repository.ErrConflict),
+			expectedCode:      http.StatusConflict,
+			expectedMessage:   msgPlotTaken,
+			expectedProblem:   generated.ProblemCodePlotOccupied,
+		},
+		{
+			name:            "Estate shrunk meanwhile",
+			payload:         `{"x": 3, "y": 1, "height": 8}`,
+			lockedEstate:    &repository.Estate{ID: "valid_estate_id", Width: 2, Length: 1},
+			expectedCode:    http.StatusConflict,
+			expectedMessage: msgPlotOutside,
+			expectedProblem: generated.ProblemCodeTreeOutOfBounds,
+		},
+		{
+			name:            "Estate reshaped meanwhile",
+			payload:         `{"x": 3, "y": 1, "height": 8}`,
+			lockedEstate:    &repository.Estate{ID: "valid_estate_id", Width: 5, Length: 1, Boundary: []repository.Plot{{X: 1, Y: 1}, {X: 2, Y: 1}, {X: 1, Y: 1}}},
+			expectedCode:    http.StatusConflict,
+			expectedMessage: msgPlotOutside,
+			expectedProblem: generated.ProblemCodeTreeOutOfBounds,
+		},
+		{
+			name:            "Tree outside of the estate boundary",
+			payload:         `{"x": 3, "y": 1, "height": 8}`,
+			readEstate:      &repository.Estate{ID: "valid_estate_id", Width: 5, Length: 1, Boundary: []repository.Plot{{X: 1, Y: 1}, {X: 2, Y: 1}, {X: 1, Y: 1}}},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "plot (3,1) is outside of the estate boundary",
+			expectedProblem: generated.ProblemCodeTreeOutOfBounds,
+			expectedFields:  []string{"x"},
+		},
+		{
+			name:            "Tree outside of the estate",
+			payload:         `{"x": 3, "y": 2, "height": 8}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "y must be between 1 and 1",
+			expectedProblem: generated.ProblemCodeTreeOutOfBounds,
+			expectedFields:  []string{"y"},
+		},
+		{
+			name:            "Several fields invalid",
+			payload:         `{"x": 6, "y": 2, "height": 8}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "x must be between 1 and 5, y must be between 1 and 1",
+			expectedProblem: generated.ProblemCodeTreeOutOfBounds,
+			expectedFields:  []string{"x", "y"},
+		},
+		{
+			name:            "Invalid payload",
+			payload:         `{"x": "3"}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "payload is not a valid tree",
+			expectedProblem: generated.ProblemCodeInvalidBody,
+		},
+		{
+			name:             "Estate not found",
+			payload:          `{"x": 3, "y": 1, "height": 8}`,
+			mockGetEstateErr: repository.ErrNotFound,
+			expectedCode:     http.StatusNotFound,
+			expectedMessage:  "resource not found",
+			expectedProblem:  generated.ProblemCodeResourceNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/estate/valid_estate_id/tree", bytes.NewBufferString(tc.payload))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			readEstate := estate
+			if tc.readEstate != nil {
+				readEstate = *tc.readEstate
+			}
+			mockRepo.EXPECT().
+				GetEstateByID(gomock.Any(), "valid_estate_id").
+				Return(readEstate, tc.mockGetEstateErr).
+				Times(1)
+			lockedEstate := estate
+			if tc.lockedEstate != nil {
+				lockedEstate = *tc.lockedEstate
+			}
+			if tc.lockedEstate != nil || tc.callInsertTree {
+				expectEstateLock(mockRepo, lockedEstate)
+			}
+			if tc.callInsertTree {
+				mockRepo.EXPECT().
+					InsertTree(gomock.Any(), "valid_estate_id", 3, 1, 8).
+					Return("mocked_tree_id", tc.mockInsertTreeErr).
+					Times(1)
+			}
+			if tc.mockCalculateErr != nil {
+				// the tree is stored again along with the recalculation job
+				expectEstateLock(mockRepo, estate)
+				mockRepo.EXPECT().
+					InsertTree(gomock.Any(), "valid_estate_id", 3, 1, 8).
+					Return("mocked_tree_id", nil).
+					Times(1)
+			}
+			if tc.callEnqueue {
+				mockRepo.EXPECT().EnqueueRecalculation(gomock.Any(), "valid_estate_id").Return(nil).Times(1)
+			}
+			if tc.callCalculate {
+				mockRepo.EXPECT().GetEstateByID(gomock.Any(), "valid_estate_id").Return(estate, nil).Times(1)
+				mockRepo.EXPECT().GetPatrolNeighbourTrees(gomock.Any(), "valid_estate_id", 3, 1).Return(&prevTree, nil, nil).Times(1)
+				mockRepo.EXPECT().GetTreeHeightCounts(gomock.Any(), "valid_estate_id").Return(map[int]int{5: 1, 8: 1}, nil).Times(1)
+				// the drone climbs 3 meters more over the new tree, and lands from there
+				mockRepo.EXPECT().
+					UpdateEstate(gomock.Any(), "valid_estate_id", 2, 5, 8, 6, 68, nil).
+					Return(tc.mockCalculateErr).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.PostEstateIdTree(c, "valid_estate_id")
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusCreated {
+				var resp generated.CreateTreeResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedTreeID, resp.Id)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+				require.Equal(t, tc.expectedMessage, respErr.Detail)
+				require.Equal(t, tc.expectedCode, respErr.Status)
+				require.Equal(t, mimeApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+				if tc.expectedProblem != "" {
+					require.Equal(t, tc.expectedProblem, respErr.Code)
+				}
+
+				fields := make([]string, 0)
+				if respErr.Errors != nil {
+					for _, fieldErr := range *respErr.Errors {
+						fields = append(fields, fieldErr.Field)
+					}
+				}
+				require.ElementsMatch(t, tc.expectedFields, fields)
+			}
+		})
+	}
+}
+
+// expectWithTx let the handler run its transaction on the mock repository itself
+func expectWithTx(mockRepo *repository.MockRepositorier) {
+	mockRepo.EXPECT().
+		WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(repo repository.Repositorier) error) error {
+			return fn(mockRepo)
+		}).
+		Times(1)
+}
+
+// expectEstateLock let the handler lock the estate in its transaction on the mock repository
+func expectEstateLock(mockRepo *repository.MockRepositorier, estate repository.Estate) {
+	expectWithTx(mockRepo)
+	mockRepo.EXPECT().
+		LockEstate(gomock.Any(), estate.ID).
+		Return(estate, nil).
+		Times(1)
+}
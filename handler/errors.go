@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+// the estate changed meanwhile, another tree is planted on the plot
+const msgPlotTaken = "plot already has a tree"
+
+// the estate changed meanwhile, it is resized or reshaped without the plot
+const msgPlotOutside = "plot is outside of the resized or reshaped estate"
+
+// errPlotOutside is the conflict of a tree planted on a plot the estate no longer has
+var errPlotOutside = errors.New("plot is outside of the estate")
+
+// content type of the error bodies, see RFC 7807
+const mimeApplicationProblemJSON = "application/problem+json"
+
+// problemTitles is the short summary of every problem code, it does not change between
+// occurrences of the problem, the detail tells what went wrong this time
+var problemTitles = map[generated.ProblemCode]string{
+	generated.ProblemCodeInvalidBody:            "Invalid body",
+	generated.ProblemCodeInvalidParameter:       "Invalid parameter",
+	generated.ProblemCodeValidationFailed:       "Validation failed",
+	generated.ProblemCodeOutOfRange:             "Value out of range",
+	generated.ProblemCodeTreeOutOfBounds:        "Tree out of bounds",
+	generated.ProblemCodeZoneOutOfBounds:        "Zone out of bounds",
+	generated.ProblemCodePlotOccupied:           "Plot occupied",
+	generated.ProblemCodeTreesOutsideEstate:     "Trees outside of the estate",
+	generated.ProblemCodeEstateHasTrees:         "Estate has trees",
+	generated.ProblemCodeEstateNotGeoreferenced: "Estate not georeferenced",
+	generated.ProblemCodeTileOutOfBounds:        "Tile out of bounds",
+	generated.ProblemCodeUnsupportedMediaType:   "Unsupported media type",
+	generated.ProblemCodeResourceNotFound:       "Resource not found",
+	generated.ProblemCodeConflict:               "Conflict",
+	generated.ProblemCodeUnprocessableValue:     "Unprocessable value",
+	generated.ProblemCodeInternalError:          "Internal server error",
+	generated.ProblemCodeServiceUnavailable:     "Service unavailable",
+}
+
+// problemType return the URI reference of the problem type of code,
+// e.g. /problems/tree-out-of-bounds for TREE_OUT_OF_BOUNDS
+func problemType(code generated.ProblemCode) string {
+	return "/problems/" + strings.ReplaceAll(strings.ToLower(string(code)), "_", "-")
+}
+
+// newProblem return the problem details of code, detail tells what went wrong this time.
+// message is kept the same as detail for the clients reading the former error body.
+func newProblem(status int, code generated.ProblemCode, detail string) generated.ErrorResponse {
+	return generated.ErrorResponse{
+		Type:    problemType(code),
+		Title:   problemTitles[code],
+		Status:  status,
+		Detail:  detail,
+		Code:    code,
+		Message: detail,
+	}
+}
+
+// fieldError tells the field (or query parameter) at fault for the client to highlight it
+func fieldError(field string, code generated.ProblemCode, message string) generated.FieldError {
+	return generated.FieldError{
+		Field:   field,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// respondProblem respond with body as application/problem+json, body is any of the
+// problem details of api.yml
+func respondProblem(ectx echo.Context, status int, body interface{}) error {
+	// echo keeps the content type when it is already set
+	ectx.Response().Header().Set(echo.HeaderContentType, mimeApplicationProblemJSON)
+	return ectx.JSON(status, body)
+}
+
+// respondBadRequest respond with 400 for a request that can not be read or makes no sense
+func respondBadRequest(ectx echo.Context, code generated.ProblemCode, detail string) error {
+	return respondProblem(ectx, http.StatusBadRequest, newProblem(http.StatusBadRequest, code, detail))
+}
+
+// respondInvalidFields respond with 400 for the fields at fault. The problem has the code
+// of the fields when all of them share it, e.g. TREE_OUT_OF_BOUNDS for x and y.
+func respondInvalidFields(ectx echo.Context, fieldErrs []generated.FieldError) error {
+	code := fieldErrs[0].Code
+	messages := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		if fieldErr.Code != code {
+			code = generated.ProblemCodeValidationFailed
+		}
+		messages = append(messages, fieldErr.Message)
+	}
+
+	problem := newProblem(http.StatusBadRequest, code, strings.Join(messages, ", "))
+	problem.Errors = &fieldErrs
+	return respondProblem(ectx, http.StatusBadRequest, problem)
+}
+
+// respondNotFound respond with 404 for the estate (or tree) that does not exist
+func respondNotFound(ectx echo.Context) error {
+	return respondProblem(ectx, http.StatusNotFound, newProblem(http.StatusNotFound, generated.ProblemCodeResourceNotFound, "resource not found"))
+}
+
+// respondConflict respond with 409 for a request that conflicts with the current state of the estate
+func respondConflict(ectx echo.Context, code generated.ProblemCode, detail string) error {
+	return respondProblem(ectx, http.StatusConflict, newProblem(http.StatusConflict, code, detail))
+}
+
+// errorStatus return the http status code for the error of the repository layer,
+// anything else is an internal server error.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, repository.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondError respond with the problem of the error, see respondErrorAs
+func respondError(ectx echo.Context, err error) error {
+	return respondErrorAs(ectx, err, generated.ProblemCodeConflict, "request conflicts with the current state of the estate")
+}
+
+// respondErrorAs respond with the problem of the error, conflicts are told as code with
+// detail. Not found, invalid values and server side errors get a problem of their own,
+// server side details are only logged.
+func respondErrorAs(ectx echo.Context, err error, code generated.ProblemCode, detail string) error {
+	status := errorStatus(err)
+	switch status {
+	case http.StatusNotFound:
+		return respondNotFound(ectx)
+	case http.StatusConflict:
+		return respondConflict(ectx, code, detail)
+	case http.StatusUnprocessableEntity:
+		code, detail = generated.ProblemCodeUnprocessableValue, "a value can not be stored"
+	case http.StatusServiceUnavailable:
+		code, detail = generated.ProblemCodeServiceUnavailable, "service unavailable, try again later"
+	default:
+		code, detail = generated.ProblemCodeInternalError, "internal server error"
+	}
+
+	return respondProblem(ectx, status, newProblem(status, code, detail))
+}
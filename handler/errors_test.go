@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespondErrorAs(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		expectedCode    int
+		expectedProblem generated.ErrorResponse
+	}{
+		{
+			name:         "Not found",
+			err:          fmt.Errorf("%w: sql: no rows in result set", repository.ErrNotFound),
+			expectedCode: http.StatusNotFound,
+			expectedProblem: generated.ErrorResponse{
+				Type:    "/problems/resource-not-found",
+				Title:   "Resource not found",
+				Status:  http.StatusNotFound,
+				Detail:  "resource not found",
+				Code:    generated.ProblemCodeResourceNotFound,
+				Message: "resource not found",
+			},
+		},
+		{
+			name:         "Conflict",
+			err:          fmt.Errorf("%w: plot (2,1) is outside of the estate", repository.ErrConflict),
+			expectedCode: http.StatusConflict,
+			expectedProblem: generated.ErrorResponse{
+				Type:    "/problems/plot-occupied",
+				Title:   "Plot occupied",
+				Status:  http.StatusConflict,
+				Detail:  msgPlotTaken,
+				Code:    generated.ProblemCodePlotOccupied,
+				Message: msgPlotTaken,
+			},
+		},
+		{
+			name:         "Invalid",
+			err:          fmt.Errorf("%w: numeric value out of range", repository.ErrInvalid),
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedProblem: generated.ErrorResponse{
+				Type:    "/problems/unprocessable-value",
+				Title:   "Unprocessable value",
+				Status:  http.StatusUnprocessableEntity,
+				Detail:  "a value can not be stored",
+				Code:    generated.ProblemCodeUnprocessableValue,
+				Message: "a value can not be stored",
+			},
+		},
+		{
+			name:         "Unavailable",
+			err:          fmt.Errorf("%w: too many connections", repository.ErrUnavailable),
+			expectedCode: http.StatusServiceUnavailable,
+			expectedProblem: generated.ErrorResponse{
+				Type:    "/problems/service-unavailable",
+				Title:   "Service unavailable",
+				Status:  http.StatusServiceUnavailable,
+				Detail:  "service unavailable, try again later",
+				Code:    generated.ProblemCodeServiceUnavailable,
+				Message: "service unavailable, try again later",
+			},
+		},
+		{
+			name:         "Internal",
+			err:          errors.New("patrol failed"),
+			expectedCode: http.StatusInternalServerError,
+			expectedProblem: generated.ErrorResponse{
+				Type:    "/problems/internal-error",
+				Title:   "Internal server error",
+				Status:  http.StatusInternalServerError,
+				Detail:  "internal server error",
+				Code:    generated.ProblemCodeInternalError,
+				Message: "internal server error",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+			err := respondErrorAs(c, tc.err, generated.ProblemCodePlotOccupied, msgPlotTaken)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+			require.Equal(t, mimeApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+			var problem generated.ErrorResponse
+			err = json.Unmarshal(rec.Body.Bytes(), &problem)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedProblem, problem)
+		})
+	}
+}
+
+func TestRespondInvalidFields(t *testing.T) {
+	tests := []struct {
+		name           string
+		fieldErrs      []generated.FieldError
+		expectedCode   generated.ProblemCode
+		expectedDetail string
+	}{
+		{
+			name: "Single field",
+			fieldErrs: []generated.FieldError{
+				fieldError("x", generated.ProblemCodeTreeOutOfBounds, "x must be between 1 and 5"),
+			},
+			expectedCode:   generated.ProblemCodeTreeOutOfBounds,
+			expectedDetail: "x must be between 1 and 5",
+		},
+		{
+			name: "Fields sharing the code",
+			fieldErrs: []generated.FieldError{
+				fieldError("x", generated.ProblemCodeTreeOutOfBounds, "x must be between 1 and 5"),
+				fieldError("y", generated.ProblemCodeTreeOutOfBounds, "y must be between 1 and 1"),
+			},
+			expectedCode:   generated.ProblemCodeTreeOutOfBounds,
+			expectedDetail: "x must be between 1 and 5, y must be between 1 and 1",
+		},
+		{
+			name: "Fields of different codes",
+			fieldErrs: []generated.FieldError{
+				fieldError("x", generated.ProblemCodeTreeOutOfBounds, "x must be between 1 and 5"),
+				fieldError("height", generated.ProblemCodeOutOfRange, "height must be between 1 and 30"),
+			},
+			expectedCode:   generated.ProblemCodeValidationFailed,
+			expectedDetail: "x must be between 1 and 5, height must be between 1 and 30",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+			err := respondInvalidFields(c, tc.fieldErrs)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			require.Equal(t, mimeApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+			var problem generated.ErrorResponse
+			err = json.Unmarshal(rec.Body.Bytes(), &problem)
+			require.NoError(t, err)
+
+			require.Equal(t, http.StatusBadRequest, problem.Status)
+			require.Equal(t, tc.expectedCode, problem.Code)
+			require.Equal(t, tc.expectedDetail, problem.Detail)
+			require.NotNil(t, problem.Errors)
+			require.Equal(t, tc.fieldErrs, *problem.Errors)
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+const estatesPageLimitDefault = 100
+
+// page limits are the ones of api.yml, like the tree height
+var estatesPageLimitMin, estatesPageLimitMax = specParamIntRange("/estate", http.MethodGet, "limit")
+
+var (
+	errTreesOutsideEstate = errors.New("trees outside of the estate")
+	errInvalidEstate      = errors.New("invalid estate")
+)
+
+func (srv *Server) GetEstate(ectx echo.Context, params generated.GetEstateParams) error {
+	// cursor is the creation time and id of the last estate of the previous page
+	var after *repository.Estate
+	if params.Cursor != nil {
+		strCreatedAt, estateID, found := strings.Cut(*params.Cursor, ",")
+		createdAt, err := time.Parse(time.RFC3339Nano, strCreatedAt)
+		if !found || err != nil || len(estateID) == 0 {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[ListEstates] invalid param cursor: %s", *params.Cursor)
+			return respondInvalidFields(ectx, []generated.FieldError{
+				fieldError("cursor", generated.ProblemCodeInvalidParameter, "cursor is not one of a previous page"),
+			})
+		}
+		after = &repository.Estate{ID: estateID, CreatedAt: createdAt}
+	}
+
+	limit := estatesPageLimitDefault
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	// validation
+	fieldErrs := make([]generated.FieldError, 0)
+	if limit < estatesPageLimitMin || limit > estatesPageLimitMax {
+		fieldErrs = append(fieldErrs, fieldError("limit", generated.ProblemCodeInvalidParameter, fmt.Sprintf("limit must be between %d and %d", estatesPageLimitMin, estatesPageLimitMax)))
+	}
+	if params.Sort != nil && *params.Sort != generated.CreatedAt && *params.Sort != generated.MinusCreatedAt {
+		fieldErrs = append(fieldErrs, fieldError("sort", generated.ProblemCodeInvalidParameter, "sort must be created_at or -created_at"))
+	}
+	if len(fieldErrs) > 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[ListEstates] invalid params:%+v", fieldErrs)
+		return respondInvalidFields(ectx, fieldErrs)
+	}
+
+	newestFirst := params.Sort != nil && *params.Sort == generated.MinusCreatedAt
+
+	// one more estate than asked tells whether there is a next page
+	estates, err := srv.repository.GetEstatesPage(ectx.Request().Context(), after, newestFirst, limit+1)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[ListEstates] failed to read estates, err:%s", err)
+		return respondError(ectx, err)
+	}
+
+	var resp generated.EstatesResponse
+	if len(estates) > limit {
+		estates = estates[:limit]
+		last := estates[limit-1]
+		nextCursor := last.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + last.ID
+		resp.NextCursor = &nextCursor
+	}
+
+	resp.Estates = make([]generated.Estate, 0, len(estates))
+	for _, estate := range estates {
+		resp.Estates = append(resp.Estates, estateResponse(estate))
+	}
+
+	return ectx.JSON(http.StatusOK, resp)
+}
+
+func (srv *Server) GetEstateId(ectx echo.Context, id string) error {
+	// id is estateID
+	if len(id) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[GetEstate] param estate_id not passed")
+		return respondNotFound(ectx)
+	}
+
+	estate, err := srv.repository.GetEstateByID(
+		ectx.Request().Context(),
+		id,
+	)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[GetEstate] failed retrieve information estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	return ectx.JSON(http.StatusOK, estateResponse(estate))
+}
+
+func (srv *Server) PatchEstateId(ectx echo.Context, id string, params generated.PatchEstateIdParams) error {
+	var payload generated.UpdateEstateRequestBody
+
+	// id is estateID
+	if len(id) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[UpdateEstate] param estate_id not passed")
+		return respondNotFound(ectx)
+	}
+
+	_, err := srv.repository.GetEstateByID(
+		ectx.Request().Context(),
+		id,
+	)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[UpdateEstate] failed retrieve information estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	defer ectx.Request().Body.Close()
+	err = ectx.Bind(&payload)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[UpdateEstate] failed read payload, err:%s", err)
+		return respondBadRequest(ectx, generated.ProblemCodeInvalidBody, "payload is not a valid estate update")
+	}
+
+	// the payload is in the limits of api.yml (see Validator), the changes apply
+	// on the estate as it is once every change before is done
+	var updatedEstate repository.Estate
+	var orphanTrees int
+	var fieldErrs []generated.FieldError
+	err = srv.withEstateLock(ectx.Request().Context(), id, func(txSrv *Server, estate repository.Estate) error {
+		updatedEstate = estate
+		updatedEstate.Drone = applyDroneProfile(estateDrone(estate), payload.Drone)
+		fieldErrs = droneFieldErrors(updatedEstate.Drone)
+
+		if payload.Width != nil {
+			updatedEstate.Width = *payload.Width
+		}
+		if payload.Length != nil {
+			updatedEstate.Length = *payload.Length
+		}
+
+		// the boundary kept must still fit in the estate resized
+		switch {
+		case payload.Boundary != nil:
+			var boundaryErrs []generated.FieldError
+			updatedEstate.Boundary, boundaryErrs = estateBoundary(updatedEstate.Width, updatedEstate.Length, *payload.Boundary)
+			fieldErrs = append(fieldErrs, boundaryErrs...)
+		case !boundaryInside(updatedEstate.Width, updatedEstate.Length, updatedEstate.Boundary):
+			fieldErrs = append(fieldErrs, fieldError("boundary", generated.ProblemCodeOutOfRange,
+				fmt.Sprintf("the boundary must be inside the estate, columns 1 to %d and rows 1 to %d, change it along", updatedEstate.Width, updatedEstate.Length)))
+		}
+		if len(fieldErrs) > 0 {
+			return errInvalidEstate
+		}
+		if payload.Origin != nil {
+			updatedEstate.OriginLat = &payload.Origin.Latitude
+			updatedEstate.OriginLon = &payload.Origin.Longitude
+		}
+		if payload.Bearing != nil {
+			updatedEstate.Bearing = *payload.Bearing
+		}
+
+		// the patrol route is on plots, moving or turning the estate leaves it as is
+		// unlike another drone patrolling the same plots with other distances and altitudes,
+		// or another shape. The trees out of a new boundary are kept, they are not patrolled.
+		resized := updatedEstate.Width != estate.Width || updatedEstate.Length != estate.Length
+		reshaped := !slices.Equal(updatedEstate.Boundary, estate.Boundary)
+		if !resized && !reshaped && updatedEstate.Drone == estateDrone(estate) {
+			err := txSrv.repository.UpdateEstateLayout(ectx.Request().Context(), updatedEstate)
+			if err != nil {
+				// TODO: change log level according log level company guideline (info, error, etc)
+				ectx.Logger().Errorf("[UpdateEstate] failed to update estate_id:%s, err:%s", id, err)
+			}
+
+			return err
+		}
+
+		if resized {
+			trees, err := txSrv.repository.GetAllTreesInEstate(ectx.Request().Context(), id)
+			if err != nil {
+				// TODO: change log level according log level company guideline (info, error, etc)
+				ectx.Logger().Errorf("[UpdateEstate] failed to read trees estate_id:%s, err:%s", id, err)
+				return err
+			}
+
+			keptTrees := make([]repository.Tree, 0, len(trees))
+			for _, tree := range trees {
+				if tree.X <= updatedEstate.Width && tree.Y <= updatedEstate.Length {
+					keptTrees = append(keptTrees, tree)
+				}
+			}
+
+			orphanTrees = len(trees) - len(keptTrees)
+			if orphanTrees > 0 && (params.Force == nil || !*params.Force) {
+				return errTreesOutsideEstate
+			}
+		}
+
+		err := txSrv.repository.UpdateEstateLayout(ectx.Request().Context(), updatedEstate)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[UpdateEstate] failed to update estate_id:%s, err:%s", id, err)
+			return err
+		}
+
+		if orphanTrees > 0 {
+			err = txSrv.repository.DeleteTreesOutside(ectx.Request().Context(), id, updatedEstate.Width, updatedEstate.Length)
+			if err != nil {
+				// TODO: change log level according log level company guideline (info, error, etc)
+				ectx.Logger().Errorf("[UpdateEstate] failed to delete trees outside estate_id:%s, err:%s", id, err)
+				return err
+			}
+		}
+
+		// every row (or altitude) of the patrol changes, patrol the estate from scratch in the background
+		err = txSrv.repository.EnqueueRecalculation(ectx.Request().Context(), id)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[UpdateEstate] failed to enqueue recalculation estate_id:%s, err:%s", id, err)
+			return err
+		}
+
+		updatedEstate.StatsStatus = repository.StatsStatusPending
+		return nil
+	})
+	if errors.Is(err, errInvalidEstate) {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[UpdateEstate] invalid values:%+v, payload:%+v", fieldErrs, payload)
+		return respondInvalidFields(ectx, fieldErrs)
+	}
+	if errors.Is(err, errTreesOutsideEstate) {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[UpdateEstate] shrinking estate_id:%s leaves %d trees outside", id, orphanTrees)
+		return respondConflict(ectx, generated.ProblemCodeTreesOutsideEstate, fmt.Sprintf("%d trees would be left outside of the estate, use force to delete them", orphanTrees))
+	}
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	return ectx.JSON(http.StatusOK, estateResponse(updatedEstate))
+}
+
+func (srv *Server) DeleteEstateId(ectx echo.Context, id string, params generated.DeleteEstateIdParams) error {
+	// id is estateID
+	if len(id) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[DeleteEstate] param estate_id not passed")
+		return respondNotFound(ectx)
+	}
+
+	_, err := srv.repository.GetEstateByID(
+		ectx.Request().Context(),
+		id,
+	)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[DeleteEstate] failed retrieve information estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	cascade := params.Cascade != nil && *params.Cascade
+	err = srv.repository.DeleteEstate(ectx.Request().Context(), id, cascade)
+	if errors.Is(err, repository.ErrEstateHasTrees) {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[DeleteEstate] estate_id:%s still has trees", id)
+		return respondConflict(ectx, generated.ProblemCodeEstateHasTrees, "estate still has trees, use cascade to delete them along")
+	}
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[DeleteEstate] failed to delete estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
+
+func estateResponse(estate repository.Estate) generated.Estate {
+	resp := generated.Estate{
+		Id:          estate.ID,
+		Width:       estate.Width,
+		Length:      estate.Length,
+		Bearing:     estate.Bearing,
+		TreeCount:   estate.Count,
+		StatsStatus: generated.StatsStatus(estate.StatsStatus),
+		Drone:       droneProfileResponse(estateDrone(estate)),
+		Boundary:    boundaryResponse(estate.Boundary),
+		CreatedAt:   estate.CreatedAt,
+	}
+	if estate.OriginLat != nil && estate.OriginLon != nil {
+		resp.Origin = &generated.GeoPoint{
+			Latitude:  *estate.OriginLat,
+			Longitude: *estate.OriginLon,
+		}
+	}
+
+	return resp
+}
+
+func droneProfileResponse(drone repository.DroneProfile) generated.DroneProfile {
+	return generated.DroneProfile{
+		PlotSize:          drone.PlotSize,
+		MonitorClearance:  drone.MonitorClearance,
+		CruiseAltitudeMin: drone.CruiseAltitudeMin,
+		MaxAltitude:       drone.MaxAltitude,
+	}
+}
+
+// applyDroneProfile return the drone profile with the fields given in payload changed,
+// payload is nil when the drone is left as it is
+func applyDroneProfile(drone repository.DroneProfile, payload *generated.DroneProfileRequestBody) repository.DroneProfile {
+	if payload == nil {
+		return drone
+	}
+
+	if payload.PlotSize != nil {
+		drone.PlotSize = *payload.PlotSize
+	}
+	if payload.MonitorClearance != nil {
+		drone.MonitorClearance = *payload.MonitorClearance
+	}
+	if payload.CruiseAltitudeMin != nil {
+		drone.CruiseAltitudeMin = *payload.CruiseAltitudeMin
+	}
+	if payload.MaxAltitude != nil {
+		drone.MaxAltitude = *payload.MaxAltitude
+	}
+
+	return drone
+}
+
+// droneFieldErrors tells the fields of the drone profile at fault, every field is in the
+// limits of api.yml, only their combination is left to check
+func droneFieldErrors(drone repository.DroneProfile) []generated.FieldError {
+	fieldErrs := make([]generated.FieldError, 0)
+	if drone.CruiseAltitudeMin > drone.MaxAltitude {
+		fieldErrs = append(fieldErrs, fieldError("drone.cruise_altitude_min", generated.ProblemCodeOutOfRange,
+			fmt.Sprintf("drone.cruise_altitude_min must be at most max_altitude %d", drone.MaxAltitude)))
+	}
+
+	return fieldErrs
+}
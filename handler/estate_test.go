@@ -0,0 +1,543 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetEstate(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+	estates := []repository.Estate{
+		{ID: "estate_id_1", Width: 5, Length: 1, Count: 2, CreatedAt: createdAt},
+		{ID: "estate_id_2", Width: 10, Length: 10, CreatedAt: createdAt.Add(time.Hour)},
+		{ID: "estate_id_3", Width: 3, Length: 3, CreatedAt: createdAt.Add(2 * time.Hour)},
+	}
+	cursor := "2024-03-01T09:30:00Z,estate_id_2"
+	badCursor := "estate_id_2"
+	limit, badLimit := 2, 1001
+	newestFirst := generated.MinusCreatedAt
+
+	tests := []struct {
+		name               string
+		params             generated.GetEstateParams
+		callGetEstatesPage bool
+		mockErr            error
+		expectedAfter      *repository.Estate
+		expectedNewest     bool
+		expectedLimit      int
+		mockEstates        []repository.Estate
+		expectedCode       int
+		expectedEstateIDs  []string
+		expectedNextCursor *string
+		expectedMessage    string
+	}{
+		{
+			name:               "Positive Flow - First page",
+			params:             generated.GetEstateParams{Limit: &limit},
+			callGetEstatesPage: true,
+			expectedLimit:      3,
+			mockEstates:        estates,
+			expectedCode:       http.StatusOK,
+			expectedEstateIDs:  []string{"estate_id_1", "estate_id_2"},
+			expectedNextCursor: &cursor,
+		},
+		{
+			name:               "Positive Flow - Next page newest first",
+			params:             generated.GetEstateParams{Cursor: &cursor, Sort: &newestFirst},
+			callGetEstatesPage: true,
+			expectedAfter:      &repository.Estate{ID: "estate_id_2", CreatedAt: createdAt.Add(time.Hour)},
+			expectedNewest:     true,
+			expectedLimit:      101,
+			mockEstates:        estates[:1],
+			expectedCode:       http.StatusOK,
+			expectedEstateIDs:  []string{"estate_id_1"},
+		},
+		{
+			name:            "Invalid cursor",
+			params:          generated.GetEstateParams{Cursor: &badCursor},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "cursor is not one of a previous page",
+		},
+		{
+			name:            "Invalid limit",
+			params:          generated.GetEstateParams{Limit: &badLimit},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "limit must be between 1 and 1000",
+		},
+		{
+			name:               "Repository Error",
+			callGetEstatesPage: true,
+			mockErr:            errors.New("repository error"),
+			expectedLimit:      101,
+			expectedCode:       http.StatusInternalServerError,
+			expectedMessage:    "internal server error",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate", nil)
+			rec := httptest.NewRecorder()
+
+			if tc.callGetEstatesPage {
+				mockRepo.EXPECT().
+					GetEstatesPage(gomock.Any(), tc.expectedAfter, tc.expectedNewest, tc.expectedLimit).
+					Return(tc.mockEstates, tc.mockErr).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.GetEstate(c, tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				var resp generated.EstatesResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				estateIDs := make([]string, 0, len(resp.Estates))
+				for _, estate := range resp.Estates {
+					estateIDs = append(estateIDs, estate.Id)
+				}
+				require.Equal(t, tc.expectedEstateIDs, estateIDs)
+				require.Equal(t, tc.expectedNextCursor, resp.NextCursor)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestGetEstateId(t *testing.T) {
+	lat, lon := -6.2, 106.8
+	createdAt := time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+	estate := repository.Estate{
+		ID:        "valid_estate_id",
+		Width:     5,
+		Length:    1,
+		Count:     2,
+		OriginLat: &lat,
+		OriginLon: &lon,
+		Bearing:   90,
+		CreatedAt: createdAt,
+	}
+
+	tests := []struct {
+		name             string
+		id               string
+		callGetEstate    bool
+		mockGetEstateErr error
+		expectedCode     int
+		expectedEstate   generated.Estate
+		expectedMessage  string
+	}{
+		{
+			name:          "Positive Flow",
+			id:            "valid_estate_id",
+			callGetEstate: true,
+			expectedCode:  http.StatusOK,
+			expectedEstate: generated.Estate{
+				Id:        "valid_estate_id",
+				Width:     5,
+				Length:    1,
+				TreeCount: 2,
+				Origin:    &generated.GeoPoint{Latitude: lat, Longitude: lon},
+				Bearing:   90,
+				Drone:     generated.DroneProfile{PlotSize: 10, MonitorClearance: 1, CruiseAltitudeMin: 0, MaxAltitude: 120},
+				CreatedAt: createdAt,
+			},
+		},
+		{
+			name:            "Empty ID",
+			id:              "",
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+		{
+			name:             "Estate not found",
+			id:               "valid_estate_id",
+			callGetEstate:    true,
+			mockGetEstateErr: repository.ErrNotFound,
+			expectedCode:     http.StatusNotFound,
+			expectedMessage:  "resource not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/"+tc.id, nil)
+			rec := httptest.NewRecorder()
+
+			if tc.callGetEstate {
+				mockRepo.EXPECT().
+					GetEstateByID(gomock.Any(), tc.id).
+					Return(estate, tc.mockGetEstateErr).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateId(c, tc.id)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				var resp generated.Estate
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedEstate, resp)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestPatchEstateId(t *testing.T) {
+	estate := repository.Estate{
+		ID:             "valid_estate_id",
+		Width:          5,
+		Length:         1,
+		Count:          2,
+		Min:            3,
+		Max:            5,
+		Median:         4,
+		PatrolDistance: 62,
+		StatsStatus:    repository.StatsStatusFresh,
+		Drone:          repository.DefaultDroneProfile(),
+	}
+	trees := []repository.Tree{
+		{ID: "tree_id_1", EstateID: "valid_estate_id", X: 2, Y: 1, Height: 5},
+		{ID: "tree_id_2", EstateID: "valid_estate_id", X: 4, Y: 1, Height: 3},
+	}
+	force := true
+
+	tests := []struct {
+		name              string
+		payload           string
+		params            generated.PatchEstateIdParams
+		mockGetEstateErr  error
+		callLock          bool
+		callGetTrees      bool
+		callUpdateLayout  bool
+		expectedLayout    repository.Estate
+		mockUpdateErr     error
+		callDeleteOutside bool
+		callEnqueue       bool
+		mockEnqueueErr    error
+		expectedCode      int
+		expectedWidth     int
+		expectedStatus    generated.StatsStatus
+		expectedMessage   string
+	}{
+		{
+			name:             "Positive Flow - Bearing only",
+			payload:          `{"bearing": 45}`,
+			callUpdateLayout: true,
+			expectedLayout: repository.Estate{
+				ID: "valid_estate_id", Width: 5, Length: 1, Count: 2, Min: 3, Max: 5, Median: 4, PatrolDistance: 62, Bearing: 45, StatsStatus: "fresh",
+				Drone: repository.DefaultDroneProfile(),
+			},
+			expectedCode:   http.StatusOK,
+			expectedWidth:  5,
+			expectedStatus: generated.Fresh,
+		},
+		{
+			name:             "Positive Flow - Shrink with force",
+			payload:          `{"width": 3}`,
+			params:           generated.PatchEstateIdParams{Force: &force},
+			callGetTrees:     true,
+			callUpdateLayout: true,
+			expectedLayout: repository.Estate{
+				ID: "valid_estate_id", Width: 3, Length: 1, Count: 2, Min: 3, Max: 5, Median: 4, PatrolDistance: 62, StatsStatus: "fresh",
+				Drone: repository.DefaultDroneProfile(),
+			},
+			callDeleteOutside: true,
+			callEnqueue:       true,
+			expectedCode:      http.StatusOK,
+			expectedWidth:     3,
+			expectedStatus:    generated.Pending,
+		},
+		{
+			name:             "Positive Flow - Drone profile",
+			payload:          `{"drone": {"plot_size": 5, "cruise_altitude_min": 20}}`,
+			callUpdateLayout: true,
+			expectedLayout: repository.Estate{
+				ID: "valid_estate_id", Width: 5, Length: 1, Count: 2, Min: 3, Max: 5, Median: 4, PatrolDistance: 62, StatsStatus: "fresh",
+				Drone: repository.DroneProfile{PlotSize: 5, MonitorClearance: 1, CruiseAltitudeMin: 20, MaxAltitude: 120},
+			},
+			callEnqueue:    true,
+			expectedCode:   http.StatusOK,
+			expectedWidth:  5,
+			expectedStatus: generated.Pending,
+		},
+		{
+			name:             "Positive Flow - Boundary",
+			payload:          `{"boundary": {"rows": [{"y": 1, "from_x": 2, "to_x": 4}]}}`,
+			callUpdateLayout: true,
+			expectedLayout: repository.Estate{
+				ID: "valid_estate_id", Width: 5, Length: 1, Count: 2, Min: 3, Max: 5, Median: 4, PatrolDistance: 62, StatsStatus: "fresh",
+				Drone: repository.DefaultDroneProfile(), Boundary: []repository.Plot{{X: 4, Y: 1}, {X: 2, Y: 1}},
+			},
+			callEnqueue:    true,
+			expectedCode:   http.StatusOK,
+			expectedWidth:  5,
+			expectedStatus: generated.Pending,
+		},
+		{
+			name:            "Boundary outside of the estate",
+			payload:         `{"boundary": {"rows": [{"y": 1, "from_x": 2, "to_x": 6}]}}`,
+			callLock:        true,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "the boundary must be inside the estate, columns 1 to 5 and rows 1 to 1",
+		},
+		{
+			name:            "Drone cruising above its ceiling",
+			payload:         `{"drone": {"cruise_altitude_min": 50, "max_altitude": 40}}`,
+			callLock:        true,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "drone.cruise_altitude_min must be at most max_altitude 40",
+		},
+		{
+			name:            "Shrink leaves trees outside",
+			payload:         `{"width": 3}`,
+			callGetTrees:    true,
+			expectedCode:    http.StatusConflict,
+			expectedMessage: "1 trees would be left outside of the estate, use force to delete them",
+		},
+		{
+			name:             "Enqueue failed",
+			payload:          `{"width": 3}`,
+			params:           generated.PatchEstateIdParams{Force: &force},
+			callGetTrees:     true,
+			callUpdateLayout: true,
+			expectedLayout: repository.Estate{
+				ID: "valid_estate_id", Width: 3, Length: 1, Count: 2, Min: 3, Max: 5, Median: 4, PatrolDistance: 62, StatsStatus: "fresh",
+				Drone: repository.DefaultDroneProfile(),
+			},
+			callDeleteOutside: true,
+			callEnqueue:       true,
+			mockEnqueueErr:    errors.New("repository error"),
+			expectedCode:      http.StatusInternalServerError,
+			expectedMessage:   "internal server error",
+		},
+		{
+			name:             "Update failed",
+			payload:          `{"bearing": 45}`,
+			callUpdateLayout: true,
+			expectedLayout: repository.Estate{
+				ID: "valid_estate_id", Width: 5, Length: 1, Count: 2, Min: 3, Max: 5, Median: 4, PatrolDistance: 62, Bearing: 45, StatsStatus: "fresh",
+				Drone: repository.DefaultDroneProfile(),
+			},
+			mockUpdateErr:   repository.ErrUnavailable,
+			expectedCode:    http.StatusServiceUnavailable,
+			expectedMessage: "service unavailable, try again later",
+		},
+		{
+			name:             "Estate not found",
+			payload:          `{"width": 3}`,
+			mockGetEstateErr: repository.ErrNotFound,
+			expectedCode:     http.StatusNotFound,
+			expectedMessage:  "resource not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/estate/valid_estate_id", bytes.NewBufferString(tc.payload))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			mockRepo.EXPECT().
+				GetEstateByID(gomock.Any(), "valid_estate_id").
+				Return(estate, tc.mockGetEstateErr).
+				Times(1)
+			if tc.callGetTrees {
+				mockRepo.EXPECT().
+					GetAllTreesInEstate(gomock.Any(), "valid_estate_id").
+					Return(trees, nil).
+					Times(1)
+			}
+			if tc.callLock || tc.callGetTrees || tc.callUpdateLayout {
+				expectEstateLock(mockRepo, estate)
+			}
+			if tc.callUpdateLayout {
+				mockRepo.EXPECT().
+					UpdateEstateLayout(gomock.Any(), tc.expectedLayout).
+					Return(tc.mockUpdateErr).
+					Times(1)
+			}
+			if tc.callDeleteOutside {
+				mockRepo.EXPECT().
+					DeleteTreesOutside(gomock.Any(), "valid_estate_id", 3, 1).
+					Return(nil).
+					Times(1)
+			}
+			if tc.callEnqueue {
+				mockRepo.EXPECT().
+					EnqueueRecalculation(gomock.Any(), "valid_estate_id").
+					Return(tc.mockEnqueueErr).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.PatchEstateId(c, "valid_estate_id", tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				var resp generated.Estate
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedWidth, resp.Width)
+				require.Equal(t, tc.expectedStatus, resp.StatsStatus)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestDeleteEstateId(t *testing.T) {
+	cascade := true
+
+	tests := []struct {
+		name             string
+		params           generated.DeleteEstateIdParams
+		mockGetEstateErr error
+		callDelete       bool
+		expectedCascade  bool
+		mockDeleteErr    error
+		expectedCode     int
+		expectedMessage  string
+	}{
+		{
+			name:         "Positive Flow",
+			callDelete:   true,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:            "Positive Flow - Cascade",
+			params:          generated.DeleteEstateIdParams{Cascade: &cascade},
+			callDelete:      true,
+			expectedCascade: true,
+			expectedCode:    http.StatusNoContent,
+		},
+		{
+			name:            "Estate still has trees",
+			callDelete:      true,
+			mockDeleteErr:   repository.ErrEstateHasTrees,
+			expectedCode:    http.StatusConflict,
+			expectedMessage: "estate still has trees, use cascade to delete them along",
+		},
+		{
+			name:            "Delete failed",
+			callDelete:      true,
+			mockDeleteErr:   errors.New("repository error"),
+			expectedCode:    http.StatusInternalServerError,
+			expectedMessage: "internal server error",
+		},
+		{
+			name:             "Estate not found",
+			mockGetEstateErr: repository.ErrNotFound,
+			expectedCode:     http.StatusNotFound,
+			expectedMessage:  "resource not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/estate/valid_estate_id", nil)
+			rec := httptest.NewRecorder()
+
+			mockRepo.EXPECT().
+				GetEstateByID(gomock.Any(), "valid_estate_id").
+				Return(repository.Estate{ID: "valid_estate_id"}, tc.mockGetEstateErr).
+				Times(1)
+			if tc.callDelete {
+				mockRepo.EXPECT().
+					DeleteEstate(gomock.Any(), "valid_estate_id", tc.expectedCascade).
+					Return(tc.mockDeleteErr).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.DeleteEstateId(c, "valid_estate_id", tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode != http.StatusNoContent {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nahwinrajan/testswpro/mission"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+// flight modes of the drone plan, see flightMode
+const (
+	flightModeNameCanopyFollow  = "canopy-follow"
+	flightModeNameFixedAltitude = "fixed-altitude"
+	flightModeNameRowMax        = "row-max"
+	flightModeNameLookahead     = "lookahead"
+	// flightModeNameCompare patrol the estate in every flight mode, see compareFlightModes
+	flightModeNameCompare = "compare"
+)
+
+const (
+	flightModeLookaheadMax = 1000
+	// lookahead window of the lookahead mode compared with the other flight modes
+	flightModeLookaheadCompare = 3
+)
+
+var errUnknownFlightMode = errors.New("unknown flight mode")
+
+// flightMode tells the altitude the drone flies over every tree of the patrol,
+// the drone only climbs or descends over a tree and keeps its altitude in between
+type flightMode struct {
+	name string
+	// lookahead is the number of trees, from the tree on, the drone clears in lookahead mode
+	lookahead int
+}
+
+// flightModeCanopyFollow climb and descend over every tree, the patrol stored with the estate
+var flightModeCanopyFollow = flightMode{name: flightModeNameCanopyFollow}
+
+// String return the flight mode as it is asked, e.g. lookahead-3
+func (mode flightMode) String() string {
+	if mode.name == flightModeNameLookahead {
+		return fmt.Sprintf("%s-%d", mode.name, mode.lookahead)
+	}
+
+	return mode.name
+}
+
+// parseFlightModes return the flight modes asked by name, every flight mode for compare
+func parseFlightModes(name string) ([]flightMode, error) {
+	switch name {
+	case flightModeNameCompare:
+		return []flightMode{
+			flightModeCanopyFollow,
+			{name: flightModeNameFixedAltitude},
+			{name: flightModeNameRowMax},
+			{name: flightModeNameLookahead, lookahead: flightModeLookaheadCompare},
+		}, nil
+	case flightModeNameCanopyFollow, flightModeNameFixedAltitude, flightModeNameRowMax:
+		return []flightMode{{name: name}}, nil
+	}
+
+	strLookahead, found := strings.CutPrefix(name, flightModeNameLookahead+"-")
+	lookahead, err := strconv.Atoi(strLookahead)
+	if !found || err != nil || lookahead < 1 || lookahead > flightModeLookaheadMax {
+		return nil, errUnknownFlightMode
+	}
+
+	return []flightMode{{name: flightModeNameLookahead, lookahead: lookahead}}, nil
+}
+
+// altitudes return the altitude of the drone over every tree, trees are in the patrol order.
+// floors is the lowest altitude over every tree, nil when there is none, a tree without
+// height is a plot without tree the drone only flies over at its floor.
+func (mode flightMode) altitudes(drone repository.DroneProfile, trees []repository.Tree, floors []int) []int {
+	altitudes := make([]int, len(trees))
+	for i := range trees {
+		if trees[i].Height > 0 {
+			altitudes[i] = droneAltitude(drone, &trees[i])
+		}
+		if floors != nil {
+			altitudes[i] = max(altitudes[i], floors[i])
+		}
+	}
+
+	switch mode.name {
+	case flightModeNameFixedAltitude:
+		// the whole estate above the tallest tree
+		var top int
+		for _, altitude := range altitudes {
+			top = max(top, altitude)
+		}
+		for i := range altitudes {
+			altitudes[i] = top
+		}
+	case flightModeNameRowMax:
+		// every row above its tallest tree, the trees of a row are next to each other
+		for rowStart := 0; rowStart < len(trees); {
+			rowEnd, top := rowStart, 0
+			for rowEnd < len(trees) && trees[rowEnd].Y == trees[rowStart].Y {
+				top = max(top, altitudes[rowEnd])
+				rowEnd++
+			}
+			for i := rowStart; i < rowEnd; i++ {
+				altitudes[i] = top
+			}
+			rowStart = rowEnd
+		}
+	case flightModeNameLookahead:
+		// above the tallest of the next trees, window keeps the trees of the next ones
+		// which are not cleared by a taller one before them, tallest first
+		cleared := make([]int, len(trees))
+		window := make([]int, 0, mode.lookahead)
+		for i := len(trees) - 1; i >= 0; i-- {
+			if len(window) > 0 && window[0] >= i+mode.lookahead {
+				window = window[1:]
+			}
+			for len(window) > 0 && altitudes[window[len(window)-1]] <= altitudes[i] {
+				window = window[:len(window)-1]
+			}
+			window = append(window, i)
+			cleared[i] = altitudes[window[0]]
+		}
+		altitudes = cleared
+	}
+
+	return altitudes
+}
+
+// flightPlan is the patrol route of the drone flying in the flight mode along the sweep,
+// the route steps are on the plots of the patrol, see sweep
+type flightPlan struct {
+	mode  flightMode
+	sweep sweep
+	// firstRow and lastRow are the rows of the patrol in the strip of the drone, see dronePlans,
+	// the route steps are on the plots of the strip. Zero when the drone patrols every row.
+	firstRow, lastRow int
+	distance          int
+	routeSteps        []repository.RouteStep
+}
+
+// patrolEstate return the estate laid out for the patrol of the plan, only the strip of the drone
+func (plan flightPlan) patrolEstate(estate repository.Estate) repository.Estate {
+	patrolEstate := plan.sweep.patrolEstate(estate)
+	if plan.lastRow > 0 {
+		patrolEstate.Length = plan.lastRow - plan.firstRow + 1
+	}
+
+	// the zones and boundary over the strip, the parts over the other rows do not reach it
+	if plan.firstRow > 1 {
+		toStrip := func(polygon []repository.Plot) []repository.Plot {
+			if len(polygon) == 0 {
+				return polygon
+			}
+
+			stripPolygon := make([]repository.Plot, len(polygon))
+			for i, vertex := range polygon {
+				stripPolygon[i] = repository.Plot{X: vertex.X, Y: vertex.Y - plan.firstRow + 1}
+			}
+			return stripPolygon
+		}
+
+		if len(patrolEstate.Zones) > 0 {
+			zones := make([]repository.Zone, len(patrolEstate.Zones))
+			for i, zone := range patrolEstate.Zones {
+				zone.Polygon = toStrip(zone.Polygon)
+				zones[i] = zone
+			}
+			patrolEstate.Zones = zones
+		}
+		patrolEstate.Boundary = toStrip(patrolEstate.Boundary)
+	}
+
+	return patrolEstate
+}
+
+// fromPatrol return the plot of the estate of plot (x,y) of the patrol of the plan
+func (plan flightPlan) fromPatrol(estate repository.Estate, x, y int) (int, int) {
+	if plan.firstRow > 1 {
+		y += plan.firstRow - 1
+	}
+
+	return plan.sweep.fromPatrol(estate, x, y)
+}
+
+// steps return the route steps of the plan on the plots of the estate
+func (plan flightPlan) steps(estate repository.Estate) []repository.RouteStep {
+	routeSteps := plan.routeSteps
+	if plan.firstRow > 1 {
+		routeSteps = make([]repository.RouteStep, len(plan.routeSteps))
+		for i, routeStep := range plan.routeSteps {
+			routeStep.Y += plan.firstRow - 1
+			routeSteps[i] = routeStep
+		}
+	}
+
+	return plan.sweep.routeSteps(estate, routeSteps)
+}
+
+// path return the waypoints the drone flies through on the plots of the estate
+func (plan flightPlan) path(estate repository.Estate) []mission.Waypoint {
+	waypoints := mission.Path(plan.patrolEstate(estate), plan.routeSteps)
+	for i := range waypoints {
+		waypoints[i].X, waypoints[i].Y = plan.fromPatrol(estate, waypoints[i].X, waypoints[i].Y)
+	}
+
+	return waypoints
+}
+
+// rest return the plot of the estate the drone rests on after flying maxDistance of the plan
+func (srv *Server) rest(estate repository.Estate, plan flightPlan, maxDistance int) (int, int) {
+	restX, restY := srv.calculateMaxDistance(plan.patrolEstate(estate), plan.routeSteps, maxDistance)
+	return plan.fromPatrol(estate, restX, restY)
+}
+
+// flightPlans patrol the estate with every tree in it in each flight mode along the shortest
+// of the sweeps, the first sweep asked first among the same distance. The plans are sorted
+// from the shortest, the first mode asked first among the same distance.
+func (srv *Server) flightPlans(
+	ctx context.Context,
+	estate repository.Estate,
+	modes []flightMode,
+	sweeps []sweep,
+) ([]flightPlan, error) {
+	trees, err := srv.repository.GetAllTreesInEstate(ctx, estate.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	estate, err = srv.estateZones(ctx, estate)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]flightPlan, 0, len(modes))
+	for _, mode := range modes {
+		var best flightPlan
+		for i, s := range sweeps {
+			plan := flightPlan{mode: mode, sweep: s}
+			if len(trees) > 0 {
+				_, _, _, plan.distance, plan.routeSteps, err = srv.patrolAs(s.patrolEstate(estate), s.patrolTrees(estate, trees), mode)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if i == 0 || plan.distance < best.distance {
+				best = plan
+			}
+		}
+		plans = append(plans, best)
+	}
+
+	sort.SliceStable(plans, func(i, j int) bool {
+		return plans[i].distance < plans[j].distance
+	})
+
+	return plans, nil
+}
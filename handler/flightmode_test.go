@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFlightModes(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		expectedModes []string
+		expectedErr   error
+	}{
+		{
+			name:          "Canopy follow",
+			mode:          "canopy-follow",
+			expectedModes: []string{"canopy-follow"},
+		},
+		{
+			name:          "Fixed altitude",
+			mode:          "fixed-altitude",
+			expectedModes: []string{"fixed-altitude"},
+		},
+		{
+			name:          "Row max",
+			mode:          "row-max",
+			expectedModes: []string{"row-max"},
+		},
+		{
+			name:          "Lookahead",
+			mode:          "lookahead-12",
+			expectedModes: []string{"lookahead-12"},
+		},
+		{
+			name:          "Compare",
+			mode:          "compare",
+			expectedModes: []string{"canopy-follow", "fixed-altitude", "row-max", "lookahead-3"},
+		},
+		{
+			name:        "Lookahead without window",
+			mode:        "lookahead",
+			expectedErr: errUnknownFlightMode,
+		},
+		{
+			name:        "Lookahead over the limit",
+			mode:        "lookahead-1001",
+			expectedErr: errUnknownFlightMode,
+		},
+		{
+			name:        "Unknown mode",
+			mode:        "hover",
+			expectedErr: errUnknownFlightMode,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			modes, err := parseFlightModes(tc.mode)
+			require.Equal(t, tc.expectedErr, err)
+
+			var names []string
+			for _, mode := range modes {
+				names = append(names, mode.String())
+			}
+			require.Equal(t, tc.expectedModes, names)
+		})
+	}
+}
+
+func TestFlightModeAltitudes(t *testing.T) {
+	// trees in the patrol order, the second row goes from column end to column start
+	trees := []repository.Tree{
+		{X: 1, Y: 1, Height: 10},
+		{X: 2, Y: 1, Height: 2},
+		{X: 4, Y: 1, Height: 5},
+		{X: 4, Y: 2, Height: 1},
+		{X: 3, Y: 2, Height: 7},
+		{X: 1, Y: 2, Height: 3},
+	}
+
+	tests := []struct {
+		name              string
+		mode              flightMode
+		drone             repository.DroneProfile
+		floors            []int
+		expectedAltitudes []int
+	}{
+		{
+			name:              "Canopy follow",
+			mode:              flightModeCanopyFollow,
+			drone:             repository.DefaultDroneProfile(),
+			expectedAltitudes: []int{11, 3, 6, 2, 8, 4},
+		},
+		{
+			name:              "Fixed altitude",
+			mode:              flightMode{name: flightModeNameFixedAltitude},
+			drone:             repository.DefaultDroneProfile(),
+			expectedAltitudes: []int{11, 11, 11, 11, 11, 11},
+		},
+		{
+			name:              "Row max",
+			mode:              flightMode{name: flightModeNameRowMax},
+			drone:             repository.DefaultDroneProfile(),
+			expectedAltitudes: []int{11, 11, 11, 8, 8, 8},
+		},
+		{
+			name:              "Lookahead of one tree is canopy follow",
+			mode:              flightMode{name: flightModeNameLookahead, lookahead: 1},
+			drone:             repository.DefaultDroneProfile(),
+			expectedAltitudes: []int{11, 3, 6, 2, 8, 4},
+		},
+		{
+			name:              "Lookahead across rows",
+			mode:              flightMode{name: flightModeNameLookahead, lookahead: 2},
+			drone:             repository.DefaultDroneProfile(),
+			expectedAltitudes: []int{11, 6, 6, 8, 8, 4},
+		},
+		{
+			name:              "Lookahead longer than the patrol",
+			mode:              flightMode{name: flightModeNameLookahead, lookahead: 10},
+			drone:             repository.DefaultDroneProfile(),
+			expectedAltitudes: []int{11, 8, 8, 8, 8, 4},
+		},
+		{
+			name:              "Within the drone altitudes",
+			mode:              flightMode{name: flightModeNameRowMax},
+			drone:             repository.DroneProfile{PlotSize: 10, MonitorClearance: 1, CruiseAltitudeMin: 5, MaxAltitude: 9},
+			expectedAltitudes: []int{9, 9, 9, 8, 8, 8},
+		},
+		{
+			name:              "Above the zone floors",
+			mode:              flightModeCanopyFollow,
+			drone:             repository.DefaultDroneProfile(),
+			floors:            []int{0, 20, 20, 0, 0, 0},
+			expectedAltitudes: []int{11, 20, 20, 2, 8, 4},
+		},
+		{
+			name:              "Row max above the zone floors",
+			mode:              flightMode{name: flightModeNameRowMax},
+			drone:             repository.DefaultDroneProfile(),
+			floors:            []int{0, 0, 0, 0, 15, 0},
+			expectedAltitudes: []int{11, 11, 11, 15, 15, 15},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			altitudes := tc.mode.altitudes(tc.drone, trees, tc.floors)
+			require.Equal(t, tc.expectedAltitudes, altitudes)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/geo"
+	"github.com/nahwinrajan/testswpro/mission"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+const (
+	mimeApplicationGeoJSON = "application/geo+json"
+	mimeApplicationKML     = "application/vnd.google-earth.kml+xml"
+)
+
+var errEstateNotGeoReferenced = errors.New("estate has no origin")
+
+func (srv *Server) GetEstateIdGeojson(ectx echo.Context, id string) error {
+	return srv.exportEstateGeo(ectx, id, "[EstateGeoJSON]", mimeApplicationGeoJSON, geo.EncodeGeoJSON)
+}
+
+func (srv *Server) GetEstateIdKml(ectx echo.Context, id string) error {
+	return srv.exportEstateGeo(ectx, id, "[EstateKML]", mimeApplicationKML, geo.EncodeKML)
+}
+
+// exportEstateGeo respond with the geo-referenced estate written by encode
+func (srv *Server) exportEstateGeo(
+	ectx echo.Context,
+	id, logTag, contentType string,
+	encode func(geo.Estate) ([]byte, error),
+) error {
+	// id is estateID
+	if len(id) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s param estate_id not passed", logTag)
+		return respondNotFound(ectx)
+	}
+
+	estate, err := srv.repository.GetEstateByID(
+		ectx.Request().Context(),
+		id,
+	)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s failed retrieve information estate_id:%s, err:%s", logTag, id, err)
+		return respondError(ectx, err)
+	}
+
+	estateGeo, err := srv.estateGeo(ectx.Request().Context(), estate)
+	if errors.Is(err, errEstateNotGeoReferenced) {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s estate_id:%s is not geo-referenced", logTag, id)
+		return respondBadRequest(ectx, generated.ProblemCodeEstateNotGeoreferenced, "estate has no origin coordinate")
+	}
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s failed to read estate_id:%s, err:%s", logTag, id, err)
+		return respondError(ectx, err)
+	}
+
+	data, err := encode(estateGeo)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s failed to encode estate_id:%s, err:%s", logTag, id, err)
+		return respondError(ectx, err)
+	}
+
+	return ectx.Blob(http.StatusOK, contentType, data)
+}
+
+// estateGeo lay the estate, its trees and patrol route on the earth from the estate origin,
+// plots are as wide as the plot size of the drone patrolling the estate.
+func (srv *Server) estateGeo(ctx context.Context, estate repository.Estate) (geo.Estate, error) {
+	if estate.OriginLat == nil || estate.OriginLon == nil {
+		return geo.Estate{}, errEstateNotGeoReferenced
+	}
+
+	estate, trees, routeSteps, err := srv.patrolTreesAndRoute(ctx, estate)
+	if err != nil {
+		return geo.Estate{}, err
+	}
+
+	grid := geo.Grid{
+		Origin:   geo.Point{Latitude: *estate.OriginLat, Longitude: *estate.OriginLon},
+		Bearing:  estate.Bearing,
+		PlotSize: float64(estateDrone(estate).PlotSize),
+	}
+
+	estateGeo := geo.Estate{
+		ID:             estate.ID,
+		Width:          estate.Width,
+		Length:         estate.Length,
+		Boundary:       grid.Boundary(estate.Width, estate.Length),
+		Trees:          make([]geo.Tree, 0, len(trees)),
+		PatrolDistance: estate.PatrolDistance,
+	}
+
+	for _, tree := range trees {
+		estateGeo.Trees = append(estateGeo.Trees, geo.Tree{
+			ID:       tree.ID,
+			X:        tree.X,
+			Y:        tree.Y,
+			Height:   tree.Height,
+			Position: grid.Position(float64(tree.X), float64(tree.Y)),
+		})
+	}
+
+	for _, waypoint := range mission.Path(estate, routeSteps) {
+		estateGeo.PatrolRoute = append(estateGeo.PatrolRoute, geo.PathPoint{
+			Position: grid.Position(float64(waypoint.X), float64(waypoint.Y)),
+			Altitude: waypoint.Altitude,
+		})
+	}
+
+	return estateGeo, nil
+}
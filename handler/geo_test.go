@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetEstateIdGeojson(t *testing.T) {
+	originLat, originLon := -6.2, 106.8
+	geoEstate := repository.Estate{
+		ID:             "valid_estate_id",
+		Width:          5,
+		Length:         1,
+		Count:          3,
+		PatrolDistance: 64,
+		OriginLat:      &originLat,
+		OriginLon:      &originLon,
+	}
+	trees := []repository.Tree{
+		{ID: "tree_id_1", EstateID: "valid_estate_id", X: 2, Y: 1, Height: 5},
+		{ID: "tree_id_2", EstateID: "valid_estate_id", X: 3, Y: 1, Height: 3},
+		{ID: "tree_id_3", EstateID: "valid_estate_id", X: 4, Y: 1, Height: 4},
+	}
+	routeSteps := []repository.RouteStep{
+		{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 10},
+		{Step: 2, X: 2, Y: 1, Direction: "vu", StepDistance: 6, CurrentDistance: 16, Altitude: 6},
+		{Step: 3, X: 2, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 26, Altitude: 6},
+		{Step: 4, X: 3, Y: 1, Direction: "vd", StepDistance: 2, CurrentDistance: 28, Altitude: 4},
+		{Step: 5, X: 3, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 38, Altitude: 4},
+		{Step: 6, X: 4, Y: 1, Direction: "vu", StepDistance: 1, CurrentDistance: 39, Altitude: 5},
+		{Step: 7, X: 4, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 49, Altitude: 5},
+		{Step: 8, X: 5, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 59, Altitude: 5},
+	}
+
+	tests := []struct {
+		name             string
+		id               string
+		estate           repository.Estate
+		mockGetEstateErr error
+		callGetEstate    bool
+		callGetTrees     bool
+		storedRoute      []repository.RouteStep
+		rebuildRoute     bool
+		lockedRoute      []repository.RouteStep
+		expectedCode     int
+		expectedFeatures int
+		expectedMessage  string
+	}{
+		{
+			name:             "Positive Flow",
+			id:               "valid_estate_id",
+			estate:           geoEstate,
+			callGetEstate:    true,
+			callGetTrees:     true,
+			storedRoute:      routeSteps,
+			expectedCode:     http.StatusOK,
+			expectedFeatures: 5,
+		},
+		{
+			name:             "Positive Flow - Rebuild route cleared by incremental recalculation",
+			id:               "valid_estate_id",
+			estate:           geoEstate,
+			callGetEstate:    true,
+			callGetTrees:     true,
+			storedRoute:      []repository.RouteStep{},
+			rebuildRoute:     true,
+			expectedCode:     http.StatusOK,
+			expectedFeatures: 5,
+		},
+		{
+			name:             "Positive Flow - Route rebuilt by another request while waiting for the lock",
+			id:               "valid_estate_id",
+			estate:           geoEstate,
+			callGetEstate:    true,
+			callGetTrees:     true,
+			storedRoute:      []repository.RouteStep{},
+			rebuildRoute:     true,
+			lockedRoute:      routeSteps,
+			expectedCode:     http.StatusOK,
+			expectedFeatures: 5,
+		},
+		{
+			name:            "Estate is not geo-referenced",
+			id:              "valid_estate_id",
+			estate:          repository.Estate{ID: "valid_estate_id", Width: 5, Length: 1},
+			callGetEstate:   true,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "estate has no origin coordinate",
+		},
+		{
+			name:            "Empty ID",
+			id:              "",
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+		{
+			name:             "Repository Error",
+			id:               "valid_estate_id",
+			callGetEstate:    true,
+			mockGetEstateErr: errors.New("repository error"),
+			expectedCode:     http.StatusInternalServerError,
+			expectedMessage:  "internal server error",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/"+tc.id+".geojson", nil)
+			rec := httptest.NewRecorder()
+
+			if tc.callGetEstate {
+				mockRepo.EXPECT().
+					GetEstateByID(gomock.Any(), tc.id).
+					Return(tc.estate, tc.mockGetEstateErr).
+					Times(1)
+			}
+			if tc.callGetTrees {
+				mockRepo.EXPECT().
+					GetAllTreesInEstate(gomock.Any(), tc.id).
+					Return(trees, nil).
+					Times(1)
+				mockRepo.EXPECT().
+					GetRouteSteps(gomock.Any(), tc.id).
+					Return(tc.storedRoute, nil).
+					Times(1)
+			}
+			if tc.rebuildRoute {
+				// the trees and the route are read again under the lock, only the route is stored
+				expectEstateLock(mockRepo, tc.estate)
+				mockRepo.EXPECT().
+					GetAllTreesInEstate(gomock.Any(), tc.id).
+					Return(trees, nil).
+					Times(1)
+				mockRepo.EXPECT().
+					GetRouteSteps(gomock.Any(), tc.id).
+					Return(tc.lockedRoute, nil).
+					Times(1)
+				if len(tc.lockedRoute) == 0 {
+					mockRepo.EXPECT().
+						UpdateRouteSteps(gomock.Any(), tc.id, routeSteps).
+						Return(nil).
+						Times(1)
+				}
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdGeojson(c, tc.id)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				require.Equal(t, mimeApplicationGeoJSON, rec.Header().Get(echo.HeaderContentType))
+
+				var collection struct {
+					Features []struct {
+						Geometry struct {
+							Type        string          `json:"type"`
+							Coordinates json.RawMessage `json:"coordinates"`
+						} `json:"geometry"`
+					} `json:"features"`
+				}
+				err := json.Unmarshal(rec.Body.Bytes(), &collection)
+				require.NoError(t, err)
+				require.Len(t, collection.Features, tc.expectedFeatures)
+
+				// take off, over every tree, to the end of the row then land
+				route := collection.Features[len(collection.Features)-1].Geometry
+				require.Equal(t, "LineString", route.Type)
+				var coordinates [][3]float64
+				require.NoError(t, json.Unmarshal(route.Coordinates, &coordinates))
+				require.Len(t, coordinates, 9)
+				require.Equal(t, [3]float64{originLon, originLat, 0}, coordinates[0])
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestGetEstateIdKml(t *testing.T) {
+	e := echo.New()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositorier(ctrl)
+	srv := Server{
+		repository: mockRepo,
+	}
+
+	originLat, originLon := -6.2, 106.8
+	mockRepo.EXPECT().
+		GetEstateByID(gomock.Any(), "valid_estate_id").
+		Return(repository.Estate{
+			ID:        "valid_estate_id",
+			Width:     5,
+			Length:    1,
+			OriginLat: &originLat,
+			OriginLon: &originLon,
+		}, nil).
+		Times(1)
+	mockRepo.EXPECT().
+		GetAllTreesInEstate(gomock.Any(), "valid_estate_id").
+		Return([]repository.Tree{}, nil).
+		Times(1)
+	mockRepo.EXPECT().
+		GetRouteSteps(gomock.Any(), "valid_estate_id").
+		Return([]repository.RouteStep{}, nil).
+		Times(1)
+
+	req := httptest.NewRequest(http.MethodGet, "/estate/valid_estate_id.kml", nil)
+	rec := httptest.NewRecorder()
+
+	c := e.NewContext(req, rec)
+	err := srv.GetEstateIdKml(c, "valid_estate_id")
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, mimeApplicationKML, rec.Header().Get(echo.HeaderContentType))
+	require.Contains(t, rec.Body.String(), "<name>estate valid_estate_id</name>")
+	require.Contains(t, rec.Body.String(), "<name>boundary</name>")
+	require.NotContains(t, rec.Body.String(), "<name>patrol_route</name>")
+}
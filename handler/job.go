@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+const (
+	// a job not done within the lease is claimed again by another worker
+	jobLease        = 5 * time.Minute
+	jobPollInterval = time.Second
+	jobMaxAttempts  = 8
+	jobMaxBackoff   = 5 * time.Minute
+)
+
+var errStatsNotUpdated = errors.New("estate stats not updated")
+
+// RunJobs run the jobs due with the given number of workers until ctx is done.
+func (srv *Server) RunJobs(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				ran, err := srv.runNextJob(ctx)
+				if err != nil {
+					// TODO: change log level according log level company guideline (info, error, etc)
+					log.Printf("[RunJobs] failed to run job, err:%s", err)
+				}
+				if ran {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(jobPollInterval):
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// runNextJob claim the job due the earliest and run it, ran is false when no job is due.
+// The failed job is retried with exponential backoff until it runs out of attempts.
+func (srv *Server) runNextJob(ctx context.Context) (ran bool, err error) {
+	job, err := srv.repository.ClaimJob(ctx, jobLease)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	err = srv.runJob(ctx, *job)
+	if err == nil {
+		return true, nil
+	}
+
+	// TODO: change log level according log level company guideline (info, error, etc)
+	log.Printf("[RunJobs] job_id:%s kind:%s estate_id:%s failed attempt:%d, err:%s", job.ID, job.Kind, job.EstateID, job.Attempts, err)
+	if job.Attempts >= jobMaxAttempts {
+		// TODO: alert on dead jobs, the estate stats stay failed until the estate changes again
+		return true, srv.repository.DeadLetterJob(ctx, *job, err.Error())
+	}
+
+	return true, srv.repository.RetryJob(ctx, job.ID, time.Now().Add(jobBackoff(job.Attempts)), err.Error())
+}
+
+// runJob run the job, it is done only when the work is stored.
+func (srv *Server) runJob(ctx context.Context, job repository.Job) error {
+	switch job.Kind {
+	case repository.JobKindRecalculateEstate:
+		return srv.withEstateLock(ctx, job.EstateID, func(txSrv *Server, estate repository.Estate) error {
+			_, _, err := txSrv.recalculateEstate(ctx, estate)
+			if err != nil {
+				return err
+			}
+
+			return txSrv.repository.CompleteJob(ctx, job.ID)
+		})
+	default:
+		return fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+}
+
+// jobBackoff return how long to wait before the next attempt, doubling
+// from a second after each failed attempt.
+func jobBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return time.Second
+	}
+	if attempts > 20 {
+		return jobMaxBackoff
+	}
+
+	return min(time.Second<<(attempts-1), jobMaxBackoff)
+}
+
+// changeEstateTrees run change on the trees of the estate while holding its lock and
+// update the fresh estate metadata right after, see calculateEstateMetadataIncremental,
+// the recalculation of the estate is enqueued instead when it can not be updated so.
+// change return the tree before and after the change, both nil when nothing changed.
+// The change is stored even when the metadata can not be updated along.
+func (srv *Server) changeEstateTrees(
+	ectx echo.Context,
+	logTag string,
+	estateID string,
+	change func(txSrv *Server, estate repository.Estate) (before, after *repository.Tree, err error),
+) error {
+	ctx := ectx.Request().Context()
+	err := srv.withEstateLock(ctx, estateID, func(txSrv *Server, estate repository.Estate) error {
+		before, after, err := change(txSrv, estate)
+		if err != nil || (before == nil && after == nil) {
+			return err
+		}
+
+		// there is no up to date patrol to build upon, or the drone may fly around
+		// the zones or the boundary another way than right over the plot
+		if estate.StatsStatus != repository.StatsStatusFresh || estate.ZoneCount > 0 || len(estate.Boundary) > 0 {
+			return txSrv.repository.EnqueueRecalculation(ctx, estateID)
+		}
+
+		err = txSrv.calculateEstateMetadataIncremental(ctx, estateID, before, after)
+		if err != nil {
+			return fmt.Errorf("%w: %w", errStatsNotUpdated, err)
+		}
+
+		return nil
+	})
+	if !errors.Is(err, errStatsNotUpdated) {
+		return err
+	}
+
+	// TODO: change log level according log level company guideline (info, error, etc)
+	ectx.Logger().Errorf("%s failed to calculate stats and distance estate_id:%s, err:%s", logTag, estateID, err)
+
+	// the failed update may have left the transaction unusable, store the change again
+	// along with the recalculation job
+	return srv.withEstateLock(ctx, estateID, func(txSrv *Server, estate repository.Estate) error {
+		before, after, err := change(txSrv, estate)
+		if err != nil || (before == nil && after == nil) {
+			return err
+		}
+
+		return txSrv.repository.EnqueueRecalculation(ctx, estateID)
+	})
+}
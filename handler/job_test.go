@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRunNextJob(t *testing.T) {
+	estate := repository.Estate{ID: "valid_estate_id", Width: 5, Length: 1, StatsStatus: repository.StatsStatusPending}
+	trees := []repository.Tree{{ID: "tree_id_1", EstateID: "valid_estate_id", X: 2, Y: 1, Height: 5}}
+	recalculation := func(attempts int) *repository.Job {
+		return &repository.Job{ID: "job_id", Kind: repository.JobKindRecalculateEstate, EstateID: "valid_estate_id", Attempts: attempts}
+	}
+
+	tests := []struct {
+		name               string
+		mockJob            *repository.Job
+		mockClaimErr       error
+		callRecalculate    bool
+		mockTrees          []repository.Tree
+		mockRecalculateErr error
+		expectedRetry      time.Duration
+		expectedDeadLetter bool
+		expectedRan        bool
+		expectedErr        error
+	}{
+		{
+			name:            "Positive Flow",
+			mockJob:         recalculation(1),
+			callRecalculate: true,
+			expectedRan:     true,
+		},
+		{
+			// nothing to store, completing the job tells the stats are fresh again
+			name:            "Positive Flow - Estate without trees",
+			mockJob:         recalculation(1),
+			callRecalculate: true,
+			mockTrees:       []repository.Tree{},
+			expectedRan:     true,
+		},
+		{
+			name:        "No job due",
+			expectedRan: false,
+		},
+		{
+			name:         "Claim failed",
+			mockClaimErr: errors.New("repository error"),
+			expectedRan:  false,
+			expectedErr:  errors.New("repository error"),
+		},
+		{
+			name:               "Recalculation failed, retried later",
+			mockJob:            recalculation(3),
+			callRecalculate:    true,
+			mockRecalculateErr: errors.New("repository error"),
+			expectedRetry:      4 * time.Second,
+			expectedRan:        true,
+		},
+		{
+			name:               "Recalculation failed, out of attempts",
+			mockJob:            recalculation(jobMaxAttempts),
+			callRecalculate:    true,
+			mockRecalculateErr: errors.New("repository error"),
+			expectedDeadLetter: true,
+			expectedRan:        true,
+		},
+		{
+			name:          "Unknown job kind",
+			mockJob:       &repository.Job{ID: "job_id", Kind: "unknown", EstateID: "valid_estate_id", Attempts: 1},
+			expectedRetry: time.Second,
+			expectedRan:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			mockRepo.EXPECT().
+				ClaimJob(gomock.Any(), jobLease).
+				Return(tc.mockJob, tc.mockClaimErr).
+				Times(1)
+			if tc.callRecalculate {
+				expectEstateLock(mockRepo, estate)
+				if tc.mockTrees == nil {
+					tc.mockTrees = trees
+					mockRepo.EXPECT().
+						UpdateEstate(gomock.Any(), "valid_estate_id", 1, 5, 5, 5, 62, gomock.Any()).
+						Return(tc.mockRecalculateErr).
+						Times(1)
+				}
+				mockRepo.EXPECT().
+					GetAllTreesInEstate(gomock.Any(), "valid_estate_id").
+					Return(tc.mockTrees, nil).
+					Times(1)
+				if tc.mockRecalculateErr == nil {
+					mockRepo.EXPECT().CompleteJob(gomock.Any(), "job_id").Return(nil).Times(1)
+				}
+			}
+			if tc.expectedRetry > 0 {
+				before := time.Now()
+				mockRepo.EXPECT().
+					RetryJob(gomock.Any(), "job_id", gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, jobID string, runAt time.Time, lastErr string) error {
+						require.WithinDuration(t, before.Add(tc.expectedRetry), runAt, time.Second)
+						require.NotEmpty(t, lastErr)
+						return nil
+					}).
+					Times(1)
+			}
+			if tc.expectedDeadLetter {
+				mockRepo.EXPECT().
+					DeadLetterJob(gomock.Any(), *tc.mockJob, "repository error").
+					Return(nil).
+					Times(1)
+			}
+
+			ran, err := srv.runNextJob(context.Background())
+
+			require.Equal(t, tc.expectedErr, err)
+			require.Equal(t, tc.expectedRan, ran)
+		})
+	}
+}
+
+func TestJobBackoff(t *testing.T) {
+	require.Equal(t, time.Second, jobBackoff(1))
+	require.Equal(t, 8*time.Second, jobBackoff(4))
+	require.Equal(t, jobMaxBackoff, jobBackoff(10))
+	require.Equal(t, jobMaxBackoff, jobBackoff(64))
+}
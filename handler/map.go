@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/estatemap"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+const (
+	mimeImageSVG = "image/svg+xml"
+	mimeImagePNG = "image/png"
+)
+
+func (srv *Server) GetEstateIdMapSvg(ectx echo.Context, id string, params generated.GetEstateIdMapSvgParams) error {
+	return srv.renderEstateMap(ectx, id, "[EstateMapSVG]", params, mimeImageSVG, func(m estatemap.Map) ([]byte, error) {
+		return m.SVG(), nil
+	})
+}
+
+func (srv *Server) GetEstateIdMapPng(ectx echo.Context, id string, params generated.GetEstateIdMapPngParams) error {
+	return srv.renderEstateMap(ectx, id, "[EstateMapPNG]", generated.GetEstateIdMapSvgParams(params), mimeImagePNG, estatemap.Map.PNG)
+}
+
+// renderEstateMap respond with the estate map, or its tile, drawn by render
+func (srv *Server) renderEstateMap(
+	ectx echo.Context,
+	id, logTag string,
+	params generated.GetEstateIdMapSvgParams,
+	contentType string,
+	render func(estatemap.Map) ([]byte, error),
+) error {
+	// id is estateID
+	if len(id) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s param estate_id not passed", logTag)
+		return respondNotFound(ectx)
+	}
+
+	estate, err := srv.repository.GetEstateByID(
+		ectx.Request().Context(),
+		id,
+	)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s failed retrieve information estate_id:%s, err:%s", logTag, id, err)
+		return respondError(ectx, err)
+	}
+
+	estate, trees, routeSteps, err := srv.patrolTreesAndRoute(ectx.Request().Context(), estate)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s failed to read estate_id:%s, err:%s", logTag, id, err)
+		return respondError(ectx, err)
+	}
+
+	estateMap, err := estatemap.New(estate, trees, routeSteps, mapViewport(estate, params))
+	if errors.Is(err, estatemap.ErrInvalidViewport) {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s invalid tile estate_id:%s, params:%+v", logTag, id, params)
+		return respondBadRequest(ectx, generated.ProblemCodeTileOutOfBounds, "tile must be inside the estate")
+	}
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s failed to draw estate_id:%s, err:%s", logTag, id, err)
+		return respondError(ectx, err)
+	}
+
+	data, err := render(estateMap)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s failed to render estate_id:%s, err:%s", logTag, id, err)
+		return respondError(ectx, err)
+	}
+
+	return ectx.Blob(http.StatusOK, contentType, data)
+}
+
+// mapViewport return the tile asked in params, every side not given is the estate side
+func mapViewport(estate repository.Estate, params generated.GetEstateIdMapSvgParams) estatemap.Viewport {
+	viewport := estatemap.Viewport{
+		FromX: 1,
+		FromY: 1,
+		ToX:   estate.Width,
+		ToY:   estate.Length,
+	}
+
+	if params.FromX != nil {
+		viewport.FromX = *params.FromX
+	}
+	if params.ToX != nil {
+		viewport.ToX = *params.ToX
+	}
+	if params.FromY != nil {
+		viewport.FromY = *params.FromY
+	}
+	if params.ToY != nil {
+		viewport.ToY = *params.ToY
+	}
+
+	return viewport
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetEstateIdMapSvg(t *testing.T) {
+	estate := repository.Estate{
+		ID:             "valid_estate_id",
+		Width:          5,
+		Length:         1,
+		Count:          1,
+		Max:            5,
+		Min:            5,
+		Median:         5,
+		PatrolDistance: 52,
+	}
+	trees := []repository.Tree{
+		{ID: "tree_id_1", EstateID: "valid_estate_id", X: 2, Y: 1, Height: 5},
+	}
+	routeSteps := []repository.RouteStep{
+		{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 10},
+		{Step: 2, X: 2, Y: 1, Direction: "vu", StepDistance: 6, CurrentDistance: 16, Altitude: 6},
+		{Step: 3, X: 2, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 26, Altitude: 6},
+		{Step: 4, X: 3, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 36, Altitude: 6},
+		{Step: 5, X: 4, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 46, Altitude: 6},
+	}
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name             string
+		id               string
+		params           generated.GetEstateIdMapSvgParams
+		mockGetEstateErr error
+		callGetEstate    bool
+		callGetTrees     bool
+		expectedCode     int
+		expectedContains []string
+		expectedMessage  string
+	}{
+		{
+			name:          "Positive Flow",
+			id:            "valid_estate_id",
+			callGetEstate: true,
+			callGetTrees:  true,
+			expectedCode:  http.StatusOK,
+			expectedContains: []string{
+				`<title>plots (1,1) to (5,1), 1 x 1 plots per cell</title>`,
+				`<title>5</title>`,
+				`class="route"`,
+			},
+		},
+		{
+			name:          "Positive Flow - Tile",
+			id:            "valid_estate_id",
+			params:        generated.GetEstateIdMapSvgParams{FromX: intPtr(3), ToX: intPtr(4)},
+			callGetEstate: true,
+			callGetTrees:  true,
+			expectedCode:  http.StatusOK,
+			expectedContains: []string{
+				`<title>plots (3,1) to (4,1), 1 x 1 plots per cell</title>`,
+			},
+		},
+		{
+			name:            "Tile outside of the estate",
+			id:              "valid_estate_id",
+			params:          generated.GetEstateIdMapSvgParams{FromY: intPtr(1), ToY: intPtr(2)},
+			callGetEstate:   true,
+			callGetTrees:    true,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "tile must be inside the estate",
+		},
+		{
+			name:            "Empty ID",
+			id:              "",
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+		{
+			name:             "Repository Error",
+			id:               "valid_estate_id",
+			callGetEstate:    true,
+			mockGetEstateErr: errors.New("repository error"),
+			expectedCode:     http.StatusInternalServerError,
+			expectedMessage:  "internal server error",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/"+tc.id+"/map.svg", nil)
+			rec := httptest.NewRecorder()
+
+			if tc.callGetEstate {
+				mockRepo.EXPECT().
+					GetEstateByID(gomock.Any(), tc.id).
+					Return(estate, tc.mockGetEstateErr).
+					Times(1)
+			}
+			if tc.callGetTrees {
+				mockRepo.EXPECT().
+					GetAllTreesInEstate(gomock.Any(), tc.id).
+					Return(trees, nil).
+					Times(1)
+				mockRepo.EXPECT().
+					GetRouteSteps(gomock.Any(), tc.id).
+					Return(routeSteps, nil).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdMapSvg(c, tc.id, tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				require.Equal(t, mimeImageSVG, rec.Header().Get(echo.HeaderContentType))
+				for _, expected := range tc.expectedContains {
+					require.Contains(t, rec.Body.String(), expected)
+				}
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestGetEstateIdMapPng(t *testing.T) {
+	e := echo.New()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositorier(ctrl)
+	srv := Server{
+		repository: mockRepo,
+	}
+
+	mockRepo.EXPECT().
+		GetEstateByID(gomock.Any(), "valid_estate_id").
+		Return(repository.Estate{ID: "valid_estate_id", Width: 4, Length: 2}, nil).
+		Times(1)
+	mockRepo.EXPECT().
+		GetAllTreesInEstate(gomock.Any(), "valid_estate_id").
+		Return([]repository.Tree{}, nil).
+		Times(1)
+	mockRepo.EXPECT().
+		GetRouteSteps(gomock.Any(), "valid_estate_id").
+		Return([]repository.RouteStep{}, nil).
+		Times(1)
+
+	req := httptest.NewRequest(http.MethodGet, "/estate/valid_estate_id/map.png", nil)
+	rec := httptest.NewRecorder()
+
+	c := e.NewContext(req, rec)
+	err := srv.GetEstateIdMapPng(c, "valid_estate_id", generated.GetEstateIdMapPngParams{})
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, mimeImagePNG, rec.Header().Get(echo.HeaderContentType))
+
+	img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 128, img.Bounds().Dx())
+	require.Equal(t, 64, img.Bounds().Dy())
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/geo"
+	"github.com/nahwinrajan/testswpro/mission"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+const (
+	missionFileQGCPlan   = "plan"
+	missionFileWaypoints = "waypoints"
+)
+
+// missionGrid lay the estate on the earth to export the patrol route as mission,
+// the origin given in params takes precedence over the estate origin and
+// the plot size defaults to the one of the drone patrolling the estate.
+func missionGrid(estate repository.Estate, params generated.GetEstateIdDronePlanParams) (geo.Grid, []generated.FieldError) {
+	grid := geo.Grid{
+		Bearing:  estate.Bearing,
+		PlotSize: float64(estateDrone(estate).PlotSize),
+	}
+	if params.PlotSize != nil {
+		grid.PlotSize = *params.PlotSize
+	}
+
+	originLat, originLon := estate.OriginLat, estate.OriginLon
+	if params.OriginLat != nil || params.OriginLon != nil {
+		originLat, originLon = params.OriginLat, params.OriginLon
+	}
+
+	fieldErrs := make([]generated.FieldError, 0)
+	if *params.Format != generated.QgcPlan && *params.Format != generated.MavlinkWaypoints {
+		fieldErrs = append(fieldErrs, fieldError("format", generated.ProblemCodeInvalidParameter, fmt.Sprintf("unknown format %s", *params.Format)))
+	}
+	switch {
+	case originLat == nil:
+		fieldErrs = append(fieldErrs, fieldError("origin_lat", generated.ProblemCodeInvalidParameter, "origin_lat is required"))
+	case *originLat < -90 || *originLat > 90:
+		fieldErrs = append(fieldErrs, fieldError("origin_lat", generated.ProblemCodeInvalidParameter, "origin_lat must be between -90 and 90"))
+	}
+	switch {
+	case originLon == nil:
+		fieldErrs = append(fieldErrs, fieldError("origin_lon", generated.ProblemCodeInvalidParameter, "origin_lon is required"))
+	case *originLon < -180 || *originLon > 180:
+		fieldErrs = append(fieldErrs, fieldError("origin_lon", generated.ProblemCodeInvalidParameter, "origin_lon must be between -180 and 180"))
+	}
+	if grid.PlotSize <= 0 {
+		fieldErrs = append(fieldErrs, fieldError("plot_size", generated.ProblemCodeInvalidParameter, "plot_size must be greater than 0"))
+	}
+	if len(fieldErrs) > 0 {
+		return geo.Grid{}, fieldErrs
+	}
+
+	grid.Origin = geo.Point{Latitude: *originLat, Longitude: *originLon}
+
+	return grid, nil
+}
+
+// writeMission respond with the mission file as attachment so it can be loaded
+// straight into the ground station.
+func writeMission(ectx echo.Context, estateID string, format generated.GetEstateIdDronePlanParamsFormat, droneMission mission.Mission) error {
+	contentType, extension := echo.MIMETextPlainCharsetUTF8, missionFileWaypoints
+	var data []byte
+	if format == generated.QgcPlan {
+		var err error
+		data, err = mission.EncodeQGCPlan(droneMission)
+		if err != nil {
+			return err
+		}
+		contentType, extension = echo.MIMEApplicationJSON, missionFileQGCPlan
+	} else {
+		data = mission.EncodeWaypoints(droneMission)
+	}
+
+	ectx.Response().Header().Set(
+		echo.HeaderContentDisposition,
+		fmt.Sprintf("attachment; filename=%q", "estate-"+estateID+"."+extension),
+	)
+
+	return ectx.Blob(http.StatusOK, contentType, data)
+}
@@ -4,23 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"sort"
-	"strings"
 
 	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/nahwinrajan/testswpro/routecodec"
 )
 
-// Ideally this is put in Config files, but since this is test
-// and we'll move things around better to play it safe and make it
-// as mere const for time being
-const (
-	distanceBetweenPlan = 10
-	treeHeightMax       = 30
-	treeHeightMin       = 1
-
-	// stepStrFormat: step_#,x,y,direction,step_distance,current_distance
-	stepStrFormat = "%d,%d,%d,%s,%d,%d;"
-)
+// tree height limits are the ones of api.yml, the spec is the single source of truth
+var treeHeightMin, treeHeightMax = specIntRange("CreateTreeRequestBody", "height")
 
 const (
 	directionEW         = "ew" // to the left x axis
@@ -32,15 +24,230 @@ const (
 	directionSameHeight = "--" // down adjusting for tree height
 )
 
+const routeStepsPageLimitDefault = 100
+
+// page limits are the ones of api.yml, like the tree height
+var routeStepsPageLimitMin, routeStepsPageLimitMax = specParamIntRange("/estate/{id}/drone-plan/steps", http.MethodGet, "limit")
+
+// max_distance limit is the one of api.yml, there is no maximum
+var maxDistanceMin, _ = specParamIntRange("/estate/{id}/drone-plan", http.MethodGet, "max_distance")
+
 // NOTE: ideally I would want to put this in "usecase" layer
 // but since this is following SDK and there is no such layer,
 // let's just follow as is
 
-// calculateEstateMetadata to make patrol function free of database dependencies
-// easier and simpler test cases.
-func (srv *Server) calculateEstateMetadata(
+// recalculateEstate patrol the estate with every tree in it and store the result,
+// return the estate with updated metadata and its patrol route.
+func (srv *Server) recalculateEstate(
+	ctx context.Context,
+	estate repository.Estate,
+) (repository.Estate, []repository.RouteStep, error) {
+	trees, err := srv.repository.GetAllTreesInEstate(ctx, estate.ID)
+	if err != nil {
+		return estate, nil, err
+	}
+
+	return srv.updateEstateMetadata(ctx, estate, trees)
+}
+
+// updateEstateMetadata patrol the estate with the given trees, which must be
+// every tree in the estate, and store the result. The stats status is left to
+// the job completing the recalculation.
+func (srv *Server) updateEstateMetadata(
+	ctx context.Context,
+	estate repository.Estate,
+	trees []repository.Tree,
+) (repository.Estate, []repository.RouteStep, error) {
+	lenTrees := len(trees)
+	switch {
+	case lenTrees < 1 && estate.Count < 1:
+		return estate, nil, nil
+	case lenTrees < 1:
+		// every tree is gone, back to the estate nobody patrolled yet
+		err := srv.repository.UpdateEstate(ctx, estate.ID, 0, 0, 0, 0, 0, nil)
+		if err != nil {
+			return estate, nil, err
+		}
+
+		estate.Count, estate.Min, estate.Max, estate.Median, estate.PatrolDistance, estate.PatrolRoute = 0, 0, 0, 0, 0, ""
+		return estate, nil, nil
+	}
+
+	estate, err := srv.estateZones(ctx, estate)
+	if err != nil {
+		return estate, nil, err
+	}
+
+	minHeight, maxHeight, medianHeight, routeDistance, routeSteps, err := srv.patrol(estate, trees)
+	if err != nil {
+		return estate, nil, err
+	}
+
+	err = srv.repository.UpdateEstate(
+		ctx,
+		estate.ID,
+		lenTrees,
+		minHeight,
+		maxHeight,
+		medianHeight,
+		routeDistance,
+		routeSteps,
+	)
+	if err != nil {
+		return estate, nil, err
+	}
+
+	estate.Count = lenTrees
+	estate.Min = minHeight
+	estate.Max = maxHeight
+	estate.Median = medianHeight
+	estate.PatrolDistance = routeDistance
+	estate.PatrolRoute = ""
+
+	return estate, routeSteps, nil
+}
+
+// patrolRoute return the patrol route steps of the estate, estates patrolled
+// before the steps are stored on their own still have the legacy text route.
+func (srv *Server) patrolRoute(
+	ctx context.Context,
+	estate repository.Estate,
+) ([]repository.RouteStep, error) {
+	routeSteps, err := srv.repository.GetRouteSteps(ctx, estate.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(routeSteps) > 0 || len(estate.PatrolRoute) == 0 {
+		return routeSteps, nil
+	}
+
+	return routecodec.Decode(estate.PatrolRoute)
+}
+
+// patrolTreesAndRoute return every tree in the estate and its patrol route, the route
+// cleared by incremental recalculation is rebuilt, see rebuildPatrolRoute.
+func (srv *Server) patrolTreesAndRoute(
+	ctx context.Context,
+	estate repository.Estate,
+) (repository.Estate, []repository.Tree, []repository.RouteStep, error) {
+	trees, err := srv.repository.GetAllTreesInEstate(ctx, estate.ID)
+	if err != nil {
+		return estate, nil, nil, err
+	}
+
+	routeSteps, err := srv.patrolRoute(ctx, estate)
+	if err != nil {
+		return estate, nil, nil, err
+	}
+
+	if len(routeSteps) == 0 && len(trees) > 0 {
+		return srv.rebuildPatrolRoute(ctx, estate.ID)
+	}
+
+	return estate, trees, routeSteps, nil
+}
+
+// rebuildPatrolRoute patrol the estate again while holding its lock and store only the route
+// steps, the trees and the route are read again under the lock. The stats are left to the
+// tree writes and the recalculation jobs, a read must not overwrite them.
+func (srv *Server) rebuildPatrolRoute(
 	ctx context.Context,
 	estateID string,
+) (estate repository.Estate, trees []repository.Tree, routeSteps []repository.RouteStep, err error) {
+	err = srv.withEstateLock(ctx, estateID, func(txSrv *Server, locked repository.Estate) error {
+		estate = locked
+		trees, err = txSrv.repository.GetAllTreesInEstate(ctx, estateID)
+		if err != nil {
+			return err
+		}
+
+		// another request may have rebuilt it while we waited for the lock
+		routeSteps, err = txSrv.patrolRoute(ctx, estate)
+		if err != nil || len(routeSteps) > 0 || len(trees) == 0 {
+			return err
+		}
+
+		estate, err = txSrv.estateZones(ctx, estate)
+		if err != nil {
+			return err
+		}
+
+		_, _, _, _, routeSteps, err = txSrv.patrol(estate, trees)
+		if err != nil {
+			return err
+		}
+
+		return txSrv.repository.UpdateRouteSteps(ctx, estateID, routeSteps)
+	})
+	if err != nil {
+		return estate, nil, nil, err
+	}
+
+	return estate, trees, routeSteps, nil
+}
+
+// patrolRoutePage return at most limit patrol route steps after the step afterStep
+// which travel overlaps [fromDistance, toDistance]. The legacy text route and the
+// route cleared by incremental recalculation can not be paged in database,
+// those are read (or rebuilt) whole and paged here.
+func (srv *Server) patrolRoutePage(
+	ctx context.Context,
+	estate repository.Estate,
+	afterStep, fromDistance, toDistance, limit int,
+) ([]repository.RouteStep, error) {
+	if len(estate.PatrolRoute) == 0 {
+		routeSteps, err := srv.repository.GetRouteStepsPage(ctx, estate.ID, afterStep, fromDistance, toDistance, limit)
+		if err != nil || len(routeSteps) > 0 || estate.Count < 1 {
+			return routeSteps, err
+		}
+	}
+
+	// empty page, either nothing matches or there is no stored route to page
+	routeSteps, err := srv.patrolRoute(ctx, estate)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(routeSteps) == 0 && estate.Count > 0 {
+		_, _, routeSteps, err = srv.rebuildPatrolRoute(ctx, estate.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pageRouteSteps(routeSteps, afterStep, fromDistance, toDistance, limit), nil
+}
+
+// pageRouteSteps is GetRouteStepsPage for the route steps already in memory.
+func pageRouteSteps(routeSteps []repository.RouteStep, afterStep, fromDistance, toDistance, limit int) []repository.RouteStep {
+	page := make([]repository.RouteStep, 0, min(limit, len(routeSteps)))
+	for _, routeStep := range routeSteps {
+		if len(page) >= limit {
+			break
+		}
+
+		if routeStep.Step <= afterStep ||
+			routeStep.CurrentDistance < fromDistance ||
+			routeStep.CurrentDistance-routeStep.StepDistance > toDistance {
+			continue
+		}
+		page = append(page, routeStep)
+	}
+
+	return page
+}
+
+// calculateEstateMetadataIncremental update the estate metadata right after the tree on
+// one plot is planted (before is nil), grown (both given) or felled (after is nil) without
+// reloading every tree in the estate. Stats are derived from the number of trees per height
+// and the patrol distance only changes by the vertical moves over that plot, so only its
+// neighbours in the patrol order are needed.
+// The patrol route is cleared, it is rebuilt when somebody actually needs it.
+func (srv *Server) calculateEstateMetadataIncremental(
+	ctx context.Context,
+	estateID string,
+	before, after *repository.Tree,
 ) error {
 	estate, err := srv.repository.GetEstateByID(
 		ctx,
@@ -50,40 +257,151 @@ func (srv *Server) calculateEstateMetadata(
 		return err
 	}
 
-	trees, err := srv.repository.GetAllTreesInEstate(ctx, estateID)
-	if err != nil {
+	// first tree in the estate, there is no previous patrol to build upon
+	if before == nil && estate.Count < 1 {
+		_, _, err = srv.recalculateEstate(ctx, estate)
 		return err
 	}
 
-	lenTrees := len(trees)
-	if lenTrees < 1 {
-		return nil
+	plot := after
+	if plot == nil {
+		plot = before
 	}
 
-	minHeight, maxHeight, medianHeight, routeDistance, routePath, err := srv.patrol(estate, trees)
+	prevTree, nextTree, err := srv.repository.GetPatrolNeighbourTrees(ctx, estateID, plot.X, plot.Y)
 	if err != nil {
 		return err
 	}
 
+	heightCounts, err := srv.repository.GetTreeHeightCounts(ctx, estateID)
+	if err != nil {
+		return err
+	}
+
+	count, minHeight, maxHeight, medianHeight := heightStats(heightCounts)
+	switch {
+	case count < 1 && after != nil:
+		return errors.New("no trees found in estate")
+	case count < 1:
+		// last tree felled, back to the estate nobody patrolled yet
+		return srv.repository.UpdateEstate(ctx, estateID, 0, 0, 0, 0, 0, nil)
+	}
+
+	// the way from previous to next tree over the plot before is replaced by the way over the plot after
+	drone := estateDrone(estate)
+	prevAltitude, nextAltitude := droneAltitude(drone, prevTree), droneAltitude(drone, nextTree)
+	routeDistance := estate.PatrolDistance -
+		patrolDistanceOver(drone, prevAltitude, before, nextAltitude) +
+		patrolDistanceOver(drone, prevAltitude, after, nextAltitude)
+
 	err = srv.repository.UpdateEstate(
 		ctx,
 		estateID,
-		lenTrees,
+		count,
 		minHeight,
 		maxHeight,
 		medianHeight,
 		routeDistance,
-		routePath,
+		nil,
 	)
 
 	return err
 }
 
-// patrol do patrol the estate and calculate values for the stats and distance
+// patrolDistanceOver return the vertical distance from the previous to the next tree
+// passing over the plot, the drone keeps its altitude over plot without tree.
+func patrolDistanceOver(drone repository.DroneProfile, prevAltitude int, tree *repository.Tree, nextAltitude int) int {
+	if tree == nil {
+		return abs(nextAltitude - prevAltitude)
+	}
+
+	treeAltitude := droneAltitude(drone, tree)
+
+	return abs(treeAltitude-prevAltitude) + abs(nextAltitude-treeAltitude)
+}
+
+// heightStats calculate count, min, max and median of tree heights
+// given the number of trees for each height.
+func heightStats(heightCounts map[int]int) (count, minHeight, maxHeight, medianHeight int) {
+	for height := treeHeightMin; height <= treeHeightMax; height++ {
+		if heightCounts[height] < 1 {
+			continue
+		}
+
+		if count == 0 {
+			minHeight = height
+		}
+		maxHeight = height
+		count += heightCounts[height]
+	}
+
+	if count < 1 {
+		return
+	}
+
+	// nth return the height of the nth (0 index base) tree sorted by height
+	nth := func(n int) int {
+		for height := treeHeightMin; height <= treeHeightMax; height++ {
+			if n < heightCounts[height] {
+				return height
+			}
+			n -= heightCounts[height]
+		}
+		return maxHeight
+	}
+
+	medianHeight = nth(count / 2)
+	if count%2 == 0 {
+		medianHeight = (nth(count/2-1) + nth(count/2)) / 2
+	}
+
+	return
+}
+
+// estateDrone return the drone profile of the estate, the estate without one
+// (e.g. not read from the database) is patrolled by the default drone.
+func estateDrone(estate repository.Estate) repository.DroneProfile {
+	if estate.Drone == (repository.DroneProfile{}) {
+		return repository.DefaultDroneProfile()
+	}
+
+	return estate.Drone
+}
+
+// droneAltitude return the altitude of drone monitoring the tree, the clearance above
+// the tree top kept within the cruise altitudes of the drone. No tree means the drone
+// is on the ground.
+func droneAltitude(drone repository.DroneProfile, tree *repository.Tree) int {
+	if tree == nil {
+		return 0
+	}
+
+	return min(max(tree.Height+drone.MonitorClearance, drone.CruiseAltitudeMin), drone.MaxAltitude)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// patrol do patrol the estate and calculate values for the stats and distance,
+// the drone follows the canopy
 func (srv *Server) patrol(
 	estate repository.Estate,
 	trees []repository.Tree,
-) (min, max, median, routeDistance int, routePath string, err error) {
+) (min, max, median, routeDistance int, routeSteps []repository.RouteStep, err error) {
+	return srv.patrolAs(estate, trees, flightModeCanopyFollow)
+}
+
+// patrolAs do patrol the estate flying as the flight mode tells the drone
+func (srv *Server) patrolAs(
+	estate repository.Estate,
+	trees []repository.Tree,
+	mode flightMode,
+) (min, max, median, routeDistance int, routeSteps []repository.RouteStep, err error) {
 	if estate.Width == 0 || estate.Length == 0 {
 		err = errors.New("invalid estate value")
 		return
@@ -95,20 +413,23 @@ func (srv *Server) patrol(
 		return
 	}
 
-	// we are making it so it is base 1 instead index 0
-	lenRow := estate.Length + 1
-	lenCol := estate.Width + 1
-	fields := make([][]int, lenRow)
-	for rowIdx := 0; rowIdx < lenRow; rowIdx++ {
-		fields[rowIdx] = make([]int, lenCol)
-	}
+	drone := estateDrone(estate)
+
+	// only the planted plots are kept (sorted in the patrol order), the empty stretches
+	// between them are jumped over so we never allocate the whole Width x Length fields
+	plantedTrees := make([]repository.Tree, lenTrees)
+	copy(plantedTrees, trees)
+	sort.Slice(plantedTrees, func(i, j int) bool {
+		return patrolBefore(plantedTrees[i].X, plantedTrees[i].Y, plantedTrees[j].X, plantedTrees[j].Y)
+	})
 
-	// TODO: in case performance detoriate, do this within the distance / route loop
-	// populate the fields with respective trees in their plot
 	heights := make([]int, 0, lenTrees)
 	var minHeight, maxHeight int
-	for _, tree := range trees {
-		fields[tree.Y][tree.X] = tree.Height
+	for _, tree := range plantedTrees {
+		if tree.X < 1 || tree.X > estate.Width || tree.Y < 1 || tree.Y > estate.Length {
+			err = fmt.Errorf("tree on plot (%d,%d) is outside of the estate", tree.X, tree.Y)
+			return
+		}
 
 		heights = append(heights, tree.Height)
 		// if this is the first iteration
@@ -131,85 +452,175 @@ func (srv *Server) patrol(
 		medianHeight = (heights[lenTrees/2-1] + heights[lenTrees/2]) / 2
 	}
 
+	// the zones the drone flies around or over, the trees in forbidden zones are left out
+	zm := newZoneMap(estate)
+	patrolPlots, floors := zm.patrolPlots(plantedTrees)
+	lenPlots := len(patrolPlots)
+
 	var currDistance, verticalMove int
 	var currDirection string
-	var strb strings.Builder
-	currDroneHeight, currTreeHeight, currStepNumber := 0, 0, 0
-	var monitorDistance = 1
-
-	// stepStrFormat: step_#,x,y,direction,step_distance,current_distance
-	// stepStrFormat = "%d,%d,%d,%s,%d,%d;"
-
-	for y := 1; y < lenRow; y++ {
-		// calculate the distance between row on row change
-		if y > 1 {
-			currDistance += distanceBetweenPlan
-			currDirection = directionSN // always go up on changing row
-			currStepNumber++
-
-			fmt.Fprintf(&strb, stepStrFormat, currStepNumber, estate.Width, y, currDirection, distanceBetweenPlan, currDistance)
-		}
-
-		// crude hack to mimick drone movement
-		if y%2 == 0 {
-			// if it is even row, loop from end (width) to start / west to east
-			// the tree given by input is 1 index base not 0 index base, thus we just leave the 0 row and col empty
-			for x := lenCol - 1; x > 0; x-- {
-				currTreeHeight = fields[y][x]
-				// calculate vertical movement distance if there is tree planted
-				if currTreeHeight > 0 {
-					if currDroneHeight == (currTreeHeight + monitorDistance) {
-						verticalMove = 0
-						currDirection = directionSameHeight
-					} else if (currTreeHeight + monitorDistance) < currDroneHeight {
-						verticalMove = (currDroneHeight - (currTreeHeight + monitorDistance))
-						currDirection = directionVD
-					} else if (currTreeHeight + monitorDistance) > currDroneHeight {
-						verticalMove = ((currTreeHeight + monitorDistance) - currDroneHeight)
-						currDirection = directionVU
-					}
-
-					currDistance = currDistance + verticalMove
-					currDroneHeight = currTreeHeight + monitorDistance
-					currStepNumber++
-
-					fmt.Fprintf(&strb, stepStrFormat, currStepNumber, x, y, currDirection, verticalMove, currDistance)
-				}
+	currDroneHeight := 0
+	steps := make([]repository.RouteStep, 0, 2*lenPlots+2*estate.Length)
+
+	// step record the drone move from/on plot (x,y)
+	step := func(x, y, stepDistance int) {
+		currDistance += stepDistance
+		steps = append(steps, repository.RouteStep{
+			Step:            len(steps) + 1,
+			X:               x,
+			Y:               y,
+			Direction:       currDirection,
+			StepDistance:    stepDistance,
+			CurrentDistance: currDistance,
+			Altitude:        currDroneHeight,
+		})
+	}
 
-				currDistance += distanceBetweenPlan
+	// jump moves the drone over count plots in one step starting from plot (x,y)
+	jump := func(x, y, count int) {
+		if count < 1 {
+			return
+		}
+
+		step(x, y, count*drone.PlotSize)
+	}
+
+	// altitude of the drone over every planted tree
+	altitudes := mode.altitudes(drone, patrolPlots, floors)
+
+	// monitor adjust the drone height over the tree planted on plot (x,y)
+	monitor := func(x, y, altitude int) {
+		if currDroneHeight == altitude {
+			verticalMove = 0
+			currDirection = directionSameHeight
+		} else if altitude < currDroneHeight {
+			verticalMove = (currDroneHeight - altitude)
+			currDirection = directionVD
+		} else if altitude > currDroneHeight {
+			verticalMove = (altitude - currDroneHeight)
+			currDirection = directionVU
+		}
+
+		currDroneHeight = altitude
+		step(x, y, verticalMove)
+	}
+
+	// detour fly from plot from to plot to around the forbidden plots, climbing first over
+	// every tree and min altitude zone on the way. It tells whether there is a way at all.
+	treeAltitudes := zm.treeAltitudes(drone, plantedTrees)
+	detour := func(from, to repository.Plot) bool {
+		plots := zm.detour(from, to)
+		if plots == nil {
+			return false
+		}
+
+		if altitude := zm.clearance(plots, treeAltitudes); altitude > currDroneHeight {
+			monitor(from.X, from.Y, altitude)
+		}
+
+		for i := 1; i < len(plots); {
+			prev, plot := plots[i-1], plots[i]
+			switch {
+			case plot.Y > prev.Y:
+				currDirection = directionSN
+				step(plot.X, plot.Y, drone.PlotSize)
+				i++
+				continue
+			case plot.Y < prev.Y:
+				currDirection = directionNS
+				step(plot.X, plot.Y, drone.PlotSize)
+				i++
+				continue
+			}
+
+			// the moves along the row in one step from the plot the drone is over
+			dx, count := plot.X-prev.X, 1
+			for i+count < len(plots) && plots[i+count].Y == prev.Y && plots[i+count].X-plots[i+count-1].X == dx {
+				count++
+			}
+			currDirection = directionEW
+			if dx < 0 {
 				currDirection = directionWE
-				currStepNumber++
-				fmt.Fprintf(&strb, stepStrFormat, currStepNumber, x, y, currDirection, verticalMove, currDistance)
 			}
-		} else {
-			// if its odd row, loop from column start to column end (east to west)
-			for x := 1; x < lenCol; x++ {
-				currTreeHeight = fields[y][x]
-				// calculate vertical movement distance if there is tree planted
-				if currTreeHeight > 0 {
-					if currDroneHeight == (currTreeHeight + monitorDistance) {
-						verticalMove = 0
-						currDirection = directionSameHeight
-					} else if (currTreeHeight + monitorDistance) < currDroneHeight {
-						verticalMove = (currDroneHeight - (currTreeHeight + monitorDistance))
-						currDirection = directionVD
-					} else if (currTreeHeight + monitorDistance) > currDroneHeight {
-						verticalMove = ((currTreeHeight + monitorDistance) - currDroneHeight)
-						currDirection = directionVU
-					}
-
-					currDistance = currDistance + verticalMove
-					currDroneHeight = currTreeHeight + monitorDistance
-					currStepNumber++
-
-					fmt.Fprintf(&strb, stepStrFormat, currStepNumber, x, y, currDirection, verticalMove, currDistance)
+			jump(prev.X, prev.Y, count)
+			i += count
+		}
+
+		return true
+	}
+
+	idxPlot := 0
+	// at is the plot the drone is over once it took off, pastRowEnd tells it flew
+	// past the end of its row, right before the row change
+	var at *repository.Plot
+	var pastRowEnd bool
+	for y := 1; y <= estate.Length; y++ {
+		// plots of this row, sorted in the patrol order
+		rowStart := idxPlot
+		for idxPlot < lenPlots && patrolPlots[idxPlot].Y == y {
+			idxPlot++
+		}
+
+		// crude hack to mimick drone movement, odd row loops from column start to column end
+		// (east to west), even row from end (width) to start (west to east)
+		forward := y%2 == 1
+		rowDirection, dx, rowEnd := directionEW, 1, estate.Width
+		if !forward {
+			rowDirection, dx, rowEnd = directionWE, -1, 1
+		}
+
+		i := rowStart
+		for _, run := range zm.clearRuns(y, forward) {
+			// plots of the run, the run from the first plot to the last one in the patrol order
+			runEnd := i
+			for runEnd < idxPlot && (patrolPlots[runEnd].X-run.to)*dx <= 0 {
+				runEnd++
+			}
+
+			switch {
+			case at == nil:
+				// the drone takes off from the first plot of the patrol
+			case pastRowEnd && at.Y == y-1 && at.X == run.from:
+				// calculate the distance between row on row change
+				currDirection = directionSN // always go up on changing row
+				step(run.from, y, drone.PlotSize)
+			case !detour(*at, repository.Plot{X: run.from, Y: y}):
+				// no way to the run around the forbidden plots, it is left out
+				i = runEnd
+				continue
+			}
+
+			// the drone flies past the end of the row, and stops over the last plot
+			// of the run before the forbidden plots
+			stop := run.to
+			if run.to == rowEnd {
+				stop += dx
+			}
+
+			x := run.from
+			for ; i < runEnd; i++ {
+				plot := patrolPlots[i]
+				// a plot without tree is only flown over to climb above a zone
+				if plot.Height == 0 && altitudes[i] == currDroneHeight {
+					continue
 				}
 
-				currDistance += distanceBetweenPlan
-				currDirection = directionEW
-				currStepNumber++
-				fmt.Fprintf(&strb, stepStrFormat, currStepNumber, x, y, currDirection, verticalMove, currDistance)
+				currDirection = rowDirection
+				jump(x, y, (plot.X-x)*dx)
+
+				monitor(plot.X, y, altitudes[i])
+				x = plot.X
+				if plot.X != run.to || run.to == rowEnd {
+					currDirection = rowDirection
+					jump(plot.X, y, 1)
+					x = plot.X + dx
+				}
 			}
+
+			currDirection = rowDirection
+			jump(x, y, (stop-x)*dx)
+
+			at, pastRowEnd = &repository.Plot{X: run.to, Y: y}, run.to == rowEnd
 		}
 	}
 
@@ -217,5 +628,72 @@ func (srv *Server) patrol(
 		currDistance = currDistance + currDroneHeight
 	}
 
-	return minHeight, maxHeight, medianHeight, currDistance, strb.String(), nil
+	return minHeight, maxHeight, medianHeight, currDistance, steps, nil
+}
+
+// patrolBefore tells the drone patrols plot (x1,y1) before plot (x2,y2)
+func patrolBefore(x1, y1, x2, y2 int) bool {
+	if y1 != y2 {
+		return y1 < y2
+	}
+	// odd row goes from column start to column end, even row the other way around
+	if y1%2 == 1 {
+		return x1 < x2
+	}
+	return x1 > x2
+}
+
+// calculateMaxDistance return the plot (x,y) where the drone rests after travelling
+// maxDistance along the estate patrol route. When the battery runs out in the middle
+// of a step the drone rests on the plot it is currently above:
+//   - climbing/descending over a tree, it is still above that tree plot
+//   - moving between plots, it is above the next plot only once it passed the half way
+//     of it, a single step may jump over several empty plots
+func (srv *Server) calculateMaxDistance(estate repository.Estate, routeSteps []repository.RouteStep, maxDistance int) (restX, restY int) {
+	// drone always take off from the first plot of the route
+	restX, restY = 1, 1
+	if len(routeSteps) > 0 {
+		restX, restY = routeSteps[0].X, routeSteps[0].Y
+	}
+	plotSize := estateDrone(estate).PlotSize
+
+	var prevDistance int
+	for _, routeStep := range routeSteps {
+		travelled := min(routeStep.CurrentDistance, maxDistance) - prevDistance
+		completed := routeStep.CurrentDistance <= maxDistance
+
+		switch routeStep.Direction {
+		case directionEW, directionWE:
+			// horizontal move may jump over several empty plots at once
+			plots := travelled / plotSize
+			if !completed && (travelled%plotSize)*2 > plotSize {
+				plots++
+			}
+
+			restY = routeStep.Y
+			if routeStep.Direction == directionEW {
+				restX = min(routeStep.X+plots, estate.Width)
+			} else {
+				restX = max(routeStep.X-plots, 1)
+			}
+		case directionSN, directionNS:
+			// the drone keeps its column on row change
+			if completed || travelled*2 > routeStep.StepDistance {
+				restY = routeStep.Y
+			}
+		default:
+			// vertical move stays on the same plot
+			restX, restY = routeStep.X, routeStep.Y
+		}
+
+		if !completed {
+			// ran out of battery in the middle of this step
+			return
+		}
+
+		prevDistance = routeStep.CurrentDistance
+	}
+
+	// whatever distance left is for landing on the last plot
+	return
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/nahwinrajan/testswpro/routecodec"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
@@ -37,9 +38,143 @@ func TestPatrol(t *testing.T) {
 			expectedMax:      5,
 			expectedMedian:   4,
 			expectedDistance: 64,
-			expectedPath:     "1,1,1,ew,0,10;2,2,1,vu,6,16;3,2,1,ew,6,26;4,3,1,vd,2,28;5,3,1,ew,2,38;6,4,1,vu,1,39;7,4,1,ew,1,49;8,5,1,ew,1,59;",
+			expectedPath:     "1,1,1,ew,10,10;2,2,1,vu,6,16;3,2,1,ew,10,26;4,3,1,vd,2,28;5,3,1,ew,10,38;6,4,1,vu,1,39;7,4,1,ew,10,49;8,5,1,ew,10,59;",
 			expectedError:    nil,
 		},
+		{
+			name: "Multi row estate with empty stretches",
+			estate: repository.Estate{
+				Width:  5,
+				Length: 3,
+			},
+			trees: []repository.Tree{
+				{X: 2, Y: 2, Height: 2},
+				{X: 4, Y: 1, Height: 9},
+			},
+			expectedMin:      2,
+			expectedMax:      9,
+			expectedMedian:   5,
+			expectedDistance: 190,
+			expectedPath:     "1,1,1,ew,30,30;2,4,1,vu,10,40;3,4,1,ew,10,50;4,5,1,ew,10,60;5,5,2,sn,10,70;6,5,2,we,30,100;7,2,2,vd,7,107;8,2,2,we,10,117;9,1,2,we,10,127;10,1,3,sn,10,137;11,1,3,ew,50,187;",
+			expectedError:    nil,
+		},
+		{
+			name: "Drone profile with altitude floor and ceiling",
+			estate: repository.Estate{
+				Width:  5,
+				Length: 1,
+				Drone:  repository.DroneProfile{PlotSize: 5, MonitorClearance: 2, CruiseAltitudeMin: 6, MaxAltitude: 8},
+			},
+			trees: []repository.Tree{
+				{X: 2, Y: 1, Height: 5},
+				{X: 3, Y: 1, Height: 3},
+				{X: 4, Y: 1, Height: 7},
+			},
+			expectedMin:      3,
+			expectedMax:      7,
+			expectedMedian:   5,
+			expectedDistance: 43,
+			expectedPath:     "1,1,1,ew,5,5;2,2,1,vu,7,12;3,2,1,ew,5,17;4,3,1,vd,1,18;5,3,1,ew,5,23;6,4,1,vu,2,25;7,4,1,ew,5,30;8,5,1,ew,5,35;",
+			expectedError:    nil,
+		},
+		{
+			name: "Detour around a forbidden zone",
+			estate: repository.Estate{
+				Width:  5,
+				Length: 3,
+				Zones: []repository.Zone{
+					{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 2, Y: 2}, {X: 3, Y: 2}, {X: 3, Y: 2}, {X: 2, Y: 2}}},
+				},
+			},
+			trees: []repository.Tree{
+				{X: 2, Y: 2, Height: 2},
+				{X: 4, Y: 1, Height: 9},
+				{X: 5, Y: 3, Height: 4},
+			},
+			// the tree in the zone is left out, the drone flies over row 3 and back to go around it
+			expectedMin:      2,
+			expectedMax:      9,
+			expectedMedian:   4,
+			expectedDistance: 210,
+			expectedPath:     "1,1,1,ew,30,30;2,4,1,vu,10,40;3,4,1,ew,10,50;4,5,1,ew,10,60;5,5,2,sn,10,70;6,5,2,we,10,80;7,4,3,sn,10,90;8,4,3,we,30,120;9,1,2,ns,10,130;10,1,2,we,10,140;11,1,3,sn,10,150;12,1,3,ew,40,190;13,5,3,vd,5,195;14,5,3,ew,10,205;",
+			expectedError:    nil,
+		},
+		{
+			name: "Climb over a min altitude zone",
+			estate: repository.Estate{
+				Width:  5,
+				Length: 3,
+				Zones: []repository.Zone{
+					{Kind: repository.ZoneKindMinAltitude, MinAltitude: 20, Polygon: []repository.Plot{{X: 3, Y: 1}, {X: 4, Y: 1}, {X: 4, Y: 2}, {X: 3, Y: 2}}},
+				},
+			},
+			trees: []repository.Tree{
+				{X: 2, Y: 2, Height: 2},
+				{X: 4, Y: 1, Height: 9},
+				{X: 5, Y: 3, Height: 4},
+			},
+			// the drone climbs over the plot right before the zone and stays up until the next tree after it
+			expectedMin:      2,
+			expectedMax:      9,
+			expectedMedian:   4,
+			expectedDistance: 214,
+			expectedPath:     "1,1,1,ew,10,10;2,2,1,vu,20,30;3,2,1,ew,10,40;4,3,1,ew,10,50;5,4,1,--,0,50;6,4,1,ew,10,60;7,5,1,ew,10,70;8,5,2,sn,10,80;9,5,2,we,30,110;10,2,2,vd,17,127;11,2,2,we,10,137;12,1,2,we,10,147;13,1,3,sn,10,157;14,1,3,ew,40,197;15,5,3,vu,2,199;16,5,3,ew,10,209;",
+			expectedError:    nil,
+		},
+		{
+			name: "Rows cut off by a forbidden zone",
+			estate: repository.Estate{
+				Width:  5,
+				Length: 3,
+				Zones: []repository.Zone{
+					{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 1, Y: 2}, {X: 5, Y: 2}, {X: 5, Y: 2}, {X: 1, Y: 2}}},
+				},
+			},
+			trees: []repository.Tree{
+				{X: 2, Y: 2, Height: 2},
+				{X: 4, Y: 1, Height: 9},
+				{X: 5, Y: 3, Height: 4},
+			},
+			expectedMin:      2,
+			expectedMax:      9,
+			expectedMedian:   4,
+			expectedDistance: 70,
+			expectedPath:     "1,1,1,ew,30,30;2,4,1,vu,10,40;3,4,1,ew,10,50;4,5,1,ew,10,60;",
+			expectedError:    nil,
+		},
+		{
+			name: "Estate with boundary",
+			estate: repository.Estate{
+				Width:    5,
+				Length:   3,
+				Boundary: []repository.Plot{{X: 4, Y: 1}, {X: 5, Y: 2}, {X: 3, Y: 3}, {X: 3, Y: 3}, {X: 1, Y: 2}, {X: 2, Y: 1}},
+			},
+			trees: []repository.Tree{
+				{X: 2, Y: 2, Height: 2},
+				{X: 4, Y: 1, Height: 9},
+				{X: 5, Y: 3, Height: 4},
+			},
+			// the drone takes off from the first plot of the estate, goes around the plots outside
+			// of it and leaves the tree outside out
+			expectedMin:      2,
+			expectedMax:      9,
+			expectedMedian:   4,
+			expectedDistance: 140,
+			expectedPath:     "1,2,1,ew,20,20;2,4,1,vu,10,30;3,4,2,sn,10,40;4,4,2,ew,10,50;5,5,2,we,30,80;6,2,2,vd,7,87;7,2,2,we,10,97;8,1,2,we,10,107;9,1,2,ew,20,127;10,3,3,sn,10,137;",
+			expectedError:    nil,
+		},
+		{
+			name: "Tree outside of estate",
+			estate: repository.Estate{
+				Width:  5,
+				Length: 1,
+			},
+			trees: []repository.Tree{
+				{X: 2, Y: 1, Height: 5},
+				{X: 6, Y: 1, Height: 3},
+			},
+			expectedError: errors.New("tree on plot (6,1) is outside of the estate"),
+		},
 		{
 			name:   "Empty estate",
 			estate: repository.Estate{},
@@ -104,64 +239,535 @@ func TestPatrol(t *testing.T) {
 			require.Equal(t, tc.expectedMax, max)
 			require.Equal(t, tc.expectedMedian, median)
 			require.Equal(t, tc.expectedDistance, distance)
-			require.Equal(t, tc.expectedPath, path)
+			require.Equal(t, tc.expectedPath, routecodec.Encode(path))
 			require.Equal(t, tc.expectedError, err)
+
+			// drone lands from the altitude of its last step
+			if len(path) > 0 {
+				lastStep := path[len(path)-1]
+				require.Equal(t, tc.expectedDistance, lastStep.CurrentDistance+lastStep.Altitude)
+			}
 		})
 	}
 }
 
-func TestCalculateEstateMetadata(t *testing.T) {
+func TestCalculateMaxDistance(t *testing.T) {
+	singleRowEstate := repository.Estate{
+		Width:          5,
+		Length:         1,
+		PatrolDistance: 64,
+		PatrolRoute:    "1,1,1,ew,0,10;2,2,1,vu,6,16;3,2,1,ew,6,26;4,3,1,vd,2,28;5,3,1,ew,2,38;6,4,1,vu,1,39;7,4,1,ew,1,49;8,5,1,ew,1,59;",
+	}
+	multiRowEstate := repository.Estate{
+		Width:          3,
+		Length:         2,
+		PatrolDistance: 90,
+		PatrolRoute:    "1,1,1,ew,0,10;2,2,1,vu,3,13;3,2,1,ew,3,23;4,3,1,ew,3,33;5,3,2,sn,10,43;6,3,2,we,3,53;7,2,2,we,3,63;8,1,2,vu,7,70;9,1,2,we,7,80;",
+	}
+
+	sparseEstate := repository.Estate{
+		Width:          5,
+		Length:         3,
+		PatrolDistance: 190,
+		PatrolRoute:    "1,1,1,ew,30,30;2,4,1,vu,10,40;3,4,1,ew,10,50;4,5,1,ew,10,60;5,5,2,sn,10,70;6,5,2,we,30,100;7,2,2,vd,7,107;8,2,2,we,10,117;9,1,2,we,10,127;10,1,3,sn,10,137;11,1,3,ew,50,187;",
+	}
+
 	tests := []struct {
-		name                string
-		estate              repository.Estate
-		trees               []repository.Tree
-		mockGetEstateErr    error
-		mockGetAllTreesErr  error
-		mockUpdateEstateErr error
-		expectedUpdateErr   error
+		name        string
+		estate      repository.Estate
+		maxDistance int
+		expectedX   int
+		expectedY   int
 	}{
 		{
-			name: "Valid estate with trees",
-			estate: repository.Estate{
-				Width:  5,
-				Length: 1,
+			name:        "No distance at all",
+			estate:      singleRowEstate,
+			maxDistance: 0,
+			expectedX:   1,
+			expectedY:   1,
+		},
+		{
+			name:        "Exact match on step",
+			estate:      singleRowEstate,
+			maxDistance: 10,
+			expectedX:   2,
+			expectedY:   1,
+		},
+		{
+			name:        "Mid transit before half way",
+			estate:      singleRowEstate,
+			maxDistance: 4,
+			expectedX:   1,
+			expectedY:   1,
+		},
+		{
+			name:        "Mid transit after half way",
+			estate:      singleRowEstate,
+			maxDistance: 6,
+			expectedX:   2,
+			expectedY:   1,
+		},
+		{
+			name:        "Mid climb over tree",
+			estate:      singleRowEstate,
+			maxDistance: 13,
+			expectedX:   2,
+			expectedY:   1,
+		},
+		{
+			name:        "Enough for whole patrol",
+			estate:      singleRowEstate,
+			maxDistance: 100,
+			expectedX:   5,
+			expectedY:   1,
+		},
+		{
+			name:        "Mid row change before half way",
+			estate:      multiRowEstate,
+			maxDistance: 36,
+			expectedX:   3,
+			expectedY:   1,
+		},
+		{
+			name:        "Mid row change after half way",
+			estate:      multiRowEstate,
+			maxDistance: 40,
+			expectedX:   3,
+			expectedY:   2,
+		},
+		{
+			name:        "Mid climb on even row",
+			estate:      multiRowEstate,
+			maxDistance: 65,
+			expectedX:   1,
+			expectedY:   2,
+		},
+		{
+			name:        "Landing on last plot of even row",
+			estate:      multiRowEstate,
+			maxDistance: 85,
+			expectedX:   1,
+			expectedY:   2,
+		},
+		{
+			name:        "Mid jump over empty plots before half way",
+			estate:      sparseEstate,
+			maxDistance: 15,
+			expectedX:   2,
+			expectedY:   1,
+		},
+		{
+			name:        "Mid jump over empty plots after half way",
+			estate:      sparseEstate,
+			maxDistance: 16,
+			expectedX:   3,
+			expectedY:   1,
+		},
+		{
+			name:        "Mid jump over empty plots on even row",
+			estate:      sparseEstate,
+			maxDistance: 85,
+			expectedX:   4,
+			expectedY:   2,
+		},
+		{
+			name:        "Mid jump over empty last row",
+			estate:      sparseEstate,
+			maxDistance: 160,
+			expectedX:   3,
+			expectedY:   3,
+		},
+		{
+			name:        "Estate without route",
+			estate:      repository.Estate{Width: 5, Length: 1},
+			maxDistance: 50,
+			expectedX:   1,
+			expectedY:   1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			routeSteps, err := routecodec.Decode(tc.estate.PatrolRoute)
+			require.NoError(t, err)
+
+			srv := Server{}
+			x, y := srv.calculateMaxDistance(tc.estate, routeSteps, tc.maxDistance)
+
+			require.Equal(t, tc.expectedX, x)
+			require.Equal(t, tc.expectedY, y)
+		})
+	}
+}
+
+func BenchmarkPatrol(b *testing.B) {
+	// maximum estate size allowed by CreateEstateRequestBody
+	estate := repository.Estate{
+		Width:  50000,
+		Length: 50000,
+	}
+
+	benchmarks := []struct {
+		name     string
+		numTrees int
+	}{
+		{name: "1 tree", numTrees: 1},
+		{name: "1k trees", numTrees: 1000},
+		{name: "100k trees", numTrees: 100000},
+	}
+
+	for _, bm := range benchmarks {
+		// spread the trees evenly across the estate, one plot per tree
+		trees := make([]repository.Tree, 0, bm.numTrees)
+		for i := 0; i < bm.numTrees; i++ {
+			plot := i * (estate.Width * estate.Length / bm.numTrees)
+			trees = append(trees, repository.Tree{
+				X:      plot%estate.Width + 1,
+				Y:      plot/estate.Width + 1,
+				Height: i%30 + 1,
+			})
+		}
+
+		b.Run(bm.name, func(b *testing.B) {
+			srv := Server{}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _, _, _, _, err := srv.patrol(estate, trees)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestHeightStats(t *testing.T) {
+	tests := []struct {
+		name           string
+		heightCounts   map[int]int
+		expectedCount  int
+		expectedMin    int
+		expectedMax    int
+		expectedMedian int
+	}{
+		{
+			name:         "No trees",
+			heightCounts: map[int]int{},
+		},
+		{
+			name:           "Single tree",
+			heightCounts:   map[int]int{7: 1},
+			expectedCount:  1,
+			expectedMin:    7,
+			expectedMax:    7,
+			expectedMedian: 7,
+		},
+		{
+			name:           "Odd number of trees",
+			heightCounts:   map[int]int{3: 1, 4: 1, 5: 1},
+			expectedCount:  3,
+			expectedMin:    3,
+			expectedMax:    5,
+			expectedMedian: 4,
+		},
+		{
+			name:           "Even number of trees",
+			heightCounts:   map[int]int{10: 2, 20: 1, 25: 1},
+			expectedCount:  4,
+			expectedMin:    10,
+			expectedMax:    25,
+			expectedMedian: 15,
+		},
+		{
+			name:           "Many trees with same height",
+			heightCounts:   map[int]int{1: 1, 30: 4},
+			expectedCount:  5,
+			expectedMin:    1,
+			expectedMax:    30,
+			expectedMedian: 30,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			count, min, max, median := heightStats(tc.heightCounts)
+
+			require.Equal(t, tc.expectedCount, count)
+			require.Equal(t, tc.expectedMin, min)
+			require.Equal(t, tc.expectedMax, max)
+			require.Equal(t, tc.expectedMedian, median)
+		})
+	}
+}
+
+func TestCalculateEstateMetadataIncremental(t *testing.T) {
+	emptyEstate := repository.Estate{
+		ID:     "estate_id",
+		Width:  5,
+		Length: 3,
+	}
+
+	tests := []struct {
+		name         string
+		plantedTrees []repository.Tree
+		newTree      repository.Tree
+		prevTree     *repository.Tree
+		nextTree     *repository.Tree
+		drone        repository.DroneProfile
+	}{
+		{
+			name: "Between two trees on the same row",
+			plantedTrees: []repository.Tree{
+				{X: 2, Y: 1, Height: 5},
+				{X: 4, Y: 1, Height: 4},
 			},
-			trees: []repository.Tree{
+			newTree:  repository.Tree{X: 3, Y: 1, Height: 3},
+			prevTree: &repository.Tree{X: 2, Y: 1, Height: 5},
+			nextTree: &repository.Tree{X: 4, Y: 1, Height: 4},
+		},
+		{
+			name: "Before first tree",
+			plantedTrees: []repository.Tree{
+				{X: 4, Y: 2, Height: 10},
+			},
+			newTree:  repository.Tree{X: 5, Y: 2, Height: 20},
+			prevTree: nil,
+			nextTree: &repository.Tree{X: 4, Y: 2, Height: 10},
+		},
+		{
+			name: "After last tree on another row",
+			plantedTrees: []repository.Tree{
+				{X: 2, Y: 1, Height: 8},
+				{X: 3, Y: 1, Height: 12},
+			},
+			newTree:  repository.Tree{X: 1, Y: 3, Height: 2},
+			prevTree: &repository.Tree{X: 3, Y: 1, Height: 12},
+			nextTree: nil,
+		},
+		{
+			name: "Between rows",
+			plantedTrees: []repository.Tree{
+				{X: 5, Y: 1, Height: 8},
+				{X: 1, Y: 2, Height: 30},
+				{X: 5, Y: 3, Height: 6},
+			},
+			newTree:  repository.Tree{X: 3, Y: 2, Height: 1},
+			prevTree: &repository.Tree{X: 5, Y: 1, Height: 8},
+			nextTree: &repository.Tree{X: 1, Y: 2, Height: 30},
+		},
+		{
+			name: "Drone profile with altitude floor and ceiling",
+			plantedTrees: []repository.Tree{
+				{X: 5, Y: 1, Height: 8},
+				{X: 1, Y: 2, Height: 30},
+				{X: 5, Y: 3, Height: 6},
+			},
+			newTree:  repository.Tree{X: 3, Y: 2, Height: 1},
+			prevTree: &repository.Tree{X: 5, Y: 1, Height: 8},
+			nextTree: &repository.Tree{X: 1, Y: 2, Height: 30},
+			drone:    repository.DroneProfile{PlotSize: 5, MonitorClearance: 2, CruiseAltitudeMin: 12, MaxAltitude: 25},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			estate := emptyEstate
+			estate.Drone = tc.drone
+
+			// metadata before and after planting as full patrol would have calculated it
+			currEstate := estate
+			currEstate.Count = len(tc.plantedTrees)
+			currEstate.Min, currEstate.Max, currEstate.Median, currEstate.PatrolDistance, _, _ = srv.patrol(estate, tc.plantedTrees)
+
+			allTrees := append([]repository.Tree{tc.newTree}, tc.plantedTrees...)
+			expectedMin, expectedMax, expectedMedian, expectedDistance, _, err := srv.patrol(estate, allTrees)
+			require.NoError(t, err)
+
+			heightCounts := make(map[int]int)
+			for _, tree := range allTrees {
+				heightCounts[tree.Height]++
+			}
+
+			mockRepo.EXPECT().GetEstateByID(gomock.Any(), estate.ID).Return(currEstate, nil).Times(1)
+			mockRepo.EXPECT().GetPatrolNeighbourTrees(gomock.Any(), estate.ID, tc.newTree.X, tc.newTree.Y).Return(tc.prevTree, tc.nextTree, nil).Times(1)
+			mockRepo.EXPECT().GetTreeHeightCounts(gomock.Any(), estate.ID).Return(heightCounts, nil).Times(1)
+			mockRepo.EXPECT().
+				UpdateEstate(gomock.Any(), estate.ID, len(allTrees), expectedMin, expectedMax, expectedMedian, expectedDistance, nil).
+				Return(nil).
+				Times(1)
+
+			err = srv.calculateEstateMetadataIncremental(context.Background(), estate.ID, nil, &tc.newTree)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCalculateEstateMetadataIncrementalFirstTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositorier(ctrl)
+	srv := Server{
+		repository: mockRepo,
+	}
+
+	estate := repository.Estate{ID: "estate_id", Width: 5, Length: 1}
+	tree := repository.Tree{X: 2, Y: 1, Height: 5}
+
+	// empty estate fallback to full recalculation
+	mockRepo.EXPECT().GetEstateByID(gomock.Any(), estate.ID).Return(estate, nil).Times(1)
+	mockRepo.EXPECT().GetAllTreesInEstate(gomock.Any(), estate.ID).Return([]repository.Tree{tree}, nil).Times(1)
+	routeSteps, err := routecodec.Decode("1,1,1,ew,10,10;2,2,1,vu,6,16;3,2,1,ew,10,26;4,3,1,ew,30,56;")
+	require.NoError(t, err)
+
+	mockRepo.EXPECT().
+		UpdateEstate(gomock.Any(), estate.ID, 1, 5, 5, 5, 62, routeSteps).
+		Return(nil).
+		Times(1)
+
+	err = srv.calculateEstateMetadataIncremental(context.Background(), estate.ID, nil, &tree)
+	require.NoError(t, err)
+}
+
+func TestCalculateEstateMetadataIncrementalGrowAndFell(t *testing.T) {
+	estate := repository.Estate{
+		ID:     "estate_id",
+		Width:  5,
+		Length: 3,
+	}
+
+	tests := []struct {
+		name         string
+		plantedTrees []repository.Tree
+		before       *repository.Tree
+		after        *repository.Tree
+		prevTree     *repository.Tree
+		nextTree     *repository.Tree
+	}{
+		{
+			name: "Grow between two trees on the same row",
+			plantedTrees: []repository.Tree{
 				{X: 2, Y: 1, Height: 5},
 				{X: 3, Y: 1, Height: 3},
 				{X: 4, Y: 1, Height: 4},
 			},
-			mockGetEstateErr:    nil,
-			mockGetAllTreesErr:  nil,
-			mockUpdateEstateErr: nil,
-			expectedUpdateErr:   nil,
+			before:   &repository.Tree{X: 3, Y: 1, Height: 3},
+			after:    &repository.Tree{X: 3, Y: 1, Height: 12},
+			prevTree: &repository.Tree{X: 2, Y: 1, Height: 5},
+			nextTree: &repository.Tree{X: 4, Y: 1, Height: 4},
+		},
+		{
+			name: "Shrink the last tree",
+			plantedTrees: []repository.Tree{
+				{X: 2, Y: 1, Height: 8},
+				{X: 1, Y: 3, Height: 12},
+			},
+			before:   &repository.Tree{X: 1, Y: 3, Height: 12},
+			after:    &repository.Tree{X: 1, Y: 3, Height: 2},
+			prevTree: &repository.Tree{X: 2, Y: 1, Height: 8},
+			nextTree: nil,
+		},
+		{
+			name: "Fell between rows",
+			plantedTrees: []repository.Tree{
+				{X: 5, Y: 1, Height: 8},
+				{X: 3, Y: 2, Height: 1},
+				{X: 1, Y: 2, Height: 30},
+			},
+			before:   &repository.Tree{X: 3, Y: 2, Height: 1},
+			after:    nil,
+			prevTree: &repository.Tree{X: 5, Y: 1, Height: 8},
+			nextTree: &repository.Tree{X: 1, Y: 2, Height: 30},
+		},
+		{
+			name: "Fell the first tree",
+			plantedTrees: []repository.Tree{
+				{X: 5, Y: 2, Height: 20},
+				{X: 4, Y: 2, Height: 10},
+			},
+			before:   &repository.Tree{X: 5, Y: 2, Height: 20},
+			after:    nil,
+			prevTree: nil,
+			nextTree: &repository.Tree{X: 4, Y: 2, Height: 10},
 		},
-		// Add more test cases here as needed
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			// Create a new instance of the mock controller
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 
 			mockRepo := repository.NewMockRepositorier(ctrl)
-
-			// Create a new server instance with the mock repository
 			srv := Server{
 				repository: mockRepo,
 			}
 
-			// Set up mock expectations
-			mockRepo.EXPECT().GetEstateByID(gomock.Any(), gomock.Any()).Return(tc.estate, tc.mockGetEstateErr).Times(1)
-			mockRepo.EXPECT().GetAllTreesInEstate(gomock.Any(), gomock.Any()).Return(tc.trees, tc.mockGetAllTreesErr).Times(1)
-			mockRepo.EXPECT().UpdateEstate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(tc.mockUpdateEstateErr).Times(1)
+			// metadata before and after the change as full patrol would have calculated it
+			currEstate := estate
+			currEstate.Count = len(tc.plantedTrees)
+			currEstate.Min, currEstate.Max, currEstate.Median, currEstate.PatrolDistance, _, _ = srv.patrol(estate, tc.plantedTrees)
+
+			changedTrees := make([]repository.Tree, 0, len(tc.plantedTrees))
+			for _, tree := range tc.plantedTrees {
+				if tree == *tc.before {
+					if tc.after == nil {
+						continue
+					}
+					tree = *tc.after
+				}
+				changedTrees = append(changedTrees, tree)
+			}
+			expectedMin, expectedMax, expectedMedian, expectedDistance, _, err := srv.patrol(estate, changedTrees)
+			require.NoError(t, err)
+
+			heightCounts := make(map[int]int)
+			for _, tree := range changedTrees {
+				heightCounts[tree.Height]++
+			}
 
-			// Call the function under test
-			err := srv.calculateEstateMetadata(context.Background(), "estate_id")
+			mockRepo.EXPECT().GetEstateByID(gomock.Any(), estate.ID).Return(currEstate, nil).Times(1)
+			mockRepo.EXPECT().GetPatrolNeighbourTrees(gomock.Any(), estate.ID, tc.before.X, tc.before.Y).Return(tc.prevTree, tc.nextTree, nil).Times(1)
+			mockRepo.EXPECT().GetTreeHeightCounts(gomock.Any(), estate.ID).Return(heightCounts, nil).Times(1)
+			mockRepo.EXPECT().
+				UpdateEstate(gomock.Any(), estate.ID, len(changedTrees), expectedMin, expectedMax, expectedMedian, expectedDistance, nil).
+				Return(nil).
+				Times(1)
 
-			// Assert the result
-			require.Equal(t, tc.expectedUpdateErr, err)
+			err = srv.calculateEstateMetadataIncremental(context.Background(), estate.ID, tc.before, tc.after)
+			require.NoError(t, err)
 		})
 	}
 }
+
+func TestCalculateEstateMetadataIncrementalLastTreeFelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := repository.NewMockRepositorier(ctrl)
+	srv := Server{
+		repository: mockRepo,
+	}
+
+	estate := repository.Estate{ID: "estate_id", Width: 5, Length: 1, Count: 1, Min: 5, Max: 5, Median: 5, PatrolDistance: 62}
+	tree := repository.Tree{X: 2, Y: 1, Height: 5}
+
+	// no tree left, the estate is back to the one nobody patrolled yet
+	mockRepo.EXPECT().GetEstateByID(gomock.Any(), estate.ID).Return(estate, nil).Times(1)
+	mockRepo.EXPECT().GetPatrolNeighbourTrees(gomock.Any(), estate.ID, tree.X, tree.Y).Return(nil, nil, nil).Times(1)
+	mockRepo.EXPECT().GetTreeHeightCounts(gomock.Any(), estate.ID).Return(map[int]int{}, nil).Times(1)
+	mockRepo.EXPECT().
+		UpdateEstate(gomock.Any(), estate.ID, 0, 0, 0, 0, 0, nil).
+		Return(nil).
+		Times(1)
+
+	err := srv.calculateEstateMetadataIncremental(context.Background(), estate.ID, &tree, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Router register the generated handlers to echo. Echo treats every ':' in the
+// path as the start of path param, so the custom methods in api.yml
+// (e.g. /estate/{id}/trees:batch) are escaped to be matched literally.
+// Echo path param also can not be followed by an extension in the same segment
+// (e.g. /estate/{id}.geojson), those routes share the route of the bare param
+// and are told apart by the extension of the param value.
+type Router struct {
+	*echo.Echo
+
+	// method and path without extension to its routes by extension
+	extensionRoutes map[string]*extensionRoute
+}
+
+// extensionRoute is the handlers of the routes ending with the same path param,
+// by the extension following it, "" is the handler of the bare param if any.
+type extensionRoute struct {
+	param    string
+	handlers map[string]echo.HandlerFunc
+}
+
+// NewRouter return reference to new instance of Router
+func NewRouter(e *echo.Echo) *Router {
+	return &Router{
+		Echo:            e,
+		extensionRoutes: make(map[string]*extensionRoute),
+	}
+}
+
+func (r *Router) CONNECT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodConnect, path, h, m...)
+}
+
+func (r *Router) DELETE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodDelete, path, h, m...)
+}
+
+func (r *Router) GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodGet, path, h, m...)
+}
+
+func (r *Router) HEAD(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodHead, path, h, m...)
+}
+
+func (r *Router) OPTIONS(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodOptions, path, h, m...)
+}
+
+func (r *Router) PATCH(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodPatch, path, h, m...)
+}
+
+func (r *Router) POST(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodPost, path, h, m...)
+}
+
+func (r *Router) PUT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodPut, path, h, m...)
+}
+
+func (r *Router) TRACE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return r.add(http.MethodTrace, path, h, m...)
+}
+
+func (r *Router) add(method, path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	path = escapeRoutePath(path)
+
+	basePath, param, extension := splitRouteExtension(path)
+	if len(param) == 0 {
+		return r.Add(method, path, h, m...)
+	}
+
+	key := method + " " + basePath
+	route, ok := r.extensionRoutes[key]
+	if !ok {
+		route = &extensionRoute{
+			param:    param,
+			handlers: make(map[string]echo.HandlerFunc),
+		}
+		r.extensionRoutes[key] = route
+	}
+
+	// same as echo does for route middlewares
+	for idx := len(m) - 1; idx >= 0; idx-- {
+		h = m[idx](h)
+	}
+	route.handlers[extension] = h
+
+	return r.Add(method, basePath, route.serve)
+}
+
+// serve call the handler of the extension the param value ends with,
+// the param value passed to the handler has the extension trimmed.
+func (route *extensionRoute) serve(ectx echo.Context) error {
+	value := ectx.Param(route.param)
+	for extension, h := range route.handlers {
+		if len(extension) == 0 || len(value) <= len(extension) || !strings.HasSuffix(value, extension) {
+			continue
+		}
+
+		names, values := ectx.ParamNames(), ectx.ParamValues()
+		for idx, name := range names {
+			if name == route.param && idx < len(values) {
+				values[idx] = strings.TrimSuffix(value, extension)
+			}
+		}
+		ectx.SetParamValues(values...)
+
+		return h(ectx)
+	}
+
+	if h, ok := route.handlers[""]; ok {
+		return h(ectx)
+	}
+
+	return echo.ErrNotFound
+}
+
+// escapeRoutePath escape ':' that is not at the start of path segment
+func escapeRoutePath(path string) string {
+	segments := strings.Split(path, "/")
+	for idx, segment := range segments {
+		if len(segment) < 2 {
+			continue
+		}
+
+		segments[idx] = segment[:1] + strings.ReplaceAll(segment[1:], ":", `\:`)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// splitRouteExtension split the path ending with path param into the path with
+// bare param, the param name and the extension following it (e.g. "/estate/:id.kml"
+// into "/estate/:id", "id" and ".kml"), param is empty when the path does not end with param.
+func splitRouteExtension(path string) (basePath, param, extension string) {
+	idxSegment := strings.LastIndex(path, "/") + 1
+	segment := path[idxSegment:]
+	if !strings.HasPrefix(segment, ":") {
+		return path, "", ""
+	}
+
+	param = segment[1:]
+	if idxExtension := strings.Index(param, "."); idxExtension >= 0 {
+		param, extension = param[:idxExtension], param[idxExtension:]
+	}
+
+	return path[:idxSegment] + ":" + param, param, extension
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscapeRoutePath(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		expectedPath string
+	}{
+		{
+			name:         "Path without param",
+			path:         "/estate",
+			expectedPath: "/estate",
+		},
+		{
+			name:         "Path with param",
+			path:         "/estate/:id/tree",
+			expectedPath: "/estate/:id/tree",
+		},
+		{
+			name:         "Path with custom method",
+			path:         "/estate/:id/trees:batch",
+			expectedPath: `/estate/:id/trees\:batch`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedPath, escapeRoutePath(tc.path))
+		})
+	}
+}
+
+func TestSplitRouteExtension(t *testing.T) {
+	tests := []struct {
+		name              string
+		path              string
+		expectedBasePath  string
+		expectedParam     string
+		expectedExtension string
+	}{
+		{
+			name:             "Path ending with static segment",
+			path:             "/estate/:id/tree",
+			expectedBasePath: "/estate/:id/tree",
+		},
+		{
+			name:             "Path ending with param",
+			path:             "/estate/:id",
+			expectedBasePath: "/estate/:id",
+			expectedParam:    "id",
+		},
+		{
+			name:              "Path ending with param and extension",
+			path:              "/estate/:id.geojson",
+			expectedBasePath:  "/estate/:id",
+			expectedParam:     "id",
+			expectedExtension: ".geojson",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			basePath, param, extension := splitRouteExtension(tc.path)
+
+			require.Equal(t, tc.expectedBasePath, basePath)
+			require.Equal(t, tc.expectedParam, param)
+			require.Equal(t, tc.expectedExtension, extension)
+		})
+	}
+}
+
+func TestRouter(t *testing.T) {
+	e := echo.New()
+	router := NewRouter(e)
+
+	router.POST("/estate/:id/tree", func(ectx echo.Context) error {
+		return ectx.String(http.StatusCreated, "tree "+ectx.Param("id"))
+	})
+	router.POST("/estate/:id/trees:batch", func(ectx echo.Context) error {
+		return ectx.String(http.StatusCreated, "batch "+ectx.Param("id"))
+	})
+
+	router.GET("/estate/:id.geojson", func(ectx echo.Context) error {
+		return ectx.String(http.StatusOK, "geojson "+ectx.Param("id"))
+	})
+	router.GET("/estate/:id", func(ectx echo.Context) error {
+		return ectx.String(http.StatusOK, "estate "+ectx.Param("id"))
+	})
+	router.GET("/estate/:id.kml", func(ectx echo.Context) error {
+		return ectx.String(http.StatusOK, "kml "+ectx.Param("id"))
+	})
+	router.GET("/tree/:id.csv", func(ectx echo.Context) error {
+		return ectx.String(http.StatusOK, "csv "+ectx.Param("id"))
+	})
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Path with param",
+			path:         "/estate/estate_id/tree",
+			expectedCode: http.StatusCreated,
+			expectedBody: "tree estate_id",
+		},
+		{
+			name:         "Path with custom method",
+			path:         "/estate/estate_id/trees:batch",
+			expectedCode: http.StatusCreated,
+			expectedBody: "batch estate_id",
+		},
+		{
+			name:         "Custom method not matched as param",
+			path:         "/estate/estate_id/trees:other",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Param with extension",
+			method:       http.MethodGet,
+			path:         "/estate/estate_id.geojson",
+			expectedCode: http.StatusOK,
+			expectedBody: "geojson estate_id",
+		},
+		{
+			name:         "Param with other extension",
+			method:       http.MethodGet,
+			path:         "/estate/estate_id.kml",
+			expectedCode: http.StatusOK,
+			expectedBody: "kml estate_id",
+		},
+		{
+			name:         "Bare param sharing the route of extensions",
+			method:       http.MethodGet,
+			path:         "/estate/estate_id",
+			expectedCode: http.StatusOK,
+			expectedBody: "estate estate_id",
+		},
+		{
+			name:         "Unknown extension is part of the param",
+			method:       http.MethodGet,
+			path:         "/estate/estate_id.svg",
+			expectedCode: http.StatusOK,
+			expectedBody: "estate estate_id.svg",
+		},
+		{
+			name:         "Param without extension nor bare param route",
+			method:       http.MethodGet,
+			path:         "/tree/tree_id",
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			method := http.MethodPost
+			if tc.method != "" {
+				method = tc.method
+			}
+			e.ServeHTTP(rec, httptest.NewRequest(method, tc.path, nil))
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+			if tc.expectedBody != "" {
+				require.Equal(t, tc.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
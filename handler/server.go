@@ -1,6 +1,10 @@
 package handler
 
-import "github.com/nahwinrajan/testswpro/repository"
+import (
+	"context"
+
+	"github.com/nahwinrajan/testswpro/repository"
+)
 
 type Server struct {
 	repository repository.Repositorier
@@ -12,3 +16,32 @@ func New(repo repository.Repositorier) *Server {
 		repository: repo,
 	}
 }
+
+// withTx run fn with a server whose repository is in a single transaction,
+// whatever fn stores is rolled back when it return an error.
+func (srv *Server) withTx(ctx context.Context, fn func(txSrv *Server) error) error {
+	return srv.repository.WithTx(ctx, func(repo repository.Repositorier) error {
+		txSrv := *srv
+		txSrv.repository = repo
+
+		return fn(&txSrv)
+	})
+}
+
+// withEstateLock run fn like withTx while holding the lock of the estate, so
+// concurrent changes of the same estate apply one after another, each on the
+// estate and trees the previous one left behind.
+func (srv *Server) withEstateLock(
+	ctx context.Context,
+	estateID string,
+	fn func(txSrv *Server, estate repository.Estate) error,
+) error {
+	return srv.withTx(ctx, func(txSrv *Server) error {
+		estate, err := txSrv.repository.LockEstate(ctx, estateID)
+		if err != nil {
+			return err
+		}
+
+		return fn(txSrv, estate)
+	})
+}
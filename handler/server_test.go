@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithEstateLockParallelTreeWrites plant, fell and trim trees of the same estate in
+// parallel, the stats and the patrol route must count every write right away, without
+// waiting for a recalculation job.
+func TestWithEstateLockParallelTreeWrites(t *testing.T) {
+	const width, length = 12, 10
+
+	repo := repository.NewMemory()
+	srv := New(repo)
+	e := echo.New()
+	generated.RegisterHandlers(NewRouter(e), srv)
+
+	serve := func(method, target string, payload any) *httptest.ResponseRecorder {
+		body, err := json.Marshal(payload)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(method, target, bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/estate", generated.CreateEstateRequestBody{Width: width, Length: length})
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created generated.CreateEstateResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	estateID := created.Id
+
+	random := rand.New(rand.NewSource(1))
+	plots := random.Perm(width * length)
+	plant := func(plot, height int) *httptest.ResponseRecorder {
+		return serve(http.MethodPost, "/estate/"+estateID+"/tree", generated.CreateTreeRequestBody{
+			X:      1 + plot%width,
+			Y:      1 + plot/width,
+			Height: height,
+		})
+	}
+
+	// half of the plots are planted beforehand, a third of those is felled and another
+	// third trimmed while the other half is planted
+	heights := make(map[int]int, len(plots))
+	planted := plots[:len(plots)/2]
+	treeIDs := make(map[int]string, len(planted))
+	for _, plot := range planted {
+		heights[plot] = 1 + random.Intn(30)
+		rec := plant(plot, heights[plot])
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var tree generated.CreateTreeResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tree))
+		treeIDs[plot] = tree.Id
+	}
+
+	var wg sync.WaitGroup
+	statusCodes := make(chan string, len(plots))
+	write := func(name string, expectedCode int, do func() *httptest.ResponseRecorder) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rec := do()
+			if rec.Code != expectedCode {
+				statusCodes <- fmt.Sprintf("%s: %d %s", name, rec.Code, rec.Body.String())
+			}
+		}()
+	}
+
+	for idx, plot := range planted {
+		plot := plot
+		target := "/estate/" + estateID + "/tree/" + treeIDs[plot]
+		switch idx % 3 {
+		case 0:
+			delete(heights, plot)
+			write("fell "+target, http.StatusNoContent, func() *httptest.ResponseRecorder {
+				return serve(http.MethodDelete, target, nil)
+			})
+		case 1:
+			heights[plot] = 1 + random.Intn(30)
+			height := heights[plot]
+			write("trim "+target, http.StatusOK, func() *httptest.ResponseRecorder {
+				return serve(http.MethodPatch, target, generated.UpdateTreeRequestBody{Height: height})
+			})
+		}
+	}
+	for _, plot := range plots[len(plots)/2:] {
+		plot := plot
+		heights[plot] = 1 + random.Intn(30)
+		height := heights[plot]
+		write(fmt.Sprintf("plant plot %d", plot), http.StatusCreated, func() *httptest.ResponseRecorder {
+			return plant(plot, height)
+		})
+	}
+	wg.Wait()
+	close(statusCodes)
+
+	for failed := range statusCodes {
+		require.Fail(t, "tree write failed", failed)
+	}
+
+	sortedHeights := make([]int, 0, len(heights))
+	for _, height := range heights {
+		sortedHeights = append(sortedHeights, height)
+	}
+	sort.Ints(sortedHeights)
+	median := sortedHeights[len(sortedHeights)/2]
+	if len(sortedHeights)%2 == 0 {
+		median = (sortedHeights[len(sortedHeights)/2-1] + sortedHeights[len(sortedHeights)/2]) / 2
+	}
+
+	rec = serve(http.MethodGet, "/estate/"+estateID+"/stats", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stats generated.EstateStatsResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	require.Equal(t, generated.EstateStatsResponse{
+		Count:       len(sortedHeights),
+		Min:         sortedHeights[0],
+		Max:         sortedHeights[len(sortedHeights)-1],
+		Median:      median,
+		StatsStatus: generated.Fresh,
+	}, stats)
+
+	// the route is the one of patrolling every tree from scratch
+	ctx := context.Background()
+	estate, err := repo.GetEstateByID(ctx, estateID)
+	require.NoError(t, err)
+	trees, err := repo.GetAllTreesInEstate(ctx, estateID)
+	require.NoError(t, err)
+	_, _, _, expectedDistance, expectedRoute, err := srv.patrol(estate, trees)
+	require.NoError(t, err)
+
+	_, _, route, err := srv.patrolTreesAndRoute(ctx, estate)
+	require.NoError(t, err)
+	require.Equal(t, expectedDistance, estate.PatrolDistance)
+	require.Equal(t, expectedRoute, route)
+
+	// nothing was left to the background
+	job, err := repo.ClaimJob(ctx, time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, job)
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/nahwinrajan/testswpro/mission"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+// sortie is a flight of the drone on a single battery. The drone takes off from home,
+// transits to the plot the patrol resumes on, flies the route steps from firstStep
+// to lastStep (indexes of the route steps of the plan) and transits back to home.
+type sortie struct {
+	firstStep, lastStep int
+	// start is where the patrol resumes, end is where the drone leaves the route
+	start, end mission.Waypoint
+	// outbound and inbound are the transits from and to home, route is the patrol in between
+	outbound, route, inbound int
+}
+
+// distance return the distance flown on the battery of the sortie
+func (s sortie) distance() int {
+	return s.outbound + s.route + s.inbound
+}
+
+// errHomeUnreachable tells the drone can not fly between home and the route, home is in
+// a no-fly zone or out of the estate boundary, or walled off from the route
+var errHomeUnreachable = errors.New("home can not reach the route around the no-fly zones")
+
+// planSorties split the route of the plan into sorties flying at most battery each, from and
+// back to home on plot (homeX,homeY) of the estate. A sortie stops and the next one resumes
+// between route steps. The transits climb over every tree of the route, flying straight at the
+// highest altitude of the route, or around the forbidden plots the same as the patrol when the
+// straight line crosses one, above the min altitude zones on the way. It also return the
+// smallest battery flying every route step in its own sortie, when it is more than battery
+// there are no sorties. errHomeUnreachable is returned when there is no way from home.
+func planSorties(
+	estate repository.Estate,
+	plan flightPlan,
+	battery, homeX, homeY int,
+) (sorties []sortie, minBattery int, err error) {
+	routeSteps := plan.routeSteps
+	if len(routeSteps) == 0 {
+		return nil, 0, nil
+	}
+
+	patrolEstate := plan.patrolEstate(estate)
+	plotSize := estateDrone(estate).PlotSize
+
+	// points[i] is where the drone is after the first i route steps, it starts on the ground
+	// of the first plot of the patrol
+	points := make([]mission.Waypoint, len(routeSteps)+1)
+	var transitAltitude int
+	x, y := routeSteps[0].X, routeSteps[0].Y
+	points[0].X, points[0].Y = plan.fromPatrol(estate, x, y)
+	for i, routeStep := range routeSteps {
+		x, y = routeStep.X, routeStep.Y
+		switch routeStep.Direction {
+		case directionEW:
+			x = min(routeStep.X+routeStep.StepDistance/plotSize, patrolEstate.Width)
+		case directionWE:
+			x = max(routeStep.X-routeStep.StepDistance/plotSize, 1)
+		}
+
+		points[i+1].X, points[i+1].Y = plan.fromPatrol(estate, x, y)
+		points[i+1].Altitude = routeStep.Altitude
+		transitAltitude = max(transitAltitude, routeStep.Altitude)
+	}
+
+	zm := newZoneMap(estate)
+	home := repository.Plot{X: homeX, Y: homeY}
+	if !zm.empty() && zm.forbidden(homeX, homeY) {
+		return nil, 0, errHomeUnreachable
+	}
+
+	// ways[plot] is the transit between home and the plot, the route flies over the same
+	// plots more than once
+	type transitWay struct{ altitude, horizontal int }
+	ways := make(map[repository.Plot]transitWay)
+
+	// transit between home and the point, straight up and down above home
+	transit := func(point mission.Waypoint) (int, error) {
+		dx, dy := float64(point.X-homeX), float64(point.Y-homeY)
+		if dx == 0 && dy == 0 {
+			return point.Altitude, nil
+		}
+
+		to := repository.Plot{X: point.X, Y: point.Y}
+		way, found := ways[to]
+		if !found {
+			way = transitWay{altitude: transitAltitude, horizontal: int(math.Ceil(math.Hypot(dx, dy) * float64(plotSize)))}
+			if !zm.empty() {
+				plots := linePlots(home, to)
+				if slices.ContainsFunc(plots, func(plot repository.Plot) bool { return zm.forbidden(plot.X, plot.Y) }) {
+					plots = zm.detour(home, to)
+					if plots == nil {
+						return 0, errHomeUnreachable
+					}
+					way.horizontal = (len(plots) - 1) * plotSize
+				}
+				way.altitude = max(way.altitude, zm.clearance(plots, nil))
+			}
+			ways[to] = way
+		}
+
+		return way.altitude + way.horizontal + way.altitude - point.Altitude, nil
+	}
+
+	// transits[i] is the transit between home and points[i], the same both ways
+	transits := make([]int, len(points))
+	for i, point := range points {
+		transits[i], err = transit(point)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// fly is the sortie flying the route steps from first to last
+	fly := func(first, last int) sortie {
+		route := routeSteps[last].CurrentDistance
+		if first > 0 {
+			route -= routeSteps[first-1].CurrentDistance
+		}
+
+		return sortie{
+			firstStep: first,
+			lastStep:  last,
+			start:     points[first],
+			end:       points[last+1],
+			outbound:  transits[first],
+			route:     route,
+			inbound:   transits[last+1],
+		}
+	}
+
+	for i := range routeSteps {
+		minBattery = max(minBattery, fly(i, i).distance())
+	}
+	if minBattery > battery {
+		return nil, minBattery, nil
+	}
+
+	for first := 0; first < len(routeSteps); {
+		// the last route step the battery flies, the drone may get closer to home further on
+		next := fly(first, first)
+		for last := first + 1; last < len(routeSteps); last++ {
+			s := fly(first, last)
+			if s.route > battery {
+				break
+			}
+			if s.distance() <= battery {
+				next = s
+			}
+		}
+
+		sorties = append(sorties, next)
+		first = next.lastStep + 1
+	}
+
+	return sorties, minBattery, nil
+}
+
+// linePlots return the plots the straight line from the center of plot from to the center
+// of plot to flies over, both ends included. The line going right through the corner of
+// plots flies over neither of the plots on the side.
+func linePlots(from, to repository.Plot) []repository.Plot {
+	dx, dy := to.X-from.X, to.Y-from.Y
+	stepX, stepY := 1, 1
+	if dx < 0 {
+		stepX = -1
+	}
+	if dy < 0 {
+		stepY = -1
+	}
+
+	plots := []repository.Plot{from}
+	plot := from
+	for crossedX, crossedY := 0, 0; crossedX < abs(dx) || crossedY < abs(dy); {
+		// the line crosses the next column edge before the next row edge when
+		// (crossedX+1/2) / |dx| < (crossedY+1/2) / |dy|
+		switch next := (2*crossedX+1)*abs(dy) - (2*crossedY+1)*abs(dx); {
+		case next < 0:
+			plot.X += stepX
+			crossedX++
+		case next > 0:
+			plot.Y += stepY
+			crossedY++
+		default:
+			plot.X, plot.Y = plot.X+stepX, plot.Y+stepY
+			crossedX, crossedY = crossedX+1, crossedY+1
+		}
+		plots = append(plots, plot)
+	}
+
+	return plots
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/nahwinrajan/testswpro/mission"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanSorties(t *testing.T) {
+	// the tallest tree is on the first row, the transits fly at 10
+	estate := repository.Estate{Width: 4, Length: 2}
+	trees := []repository.Tree{
+		{X: 1, Y: 1, Height: 4},
+		{X: 3, Y: 1, Height: 9},
+		{X: 2, Y: 2, Height: 4},
+	}
+
+	srv := Server{}
+	_, _, _, _, routeSteps, err := srv.patrol(estate, trees)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		battery            int
+		homeX, homeY       int
+		expectedSorties    []sortie
+		expectedMinBattery int
+	}{
+		{
+			name:    "Single sortie",
+			battery: 200,
+			homeX:   1,
+			homeY:   1,
+			expectedSorties: []sortie{
+				{firstStep: 0, lastStep: 10, start: mission.Waypoint{X: 1, Y: 1}, end: mission.Waypoint{X: 1, Y: 2, Altitude: 5}, outbound: 0, route: 105, inbound: 25},
+			},
+			expectedMinBattery: 92,
+		},
+		{
+			name:    "Resume where the previous sortie stops",
+			battery: 100,
+			homeX:   1,
+			homeY:   1,
+			expectedSorties: []sortie{
+				{firstStep: 0, lastStep: 5, start: mission.Waypoint{X: 1, Y: 1}, end: mission.Waypoint{X: 4, Y: 1, Altitude: 10}, outbound: 0, route: 50, inbound: 40},
+				{firstStep: 6, lastStep: 7, start: mission.Waypoint{X: 4, Y: 1, Altitude: 10}, end: mission.Waypoint{X: 2, Y: 2, Altitude: 10}, outbound: 40, route: 30, inbound: 25},
+				{firstStep: 8, lastStep: 10, start: mission.Waypoint{X: 2, Y: 2, Altitude: 10}, end: mission.Waypoint{X: 1, Y: 2, Altitude: 5}, outbound: 25, route: 25, inbound: 25},
+			},
+			expectedMinBattery: 92,
+		},
+		{
+			name:    "Home on the last plot",
+			battery: 200,
+			homeX:   1,
+			homeY:   2,
+			expectedSorties: []sortie{
+				{firstStep: 0, lastStep: 10, start: mission.Waypoint{X: 1, Y: 1}, end: mission.Waypoint{X: 1, Y: 2, Altitude: 5}, outbound: 30, route: 105, inbound: 5},
+			},
+			expectedMinBattery: 94,
+		},
+		{
+			name:               "Battery too small for a route step",
+			battery:            91,
+			homeX:              1,
+			homeY:              1,
+			expectedMinBattery: 92,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := flightPlan{mode: flightModeCanopyFollow, sweep: sweepDefault, routeSteps: routeSteps}
+			sorties, minBattery, err := planSorties(estate, plan, tc.battery, tc.homeX, tc.homeY)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMinBattery, minBattery)
+			require.Equal(t, tc.expectedSorties, sorties)
+			for _, s := range sorties {
+				require.LessOrEqual(t, s.distance(), tc.battery)
+			}
+		})
+	}
+}
+
+func TestPlanSortiesAroundZones(t *testing.T) {
+	// the no-fly zone walls row 2 off but for its last plot, between home on row 3 and the first plot
+	wall := repository.Zone{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 1, Y: 2}, {X: 4, Y: 2}}}
+	trees := []repository.Tree{
+		{X: 1, Y: 1, Height: 4},
+		{X: 5, Y: 3, Height: 9},
+	}
+
+	tests := []struct {
+		name               string
+		zones              []repository.Zone
+		homeX, homeY       int
+		expectedSorties    []sortie
+		expectedMinBattery int
+		expectedErr        error
+	}{
+		{
+			name:  "Around the no-fly zone between home and the first plot",
+			zones: []repository.Zone{wall},
+			homeX: 1,
+			homeY: 3,
+			expectedSorties: []sortie{
+				{firstStep: 0, lastStep: 9, start: mission.Waypoint{X: 1, Y: 1}, end: mission.Waypoint{X: 5, Y: 3, Altitude: 10}, outbound: 120, route: 170, inbound: 50},
+			},
+			expectedMinBattery: 240,
+		},
+		{
+			name:  "Straight when the no-fly zone is not in the way",
+			zones: []repository.Zone{wall},
+			homeX: 5,
+			homeY: 1,
+			expectedSorties: []sortie{
+				{firstStep: 0, lastStep: 9, start: mission.Waypoint{X: 1, Y: 1}, end: mission.Waypoint{X: 5, Y: 3, Altitude: 10}, outbound: 60, route: 170, inbound: 30},
+			},
+			expectedMinBattery: 140,
+		},
+		{
+			name:        "Home in the no-fly zone",
+			zones:       []repository.Zone{wall},
+			homeX:       2,
+			homeY:       2,
+			expectedErr: errHomeUnreachable,
+		},
+		{
+			name: "Home walled off",
+			zones: []repository.Zone{
+				wall,
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 2, Y: 3}, {X: 2, Y: 3}}},
+			},
+			homeX:       1,
+			homeY:       3,
+			expectedErr: errHomeUnreachable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			estate := repository.Estate{Width: 5, Length: 3, ZoneCount: len(tc.zones), Zones: tc.zones}
+
+			srv := Server{}
+			_, _, _, _, routeSteps, err := srv.patrol(estate, trees)
+			require.NoError(t, err)
+
+			plan := flightPlan{mode: flightModeCanopyFollow, sweep: sweepDefault, routeSteps: routeSteps}
+			sorties, minBattery, err := planSorties(estate, plan, 1000, tc.homeX, tc.homeY)
+			require.Equal(t, tc.expectedErr, err)
+			require.Equal(t, tc.expectedMinBattery, minBattery)
+			require.Equal(t, tc.expectedSorties, sorties)
+		})
+	}
+}
+
+func TestLinePlots(t *testing.T) {
+	tests := []struct {
+		name          string
+		from, to      repository.Plot
+		expectedPlots []repository.Plot
+	}{
+		{
+			name:          "Same plot",
+			from:          repository.Plot{X: 2, Y: 2},
+			to:            repository.Plot{X: 2, Y: 2},
+			expectedPlots: []repository.Plot{{X: 2, Y: 2}},
+		},
+		{
+			name:          "Along the column",
+			from:          repository.Plot{X: 1, Y: 3},
+			to:            repository.Plot{X: 1, Y: 1},
+			expectedPlots: []repository.Plot{{X: 1, Y: 3}, {X: 1, Y: 2}, {X: 1, Y: 1}},
+		},
+		{
+			name:          "Through the corners",
+			from:          repository.Plot{X: 1, Y: 1},
+			to:            repository.Plot{X: 3, Y: 3},
+			expectedPlots: []repository.Plot{{X: 1, Y: 1}, {X: 2, Y: 2}, {X: 3, Y: 3}},
+		},
+		{
+			name:          "Shallow line",
+			from:          repository.Plot{X: 4, Y: 1},
+			to:            repository.Plot{X: 1, Y: 2},
+			expectedPlots: []repository.Plot{{X: 4, Y: 1}, {X: 3, Y: 1}, {X: 2, Y: 2}, {X: 1, Y: 2}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedPlots, linePlots(tc.from, tc.to))
+		})
+	}
+}
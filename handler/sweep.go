@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+// sweep orientations of the drone plan, see sweep
+const (
+	sweepRows    = "rows"
+	sweepColumns = "columns"
+	// sweepAuto patrol the estate in every orientation from every corner, see allSweeps
+	sweepAuto = "auto"
+)
+
+// start corners of the sweep, x axis goes to the east and y axis to the north
+const (
+	cornerSW = "sw" // plot (1,1)
+	cornerSE = "se" // plot (width,1)
+	cornerNW = "nw" // plot (1,length)
+	cornerNE = "ne" // plot (width,length)
+)
+
+// sweep is how the drone snakes over the estate, row by row or column by column
+// starting from one of its corners. The patrol always snakes row by row from plot (1,1),
+// the estate is laid out for the patrol so that its patrol is the sweep on the estate.
+type sweep struct {
+	orientation string
+	corner      string
+}
+
+// sweepDefault snake row by row from plot (1,1), the patrol stored with the estate
+var sweepDefault = sweep{orientation: sweepRows, corner: cornerSW}
+
+// allSweeps return the sweeps in every orientation from every corner, the default one first
+func allSweeps() []sweep {
+	sweeps := make([]sweep, 0, 8)
+	for _, orientation := range []string{sweepRows, sweepColumns} {
+		for _, corner := range []string{cornerSW, cornerSE, cornerNW, cornerNE} {
+			sweeps = append(sweeps, sweep{orientation: orientation, corner: corner})
+		}
+	}
+
+	return sweeps
+}
+
+var (
+	errUnknownSweep         = errors.New("unknown sweep")
+	errUnknownStartCorner   = errors.New("unknown start corner")
+	errStartCornerAutoSweep = errors.New("start corner with auto sweep")
+)
+
+// parseSweeps return the sweeps asked by orientation and start corner, empty for the default,
+// every sweep for auto
+func parseSweeps(orientation, corner string) ([]sweep, error) {
+	switch orientation {
+	case sweepAuto:
+		if corner != "" {
+			return nil, errStartCornerAutoSweep
+		}
+		return allSweeps(), nil
+	case "":
+		orientation = sweepRows
+	case sweepRows, sweepColumns:
+	default:
+		return nil, errUnknownSweep
+	}
+
+	switch corner {
+	case "":
+		corner = cornerSW
+	case cornerSW, cornerSE, cornerNW, cornerNE:
+	default:
+		return nil, errUnknownStartCorner
+	}
+
+	return []sweep{{orientation: orientation, corner: corner}}, nil
+}
+
+func (s sweep) flipX() bool {
+	return s.corner == cornerSE || s.corner == cornerNE
+}
+
+func (s sweep) flipY() bool {
+	return s.corner == cornerNW || s.corner == cornerNE
+}
+
+// patrolEstate return the estate laid out for the patrol, columns are the rows of the patrol
+func (s sweep) patrolEstate(estate repository.Estate) repository.Estate {
+	patrolEstate := estate
+	if s != sweepDefault {
+		toPatrol := func(polygon []repository.Plot) []repository.Plot {
+			if len(polygon) == 0 {
+				return polygon
+			}
+
+			patrolPolygon := make([]repository.Plot, len(polygon))
+			for i, vertex := range polygon {
+				patrolPolygon[i].X, patrolPolygon[i].Y = s.toPatrol(estate, vertex.X, vertex.Y)
+			}
+			return patrolPolygon
+		}
+
+		if len(estate.Zones) > 0 {
+			patrolEstate.Zones = make([]repository.Zone, len(estate.Zones))
+			for i, zone := range estate.Zones {
+				zone.Polygon = toPatrol(zone.Polygon)
+				patrolEstate.Zones[i] = zone
+			}
+		}
+		patrolEstate.Boundary = toPatrol(estate.Boundary)
+	}
+
+	if s.orientation == sweepColumns {
+		patrolEstate.Width, patrolEstate.Length = estate.Length, estate.Width
+	}
+
+	return patrolEstate
+}
+
+// toPatrol return the plot of the patrol of plot (x,y) of the estate
+func (s sweep) toPatrol(estate repository.Estate, x, y int) (int, int) {
+	if s.flipX() {
+		x = estate.Width + 1 - x
+	}
+	if s.flipY() {
+		y = estate.Length + 1 - y
+	}
+	if s.orientation == sweepColumns {
+		x, y = y, x
+	}
+
+	return x, y
+}
+
+// fromPatrol return the plot of the estate of plot (x,y) of the patrol, see toPatrol
+func (s sweep) fromPatrol(estate repository.Estate, x, y int) (int, int) {
+	if s.orientation == sweepColumns {
+		x, y = y, x
+	}
+	if s.flipX() {
+		x = estate.Width + 1 - x
+	}
+	if s.flipY() {
+		y = estate.Length + 1 - y
+	}
+
+	return x, y
+}
+
+// direction return the direction on the estate of the direction of the patrol,
+// vertical moves are the same whatever the sweep
+func (s sweep) direction(direction string) string {
+	var alongX, forward bool
+	switch direction {
+	case directionEW:
+		alongX, forward = true, true
+	case directionWE:
+		alongX, forward = true, false
+	case directionSN:
+		alongX, forward = false, true
+	case directionNS:
+		alongX, forward = false, false
+	default:
+		return direction
+	}
+
+	if s.orientation == sweepColumns {
+		alongX = !alongX
+	}
+	if (alongX && s.flipX()) || (!alongX && s.flipY()) {
+		forward = !forward
+	}
+
+	switch {
+	case alongX && forward:
+		return directionEW
+	case alongX:
+		return directionWE
+	case forward:
+		return directionSN
+	default:
+		return directionNS
+	}
+}
+
+// patrolTrees return the trees of the estate on the plots of the patrol
+func (s sweep) patrolTrees(estate repository.Estate, trees []repository.Tree) []repository.Tree {
+	if s == sweepDefault {
+		return trees
+	}
+
+	patrolTrees := make([]repository.Tree, len(trees))
+	for i, tree := range trees {
+		tree.X, tree.Y = s.toPatrol(estate, tree.X, tree.Y)
+		patrolTrees[i] = tree
+	}
+
+	return patrolTrees
+}
+
+// routeSteps return the route steps of the patrol on the plots of the estate
+func (s sweep) routeSteps(estate repository.Estate, routeSteps []repository.RouteStep) []repository.RouteStep {
+	if s == sweepDefault {
+		return routeSteps
+	}
+
+	estateSteps := make([]repository.RouteStep, len(routeSteps))
+	for i, routeStep := range routeSteps {
+		routeStep.X, routeStep.Y = s.fromPatrol(estate, routeStep.X, routeStep.Y)
+		routeStep.Direction = s.direction(routeStep.Direction)
+		estateSteps[i] = routeStep
+	}
+
+	return estateSteps
+}
+
+func sweepResponse(s sweep) generated.Sweep {
+	return generated.Sweep{
+		Orientation: generated.SweepOrientation(s.orientation),
+		StartCorner: generated.SweepStartCorner(s.corner),
+	}
+}
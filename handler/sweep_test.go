@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSweeps(t *testing.T) {
+	tests := []struct {
+		name           string
+		orientation    string
+		corner         string
+		expectedSweeps []sweep
+		expectedErr    error
+	}{
+		{
+			name:           "Default",
+			expectedSweeps: []sweep{sweepDefault},
+		},
+		{
+			name:           "Columns",
+			orientation:    "columns",
+			expectedSweeps: []sweep{{orientation: "columns", corner: "sw"}},
+		},
+		{
+			name:           "Start corner",
+			corner:         "ne",
+			expectedSweeps: []sweep{{orientation: "rows", corner: "ne"}},
+		},
+		{
+			name:        "Auto",
+			orientation: "auto",
+			expectedSweeps: []sweep{
+				{orientation: "rows", corner: "sw"},
+				{orientation: "rows", corner: "se"},
+				{orientation: "rows", corner: "nw"},
+				{orientation: "rows", corner: "ne"},
+				{orientation: "columns", corner: "sw"},
+				{orientation: "columns", corner: "se"},
+				{orientation: "columns", corner: "nw"},
+				{orientation: "columns", corner: "ne"},
+			},
+		},
+		{
+			name:        "Auto with start corner",
+			orientation: "auto",
+			corner:      "sw",
+			expectedErr: errStartCornerAutoSweep,
+		},
+		{
+			name:        "Unknown sweep",
+			orientation: "diagonal",
+			expectedErr: errUnknownSweep,
+		},
+		{
+			name:        "Unknown start corner",
+			corner:      "center",
+			expectedErr: errUnknownStartCorner,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sweeps, err := parseSweeps(tc.orientation, tc.corner)
+			require.Equal(t, tc.expectedErr, err)
+			require.Equal(t, tc.expectedSweeps, sweeps)
+		})
+	}
+}
+
+func TestSweepPlots(t *testing.T) {
+	estate := repository.Estate{Width: 5, Length: 3}
+
+	tests := []struct {
+		name              string
+		sweep             sweep
+		expectedStart     [2]int
+		expectedDirection map[string]string
+	}{
+		{
+			name:              "Rows from south west",
+			sweep:             sweep{orientation: "rows", corner: "sw"},
+			expectedStart:     [2]int{1, 1},
+			expectedDirection: map[string]string{"ew": "ew", "we": "we", "sn": "sn", "ns": "ns", "vu": "vu"},
+		},
+		{
+			name:              "Rows from north east",
+			sweep:             sweep{orientation: "rows", corner: "ne"},
+			expectedStart:     [2]int{5, 3},
+			expectedDirection: map[string]string{"ew": "we", "we": "ew", "sn": "ns", "ns": "sn", "vd": "vd"},
+		},
+		{
+			name:              "Columns from south west",
+			sweep:             sweep{orientation: "columns", corner: "sw"},
+			expectedStart:     [2]int{1, 1},
+			expectedDirection: map[string]string{"ew": "sn", "we": "ns", "sn": "ew", "ns": "we", "--": "--"},
+		},
+		{
+			name:              "Columns from south east",
+			sweep:             sweep{orientation: "columns", corner: "se"},
+			expectedStart:     [2]int{5, 1},
+			expectedDirection: map[string]string{"ew": "sn", "we": "ns", "sn": "we", "ns": "ew"},
+		},
+		{
+			name:              "Columns from north west",
+			sweep:             sweep{orientation: "columns", corner: "nw"},
+			expectedStart:     [2]int{1, 3},
+			expectedDirection: map[string]string{"ew": "ns", "we": "sn", "sn": "ew", "ns": "we"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			patrolEstate := tc.sweep.patrolEstate(estate)
+			require.Equal(t, estate.Width*estate.Length, patrolEstate.Width*patrolEstate.Length)
+
+			// the patrol starts on plot (1,1)
+			x, y := tc.sweep.fromPatrol(estate, 1, 1)
+			require.Equal(t, tc.expectedStart, [2]int{x, y})
+
+			// every plot of the estate is a plot of the patrol and back
+			for x := 1; x <= estate.Width; x++ {
+				for y := 1; y <= estate.Length; y++ {
+					patrolX, patrolY := tc.sweep.toPatrol(estate, x, y)
+					require.True(t, patrolX >= 1 && patrolX <= patrolEstate.Width && patrolY >= 1 && patrolY <= patrolEstate.Length)
+
+					estateX, estateY := tc.sweep.fromPatrol(estate, patrolX, patrolY)
+					require.Equal(t, [2]int{x, y}, [2]int{estateX, estateY})
+				}
+			}
+
+			for patrolDirection, expected := range tc.expectedDirection {
+				require.Equal(t, expected, tc.sweep.direction(patrolDirection), patrolDirection)
+			}
+		})
+	}
+}
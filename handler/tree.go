@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+const treesPageLimitDefault = 100
+
+// page limits are the ones of api.yml, like the tree height
+var treesPageLimitMin, treesPageLimitMax = specParamIntRange("/estate/{id}/trees", http.MethodGet, "limit")
+
+func (srv *Server) GetEstateIdTrees(ectx echo.Context, id string, params generated.GetEstateIdTreesParams) error {
+	// id is estateID
+	if len(id) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[ListTrees] param estate_id not passed")
+		return respondNotFound(ectx)
+	}
+
+	// cursor is the plot of the last tree of the previous page
+	var afterX, afterY int
+	if params.Cursor != nil {
+		_, err := fmt.Sscanf(*params.Cursor, "%d,%d", &afterX, &afterY)
+		if err != nil || afterX < 1 || afterY < 1 {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[ListTrees] invalid param cursor: %s", *params.Cursor)
+			return respondInvalidFields(ectx, []generated.FieldError{
+				fieldError("cursor", generated.ProblemCodeInvalidParameter, "cursor is not one of a previous page"),
+			})
+		}
+	}
+
+	limit := treesPageLimitDefault
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	filter := repository.TreeFilter{
+		FromX:     1,
+		ToX:       math.MaxInt32,
+		FromY:     1,
+		ToY:       math.MaxInt32,
+		MinHeight: treeHeightMin,
+		MaxHeight: treeHeightMax,
+	}
+	if params.FromX != nil {
+		filter.FromX = *params.FromX
+	}
+	if params.ToX != nil {
+		filter.ToX = *params.ToX
+	}
+	if params.FromY != nil {
+		filter.FromY = *params.FromY
+	}
+	if params.ToY != nil {
+		filter.ToY = *params.ToY
+	}
+	if params.MinHeight != nil {
+		filter.MinHeight = *params.MinHeight
+	}
+	if params.MaxHeight != nil {
+		filter.MaxHeight = *params.MaxHeight
+	}
+
+	// validation
+	fieldErrs := make([]generated.FieldError, 0)
+	if limit < treesPageLimitMin || limit > treesPageLimitMax {
+		fieldErrs = append(fieldErrs, fieldError("limit", generated.ProblemCodeInvalidParameter, fmt.Sprintf("limit must be between %d and %d", treesPageLimitMin, treesPageLimitMax)))
+	}
+	if filter.FromX < 1 || filter.FromX > filter.ToX {
+		fieldErrs = append(fieldErrs, fieldError("from_x", generated.ProblemCodeInvalidParameter, "from_x must be at least 1 and not after to_x"))
+	}
+	if filter.FromY < 1 || filter.FromY > filter.ToY {
+		fieldErrs = append(fieldErrs, fieldError("from_y", generated.ProblemCodeInvalidParameter, "from_y must be at least 1 and not after to_y"))
+	}
+	if filter.MinHeight < treeHeightMin || filter.MaxHeight > treeHeightMax || filter.MinHeight > filter.MaxHeight {
+		fieldErrs = append(fieldErrs, fieldError("min_height", generated.ProblemCodeInvalidParameter,
+			fmt.Sprintf("min_height and max_height must be between %d and %d, min_height not above max_height", treeHeightMin, treeHeightMax)))
+	}
+	if len(fieldErrs) > 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[ListTrees] invalid params:%+v", fieldErrs)
+		return respondInvalidFields(ectx, fieldErrs)
+	}
+
+	_, err := srv.repository.GetEstateByID(
+		ectx.Request().Context(),
+		id,
+	)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[ListTrees] failed retrieve information estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	// one more tree than asked tells whether there is a next page
+	trees, err := srv.repository.GetTreesPage(ectx.Request().Context(), id, filter, afterX, afterY, limit+1)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[ListTrees] failed to read trees estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	var resp generated.EstateTreesResponse
+	if len(trees) > limit {
+		trees = trees[:limit]
+		nextCursor := fmt.Sprintf("%d,%d", trees[limit-1].X, trees[limit-1].Y)
+		resp.NextCursor = &nextCursor
+	}
+
+	resp.Trees = make([]generated.Tree, 0, len(trees))
+	for _, tree := range trees {
+		resp.Trees = append(resp.Trees, treeResponse(tree))
+	}
+
+	return ectx.JSON(http.StatusOK, resp)
+}
+
+func (srv *Server) GetEstateIdTreeTreeId(ectx echo.Context, id string, treeId string) error {
+	tree, err := srv.estateTree(ectx, "[GetTree]", id, treeId)
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	return ectx.JSON(http.StatusOK, treeResponse(tree))
+}
+
+func (srv *Server) PatchEstateIdTreeTreeId(ectx echo.Context, id string, treeId string) error {
+	var payload generated.UpdateTreeRequestBody
+
+	tree, err := srv.estateTree(ectx, "[UpdateTree]", id, treeId)
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	defer ectx.Request().Body.Close()
+	err = ectx.Bind(&payload)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[UpdateTree] failed to read payload, err:%s", err)
+		return respondBadRequest(ectx, generated.ProblemCodeInvalidBody, "payload is not a valid tree update")
+	}
+
+	// the payload is in the limits of api.yml, see Validator
+
+	grownTree := tree
+	grownTree.Height = payload.Height
+
+	err = srv.changeEstateTrees(ectx, "[UpdateTree]", id, func(txSrv *Server, _ repository.Estate) (before, after *repository.Tree, err error) {
+		// the tree may have been changed meanwhile, grow it from the height it has now
+		tree, err := txSrv.repository.GetTreeByID(ectx.Request().Context(), id, treeId)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[UpdateTree] failed retrieve information tree_id:%s, err:%s", treeId, err)
+			return nil, nil, err
+		}
+
+		// nothing changes, the estate metadata is left as is
+		if payload.Height == tree.Height {
+			return nil, nil, nil
+		}
+
+		err = txSrv.repository.UpdateTreeHeight(ectx.Request().Context(), treeId, payload.Height)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[UpdateTree] failed to update tree_id:%s, err:%s", treeId, err)
+			return nil, nil, err
+		}
+
+		return &tree, &grownTree, nil
+	})
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	return ectx.JSON(http.StatusOK, treeResponse(grownTree))
+}
+
+func (srv *Server) DeleteEstateIdTreeTreeId(ectx echo.Context, id string, treeId string) error {
+	_, err := srv.estateTree(ectx, "[DeleteTree]", id, treeId)
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	err = srv.changeEstateTrees(ectx, "[DeleteTree]", id, func(txSrv *Server, _ repository.Estate) (before, after *repository.Tree, err error) {
+		// the tree may have been changed meanwhile, fell it at the height it has now
+		tree, err := txSrv.repository.GetTreeByID(ectx.Request().Context(), id, treeId)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[DeleteTree] failed retrieve information tree_id:%s, err:%s", treeId, err)
+			return nil, nil, err
+		}
+
+		err = txSrv.repository.DeleteTree(ectx.Request().Context(), treeId)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[DeleteTree] failed to delete tree_id:%s, err:%s", treeId, err)
+			return nil, nil, err
+		}
+
+		return &tree, nil, nil
+	})
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
+
+// estateTree return the tree treeID planted in the estate estateID,
+// repository.ErrNotFound when either of them does not exist.
+func (srv *Server) estateTree(ectx echo.Context, logTag, estateID, treeID string) (repository.Tree, error) {
+	if len(estateID) == 0 || len(treeID) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s param estate_id or tree_id not passed", logTag)
+		return repository.Tree{}, fmt.Errorf("%w: estate_id or tree_id not passed", repository.ErrNotFound)
+	}
+
+	tree, err := srv.repository.GetTreeByID(ectx.Request().Context(), estateID, treeID)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s failed retrieve information estate_id:%s tree_id:%s, err:%s", logTag, estateID, treeID, err)
+		return repository.Tree{}, err
+	}
+
+	return tree, nil
+}
+
+func treeResponse(tree repository.Tree) generated.Tree {
+	return generated.Tree{
+		Id:     tree.ID,
+		X:      tree.X,
+		Y:      tree.Y,
+		Height: tree.Height,
+	}
+}
+
+// treeFieldErrors validate a tree planted on plot (x,y) is inside the estate, every
+// field at fault is told for the client to highlight all of them
+func treeFieldErrors(estate repository.Estate, x, y int) []generated.FieldError {
+	fieldErrs := make([]generated.FieldError, 0)
+	if x < 1 || x > estate.Width {
+		fieldErrs = append(fieldErrs, fieldError("x", generated.ProblemCodeTreeOutOfBounds, fmt.Sprintf("x must be between 1 and %d", estate.Width)))
+	}
+	if y < 1 || y > estate.Length {
+		fieldErrs = append(fieldErrs, fieldError("y", generated.ProblemCodeTreeOutOfBounds, fmt.Sprintf("y must be between 1 and %d", estate.Length)))
+	}
+	if len(fieldErrs) == 0 && !insideBoundary(estate, x, y) {
+		fieldErrs = append(fieldErrs, fieldError("x", generated.ProblemCodeTreeOutOfBounds, fmt.Sprintf("plot (%d,%d) is outside of the estate boundary", x, y)))
+	}
+
+	return fieldErrs
+}
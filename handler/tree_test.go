@@ -0,0 +1,542 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetEstateIdTrees(t *testing.T) {
+	cursor := "4,1"
+	badCursor := "4"
+	fromX, toX, minHeight := 2, 1, 10
+	limit := 2
+	estate := repository.Estate{ID: "valid_estate_id", Width: 5, Length: 3}
+	trees := []repository.Tree{
+		{ID: "tree_id_1", EstateID: "valid_estate_id", X: 2, Y: 1, Height: 5},
+		{ID: "tree_id_2", EstateID: "valid_estate_id", X: 4, Y: 1, Height: 12},
+		{ID: "tree_id_3", EstateID: "valid_estate_id", X: 1, Y: 2, Height: 7},
+	}
+	allTrees := repository.TreeFilter{FromX: 1, ToX: math.MaxInt32, FromY: 1, ToY: math.MaxInt32, MinHeight: 1, MaxHeight: 30}
+
+	tests := []struct {
+		name               string
+		id                 string
+		params             generated.GetEstateIdTreesParams
+		mockGetEstateErr   error
+		callGetEstate      bool
+		callGetTreesPage   bool
+		expectedFilter     repository.TreeFilter
+		expectedAfterX     int
+		expectedAfterY     int
+		expectedLimit      int
+		mockTrees          []repository.Tree
+		expectedCode       int
+		expectedTreeIDs    []string
+		expectedNextCursor *string
+		expectedMessage    string
+	}{
+		{
+			name:               "Positive Flow - First page",
+			id:                 "valid_estate_id",
+			params:             generated.GetEstateIdTreesParams{Limit: &limit},
+			callGetEstate:      true,
+			callGetTreesPage:   true,
+			expectedFilter:     allTrees,
+			expectedLimit:      3,
+			mockTrees:          trees,
+			expectedCode:       http.StatusOK,
+			expectedTreeIDs:    []string{"tree_id_1", "tree_id_2"},
+			expectedNextCursor: &cursor,
+		},
+		{
+			name:             "Positive Flow - Next page filtered by height",
+			id:               "valid_estate_id",
+			params:           generated.GetEstateIdTreesParams{Cursor: &cursor, MinHeight: &minHeight},
+			callGetEstate:    true,
+			callGetTreesPage: true,
+			expectedFilter: repository.TreeFilter{
+				FromX: 1, ToX: math.MaxInt32, FromY: 1, ToY: math.MaxInt32, MinHeight: 10, MaxHeight: 30,
+			},
+			expectedAfterX:  4,
+			expectedAfterY:  1,
+			expectedLimit:   101,
+			mockTrees:       []repository.Tree{},
+			expectedCode:    http.StatusOK,
+			expectedTreeIDs: []string{},
+		},
+		{
+			name:            "Invalid cursor",
+			id:              "valid_estate_id",
+			params:          generated.GetEstateIdTreesParams{Cursor: &badCursor},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "cursor is not one of a previous page",
+		},
+		{
+			name:            "Invalid x range",
+			id:              "valid_estate_id",
+			params:          generated.GetEstateIdTreesParams{FromX: &fromX, ToX: &toX},
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "from_x must be at least 1 and not after to_x",
+		},
+		{
+			name:            "Empty ID",
+			id:              "",
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+		{
+			name:             "Estate not found",
+			id:               "valid_estate_id",
+			callGetEstate:    true,
+			mockGetEstateErr: repository.ErrNotFound,
+			expectedCode:     http.StatusNotFound,
+			expectedMessage:  "resource not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/"+tc.id+"/trees", nil)
+			rec := httptest.NewRecorder()
+
+			if tc.callGetEstate {
+				mockRepo.EXPECT().
+					GetEstateByID(gomock.Any(), tc.id).
+					Return(estate, tc.mockGetEstateErr).
+					Times(1)
+			}
+			if tc.callGetTreesPage {
+				mockRepo.EXPECT().
+					GetTreesPage(gomock.Any(), tc.id, tc.expectedFilter, tc.expectedAfterX, tc.expectedAfterY, tc.expectedLimit).
+					Return(tc.mockTrees, nil).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdTrees(c, tc.id, tc.params)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				var resp generated.EstateTreesResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				treeIDs := make([]string, 0, len(resp.Trees))
+				for _, tree := range resp.Trees {
+					treeIDs = append(treeIDs, tree.Id)
+				}
+				require.Equal(t, tc.expectedTreeIDs, treeIDs)
+				require.Equal(t, tc.expectedNextCursor, resp.NextCursor)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestGetEstateIdTreeTreeId(t *testing.T) {
+	tree := repository.Tree{ID: "valid_tree_id", EstateID: "valid_estate_id", X: 2, Y: 1, Height: 5}
+
+	tests := []struct {
+		name            string
+		treeID          string
+		mockGetTreeErr  error
+		callGetTree     bool
+		expectedCode    int
+		expectedTree    generated.Tree
+		expectedMessage string
+	}{
+		{
+			name:         "Positive Flow",
+			treeID:       "valid_tree_id",
+			callGetTree:  true,
+			expectedCode: http.StatusOK,
+			expectedTree: generated.Tree{Id: "valid_tree_id", X: 2, Y: 1, Height: 5},
+		},
+		{
+			name:            "Tree not found in estate",
+			treeID:          "valid_tree_id",
+			callGetTree:     true,
+			mockGetTreeErr:  repository.ErrNotFound,
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+		{
+			name:            "Empty tree ID",
+			treeID:          "",
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/valid_estate_id/tree/"+tc.treeID, nil)
+			rec := httptest.NewRecorder()
+
+			if tc.callGetTree {
+				mockRepo.EXPECT().
+					GetTreeByID(gomock.Any(), "valid_estate_id", tc.treeID).
+					Return(tree, tc.mockGetTreeErr).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdTreeTreeId(c, "valid_estate_id", tc.treeID)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				var resp generated.Tree
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedTree, resp)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestPatchEstateIdTreeTreeId(t *testing.T) {
+	estate := repository.Estate{
+		ID:             "valid_estate_id",
+		Width:          5,
+		Length:         1,
+		Count:          1,
+		Min:            5,
+		Max:            5,
+		Median:         5,
+		PatrolDistance: 62,
+		StatsStatus:    repository.StatsStatusFresh,
+	}
+	tree := repository.Tree{ID: "valid_tree_id", EstateID: "valid_estate_id", X: 2, Y: 1, Height: 5}
+
+	tests := []struct {
+		name             string
+		payload          string
+		mockGetTreeErr   error
+		callLock         bool
+		callUpdate       bool
+		callCalculate    bool
+		mockCalculateErr error
+		callEnqueue      bool
+		expectedCode     int
+		expectedHeight   int
+		expectedMessage  string
+	}{
+		{
+			// the stats are kept fresh right away, no recalculation is enqueued
+			name:           "Positive Flow",
+			payload:        `{"height": 12}`,
+			callLock:       true,
+			callUpdate:     true,
+			callCalculate:  true,
+			expectedCode:   http.StatusOK,
+			expectedHeight: 12,
+		},
+		{
+			name:           "Positive Flow - Same height",
+			payload:        `{"height": 5}`,
+			callLock:       true,
+			expectedCode:   http.StatusOK,
+			expectedHeight: 5,
+		},
+		{
+			name:             "Calculation failed, height stored anyway",
+			payload:          `{"height": 12}`,
+			callLock:         true,
+			callUpdate:       true,
+			callCalculate:    true,
+			mockCalculateErr: errors.New("repository error"),
+			callEnqueue:      true,
+			expectedCode:     http.StatusOK,
+			expectedHeight:   12,
+		},
+		{
+			name:            "Invalid payload",
+			payload:         `{"height": "tall"}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "payload is not a valid tree update",
+		},
+		{
+			name:            "Tree not found in estate",
+			payload:         `{"height": 12}`,
+			mockGetTreeErr:  repository.ErrNotFound,
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/estate/valid_estate_id/tree/valid_tree_id", bytes.NewBufferString(tc.payload))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			mockRepo.EXPECT().
+				GetTreeByID(gomock.Any(), "valid_estate_id", "valid_tree_id").
+				Return(tree, tc.mockGetTreeErr).
+				Times(1)
+			// the height is stored again along with the recalculation job when the calculation failed
+			attempts := 1
+			if tc.mockCalculateErr != nil {
+				attempts = 2
+			}
+			if tc.callEnqueue {
+				mockRepo.EXPECT().EnqueueRecalculation(gomock.Any(), "valid_estate_id").Return(nil).Times(1)
+			}
+			if tc.callLock {
+				// the tree is read again once the estate is locked
+				for i := 0; i < attempts; i++ {
+					expectEstateLock(mockRepo, estate)
+				}
+				mockRepo.EXPECT().
+					GetTreeByID(gomock.Any(), "valid_estate_id", "valid_tree_id").
+					Return(tree, nil).
+					Times(attempts)
+			}
+			if tc.callUpdate {
+				mockRepo.EXPECT().
+					UpdateTreeHeight(gomock.Any(), "valid_tree_id", 12).
+					Return(nil).
+					Times(attempts)
+			}
+			if tc.callCalculate {
+				mockRepo.EXPECT().GetEstateByID(gomock.Any(), "valid_estate_id").Return(estate, nil).Times(1)
+				mockRepo.EXPECT().GetPatrolNeighbourTrees(gomock.Any(), "valid_estate_id", 2, 1).Return(nil, nil, nil).Times(1)
+				mockRepo.EXPECT().GetTreeHeightCounts(gomock.Any(), "valid_estate_id").Return(map[int]int{12: 1}, nil).Times(1)
+				// the drone climbs and descends 7 meters more over the tree
+				mockRepo.EXPECT().
+					UpdateEstate(gomock.Any(), "valid_estate_id", 1, 12, 12, 12, 76, nil).
+					Return(tc.mockCalculateErr).
+					Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.PatchEstateIdTreeTreeId(c, "valid_estate_id", "valid_tree_id")
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				var resp generated.Tree
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				require.Equal(t, generated.Tree{Id: "valid_tree_id", X: 2, Y: 1, Height: tc.expectedHeight}, resp)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestDeleteEstateIdTreeTreeId(t *testing.T) {
+	estate := repository.Estate{
+		ID:             "valid_estate_id",
+		Width:          5,
+		Length:         1,
+		Count:          2,
+		Min:            3,
+		Max:            5,
+		Median:         4,
+		PatrolDistance: 62,
+		StatsStatus:    repository.StatsStatusFresh,
+	}
+	tree := repository.Tree{ID: "valid_tree_id", EstateID: "valid_estate_id", X: 2, Y: 1, Height: 5}
+	nextTree := repository.Tree{ID: "next_tree_id", EstateID: "valid_estate_id", X: 3, Y: 1, Height: 3}
+
+	tests := []struct {
+		name             string
+		mockGetTreeErr   error
+		mockDeleteErr    error
+		pending          bool
+		callDelete       bool
+		callCalculate    bool
+		mockCalculateErr error
+		callEnqueue      bool
+		mockEnqueueErr   error
+		expectedCode     int
+		expectedMessage  string
+	}{
+		{
+			// the stats are kept fresh right away, no recalculation is enqueued
+			name:          "Positive Flow",
+			callDelete:    true,
+			callCalculate: true,
+			expectedCode:  http.StatusNoContent,
+		},
+		{
+			name:         "Stats pending, recalculated in background",
+			pending:      true,
+			callDelete:   true,
+			callEnqueue:  true,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:             "Positive Flow - Calculation failed, tree deleted anyway",
+			callDelete:       true,
+			callCalculate:    true,
+			mockCalculateErr: errors.New("repository error"),
+			callEnqueue:      true,
+			expectedCode:     http.StatusNoContent,
+		},
+		{
+			name:             "Calculation and enqueue failed",
+			callDelete:       true,
+			callCalculate:    true,
+			mockCalculateErr: errors.New("repository error"),
+			callEnqueue:      true,
+			mockEnqueueErr:   errors.New("repository error"),
+			expectedCode:     http.StatusInternalServerError,
+			expectedMessage:  "internal server error",
+		},
+		{
+			name:            "Enqueue failed",
+			pending:         true,
+			callDelete:      true,
+			callEnqueue:     true,
+			mockEnqueueErr:  errors.New("repository error"),
+			expectedCode:    http.StatusInternalServerError,
+			expectedMessage: "internal server error",
+		},
+		{
+			name:            "Delete failed",
+			callDelete:      true,
+			mockDeleteErr:   errors.New("repository error"),
+			expectedCode:    http.StatusInternalServerError,
+			expectedMessage: "internal server error",
+		},
+		{
+			name:            "Tree not found in estate",
+			mockGetTreeErr:  repository.ErrNotFound,
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/estate/valid_estate_id/tree/valid_tree_id", nil)
+			rec := httptest.NewRecorder()
+
+			mockRepo.EXPECT().
+				GetTreeByID(gomock.Any(), "valid_estate_id", "valid_tree_id").
+				Return(tree, tc.mockGetTreeErr).
+				Times(1)
+			// the tree is deleted again along with the recalculation job when the calculation failed
+			attempts := 1
+			if tc.mockCalculateErr != nil {
+				attempts = 2
+			}
+			if tc.callEnqueue {
+				mockRepo.EXPECT().EnqueueRecalculation(gomock.Any(), "valid_estate_id").Return(tc.mockEnqueueErr).Times(1)
+			}
+			if tc.callDelete {
+				lockedEstate := estate
+				if tc.pending {
+					lockedEstate.StatsStatus = repository.StatsStatusPending
+				}
+				// the tree is read again once the estate is locked
+				for i := 0; i < attempts; i++ {
+					expectEstateLock(mockRepo, lockedEstate)
+				}
+				mockRepo.EXPECT().
+					GetTreeByID(gomock.Any(), "valid_estate_id", "valid_tree_id").
+					Return(tree, nil).
+					Times(attempts)
+				mockRepo.EXPECT().
+					DeleteTree(gomock.Any(), "valid_tree_id").
+					Return(tc.mockDeleteErr).
+					Times(attempts)
+			}
+			if tc.callCalculate {
+				mockRepo.EXPECT().GetEstateByID(gomock.Any(), "valid_estate_id").Return(estate, nil).Times(1)
+				mockRepo.EXPECT().GetPatrolNeighbourTrees(gomock.Any(), "valid_estate_id", 2, 1).Return(nil, &nextTree, nil).Times(1)
+				mockRepo.EXPECT().GetTreeHeightCounts(gomock.Any(), "valid_estate_id").Return(map[int]int{3: 1}, nil).Times(1)
+				// the drone no longer climbs over the felled tree, 2 meters less up and down twice
+				mockRepo.EXPECT().
+					UpdateEstate(gomock.Any(), "valid_estate_id", 1, 3, 3, 3, 58, nil).
+					Return(tc.mockCalculateErr).
+					Times(1)
+			}
+			c := e.NewContext(req, rec)
+			err := srv.DeleteEstateIdTreeTreeId(c, "valid_estate_id", "valid_tree_id")
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode != http.StatusNoContent {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
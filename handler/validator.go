@@ -0,0 +1,380 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+)
+
+// operations with this extension validate their request body on their own,
+// e.g. the batch reports every invalid row instead of rejecting the whole body
+const extensionBodyValidatedByHandler = "x-body-validated-by-handler"
+
+// ValidatorOptions tells what the validator checks besides the requests
+type ValidatorOptions struct {
+	// ValidateResponses check the responses against the spec too, meant for tests.
+	// The response is held back until it is checked, a response out of the spec
+	// is replaced by an internal server error telling what is wrong with it.
+	ValidateResponses bool
+}
+
+// Validator validate the requests (and responses) against api.yml, the spec is the
+// single source of truth of the limits, e.g. the estate size or the tree height.
+// Requests out of the spec are told as problem details without reaching the handlers.
+type Validator struct {
+	spec    *openapi3.T
+	router  routers.Router
+	options ValidatorOptions
+}
+
+var registerBodyDecoders sync.Once
+
+// NewValidator return reference to new instance of Validator of the spec,
+// e.g. the one embedded in the generated package
+func NewValidator(spec *openapi3.T, options ValidatorOptions) (*Validator, error) {
+	// the routes are matched on path only, whatever host serves them
+	spec.Servers = nil
+
+	router, err := legacy.NewRouter(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// files are only checked for their content type
+	registerBodyDecoders.Do(func() {
+		for _, contentType := range []string{mimeApplicationGeoJSON, mimeApplicationKML, mimeImageSVG, mimeImagePNG} {
+			openapi3filter.RegisterBodyDecoder(contentType, openapi3filter.FileBodyDecoder)
+		}
+	})
+
+	return &Validator{
+		spec:    spec,
+		router:  router,
+		options: options,
+	}, nil
+}
+
+// Middleware validate the request before passing it to next
+func (v *Validator) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ectx echo.Context) error {
+		req := ectx.Request()
+		route, pathParams, err := v.findRoute(req)
+		if err != nil {
+			// not in the spec, echo tells the client (e.g. 404 or 405)
+			return next(ectx)
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				ExcludeRequestBody: route.Operation.Extensions[extensionBodyValidatedByHandler] == true,
+				MultiError:         true,
+				// the handlers own the defaults
+				SkipSettingDefaults: true,
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+		err = openapi3filter.ValidateRequest(req.Context(), input)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[Validator] invalid request %s %s, err:%s", req.Method, req.URL.Path, err)
+			return respondInvalidRequest(ectx, err)
+		}
+
+		if !v.options.ValidateResponses {
+			return next(ectx)
+		}
+
+		return v.validateResponse(ectx, next, input)
+	}
+}
+
+// findRoute return the operation of the request. The path param followed by an
+// extension (e.g. /estate/{id}.geojson) is matched as the bare param by the router,
+// the route of the extension is looked up the same way Router does.
+func (v *Validator) findRoute(req *http.Request) (*routers.Route, map[string]string, error) {
+	route, pathParams, err := v.router.FindRoute(req)
+	if err != nil || !strings.HasSuffix(route.Path, "}") {
+		return route, pathParams, err
+	}
+
+	param := route.Path[strings.LastIndex(route.Path, "{")+1 : len(route.Path)-1]
+	value := pathParams[param]
+	idxExtension := strings.LastIndex(value, ".")
+	if idxExtension < 1 {
+		return route, pathParams, nil
+	}
+
+	path := route.Path + value[idxExtension:]
+	pathItem := v.spec.Paths.Value(path)
+	if pathItem == nil || pathItem.GetOperation(req.Method) == nil {
+		return route, pathParams, nil
+	}
+
+	pathParams[param] = value[:idxExtension]
+	return &routers.Route{
+		Spec:      v.spec,
+		Path:      path,
+		PathItem:  pathItem,
+		Method:    req.Method,
+		Operation: pathItem.GetOperation(req.Method),
+	}, pathParams, nil
+}
+
+// validateResponse hold back the response of next until it is checked against the spec
+func (v *Validator) validateResponse(ectx echo.Context, next echo.HandlerFunc, input *openapi3filter.RequestValidationInput) error {
+	resp := ectx.Response()
+	writer := resp.Writer
+	recorder := &responseRecorder{header: writer.Header(), status: http.StatusOK}
+	resp.Writer = recorder
+	defer func() {
+		resp.Writer = writer
+	}()
+
+	err := next(ectx)
+	if err != nil {
+		// echo writes the error once the writer is back, it is not one of the spec
+		return err
+	}
+
+	err = openapi3filter.ValidateResponse(ectx.Request().Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 recorder.status,
+		Header:                 recorder.header,
+		Body:                   io.NopCloser(bytes.NewReader(recorder.body.Bytes())),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			MultiError:            true,
+		},
+	})
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[Validator] invalid response %s %s, err:%s", input.Request.Method, input.Request.URL.Path, err)
+
+		problem := newProblem(http.StatusInternalServerError, generated.ProblemCodeInternalError, fmt.Sprintf("response out of the spec: %s", err))
+		data, err := json.Marshal(problem)
+		if err != nil {
+			return err
+		}
+
+		recorder.header.Set(echo.HeaderContentType, mimeApplicationProblemJSON)
+		recorder.status = http.StatusInternalServerError
+		recorder.body.Reset()
+		recorder.body.Write(data)
+	}
+
+	writer.WriteHeader(recorder.status)
+	_, err = writer.Write(recorder.body.Bytes())
+	return err
+}
+
+// responseRecorder keep the response for it to be checked before it is written
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	return r.body.Write(data)
+}
+
+// respondInvalidRequest respond with the problem of the request out of the spec,
+// every field (or query parameter) at fault is told for the client to highlight it
+func respondInvalidRequest(ectx echo.Context, err error) error {
+	fieldErrs, details := requestFieldErrors(err, nil, nil, "", generated.ProblemCodeInvalidBody)
+	if len(fieldErrs) == 0 {
+		return respondBadRequest(ectx, generated.ProblemCodeInvalidBody, strings.Join(details, ", "))
+	}
+
+	return respondInvalidFields(ectx, fieldErrs)
+}
+
+// requestFieldErrors collect the fields at fault of the validation error, details
+// is the errors that are not of any field, e.g. the body is not a JSON at all.
+// param is the query (or path) parameter the error is of, "" for the body.
+func requestFieldErrors(
+	err error,
+	fieldErrs []generated.FieldError,
+	details []string,
+	param string,
+	code generated.ProblemCode,
+) ([]generated.FieldError, []string) {
+	var reqErr *openapi3filter.RequestError
+	var schemaErr *openapi3.SchemaError
+	var parseErr *openapi3filter.ParseError
+
+	// the errors wrap each other both ways, e.g. the error of a parameter wraps the
+	// errors of its schema, the outermost one is taken first
+	multiErr, isMulti := err.(openapi3.MultiError)
+	if !isMulti {
+		errors.As(err, &reqErr)
+	}
+
+	switch {
+	case isMulti:
+		for _, err := range multiErr {
+			fieldErrs, details = requestFieldErrors(err, fieldErrs, details, param, code)
+		}
+	case reqErr != nil && reqErr.Parameter != nil:
+		return requestFieldErrors(reqErr.Err, fieldErrs, details, reqErr.Parameter.Name, generated.ProblemCodeInvalidParameter)
+	case reqErr != nil && reqErr.Err != nil:
+		return requestFieldErrors(reqErr.Err, fieldErrs, details, param, code)
+	case errors.As(err, &schemaErr):
+		field := param
+		if len(field) == 0 {
+			field = strings.Join(schemaErr.JSONPointer(), ".")
+		}
+		fieldErrs = append(fieldErrs, schemaFieldError(field, code, schemaErr))
+	case len(param) > 0 && errors.As(err, &parseErr):
+		fieldErrs = append(fieldErrs, fieldError(param, code, fmt.Sprintf("%s is not a valid value", param)))
+	case len(param) > 0:
+		fieldErrs = append(fieldErrs, fieldError(param, code, fmt.Sprintf("%s: %s", param, err)))
+	case errors.As(err, &parseErr):
+		details = append(details, "payload is not a valid JSON")
+	default:
+		details = append(details, err.Error())
+	}
+
+	return fieldErrs, details
+}
+
+// schemaFieldError tells the field out of its schema, values out of range are told
+// with their range the same way as the fields validated by the handlers
+func schemaFieldError(field string, code generated.ProblemCode, schemaErr *openapi3.SchemaError) generated.FieldError {
+	switch schemaErr.SchemaField {
+	case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+		if code == generated.ProblemCodeInvalidBody {
+			code = generated.ProblemCodeOutOfRange
+		}
+		return fieldError(field, code, fmt.Sprintf("%s must be %s", field, schemaRange(schemaErr.Schema)))
+	case "required":
+		return fieldError(field, code, fmt.Sprintf("%s is required", field))
+	}
+
+	if len(field) == 0 {
+		field = "body"
+	}
+	return fieldError(field, code, fmt.Sprintf("%s: %s", field, schemaErr.Reason))
+}
+
+// schemaRange tells the range of the schema, e.g. "between 1 and 30"
+func schemaRange(schema *openapi3.Schema) string {
+	if schema.Min != nil && schema.Max != nil && !schema.ExclusiveMin && !schema.ExclusiveMax {
+		return fmt.Sprintf("between %v and %v", *schema.Min, *schema.Max)
+	}
+
+	bounds := make([]string, 0, 2)
+	switch {
+	case schema.Min != nil && schema.ExclusiveMin:
+		bounds = append(bounds, fmt.Sprintf("greater than %v", *schema.Min))
+	case schema.Min != nil:
+		bounds = append(bounds, fmt.Sprintf("at least %v", *schema.Min))
+	}
+	switch {
+	case schema.Max != nil && schema.ExclusiveMax:
+		bounds = append(bounds, fmt.Sprintf("less than %v", *schema.Max))
+	case schema.Max != nil:
+		bounds = append(bounds, fmt.Sprintf("at most %v", *schema.Max))
+	}
+
+	return strings.Join(bounds, " and ")
+}
+
+// specIntRange return the minimum and maximum of the property of the schema in the
+// embedded spec, e.g. the tree height. It panics when the spec does not have them,
+// the spec is embedded at build time and the limits can not go missing later on.
+func specIntRange(schemaName, property string) (int, int) {
+	schemaRef, ok := embeddedSpec().Components.Schemas[schemaName]
+	if !ok || schemaRef.Value == nil || schemaRef.Value.Properties[property] == nil {
+		panic(fmt.Sprintf("spec has no property %s of schema %s", property, schemaName))
+	}
+
+	propertySchema := schemaRef.Value.Properties[property].Value
+	if propertySchema == nil || propertySchema.Min == nil || propertySchema.Max == nil {
+		panic(fmt.Sprintf("spec has no range of property %s of schema %s", property, schemaName))
+	}
+
+	return int(*propertySchema.Min), int(*propertySchema.Max)
+}
+
+// specParamIntRange return the minimum and maximum of the query parameter of the operation
+// in the embedded spec, e.g. the page limit, math.MaxInt when the parameter has no maximum.
+// It panics like specIntRange when the spec does not have them.
+func specParamIntRange(path, method, param string) (int, int) {
+	operation := specOperation(path, method)
+	paramRef := operation.Parameters.GetByInAndName(openapi3.ParameterInQuery, param)
+	if paramRef == nil || paramRef.Schema == nil || paramRef.Schema.Value == nil || paramRef.Schema.Value.Min == nil {
+		panic(fmt.Sprintf("spec has no minimum of param %s of %s %s", param, method, path))
+	}
+
+	paramSchema := paramRef.Schema.Value
+	if paramSchema.Max == nil {
+		return int(*paramSchema.Min), math.MaxInt
+	}
+
+	return int(*paramSchema.Min), int(*paramSchema.Max)
+}
+
+// specBodyMaxItems return the maximum number of items of the JSON array body of the
+// operation in the embedded spec, e.g. the trees of the batch. It panics like specIntRange
+// when the spec does not have it.
+func specBodyMaxItems(path, method string) int {
+	operation := specOperation(path, method)
+	if operation.RequestBody == nil || operation.RequestBody.Value == nil {
+		panic(fmt.Sprintf("spec has no body of %s %s", method, path))
+	}
+
+	mediaType := operation.RequestBody.Value.GetMediaType(echo.MIMEApplicationJSON)
+	if mediaType == nil || mediaType.Schema == nil || mediaType.Schema.Value == nil || mediaType.Schema.Value.MaxItems == nil {
+		panic(fmt.Sprintf("spec has no max items of the body of %s %s", method, path))
+	}
+
+	return int(*mediaType.Schema.Value.MaxItems)
+}
+
+// specOperation return the operation of the path in the embedded spec
+func specOperation(path, method string) *openapi3.Operation {
+	var operation *openapi3.Operation
+	if pathItem := embeddedSpec().Paths.Find(path); pathItem != nil {
+		operation = pathItem.GetOperation(method)
+	}
+	if operation == nil {
+		panic(fmt.Sprintf("spec has no operation %s %s", method, path))
+	}
+
+	return operation
+}
+
+// embeddedSpec return the spec embedded in the generated package
+func embeddedSpec() *openapi3.T {
+	spec, err := generated.GetSwagger()
+	if err != nil {
+		panic(fmt.Sprintf("failed to load the embedded spec, err:%s", err))
+	}
+
+	return spec
+}
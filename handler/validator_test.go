@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestValidator(t *testing.T) {
+	estate := repository.Estate{ID: "valid_estate_id", Width: 5, Length: 1, StatsStatus: repository.StatsStatusFresh}
+
+	tests := []struct {
+		name              string
+		method            string
+		path              string
+		contentType       string
+		body              string
+		mock              func(mockRepo *repository.MockRepositorier)
+		expectedCode      int
+		expectedProblem   generated.ProblemCode
+		expectedFieldErrs []generated.FieldError
+	}{
+		{
+			name:        "Positive Flow",
+			method:      http.MethodPost,
+			path:        "/estate",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"width": 5, "length": 1, "bearing": 359.5}`,
+			mock: func(mockRepo *repository.MockRepositorier) {
+				mockRepo.EXPECT().InsertEstate(gomock.Any(), gomock.Any()).Return("valid_estate_id", nil).Times(1)
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:            "Estate out of the limits",
+			method:          http.MethodPost,
+			path:            "/estate",
+			contentType:     echo.MIMEApplicationJSON,
+			body:            `{"width": 0, "length": 50001, "origin": {"latitude": 91}, "bearing": 360}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeValidationFailed,
+			expectedFieldErrs: []generated.FieldError{
+				{Field: "width", Code: generated.ProblemCodeOutOfRange, Message: "width must be between 1 and 50000"},
+				{Field: "length", Code: generated.ProblemCodeOutOfRange, Message: "length must be between 1 and 50000"},
+				{Field: "origin.latitude", Code: generated.ProblemCodeOutOfRange, Message: "origin.latitude must be between -90 and 90"},
+				{Field: "origin.longitude", Code: generated.ProblemCodeInvalidBody, Message: "origin.longitude is required"},
+				{Field: "bearing", Code: generated.ProblemCodeOutOfRange, Message: "bearing must be at least 0 and less than 360"},
+			},
+		},
+		{
+			name:            "Tree too tall",
+			method:          http.MethodPost,
+			path:            "/estate/valid_estate_id/tree",
+			contentType:     echo.MIMEApplicationJSON,
+			body:            `{"x": 1, "y": 1, "height": 31}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeOutOfRange,
+			expectedFieldErrs: []generated.FieldError{
+				{Field: "height", Code: generated.ProblemCodeOutOfRange, Message: "height must be between 1 and 30"},
+			},
+		},
+		{
+			name:            "Tree of wrong type",
+			method:          http.MethodPatch,
+			path:            "/estate/valid_estate_id/tree/tree_id",
+			contentType:     echo.MIMEApplicationJSON,
+			body:            `{"height": "10"}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeInvalidBody,
+			expectedFieldErrs: []generated.FieldError{
+				{Field: "height", Code: generated.ProblemCodeInvalidBody, Message: "height: value must be an integer"},
+			},
+		},
+		{
+			name:            "Payload not a JSON",
+			method:          http.MethodPost,
+			path:            "/estate",
+			contentType:     echo.MIMEApplicationJSON,
+			body:            `{"width": `,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeInvalidBody,
+		},
+		{
+			name:            "Query params out of the limits",
+			method:          http.MethodGet,
+			path:            "/estate/valid_estate_id/trees?limit=0&from_x=a",
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeInvalidParameter,
+			expectedFieldErrs: []generated.FieldError{
+				{Field: "limit", Code: generated.ProblemCodeInvalidParameter, Message: "limit must be between 1 and 1000"},
+				{Field: "from_x", Code: generated.ProblemCodeInvalidParameter, Message: "from_x is not a valid value"},
+			},
+		},
+		{
+			name:        "Batch rows validated by the handler",
+			method:      http.MethodPost,
+			path:        "/estate/valid_estate_id/trees:batch",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `[{"x": 1, "y": 1, "height": 31}]`,
+			mock: func(mockRepo *repository.MockRepositorier) {
+				mockRepo.EXPECT().GetEstateByID(gomock.Any(), "valid_estate_id").Return(estate, nil).Times(1)
+				expectEstateLock(mockRepo, estate)
+				mockRepo.EXPECT().GetAllTreesInEstate(gomock.Any(), "valid_estate_id").Return(nil, nil).Times(1)
+			},
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeOutOfRange,
+		},
+		{
+			name:   "Route with extension",
+			method: http.MethodGet,
+			path:   "/estate/valid_estate_id.geojson",
+			mock: func(mockRepo *repository.MockRepositorier) {
+				mockRepo.EXPECT().GetEstateByID(gomock.Any(), "valid_estate_id").Return(estate, nil).Times(1)
+			},
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeEstateNotGeoreferenced,
+		},
+		{
+			name:   "Estate not found",
+			method: http.MethodGet,
+			path:   "/estate/unknown_estate_id/stats",
+			mock: func(mockRepo *repository.MockRepositorier) {
+				mockRepo.EXPECT().GetEstateByID(gomock.Any(), "unknown_estate_id").Return(repository.Estate{}, repository.ErrNotFound).Times(1)
+			},
+			expectedCode:    http.StatusNotFound,
+			expectedProblem: generated.ProblemCodeResourceNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			if tc.mock != nil {
+				tc.mock(mockRepo)
+			}
+
+			spec, err := generated.GetSwagger()
+			require.NoError(t, err)
+			validator, err := NewValidator(spec, ValidatorOptions{ValidateResponses: true})
+			require.NoError(t, err)
+
+			e := echo.New()
+			e.Use(validator.Middleware)
+			generated.RegisterHandlers(NewRouter(e), &Server{repository: mockRepo})
+
+			req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+			if len(tc.contentType) > 0 {
+				req.Header.Set(echo.HeaderContentType, tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			// the response is out of the spec otherwise
+			require.Equal(t, tc.expectedCode, rec.Code, rec.Body.String())
+			if tc.expectedCode < http.StatusBadRequest {
+				return
+			}
+
+			require.Equal(t, mimeApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+			var problem generated.ErrorResponse
+			err = json.Unmarshal(rec.Body.Bytes(), &problem)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedProblem, problem.Code, rec.Body.String())
+			if len(tc.expectedFieldErrs) > 0 {
+				require.NotNil(t, problem.Errors)
+				require.ElementsMatch(t, tc.expectedFieldErrs, *problem.Errors)
+			}
+		})
+	}
+}
+
+func TestValidatorResponseOutOfSpec(t *testing.T) {
+	spec, err := generated.GetSwagger()
+	require.NoError(t, err)
+	validator, err := NewValidator(spec, ValidatorOptions{ValidateResponses: true})
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Use(validator.Middleware)
+	e.GET("/estate/:id/stats", func(ectx echo.Context) error {
+		return ectx.JSON(http.StatusOK, map[string]string{"count": "many"})
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/estate/valid_estate_id/stats", nil))
+
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	var problem generated.ErrorResponse
+	err = json.Unmarshal(rec.Body.Bytes(), &problem)
+	require.NoError(t, err)
+
+	require.Equal(t, generated.ProblemCodeInternalError, problem.Code)
+	require.Contains(t, problem.Detail, "response out of the spec")
+}
+
+func TestSpecIntRange(t *testing.T) {
+	minHeight, maxHeight := specIntRange("CreateTreeRequestBody", "height")
+	require.Equal(t, 1, minHeight)
+	require.Equal(t, 30, maxHeight)
+
+	require.Panics(t, func() {
+		specIntRange("CreateTreeRequestBody", "unknown")
+	})
+}
+
+func TestSpecParamIntRange(t *testing.T) {
+	minLimit, maxLimit := specParamIntRange("/estate/{id}/trees", http.MethodGet, "limit")
+	require.Equal(t, 1, minLimit)
+	require.Equal(t, 1000, maxLimit)
+
+	// without a maximum any distance goes
+	minDistance, maxDistance := specParamIntRange("/estate/{id}/drone-plan", http.MethodGet, "max_distance")
+	require.Equal(t, 0, minDistance)
+	require.Equal(t, math.MaxInt, maxDistance)
+
+	require.Panics(t, func() {
+		specParamIntRange("/estate/{id}/trees", http.MethodGet, "unknown")
+	})
+	require.Panics(t, func() {
+		specParamIntRange("/estate/{id}/trees", http.MethodPut, "limit")
+	})
+}
+
+func TestSpecBodyMaxItems(t *testing.T) {
+	require.Equal(t, 10000, specBodyMaxItems("/estate/{id}/trees:batch", http.MethodPost))
+
+	require.Panics(t, func() {
+		specBodyMaxItems("/estate/{id}/tree", http.MethodPost)
+	})
+}
@@ -0,0 +1,319 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+var errInvalidZone = errors.New("invalid zone")
+
+func (srv *Server) PostEstateIdZone(ectx echo.Context, id string) error {
+	var payload generated.CreateZoneRequestBody
+
+	// id is estateID
+	if len(id) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[CreateZone] param estate_id not passed")
+		return respondNotFound(ectx)
+	}
+
+	defer ectx.Request().Body.Close()
+	err := ectx.Bind(&payload)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[CreateZone] failed to read payload, err:%s", err)
+		return respondBadRequest(ectx, generated.ProblemCodeInvalidBody, "payload is not a valid zone")
+	}
+
+	// the payload is in the limits of api.yml (see Validator), the zone must be in the estate too
+	var resp generated.CreateZoneResponse
+	var fieldErrs []generated.FieldError
+	err = srv.withEstateLock(ectx.Request().Context(), id, func(txSrv *Server, estate repository.Estate) error {
+		if payload.Rect == nil && payload.Polygon == nil {
+			fieldErrs = []generated.FieldError{
+				fieldError("polygon", generated.ProblemCodeValidationFailed, "either rect or polygon is required"),
+			}
+			return errInvalidZone
+		}
+
+		zone := repository.Zone{EstateID: id}
+		zone, fieldErrs = applyZoneChange(estate, zone, &payload.Kind, payload.MinAltitude, payload.Rect, payload.Polygon)
+		if len(fieldErrs) > 0 {
+			return errInvalidZone
+		}
+
+		zoneID, err := txSrv.repository.InsertZone(ectx.Request().Context(), zone)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[CreateZone] failed to insert payload:%+v, err:%s", payload, err)
+			return err
+		}
+		resp.Id = zoneID
+
+		return txSrv.enqueueZoneRecalculation(ectx, "[CreateZone]", id)
+	})
+	if errors.Is(err, errInvalidZone) {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[CreateZone] invalid values:%+v, payload:%+v", fieldErrs, payload)
+		return respondInvalidFields(ectx, fieldErrs)
+	}
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[CreateZone] failed to store zone estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	return ectx.JSON(http.StatusCreated, resp)
+}
+
+func (srv *Server) GetEstateIdZones(ectx echo.Context, id string) error {
+	// id is estateID
+	if len(id) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[ListZones] param estate_id not passed")
+		return respondNotFound(ectx)
+	}
+
+	_, err := srv.repository.GetEstateByID(
+		ectx.Request().Context(),
+		id,
+	)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[ListZones] failed retrieve information estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	zones, err := srv.repository.GetZones(ectx.Request().Context(), id)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[ListZones] failed to read zones estate_id:%s, err:%s", id, err)
+		return respondError(ectx, err)
+	}
+
+	resp := generated.EstateZonesResponse{Zones: make([]generated.Zone, 0, len(zones))}
+	for _, zone := range zones {
+		resp.Zones = append(resp.Zones, zoneResponse(zone))
+	}
+
+	return ectx.JSON(http.StatusOK, resp)
+}
+
+func (srv *Server) GetEstateIdZoneZoneId(ectx echo.Context, id string, zoneId string) error {
+	zone, err := srv.estateZone(ectx, "[GetZone]", id, zoneId)
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	return ectx.JSON(http.StatusOK, zoneResponse(zone))
+}
+
+func (srv *Server) PatchEstateIdZoneZoneId(ectx echo.Context, id string, zoneId string) error {
+	var payload generated.UpdateZoneRequestBody
+
+	_, err := srv.estateZone(ectx, "[UpdateZone]", id, zoneId)
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	defer ectx.Request().Body.Close()
+	err = ectx.Bind(&payload)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[UpdateZone] failed to read payload, err:%s", err)
+		return respondBadRequest(ectx, generated.ProblemCodeInvalidBody, "payload is not a valid zone update")
+	}
+
+	// the payload is in the limits of api.yml (see Validator), the changes apply
+	// on the zone as it is once every change before is done
+	var updatedZone repository.Zone
+	var fieldErrs []generated.FieldError
+	err = srv.withEstateLock(ectx.Request().Context(), id, func(txSrv *Server, estate repository.Estate) error {
+		zone, err := txSrv.repository.GetZoneByID(ectx.Request().Context(), id, zoneId)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[UpdateZone] failed retrieve information zone_id:%s, err:%s", zoneId, err)
+			return err
+		}
+
+		updatedZone, fieldErrs = applyZoneChange(estate, zone, payload.Kind, payload.MinAltitude, payload.Rect, payload.Polygon)
+		if len(fieldErrs) > 0 {
+			return errInvalidZone
+		}
+
+		err = txSrv.repository.UpdateZone(ectx.Request().Context(), updatedZone)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[UpdateZone] failed to update zone_id:%s, err:%s", zoneId, err)
+			return err
+		}
+
+		return txSrv.enqueueZoneRecalculation(ectx, "[UpdateZone]", id)
+	})
+	if errors.Is(err, errInvalidZone) {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("[UpdateZone] invalid values:%+v, payload:%+v", fieldErrs, payload)
+		return respondInvalidFields(ectx, fieldErrs)
+	}
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	return ectx.JSON(http.StatusOK, zoneResponse(updatedZone))
+}
+
+func (srv *Server) DeleteEstateIdZoneZoneId(ectx echo.Context, id string, zoneId string) error {
+	_, err := srv.estateZone(ectx, "[DeleteZone]", id, zoneId)
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	err = srv.withEstateLock(ectx.Request().Context(), id, func(txSrv *Server, _ repository.Estate) error {
+		err := txSrv.repository.DeleteZone(ectx.Request().Context(), id, zoneId)
+		if err != nil {
+			// TODO: change log level according log level company guideline (info, error, etc)
+			ectx.Logger().Errorf("[DeleteZone] failed to delete zone_id:%s, err:%s", zoneId, err)
+			return err
+		}
+
+		return txSrv.enqueueZoneRecalculation(ectx, "[DeleteZone]", id)
+	})
+	if err != nil {
+		return respondError(ectx, err)
+	}
+
+	return ectx.NoContent(http.StatusNoContent)
+}
+
+// enqueueZoneRecalculation patrol the estate from scratch in the background,
+// the rows around the zone change and the drone may fly around it another way
+func (srv *Server) enqueueZoneRecalculation(ectx echo.Context, logTag, estateID string) error {
+	err := srv.repository.EnqueueRecalculation(ectx.Request().Context(), estateID)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s failed to enqueue recalculation estate_id:%s, err:%s", logTag, estateID, err)
+	}
+
+	return err
+}
+
+// estateZone return the zone zoneID of the estate estateID,
+// repository.ErrNotFound when either of them does not exist.
+func (srv *Server) estateZone(ectx echo.Context, logTag, estateID, zoneID string) (repository.Zone, error) {
+	if len(estateID) == 0 || len(zoneID) == 0 {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s param estate_id or zone_id not passed", logTag)
+		return repository.Zone{}, fmt.Errorf("%w: estate_id or zone_id not passed", repository.ErrNotFound)
+	}
+
+	zone, err := srv.repository.GetZoneByID(ectx.Request().Context(), estateID, zoneID)
+	if err != nil {
+		// TODO: change log level according log level company guideline (info, error, etc)
+		ectx.Logger().Errorf("%s failed retrieve information estate_id:%s zone_id:%s, err:%s", logTag, estateID, zoneID, err)
+		return repository.Zone{}, err
+	}
+
+	return zone, nil
+}
+
+// estateZones return the estate along with its zones, they are only read when it has some
+func (srv *Server) estateZones(ctx context.Context, estate repository.Estate) (repository.Estate, error) {
+	if estate.ZoneCount < 1 || estate.Zones != nil {
+		return estate, nil
+	}
+
+	zones, err := srv.repository.GetZones(ctx, estate.ID)
+	if err != nil {
+		return estate, err
+	}
+	estate.Zones = zones
+
+	return estate, nil
+}
+
+// applyZoneChange return the zone with the fields given changed, a rect is stored as the
+// polygon of its corners. Every field at fault is told for the client to highlight all of them.
+func applyZoneChange(
+	estate repository.Estate,
+	zone repository.Zone,
+	kind *generated.ZoneKind,
+	minAltitude *int,
+	rect *generated.ZoneRect,
+	polygon *[]generated.ZoneVertex,
+) (repository.Zone, []generated.FieldError) {
+	fieldErrs := make([]generated.FieldError, 0)
+	if kind != nil {
+		zone.Kind = string(*kind)
+	}
+
+	switch {
+	case zone.Kind == repository.ZoneKindMinAltitude && minAltitude != nil:
+		zone.MinAltitude = *minAltitude
+	case zone.Kind == repository.ZoneKindMinAltitude && zone.MinAltitude < 1:
+		fieldErrs = append(fieldErrs, fieldError("min_altitude", generated.ProblemCodeValidationFailed, "min_altitude is required for min-altitude zones"))
+	case minAltitude != nil:
+		fieldErrs = append(fieldErrs, fieldError("min_altitude", generated.ProblemCodeValidationFailed, "min_altitude is only for min-altitude zones"))
+	case zone.Kind != repository.ZoneKindMinAltitude:
+		zone.MinAltitude = 0
+	}
+
+	outOfBounds := fmt.Sprintf("the zone must be inside the estate, columns 1 to %d and rows 1 to %d", estate.Width, estate.Length)
+	inside := func(x, y int) bool {
+		return x >= 1 && x <= estate.Width && y >= 1 && y <= estate.Length
+	}
+
+	switch {
+	case rect != nil && polygon != nil:
+		fieldErrs = append(fieldErrs, fieldError("polygon", generated.ProblemCodeValidationFailed, "either rect or polygon tells the plots of the zone, not both"))
+	case rect != nil:
+		if rect.FromX > rect.ToX || rect.FromY > rect.ToY {
+			fieldErrs = append(fieldErrs, fieldError("rect", generated.ProblemCodeValidationFailed, "rect from_x and from_y must not be after to_x and to_y"))
+			break
+		}
+		if !inside(rect.FromX, rect.FromY) || !inside(rect.ToX, rect.ToY) {
+			fieldErrs = append(fieldErrs, fieldError("rect", generated.ProblemCodeZoneOutOfBounds, outOfBounds))
+			break
+		}
+
+		zone.Polygon = []repository.Plot{
+			{X: rect.FromX, Y: rect.FromY},
+			{X: rect.ToX, Y: rect.FromY},
+			{X: rect.ToX, Y: rect.ToY},
+			{X: rect.FromX, Y: rect.ToY},
+		}
+	case polygon != nil:
+		vertices := make([]repository.Plot, 0, len(*polygon))
+		for _, vertex := range *polygon {
+			if !inside(vertex.X, vertex.Y) {
+				fieldErrs = append(fieldErrs, fieldError("polygon", generated.ProblemCodeZoneOutOfBounds, outOfBounds))
+				break
+			}
+			vertices = append(vertices, repository.Plot{X: vertex.X, Y: vertex.Y})
+		}
+		zone.Polygon = vertices
+	}
+
+	return zone, fieldErrs
+}
+
+func zoneResponse(zone repository.Zone) generated.Zone {
+	resp := generated.Zone{
+		Id:      zone.ID,
+		Kind:    generated.ZoneKind(zone.Kind),
+		Polygon: make([]generated.PlotPosition, 0, len(zone.Polygon)),
+	}
+	if zone.Kind == repository.ZoneKindMinAltitude {
+		resp.MinAltitude = &zone.MinAltitude
+	}
+	for _, vertex := range zone.Polygon {
+		resp.Polygon = append(resp.Polygon, generated.PlotPosition{X: vertex.X, Y: vertex.Y})
+	}
+
+	return resp
+}
@@ -0,0 +1,465 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPostEstateIdZone(t *testing.T) {
+	estate := repository.Estate{ID: "valid_estate_id", Width: 10, Length: 5}
+
+	tests := []struct {
+		name            string
+		payload         string
+		callInsert      bool
+		expectedZone    repository.Zone
+		mockInsertErr   error
+		expectedCode    int
+		expectedProblem generated.ProblemCode
+		expectedMessage string
+	}{
+		{
+			name:       "Positive Flow - Rect",
+			payload:    `{"kind": "forbidden", "rect": {"from_x": 2, "from_y": 1, "to_x": 4, "to_y": 3}}`,
+			callInsert: true,
+			expectedZone: repository.Zone{
+				EstateID: "valid_estate_id",
+				Kind:     repository.ZoneKindForbidden,
+				Polygon:  []repository.Plot{{X: 2, Y: 1}, {X: 4, Y: 1}, {X: 4, Y: 3}, {X: 2, Y: 3}},
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:       "Positive Flow - Polygon",
+			payload:    `{"kind": "min-altitude", "min_altitude": 40, "polygon": [{"x": 1, "y": 1}, {"x": 10, "y": 1}, {"x": 5, "y": 5}]}`,
+			callInsert: true,
+			expectedZone: repository.Zone{
+				EstateID:    "valid_estate_id",
+				Kind:        repository.ZoneKindMinAltitude,
+				MinAltitude: 40,
+				Polygon:     []repository.Plot{{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 5}},
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:            "Neither rect nor polygon",
+			payload:         `{"kind": "forbidden"}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeValidationFailed,
+			expectedMessage: "either rect or polygon is required",
+		},
+		{
+			name:            "Both rect and polygon",
+			payload:         `{"kind": "forbidden", "rect": {"from_x": 2, "from_y": 1, "to_x": 4, "to_y": 3}, "polygon": [{"x": 1, "y": 1}, {"x": 10, "y": 1}, {"x": 5, "y": 5}]}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeValidationFailed,
+			expectedMessage: "either rect or polygon tells the plots of the zone, not both",
+		},
+		{
+			name:            "Rect outside of the estate",
+			payload:         `{"kind": "forbidden", "rect": {"from_x": 2, "from_y": 1, "to_x": 4, "to_y": 6}}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeZoneOutOfBounds,
+			expectedMessage: "the zone must be inside the estate, columns 1 to 10 and rows 1 to 5",
+		},
+		{
+			name:            "Reversed rect",
+			payload:         `{"kind": "forbidden", "rect": {"from_x": 4, "from_y": 1, "to_x": 2, "to_y": 3}}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeValidationFailed,
+			expectedMessage: "rect from_x and from_y must not be after to_x and to_y",
+		},
+		{
+			name:            "Min altitude zone without altitude",
+			payload:         `{"kind": "min-altitude", "rect": {"from_x": 2, "from_y": 1, "to_x": 4, "to_y": 3}}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeValidationFailed,
+			expectedMessage: "min_altitude is required for min-altitude zones",
+		},
+		{
+			name:            "Forbidden zone with altitude",
+			payload:         `{"kind": "forbidden", "min_altitude": 40, "rect": {"from_x": 2, "from_y": 1, "to_x": 4, "to_y": 3}}`,
+			expectedCode:    http.StatusBadRequest,
+			expectedProblem: generated.ProblemCodeValidationFailed,
+			expectedMessage: "min_altitude is only for min-altitude zones",
+		},
+		{
+			name:       "Insert failed",
+			payload:    `{"kind": "forbidden", "rect": {"from_x": 2, "from_y": 1, "to_x": 4, "to_y": 3}}`,
+			callInsert: true,
+			expectedZone: repository.Zone{
+				EstateID: "valid_estate_id",
+				Kind:     repository.ZoneKindForbidden,
+				Polygon:  []repository.Plot{{X: 2, Y: 1}, {X: 4, Y: 1}, {X: 4, Y: 3}, {X: 2, Y: 3}},
+			},
+			mockInsertErr:   errors.New("repository error"),
+			expectedCode:    http.StatusInternalServerError,
+			expectedProblem: generated.ProblemCodeInternalError,
+			expectedMessage: "internal server error",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/estate/valid_estate_id/zone", bytes.NewBufferString(tc.payload))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			expectEstateLock(mockRepo, estate)
+			if tc.callInsert {
+				mockRepo.EXPECT().
+					InsertZone(gomock.Any(), tc.expectedZone).
+					Return("valid_zone_id", tc.mockInsertErr).
+					Times(1)
+			}
+			// the estate is patrolled again around the new zone
+			if tc.callInsert && tc.mockInsertErr == nil {
+				mockRepo.EXPECT().EnqueueRecalculation(gomock.Any(), "valid_estate_id").Return(nil).Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.PostEstateIdZone(c, "valid_estate_id")
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusCreated {
+				var resp generated.CreateZoneResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				require.Equal(t, "valid_zone_id", resp.Id)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedProblem, respErr.Code)
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestGetEstateIdZones(t *testing.T) {
+	minAltitude := 40
+	zones := []repository.Zone{
+		{
+			ID:       "zone_id_1",
+			EstateID: "valid_estate_id",
+			Kind:     repository.ZoneKindForbidden,
+			Polygon:  []repository.Plot{{X: 2, Y: 1}, {X: 4, Y: 1}, {X: 4, Y: 3}, {X: 2, Y: 3}},
+		},
+		{
+			ID:          "zone_id_2",
+			EstateID:    "valid_estate_id",
+			Kind:        repository.ZoneKindMinAltitude,
+			MinAltitude: minAltitude,
+			Polygon:     []repository.Plot{{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 5}},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		mockEstateErr   error
+		callGetZones    bool
+		expectedCode    int
+		expectedZones   []generated.Zone
+		expectedMessage string
+	}{
+		{
+			name:         "Positive Flow",
+			callGetZones: true,
+			expectedCode: http.StatusOK,
+			expectedZones: []generated.Zone{
+				{
+					Id:      "zone_id_1",
+					Kind:    generated.Forbidden,
+					Polygon: []generated.PlotPosition{{X: 2, Y: 1}, {X: 4, Y: 1}, {X: 4, Y: 3}, {X: 2, Y: 3}},
+				},
+				{
+					Id:          "zone_id_2",
+					Kind:        generated.MinAltitude,
+					MinAltitude: &minAltitude,
+					Polygon:     []generated.PlotPosition{{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 5}},
+				},
+			},
+		},
+		{
+			name:            "Estate not found",
+			mockEstateErr:   repository.ErrNotFound,
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/estate/valid_estate_id/zones", nil)
+			rec := httptest.NewRecorder()
+
+			mockRepo.EXPECT().
+				GetEstateByID(gomock.Any(), "valid_estate_id").
+				Return(repository.Estate{ID: "valid_estate_id", Width: 10, Length: 5, ZoneCount: 2}, tc.mockEstateErr).
+				Times(1)
+			if tc.callGetZones {
+				mockRepo.EXPECT().GetZones(gomock.Any(), "valid_estate_id").Return(zones, nil).Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.GetEstateIdZones(c, "valid_estate_id")
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				var resp generated.EstateZonesResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedZones, resp.Zones)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestPatchEstateIdZoneZoneId(t *testing.T) {
+	estate := repository.Estate{ID: "valid_estate_id", Width: 10, Length: 5, ZoneCount: 1}
+	zone := repository.Zone{
+		ID:       "valid_zone_id",
+		EstateID: "valid_estate_id",
+		Kind:     repository.ZoneKindForbidden,
+		Polygon:  []repository.Plot{{X: 2, Y: 1}, {X: 4, Y: 1}, {X: 4, Y: 3}, {X: 2, Y: 3}},
+	}
+
+	tests := []struct {
+		name            string
+		payload         string
+		mockGetZoneErr  error
+		callLock        bool
+		callUpdate      bool
+		expectedZone    repository.Zone
+		expectedCode    int
+		expectedMessage string
+	}{
+		{
+			name:       "Positive Flow - Kind",
+			payload:    `{"kind": "min-altitude", "min_altitude": 40}`,
+			callLock:   true,
+			callUpdate: true,
+			expectedZone: repository.Zone{
+				ID:          "valid_zone_id",
+				EstateID:    "valid_estate_id",
+				Kind:        repository.ZoneKindMinAltitude,
+				MinAltitude: 40,
+				Polygon:     zone.Polygon,
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:       "Positive Flow - Rect",
+			payload:    `{"rect": {"from_x": 1, "from_y": 2, "to_x": 3, "to_y": 2}}`,
+			callLock:   true,
+			callUpdate: true,
+			expectedZone: repository.Zone{
+				ID:       "valid_zone_id",
+				EstateID: "valid_estate_id",
+				Kind:     repository.ZoneKindForbidden,
+				Polygon:  []repository.Plot{{X: 1, Y: 2}, {X: 3, Y: 2}, {X: 3, Y: 2}, {X: 1, Y: 2}},
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:            "Min altitude zone without altitude",
+			payload:         `{"kind": "min-altitude"}`,
+			callLock:        true,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "min_altitude is required for min-altitude zones",
+		},
+		{
+			name:            "Polygon outside of the estate",
+			payload:         `{"polygon": [{"x": 1, "y": 1}, {"x": 11, "y": 1}, {"x": 5, "y": 5}]}`,
+			callLock:        true,
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: "the zone must be inside the estate, columns 1 to 10 and rows 1 to 5",
+		},
+		{
+			name:            "Zone not found in estate",
+			payload:         `{"kind": "forbidden"}`,
+			mockGetZoneErr:  repository.ErrNotFound,
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/estate/valid_estate_id/zone/valid_zone_id", bytes.NewBufferString(tc.payload))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			mockRepo.EXPECT().
+				GetZoneByID(gomock.Any(), "valid_estate_id", "valid_zone_id").
+				Return(zone, tc.mockGetZoneErr).
+				Times(1)
+			if tc.callLock {
+				// the zone is read again once the estate is locked
+				expectEstateLock(mockRepo, estate)
+				mockRepo.EXPECT().
+					GetZoneByID(gomock.Any(), "valid_estate_id", "valid_zone_id").
+					Return(zone, nil).
+					Times(1)
+			}
+			if tc.callUpdate {
+				mockRepo.EXPECT().UpdateZone(gomock.Any(), tc.expectedZone).Return(nil).Times(1)
+				mockRepo.EXPECT().EnqueueRecalculation(gomock.Any(), "valid_estate_id").Return(nil).Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.PatchEstateIdZoneZoneId(c, "valid_estate_id", "valid_zone_id")
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				var resp generated.Zone
+				err := json.Unmarshal(rec.Body.Bytes(), &resp)
+				require.NoError(t, err)
+
+				require.Equal(t, zoneResponse(tc.expectedZone), resp)
+			} else {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
+
+func TestDeleteEstateIdZoneZoneId(t *testing.T) {
+	estate := repository.Estate{ID: "valid_estate_id", Width: 10, Length: 5, ZoneCount: 1}
+	zone := repository.Zone{
+		ID:       "valid_zone_id",
+		EstateID: "valid_estate_id",
+		Kind:     repository.ZoneKindForbidden,
+		Polygon:  []repository.Plot{{X: 2, Y: 1}, {X: 4, Y: 1}, {X: 4, Y: 3}, {X: 2, Y: 3}},
+	}
+
+	tests := []struct {
+		name            string
+		mockGetZoneErr  error
+		callDelete      bool
+		mockDeleteErr   error
+		expectedCode    int
+		expectedMessage string
+	}{
+		{
+			name:         "Positive Flow",
+			callDelete:   true,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:            "Delete failed",
+			callDelete:      true,
+			mockDeleteErr:   errors.New("repository error"),
+			expectedCode:    http.StatusInternalServerError,
+			expectedMessage: "internal server error",
+		},
+		{
+			name:            "Zone not found in estate",
+			mockGetZoneErr:  repository.ErrNotFound,
+			expectedCode:    http.StatusNotFound,
+			expectedMessage: "resource not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := repository.NewMockRepositorier(ctrl)
+			srv := Server{
+				repository: mockRepo,
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/estate/valid_estate_id/zone/valid_zone_id", nil)
+			rec := httptest.NewRecorder()
+
+			mockRepo.EXPECT().
+				GetZoneByID(gomock.Any(), "valid_estate_id", "valid_zone_id").
+				Return(zone, tc.mockGetZoneErr).
+				Times(1)
+			if tc.callDelete {
+				expectEstateLock(mockRepo, estate)
+				mockRepo.EXPECT().
+					DeleteZone(gomock.Any(), "valid_estate_id", "valid_zone_id").
+					Return(tc.mockDeleteErr).
+					Times(1)
+			}
+			if tc.callDelete && tc.mockDeleteErr == nil {
+				mockRepo.EXPECT().EnqueueRecalculation(gomock.Any(), "valid_estate_id").Return(nil).Times(1)
+			}
+
+			c := e.NewContext(req, rec)
+			err := srv.DeleteEstateIdZoneZoneId(c, "valid_estate_id", "valid_zone_id")
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedCode, rec.Code)
+
+			if tc.expectedCode != http.StatusNoContent {
+				var respErr generated.ErrorResponse
+				err := json.Unmarshal(rec.Body.Bytes(), &respErr)
+				require.NoError(t, err)
+
+				require.Equal(t, tc.expectedMessage, respErr.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package handler
+
+import (
+	"sort"
+
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+// detourSearchMax is the most plots searched for a way around the forbidden plots,
+// the plots further away are left out of the patrol
+const detourSearchMax = 1 << 22
+
+// plotRun is the plots of a row from column from to column to, inclusive.
+// altitude is the lowest altitude over them, zero when there is none.
+type plotRun struct {
+	from, to int
+	altitude int
+}
+
+// zoneRow is the zones over a row, forbidden runs are apart from each other sorted
+// by their first column, min altitude runs of different altitudes may overlap
+type zoneRow struct {
+	forbidden []plotRun
+	floors    []plotRun
+}
+
+// zoneMap tells the zones over the plots of the estate, the runs of a row are worked
+// out from the zones the first time the row is asked. The plots out of the boundary
+// of the estate are forbidden too.
+type zoneMap struct {
+	width, length int
+	zones         []repository.Zone
+	shape         estateShape
+	// maxAltitude is the highest altitude the drone is allowed to, it flies around
+	// the min altitude zones higher than that as if they were forbidden
+	maxAltitude int
+	// rows of the zones, minY to maxY, the other rows have no zone
+	minY, maxY int
+	rows       map[int]zoneRow
+}
+
+func newZoneMap(estate repository.Estate) *zoneMap {
+	zm := &zoneMap{
+		width:       estate.Width,
+		length:      estate.Length,
+		zones:       estate.Zones,
+		shape:       newEstateShape(estate),
+		maxAltitude: estateDrone(estate).MaxAltitude,
+		rows:        make(map[int]zoneRow),
+	}
+
+	for i, zone := range estate.Zones {
+		for j, vertex := range zone.Polygon {
+			if (i == 0 && j == 0) || vertex.Y < zm.minY {
+				zm.minY = vertex.Y
+			}
+			zm.maxY = max(zm.maxY, vertex.Y)
+		}
+	}
+	if zm.shape.rows != nil {
+		zm.minY, zm.maxY = min(zm.minY, 1), max(zm.maxY, estate.Length)
+	}
+
+	return zm
+}
+
+// empty tells there is neither zone nor boundary, the drone flies over every plot of the estate
+func (zm *zoneMap) empty() bool {
+	return len(zm.zones) == 0 && zm.shape.rows == nil
+}
+
+// row return the zones over row y
+func (zm *zoneMap) row(y int) zoneRow {
+	if zm.empty() || y < zm.minY || y > zm.maxY {
+		return zoneRow{}
+	}
+	if row, found := zm.rows[y]; found {
+		return row
+	}
+
+	row := zoneRow{forbidden: zm.shape.outside(y)}
+	floors := make(map[int][]plotRun)
+	for _, zone := range zm.zones {
+		for _, run := range polygonRuns(zone.Polygon, y) {
+			run.from, run.to = max(run.from, 1), min(run.to, zm.width)
+			if run.from > run.to {
+				continue
+			}
+
+			switch {
+			case zone.Kind == repository.ZoneKindForbidden || zone.MinAltitude > zm.maxAltitude:
+				row.forbidden = append(row.forbidden, run)
+			case zone.Kind == repository.ZoneKindMinAltitude:
+				run.altitude = zone.MinAltitude
+				floors[run.altitude] = append(floors[run.altitude], run)
+			}
+		}
+	}
+	row.forbidden = mergeRuns(row.forbidden)
+	for _, runs := range floors {
+		row.floors = append(row.floors, mergeRuns(runs)...)
+	}
+	zm.rows[y] = row
+
+	return row
+}
+
+// forbidden tells whether the drone must not fly over plot (x,y), plots outside
+// of the estate are forbidden too
+func (zm *zoneMap) forbidden(x, y int) bool {
+	if x < 1 || x > zm.width || y < 1 || y > zm.length {
+		return true
+	}
+
+	for _, run := range zm.row(y).forbidden {
+		if x >= run.from && x <= run.to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// floor return the lowest altitude the drone flies over plot (x,y), zero when there is none
+func (zm *zoneMap) floor(x, y int) int {
+	var altitude int
+	for _, run := range zm.row(y).floors {
+		if x >= run.from && x <= run.to {
+			altitude = max(altitude, run.altitude)
+		}
+	}
+
+	return altitude
+}
+
+// patrolPlots return the plots the drone adjusts its altitude over in the patrol order along
+// with the lowest altitude over each of them, nil when there is no zone. Those are the trees
+// out of the forbidden plots and the plot right before every min altitude run the drone climbs
+// over, a plot without tree has no height. trees are in the patrol order.
+func (zm *zoneMap) patrolPlots(trees []repository.Tree) ([]repository.Tree, []int) {
+	if zm.empty() {
+		return trees, nil
+	}
+
+	climbs := make(map[repository.Plot]int)
+	for y := max(zm.minY, 1); y <= min(zm.maxY, zm.length); y++ {
+		forward, dx := y%2 == 1, 1
+		if !forward {
+			dx = -1
+		}
+
+		for _, floor := range zm.row(y).floors {
+			// the min altitude run is cut by the forbidden plots
+			for _, run := range zm.clearRuns(y, true) {
+				from, to := max(run.from, floor.from), min(run.to, floor.to)
+				if from > to {
+					continue
+				}
+
+				// first plot of the run in the patrol order, or the plot before when it is clear
+				first := from
+				if !forward {
+					first = to
+				}
+				if before := first - dx; before >= run.from && before <= run.to {
+					if zm.floor(before, y) >= floor.altitude {
+						// the drone is already that high coming from a higher zone
+						continue
+					}
+					first = before
+				}
+
+				plot := repository.Plot{X: first, Y: y}
+				climbs[plot] = max(climbs[plot], floor.altitude)
+			}
+		}
+	}
+
+	type patrolPlot struct {
+		tree  repository.Tree
+		floor int
+	}
+	plots := make([]patrolPlot, 0, len(trees)+len(climbs))
+	for _, tree := range trees {
+		if zm.forbidden(tree.X, tree.Y) {
+			continue
+		}
+
+		plot := repository.Plot{X: tree.X, Y: tree.Y}
+		plots = append(plots, patrolPlot{tree: tree, floor: max(zm.floor(tree.X, tree.Y), climbs[plot])})
+		delete(climbs, plot)
+	}
+	for plot, altitude := range climbs {
+		plots = append(plots, patrolPlot{tree: repository.Tree{X: plot.X, Y: plot.Y}, floor: altitude})
+	}
+
+	sort.Slice(plots, func(i, j int) bool {
+		return patrolBefore(plots[i].tree.X, plots[i].tree.Y, plots[j].tree.X, plots[j].tree.Y)
+	})
+
+	patrolTrees, floors := make([]repository.Tree, len(plots)), make([]int, len(plots))
+	for i, plot := range plots {
+		patrolTrees[i], floors[i] = plot.tree, plot.floor
+	}
+
+	return patrolTrees, floors
+}
+
+// treeAltitudes return the altitude the drone clears every tree from, nil when there is nothing
+// to fly around
+func (zm *zoneMap) treeAltitudes(drone repository.DroneProfile, trees []repository.Tree) map[repository.Plot]int {
+	if zm.empty() {
+		return nil
+	}
+
+	altitudes := make(map[repository.Plot]int, len(trees))
+	for i := range trees {
+		altitudes[repository.Plot{X: trees[i].X, Y: trees[i].Y}] = droneAltitude(drone, &trees[i])
+	}
+
+	return altitudes
+}
+
+// clearance return the altitude the drone clears every tree and min altitude zone on the plots from
+func (zm *zoneMap) clearance(plots []repository.Plot, treeAltitudes map[repository.Plot]int) int {
+	var altitude int
+	for _, plot := range plots {
+		altitude = max(altitude, treeAltitudes[plot], zm.floor(plot.X, plot.Y))
+	}
+
+	return altitude
+}
+
+// clearRuns return the runs of row y the drone may fly over in the patrol order,
+// from is the first plot of the run the drone flies over. forward tells the row
+// goes from column start to column end.
+func (zm *zoneMap) clearRuns(y int, forward bool) []plotRun {
+	runs := make([]plotRun, 0, 1)
+	from := 1
+	for _, run := range zm.row(y).forbidden {
+		if run.from > from {
+			runs = append(runs, plotRun{from: from, to: run.from - 1})
+		}
+		from = run.to + 1
+	}
+	if from <= zm.width {
+		runs = append(runs, plotRun{from: from, to: zm.width})
+	}
+
+	if !forward {
+		for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+			runs[i], runs[j] = runs[j], runs[i]
+		}
+		for i := range runs {
+			runs[i].from, runs[i].to = runs[i].to, runs[i].from
+		}
+	}
+
+	return runs
+}
+
+// detour return the plots of the shortest way from plot from to plot to flying around the
+// forbidden plots, both ends included, nil when there is none. The search starts around both
+// plots and widens until a way is found, the estate is covered or detourSearchMax is reached.
+func (zm *zoneMap) detour(from, to repository.Plot) []repository.Plot {
+	for margin := 1; ; margin *= 2 {
+		minX, maxX := max(min(from.X, to.X)-margin, 1), min(max(from.X, to.X)+margin, zm.width)
+		minY, maxY := max(min(from.Y, to.Y)-margin, 1), min(max(from.Y, to.Y)+margin, zm.length)
+		width, length := maxX-minX+1, maxY-minY+1
+		if width*length > detourSearchMax {
+			return nil
+		}
+
+		// breadth first from the destination, every plot points to the next one on the way
+		index := func(plot repository.Plot) int {
+			return (plot.Y-minY)*width + plot.X - minX
+		}
+		next := make([]int32, width*length)
+		for i := range next {
+			next[i] = -1
+		}
+		next[index(to)] = int32(index(to))
+
+		queue := []repository.Plot{to}
+		for len(queue) > 0 && next[index(from)] < 0 {
+			plot := queue[0]
+			queue = queue[1:]
+
+			// the neighbours in the same order for the same way every time
+			for _, neighbour := range []repository.Plot{
+				{X: plot.X, Y: plot.Y + 1},
+				{X: plot.X, Y: plot.Y - 1},
+				{X: plot.X + 1, Y: plot.Y},
+				{X: plot.X - 1, Y: plot.Y},
+			} {
+				if neighbour.X < minX || neighbour.X > maxX || neighbour.Y < minY || neighbour.Y > maxY ||
+					next[index(neighbour)] >= 0 || (neighbour != from && zm.forbidden(neighbour.X, neighbour.Y)) {
+					continue
+				}
+
+				next[index(neighbour)] = int32(index(plot))
+				queue = append(queue, neighbour)
+			}
+		}
+
+		if next[index(from)] >= 0 {
+			plots := []repository.Plot{from}
+			for plot := from; plot != to; {
+				i := int(next[index(plot)])
+				plot = repository.Plot{X: minX + i%width, Y: minY + i/width}
+				plots = append(plots, plot)
+			}
+			return plots
+		}
+
+		if width == zm.width && length == zm.length {
+			return nil
+		}
+	}
+}
+
+// polygonRuns return the runs of columns on row y which plot center is inside the polygon
+// or on its boundary, they may overlap
+func polygonRuns(polygon []repository.Plot, y int) []plotRun {
+	var row rowCrossings
+	for i, a := range polygon {
+		row.edge(a, polygon[(i+1)%len(polygon)], y)
+	}
+
+	return row.runs()
+}
+
+// rowCrossings gather where the edges of a polygon cross a row, see polygonRuns
+type rowCrossings struct {
+	// crossing is where an edge crosses the row, at column num/den
+	crossings []struct{ num, den int }
+	// boundary is the plots right on an edge
+	boundary []plotRun
+}
+
+// edge add the edge from a to b crossing row y
+func (row *rowCrossings) edge(a, b repository.Plot, y int) {
+	if a.Y == b.Y {
+		// the edge along the row is on the boundary
+		if a.Y == y {
+			row.boundary = append(row.boundary, plotRun{from: min(a.X, b.X), to: max(a.X, b.X)})
+		}
+		return
+	}
+	if y < min(a.Y, b.Y) || y > max(a.Y, b.Y) {
+		return
+	}
+
+	num, den := a.X*(b.Y-a.Y)+(y-a.Y)*(b.X-a.X), b.Y-a.Y
+	if den < 0 {
+		num, den = -num, -den
+	}
+
+	// the plot right on the edge is on the boundary
+	if num%den == 0 {
+		row.boundary = append(row.boundary, plotRun{from: num / den, to: num / den})
+	}
+
+	// the upper end of every edge is left out for the crossings to pair up,
+	// a vertex between two edges crossing the row is counted once
+	if y < max(a.Y, b.Y) {
+		row.crossings = append(row.crossings, struct{ num, den int }{num: num, den: den})
+	}
+}
+
+// runs return the runs of the row inside the polygon or on its boundary, they may overlap
+func (row *rowCrossings) runs() []plotRun {
+	crossings := row.crossings
+	sort.Slice(crossings, func(i, j int) bool {
+		return crossings[i].num*crossings[j].den < crossings[j].num*crossings[i].den
+	})
+
+	runs := row.boundary
+	for i := 0; i+1 < len(crossings); i += 2 {
+		from, to := crossings[i], crossings[i+1]
+		runs = append(runs, plotRun{from: ceilDiv(from.num, from.den), to: floorDiv(to.num, to.den)})
+	}
+
+	return runs
+}
+
+// mergeRuns merge the overlapping and touching runs, sorted by their first column
+func mergeRuns(runs []plotRun) []plotRun {
+	if len(runs) < 2 {
+		return runs
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].from < runs[j].from
+	})
+
+	merged := runs[:1]
+	for _, run := range runs[1:] {
+		last := &merged[len(merged)-1]
+		if run.from <= last.to+1 {
+			last.to = max(last.to, run.to)
+			continue
+		}
+		merged = append(merged, run)
+	}
+
+	return merged
+}
+
+// floorDiv and ceilDiv round the division of n by the positive d down and up
+func floorDiv(n, d int) int {
+	q := n / d
+	if n%d != 0 && n < 0 {
+		q--
+	}
+
+	return q
+}
+
+func ceilDiv(n, d int) int {
+	q := n / d
+	if n%d != 0 && n > 0 {
+		q++
+	}
+
+	return q
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZoneMapRow(t *testing.T) {
+	tests := []struct {
+		name              string
+		zones             []repository.Zone
+		y                 int
+		expectedForbidden []plotRun
+		expectedFloors    []plotRun
+	}{
+		{
+			name: "Rect",
+			zones: []repository.Zone{
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 2, Y: 2}, {X: 4, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 3}}},
+			},
+			y:                 3,
+			expectedForbidden: []plotRun{{from: 2, to: 4}},
+		},
+		{
+			name: "Triangle",
+			zones: []repository.Zone{
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 1, Y: 1}, {X: 9, Y: 1}, {X: 5, Y: 5}}},
+			},
+			y:                 3,
+			expectedForbidden: []plotRun{{from: 3, to: 7}},
+		},
+		{
+			name: "Triangle apex",
+			zones: []repository.Zone{
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 1, Y: 1}, {X: 9, Y: 1}, {X: 5, Y: 5}}},
+			},
+			y:                 5,
+			expectedForbidden: []plotRun{{from: 5, to: 5}},
+		},
+		{
+			name: "Concave polygon",
+			zones: []repository.Zone{
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 1, Y: 1}, {X: 9, Y: 1}, {X: 9, Y: 5}, {X: 5, Y: 2}, {X: 1, Y: 5}}},
+			},
+			y:                 4,
+			expectedForbidden: []plotRun{{from: 1, to: 2}, {from: 8, to: 9}},
+		},
+		{
+			name: "Zone over the estate edge",
+			zones: []repository.Zone{
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 8, Y: 1}, {X: 12, Y: 1}, {X: 12, Y: 3}, {X: 8, Y: 3}}},
+			},
+			y:                 2,
+			expectedForbidden: []plotRun{{from: 8, to: 10}},
+		},
+		{
+			name: "Overlapping zones",
+			zones: []repository.Zone{
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 1, Y: 1}, {X: 3, Y: 1}, {X: 3, Y: 3}, {X: 1, Y: 3}}},
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 4, Y: 2}, {X: 5, Y: 2}, {X: 5, Y: 3}, {X: 4, Y: 3}}},
+				{Kind: repository.ZoneKindMinAltitude, MinAltitude: 20, Polygon: []repository.Plot{{X: 7, Y: 2}, {X: 8, Y: 2}, {X: 8, Y: 3}, {X: 7, Y: 3}}},
+				{Kind: repository.ZoneKindMinAltitude, MinAltitude: 20, Polygon: []repository.Plot{{X: 8, Y: 2}, {X: 9, Y: 2}, {X: 9, Y: 3}, {X: 8, Y: 3}}},
+			},
+			y:                 2,
+			expectedForbidden: []plotRun{{from: 1, to: 5}},
+			expectedFloors:    []plotRun{{from: 7, to: 9, altitude: 20}},
+		},
+		{
+			name: "Min altitude zone above the drone",
+			zones: []repository.Zone{
+				{Kind: repository.ZoneKindMinAltitude, MinAltitude: 500, Polygon: []repository.Plot{{X: 2, Y: 2}, {X: 4, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 3}}},
+			},
+			y:                 2,
+			expectedForbidden: []plotRun{{from: 2, to: 4}},
+		},
+		{
+			name: "Row without zone",
+			zones: []repository.Zone{
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 2, Y: 2}, {X: 4, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 3}}},
+			},
+			y: 4,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			zm := newZoneMap(repository.Estate{Width: 10, Length: 10, Zones: tc.zones})
+			row := zm.row(tc.y)
+
+			require.Equal(t, tc.expectedForbidden, row.forbidden)
+			require.Equal(t, tc.expectedFloors, row.floors)
+		})
+	}
+}
+
+func TestZoneMapDetour(t *testing.T) {
+	tests := []struct {
+		name          string
+		zones         []repository.Zone
+		from, to      repository.Plot
+		expectedPlots []repository.Plot
+	}{
+		{
+			name:          "Nothing in the way",
+			from:          repository.Plot{X: 1, Y: 1},
+			to:            repository.Plot{X: 3, Y: 1},
+			expectedPlots: []repository.Plot{{X: 1, Y: 1}, {X: 2, Y: 1}, {X: 3, Y: 1}},
+		},
+		{
+			name: "Around the zone",
+			zones: []repository.Zone{
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 2, Y: 1}, {X: 2, Y: 2}}},
+			},
+			from:          repository.Plot{X: 1, Y: 1},
+			to:            repository.Plot{X: 3, Y: 1},
+			expectedPlots: []repository.Plot{{X: 1, Y: 1}, {X: 1, Y: 2}, {X: 1, Y: 3}, {X: 2, Y: 3}, {X: 3, Y: 3}, {X: 3, Y: 2}, {X: 3, Y: 1}},
+		},
+		{
+			name: "Walled off",
+			zones: []repository.Zone{
+				{Kind: repository.ZoneKindForbidden, Polygon: []repository.Plot{{X: 2, Y: 1}, {X: 2, Y: 3}}},
+			},
+			from: repository.Plot{X: 1, Y: 1},
+			to:   repository.Plot{X: 3, Y: 1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			zm := newZoneMap(repository.Estate{Width: 3, Length: 3, Zones: tc.zones})
+			require.Equal(t, tc.expectedPlots, zm.detour(tc.from, tc.to))
+		})
+	}
+}
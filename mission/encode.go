@@ -0,0 +1,126 @@
+package mission
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	qgcFileType         = "Plan"
+	qgcGroundStation    = "QGroundControl"
+	qgcPlanVersion      = 1
+	qgcMissionVersion   = 2
+	qgcGeoFenceVersion  = 2
+	qgcRallyVersion     = 2
+	qgcSimpleItem       = "SimpleItem"
+	qgcAltitudeRelative = 1 // altitude relative to home
+	qgcFirmwareGeneric  = 0 // MAV_AUTOPILOT_GENERIC, loads on both PX4 and ArduPilot
+	qgcVehicleMultiRoto = 2 // MAV_TYPE_QUADROTOR
+
+	// https://mavlink.io/en/file_formats/#mission_plain_text_file
+	wplHeader = "QGC WPL 110"
+)
+
+// qgcPlan is the QGroundControl .plan file
+// https://docs.qgroundcontrol.com/master/en/qgc-dev-guide/file_formats/plan.html
+type qgcPlan struct {
+	FileType      string         `json:"fileType"`
+	Version       int            `json:"version"`
+	GroundStation string         `json:"groundStation"`
+	Mission       qgcMission     `json:"mission"`
+	GeoFence      qgcGeoFence    `json:"geoFence"`
+	RallyPoints   qgcRallyPoints `json:"rallyPoints"`
+}
+
+type qgcMission struct {
+	Version             int              `json:"version"`
+	FirmwareType        int              `json:"firmwareType"`
+	VehicleType         int              `json:"vehicleType"`
+	PlannedHomePosition [3]float64       `json:"plannedHomePosition"`
+	Items               []qgcMissionItem `json:"items"`
+}
+
+type qgcMissionItem struct {
+	Type                string   `json:"type"`
+	AutoContinue        bool     `json:"autoContinue"`
+	Command             int      `json:"command"`
+	DoJumpID            int      `json:"doJumpId"`
+	Frame               int      `json:"frame"`
+	Params              [7]any   `json:"params"`
+	Altitude            int      `json:"Altitude"`
+	AltitudeMode        int      `json:"AltitudeMode"`
+	AMSLAltAboveTerrain *float64 `json:"AMSLAltAboveTerrain"`
+}
+
+type qgcGeoFence struct {
+	Version  int   `json:"version"`
+	Circles  []any `json:"circles"`
+	Polygons []any `json:"polygons"`
+}
+
+type qgcRallyPoints struct {
+	Version int   `json:"version"`
+	Points  []any `json:"points"`
+}
+
+// EncodeQGCPlan write the mission as QGroundControl .plan file.
+func EncodeQGCPlan(m Mission) ([]byte, error) {
+	items := make([]qgcMissionItem, 0, len(m.Items))
+	for idx, item := range m.Items {
+		items = append(items, qgcMissionItem{
+			Type:         qgcSimpleItem,
+			AutoContinue: true,
+			Command:      item.Command,
+			DoJumpID:     idx + 1,
+			Frame:        frameGlobalRelativeAlt,
+			// hold, acceptance radius, pass radius, yaw (null keeps current heading), lat, lon, alt
+			Params:       [7]any{0, 0, 0, nil, item.Latitude, item.Longitude, item.Altitude},
+			Altitude:     item.Altitude,
+			AltitudeMode: qgcAltitudeRelative,
+		})
+	}
+
+	plan := qgcPlan{
+		FileType:      qgcFileType,
+		Version:       qgcPlanVersion,
+		GroundStation: qgcGroundStation,
+		Mission: qgcMission{
+			Version:             qgcMissionVersion,
+			FirmwareType:        qgcFirmwareGeneric,
+			VehicleType:         qgcVehicleMultiRoto,
+			PlannedHomePosition: [3]float64{m.Home.Latitude, m.Home.Longitude, 0},
+			Items:               items,
+		},
+		GeoFence: qgcGeoFence{
+			Version:  qgcGeoFenceVersion,
+			Circles:  []any{},
+			Polygons: []any{},
+		},
+		RallyPoints: qgcRallyPoints{
+			Version: qgcRallyVersion,
+			Points:  []any{},
+		},
+	}
+
+	return json.MarshalIndent(plan, "", "  ")
+}
+
+// EncodeWaypoints write the mission as MAVLink plain text waypoints file, the first
+// line after the header is the home position. Each line is tab separated
+// INDEX CURRENT_WP COORD_FRAME COMMAND PARAM1 PARAM2 PARAM3 PARAM4 LAT LON ALT AUTOCONTINUE
+func EncodeWaypoints(m Mission) []byte {
+	var strb strings.Builder
+	strb.WriteString(wplHeader + "\n")
+
+	writeLine := func(idx, current, frame, command int, lat, lon float64, alt int) {
+		fmt.Fprintf(&strb, "%d\t%d\t%d\t%d\t0\t0\t0\t0\t%.8f\t%.8f\t%d\t1\n", idx, current, frame, command, lat, lon, alt)
+	}
+
+	writeLine(0, 1, frameGlobal, CommandWaypoint, m.Home.Latitude, m.Home.Longitude, 0)
+	for idx, item := range m.Items {
+		writeLine(idx+1, 0, frameGlobalRelativeAlt, item.Command, item.Latitude, item.Longitude, item.Altitude)
+	}
+
+	return []byte(strb.String())
+}
@@ -0,0 +1,161 @@
+// Package mission convert the estate patrol route into a drone mission,
+// a list of MAVLink commands (takeoff, waypoints and land) with geographic coordinates,
+// and write it as QGroundControl plan or MAVLink waypoints file.
+package mission
+
+import (
+	"errors"
+
+	"github.com/nahwinrajan/testswpro/geo"
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+// MAVLink commands and frames used by the mission
+// https://mavlink.io/en/messages/common.html
+const (
+	CommandWaypoint = 16 // MAV_CMD_NAV_WAYPOINT
+	CommandLand     = 21 // MAV_CMD_NAV_LAND
+	CommandTakeoff  = 22 // MAV_CMD_NAV_TAKEOFF
+
+	frameGlobal            = 0 // MAV_FRAME_GLOBAL, used by home position
+	frameGlobalRelativeAlt = 3 // MAV_FRAME_GLOBAL_RELATIVE_ALT, altitude relative to home
+)
+
+const (
+	directionEW = "ew" // to the left x axis
+	directionWE = "we" // to the right x axis
+	directionSN = "sn" // up on y axis
+	directionNS = "ns" // down on y axis
+	directionVU = "vu" // up adjusting for tree height
+	directionVD = "vd" // down adjusting for tree height
+	directionSH = "--" // staying at the same height over the tree
+)
+
+var ErrEmptyRoute = errors.New("patrol route has no steps")
+
+// Item is a single mission command, altitude is in meters relative to home.
+type Item struct {
+	Command   int
+	Latitude  float64
+	Longitude float64
+	Altitude  int
+}
+
+type Mission struct {
+	Home  geo.Point
+	Items []Item
+}
+
+// Waypoint is the drone above plot (x,y) at altitude
+type Waypoint struct {
+	X, Y     int
+	Altitude int
+}
+
+// Path return the waypoints the drone flies through along the patrol route steps,
+// from the ground of the first plot, every tree and row change of the serpentine route
+// (or of the detours around forbidden zones), to the ground where the route ends. Over
+// a tree the drone arrives at its previous altitude before climbing/descending so it
+// never cuts through the canopy.
+func Path(estate repository.Estate, routeSteps []repository.RouteStep) []Waypoint {
+	if len(routeSteps) == 0 {
+		return nil
+	}
+
+	waypoints := []Waypoint{{X: routeSteps[0].X, Y: routeSteps[0].Y}}
+	add := func(x, y, altitude int) {
+		if last := waypoints[len(waypoints)-1]; last.X == x && last.Y == y && last.Altitude == altitude {
+			return
+		}
+		waypoints = append(waypoints, Waypoint{X: x, Y: y, Altitude: altitude})
+	}
+
+	var altitude int
+	for _, routeStep := range routeSteps {
+		switch routeStep.Direction {
+		case directionSN:
+			// end of the previous row, then the start of this row
+			add(routeStep.X, routeStep.Y-1, altitude)
+			add(routeStep.X, routeStep.Y, altitude)
+		case directionNS:
+			add(routeStep.X, routeStep.Y+1, altitude)
+			add(routeStep.X, routeStep.Y, altitude)
+		case directionVU, directionVD, directionSH:
+			add(routeStep.X, routeStep.Y, altitude)
+			add(routeStep.X, routeStep.Y, routeStep.Altitude)
+		}
+		altitude = routeStep.Altitude
+	}
+
+	// the route ends past its last step, at the end of the row or right before a forbidden zone
+	last := routeSteps[len(routeSteps)-1]
+	lastX, lastY := last.X, last.Y
+	plotSize := estate.Drone.PlotSize
+	if plotSize < 1 {
+		plotSize = repository.DefaultDroneProfile().PlotSize
+	}
+	switch last.Direction {
+	case directionEW:
+		lastX = min(last.X+last.StepDistance/plotSize, estate.Width)
+	case directionWE:
+		lastX = max(last.X-last.StepDistance/plotSize, 1)
+	}
+	add(lastX, lastY, altitude)
+	add(lastX, lastY, 0)
+
+	return waypoints
+}
+
+// Build convert the patrol route steps of the estate into mission, the drone flies through its Path.
+func Build(estate repository.Estate, routeSteps []repository.RouteStep, grid geo.Grid) (Mission, error) {
+	return FromPath(Path(estate, routeSteps), grid)
+}
+
+// FromPath convert the waypoints of a patrol into mission. The drone takes off on the first
+// waypoint to the altitude it monitors the first tree, flies through the waypoints
+// and lands on the last one.
+func FromPath(waypoints []Waypoint, grid geo.Grid) (Mission, error) {
+	if len(waypoints) == 0 {
+		return Mission{}, ErrEmptyRoute
+	}
+
+	// the path starts on the ground, fly to the first monitoring altitude instead
+	var takeoffAltitude int
+	for _, waypoint := range waypoints {
+		if waypoint.Altitude > 0 {
+			takeoffAltitude = waypoint.Altitude
+			break
+		}
+	}
+
+	item := func(command int, waypoint Waypoint) Item {
+		point := grid.Position(float64(waypoint.X), float64(waypoint.Y))
+		return Item{
+			Command:   command,
+			Latitude:  point.Latitude,
+			Longitude: point.Longitude,
+			Altitude:  waypoint.Altitude,
+		}
+	}
+
+	takeoff := Waypoint{X: waypoints[0].X, Y: waypoints[0].Y, Altitude: takeoffAltitude}
+	items := make([]Item, 0, len(waypoints))
+	items = append(items, item(CommandTakeoff, takeoff))
+
+	last := takeoff
+	for _, waypoint := range waypoints[1 : len(waypoints)-1] {
+		// still on the ground before the first tree
+		if waypoint.Altitude == 0 {
+			waypoint.Altitude = takeoffAltitude
+		}
+		if waypoint == last {
+			continue
+		}
+
+		items = append(items, item(CommandWaypoint, waypoint))
+		last = waypoint
+	}
+	items = append(items, item(CommandLand, waypoints[len(waypoints)-1]))
+
+	return Mission{Home: grid.Origin, Items: items}, nil
+}
@@ -0,0 +1,198 @@
+package mission
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nahwinrajan/testswpro/geo"
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/nahwinrajan/testswpro/routecodec"
+	"github.com/stretchr/testify/require"
+)
+
+// position of the plot center 10 meters away from origin (0,0)
+const plotDegree = 8.983152841195214e-05
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name          string
+		estate        repository.Estate
+		route         string
+		expectedItems []Item
+		expectedError error
+	}{
+		{
+			name:   "Single row estate",
+			estate: repository.Estate{Width: 5, Length: 1},
+			route:  "1,1,1,ew,10,10;2,2,1,vu,6,16;3,2,1,ew,10,26;4,3,1,vd,2,28;5,3,1,ew,10,38;6,4,1,vu,1,39;7,4,1,ew,10,49;8,5,1,ew,10,59;",
+			expectedItems: []Item{
+				{Command: CommandTakeoff, Latitude: 0, Longitude: 0, Altitude: 6},
+				{Command: CommandWaypoint, Latitude: 0, Longitude: plotDegree, Altitude: 6},
+				{Command: CommandWaypoint, Latitude: 0, Longitude: 2 * plotDegree, Altitude: 6},
+				{Command: CommandWaypoint, Latitude: 0, Longitude: 2 * plotDegree, Altitude: 4},
+				{Command: CommandWaypoint, Latitude: 0, Longitude: 3 * plotDegree, Altitude: 4},
+				{Command: CommandWaypoint, Latitude: 0, Longitude: 3 * plotDegree, Altitude: 5},
+				{Command: CommandWaypoint, Latitude: 0, Longitude: 4 * plotDegree, Altitude: 5},
+				{Command: CommandLand, Latitude: 0, Longitude: 4 * plotDegree, Altitude: 0},
+			},
+		},
+		{
+			name:   "Serpentine over rows",
+			estate: repository.Estate{Width: 2, Length: 2},
+			route:  "1,1,1,vu,2,2;2,1,1,ew,10,12;3,2,2,sn,10,22;4,2,2,we,10,32;5,1,2,vu,1,33;6,1,2,we,10,43;",
+			expectedItems: []Item{
+				{Command: CommandTakeoff, Latitude: 0, Longitude: 0, Altitude: 2},
+				{Command: CommandWaypoint, Latitude: 0, Longitude: plotDegree, Altitude: 2},
+				{Command: CommandWaypoint, Latitude: plotDegree, Longitude: plotDegree, Altitude: 2},
+				{Command: CommandWaypoint, Latitude: plotDegree, Longitude: 0, Altitude: 2},
+				{Command: CommandWaypoint, Latitude: plotDegree, Longitude: 0, Altitude: 3},
+				{Command: CommandLand, Latitude: plotDegree, Longitude: 0, Altitude: 0},
+			},
+		},
+		{
+			name:          "Empty route",
+			estate:        repository.Estate{Width: 5, Length: 1},
+			route:         "",
+			expectedError: ErrEmptyRoute,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			steps, err := routecodec.Decode(tc.route)
+			require.NoError(t, err)
+
+			m, err := Build(tc.estate, steps, geo.Grid{PlotSize: 10})
+			require.Equal(t, tc.expectedError, err)
+			require.Len(t, m.Items, len(tc.expectedItems))
+
+			for idx, item := range m.Items {
+				expected := tc.expectedItems[idx]
+				require.Equal(t, expected.Command, item.Command)
+				require.Equal(t, expected.Altitude, item.Altitude)
+				require.InDelta(t, expected.Latitude, item.Latitude, 1e-12)
+				require.InDelta(t, expected.Longitude, item.Longitude, 1e-12)
+			}
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	steps, err := routecodec.Decode("1,1,1,ew,10,10;2,2,1,vu,6,16;3,2,1,ew,10,26;4,2,2,sn,10,36;5,2,2,we,10,46;6,1,2,vd,2,48;7,1,2,we,10,58;")
+	require.NoError(t, err)
+
+	expected := []Waypoint{
+		{X: 1, Y: 1, Altitude: 0},
+		{X: 2, Y: 1, Altitude: 0},
+		{X: 2, Y: 1, Altitude: 6},
+		{X: 2, Y: 2, Altitude: 6},
+		{X: 1, Y: 2, Altitude: 6},
+		{X: 1, Y: 2, Altitude: 4},
+		{X: 1, Y: 2, Altitude: 0},
+	}
+
+	require.Equal(t, expected, Path(repository.Estate{Width: 2, Length: 2}, steps))
+	require.Empty(t, Path(repository.Estate{Width: 2, Length: 2}, nil))
+}
+
+func TestPathDetour(t *testing.T) {
+	// from plot (4,2) around the forbidden plot (2,2) over row 3 and back down to row 2
+	steps, err := routecodec.Decode("1,4,2,vu,6,6;2,4,2,we,10,16;3,3,3,sn,10,26;4,3,3,we,20,46;5,1,2,ns,10,56;6,1,2,we,10,66;")
+	require.NoError(t, err)
+
+	expected := []Waypoint{
+		{X: 4, Y: 2, Altitude: 0},
+		{X: 4, Y: 2, Altitude: 6},
+		{X: 3, Y: 2, Altitude: 6},
+		{X: 3, Y: 3, Altitude: 6},
+		{X: 1, Y: 3, Altitude: 6},
+		{X: 1, Y: 2, Altitude: 6},
+		{X: 1, Y: 2, Altitude: 0},
+	}
+
+	require.Equal(t, expected, Path(repository.Estate{Width: 4, Length: 3}, steps))
+}
+
+func TestFromPath(t *testing.T) {
+	// column by column from plot (2,1), still on the ground over the first plot
+	waypoints := []Waypoint{
+		{X: 2, Y: 1, Altitude: 0},
+		{X: 2, Y: 2, Altitude: 0},
+		{X: 2, Y: 2, Altitude: 6},
+		{X: 1, Y: 2, Altitude: 6},
+		{X: 1, Y: 1, Altitude: 6},
+		{X: 1, Y: 1, Altitude: 0},
+	}
+
+	m, err := FromPath(waypoints, geo.Grid{PlotSize: 10})
+	require.NoError(t, err)
+
+	expected := []Item{
+		{Command: CommandTakeoff, Latitude: 0, Longitude: plotDegree, Altitude: 6},
+		{Command: CommandWaypoint, Latitude: plotDegree, Longitude: plotDegree, Altitude: 6},
+		{Command: CommandWaypoint, Latitude: plotDegree, Longitude: 0, Altitude: 6},
+		{Command: CommandWaypoint, Latitude: 0, Longitude: 0, Altitude: 6},
+		{Command: CommandLand, Latitude: 0, Longitude: 0, Altitude: 0},
+	}
+	require.Len(t, m.Items, len(expected))
+	for idx, item := range m.Items {
+		require.Equal(t, expected[idx].Command, item.Command)
+		require.Equal(t, expected[idx].Altitude, item.Altitude)
+		require.InDelta(t, expected[idx].Latitude, item.Latitude, 1e-12)
+		require.InDelta(t, expected[idx].Longitude, item.Longitude, 1e-12)
+	}
+
+	_, err = FromPath(nil, geo.Grid{PlotSize: 10})
+	require.Equal(t, ErrEmptyRoute, err)
+}
+
+func TestEncodeWaypoints(t *testing.T) {
+	m := Mission{
+		Home: geo.Point{Latitude: -6.2, Longitude: 106.8},
+		Items: []Item{
+			{Command: CommandTakeoff, Latitude: -6.2, Longitude: 106.8, Altitude: 6},
+			{Command: CommandWaypoint, Latitude: -6.2, Longitude: 106.80009036, Altitude: 6},
+			{Command: CommandLand, Latitude: -6.2, Longitude: 106.80009036, Altitude: 0},
+		},
+	}
+
+	expected := "QGC WPL 110\n" +
+		"0\t1\t0\t16\t0\t0\t0\t0\t-6.20000000\t106.80000000\t0\t1\n" +
+		"1\t0\t3\t22\t0\t0\t0\t0\t-6.20000000\t106.80000000\t6\t1\n" +
+		"2\t0\t3\t16\t0\t0\t0\t0\t-6.20000000\t106.80009036\t6\t1\n" +
+		"3\t0\t3\t21\t0\t0\t0\t0\t-6.20000000\t106.80009036\t0\t1\n"
+
+	require.Equal(t, expected, string(EncodeWaypoints(m)))
+}
+
+func TestEncodeQGCPlan(t *testing.T) {
+	m := Mission{
+		Home: geo.Point{Latitude: -6.2, Longitude: 106.8},
+		Items: []Item{
+			{Command: CommandTakeoff, Latitude: -6.2, Longitude: 106.8, Altitude: 6},
+			{Command: CommandLand, Latitude: -6.2, Longitude: 106.8, Altitude: 0},
+		},
+	}
+
+	data, err := EncodeQGCPlan(m)
+	require.NoError(t, err)
+
+	var plan qgcPlan
+	require.NoError(t, json.Unmarshal(data, &plan))
+
+	require.Equal(t, "Plan", plan.FileType)
+	require.Equal(t, "QGroundControl", plan.GroundStation)
+	require.Equal(t, [3]float64{-6.2, 106.8, 0}, plan.Mission.PlannedHomePosition)
+	require.Len(t, plan.Mission.Items, 2)
+
+	takeoff := plan.Mission.Items[0]
+	require.Equal(t, CommandTakeoff, takeoff.Command)
+	require.Equal(t, 1, takeoff.DoJumpID)
+	require.Equal(t, frameGlobalRelativeAlt, takeoff.Frame)
+	require.Equal(t, [7]any{0.0, 0.0, 0.0, nil, -6.2, 106.8, 6.0}, takeoff.Params)
+
+	land := plan.Mission.Items[1]
+	require.Equal(t, CommandLand, land.Command)
+	require.Equal(t, 2, land.DoJumpID)
+	require.Equal(t, 0, land.Altitude)
+}
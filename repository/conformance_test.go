@@ -0,0 +1,602 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// conformanceTests check what the handlers rely on from every Repositorier, each test
+// starts with an empty repository
+var conformanceTests = []struct {
+	name string
+	run  func(t *testing.T, ctx context.Context, repo Repositorier)
+}{
+	{
+		name: "Estate",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			lat, lon := -6.2, 106.8
+			drone := DroneProfile{PlotSize: 5, MonitorClearance: 2, CruiseAltitudeMin: 10, MaxAltitude: 60}
+			estateID, err := repo.InsertEstate(ctx, Estate{
+				Width:     10,
+				Length:    5,
+				OriginLat: &lat,
+				OriginLon: &lon,
+				Bearing:   30,
+				Drone:     drone,
+				Boundary:  []Plot{{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 5}},
+				// only stored by UpdateEstate
+				Count: 3,
+			})
+			require.NoError(t, err)
+
+			estate, err := repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.False(t, estate.CreatedAt.IsZero())
+			require.Equal(t, Estate{
+				ID:          estateID,
+				Width:       10,
+				Length:      5,
+				OriginLat:   &lat,
+				OriginLon:   &lon,
+				Bearing:     30,
+				StatsStatus: StatsStatusFresh,
+				Drone:       drone,
+				Boundary:    []Plot{{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 5}},
+				CreatedAt:   estate.CreatedAt,
+			}, estate)
+
+			locked, err := repo.LockEstate(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, estate, locked)
+
+			estate.Width, estate.Length = 20, 8
+			estate.OriginLat, estate.OriginLon = nil, nil
+			estate.Drone = DefaultDroneProfile()
+			estate.Boundary = nil
+			require.NoError(t, repo.UpdateEstateLayout(ctx, estate))
+
+			updated, err := repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, estate, updated)
+
+			_, err = repo.GetEstateByID(ctx, "missing_estate_id")
+			require.ErrorIs(t, err, ErrNotFound)
+			_, err = repo.LockEstate(ctx, "missing_estate_id")
+			require.ErrorIs(t, err, ErrNotFound)
+		},
+	},
+	{
+		name: "Estates page",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			for i := 0; i < 3; i++ {
+				insertEstate(t, ctx, repo, 10, 10)
+			}
+
+			oldestFirst, err := repo.GetEstatesPage(ctx, nil, false, 10)
+			require.NoError(t, err)
+			require.Len(t, oldestFirst, 3)
+			require.True(t, sort.SliceIsSorted(oldestFirst, func(i, j int) bool {
+				return oldestFirst[i].CreatedAt.Before(oldestFirst[j].CreatedAt) ||
+					(oldestFirst[i].CreatedAt.Equal(oldestFirst[j].CreatedAt) && oldestFirst[i].ID < oldestFirst[j].ID)
+			}))
+
+			firstPage, err := repo.GetEstatesPage(ctx, nil, false, 2)
+			require.NoError(t, err)
+			require.Equal(t, oldestFirst[:2], firstPage)
+			secondPage, err := repo.GetEstatesPage(ctx, &firstPage[1], false, 2)
+			require.NoError(t, err)
+			require.Equal(t, oldestFirst[2:], secondPage)
+
+			newestFirst, err := repo.GetEstatesPage(ctx, &oldestFirst[2], true, 10)
+			require.NoError(t, err)
+			require.Equal(t, []Estate{oldestFirst[1], oldestFirst[0]}, newestFirst)
+		},
+	},
+	{
+		name: "Estate stats and route",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 10, 10)
+			steps := []RouteStep{
+				{Step: 1, X: 1, Y: 1, Direction: "vu", StepDistance: 11, CurrentDistance: 11, Altitude: 11},
+				{Step: 2, X: 2, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 21, Altitude: 11},
+				{Step: 3, X: 3, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 31, Altitude: 11},
+				{Step: 4, X: 3, Y: 1, Direction: "vd", StepDistance: 11, CurrentDistance: 42, Altitude: 0},
+			}
+			require.NoError(t, repo.UpdateEstate(ctx, estateID, 1, 10, 10, 10, 42, steps))
+
+			estate, err := repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, []int{1, 10, 10, 10, 42}, []int{estate.Count, estate.Min, estate.Max, estate.Median, estate.PatrolDistance})
+			require.Equal(t, StatsStatusFresh, estate.StatsStatus)
+
+			routeSteps, err := repo.GetRouteSteps(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, steps, routeSteps)
+
+			// steps traveling through distance 15 to 25, after step 1
+			page, err := repo.GetRouteStepsPage(ctx, estateID, 1, 15, 25, 10)
+			require.NoError(t, err)
+			require.Equal(t, steps[1:3], page)
+			page, err = repo.GetRouteStepsPage(ctx, estateID, 0, 0, 100, 2)
+			require.NoError(t, err)
+			require.Equal(t, steps[:2], page)
+
+			// the route is replaced
+			require.NoError(t, repo.UpdateEstate(ctx, estateID, 0, 0, 0, 0, 0, nil))
+			routeSteps, err = repo.GetRouteSteps(ctx, estateID)
+			require.NoError(t, err)
+			require.Empty(t, routeSteps)
+
+			err = repo.UpdateEstate(ctx, "missing_estate_id", 1, 10, 10, 10, 42, steps)
+			require.ErrorIs(t, err, ErrConflict)
+
+			// only the route is stored, the stats are left as they are
+			require.NoError(t, repo.UpdateEstate(ctx, estateID, 1, 10, 10, 10, 42, nil))
+			require.NoError(t, repo.UpdateRouteSteps(ctx, estateID, steps))
+			estate, err = repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, []int{1, 10, 10, 10, 42}, []int{estate.Count, estate.Min, estate.Max, estate.Median, estate.PatrolDistance})
+			routeSteps, err = repo.GetRouteSteps(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, steps, routeSteps)
+
+			err = repo.UpdateRouteSteps(ctx, "missing_estate_id", steps)
+			require.ErrorIs(t, err, ErrConflict)
+		},
+	},
+	{
+		name: "Tree",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 10, 10)
+			otherEstateID := insertEstate(t, ctx, repo, 10, 10)
+
+			treeID, err := repo.InsertTree(ctx, estateID, 2, 3, 15)
+			require.NoError(t, err)
+
+			tree, err := repo.GetTreeByID(ctx, estateID, treeID)
+			require.NoError(t, err)
+			require.Equal(t, Tree{ID: treeID, EstateID: estateID, X: 2, Y: 3, Height: 15}, tree)
+
+			// a single tree per plot of the estate
+			_, err = repo.InsertTree(ctx, estateID, 2, 3, 20)
+			require.ErrorIs(t, err, ErrConflict)
+			otherTreeID, err := repo.InsertTree(ctx, otherEstateID, 2, 3, 20)
+			require.NoError(t, err)
+			_, err = repo.InsertTree(ctx, "missing_estate_id", 2, 3, 20)
+			require.ErrorIs(t, err, ErrConflict)
+
+			_, err = repo.GetTreeByID(ctx, estateID, otherTreeID)
+			require.ErrorIs(t, err, ErrNotFound)
+			_, err = repo.GetTreeByID(ctx, estateID, "missing_tree_id")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			require.NoError(t, repo.UpdateTreeHeight(ctx, treeID, 25))
+			tree, err = repo.GetTreeByID(ctx, estateID, treeID)
+			require.NoError(t, err)
+			require.Equal(t, 25, tree.Height)
+
+			require.NoError(t, repo.DeleteTree(ctx, treeID))
+			_, err = repo.GetTreeByID(ctx, estateID, treeID)
+			require.ErrorIs(t, err, ErrNotFound)
+
+			// the plot is free again
+			_, err = repo.InsertTree(ctx, estateID, 2, 3, 20)
+			require.NoError(t, err)
+		},
+	},
+	{
+		name: "Trees all or none",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 10, 10)
+			_, err := repo.InsertTree(ctx, estateID, 5, 5, 10)
+			require.NoError(t, err)
+
+			_, err = repo.InsertTrees(ctx, estateID, []Tree{{X: 1, Y: 1, Height: 10}, {X: 5, Y: 5, Height: 10}})
+			require.ErrorIs(t, err, ErrConflict)
+			_, err = repo.InsertTrees(ctx, estateID, []Tree{{X: 1, Y: 1, Height: 10}, {X: 1, Y: 1, Height: 20}})
+			require.ErrorIs(t, err, ErrConflict)
+
+			trees, err := repo.GetAllTreesInEstate(ctx, estateID)
+			require.NoError(t, err)
+			require.Len(t, trees, 1)
+
+			treeIDs, err := repo.InsertTrees(ctx, estateID, []Tree{{X: 1, Y: 1, Height: 10}, {X: 2, Y: 1, Height: 20}})
+			require.NoError(t, err)
+			require.Len(t, treeIDs, 2)
+
+			tree, err := repo.GetTreeByID(ctx, estateID, treeIDs[1])
+			require.NoError(t, err)
+			require.Equal(t, Tree{ID: treeIDs[1], EstateID: estateID, X: 2, Y: 1, Height: 20}, tree)
+
+			heightCounts, err := repo.GetTreeHeightCounts(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, map[int]int{10: 2, 20: 1}, heightCounts)
+		},
+	},
+	{
+		name: "Trees page",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 10, 10)
+			treeIDs, err := repo.InsertTrees(ctx, estateID, []Tree{
+				{X: 3, Y: 2, Height: 10},
+				{X: 1, Y: 2, Height: 20},
+				{X: 4, Y: 1, Height: 30},
+				{X: 9, Y: 9, Height: 10},
+				{X: 2, Y: 3, Height: 5},
+			})
+			require.NoError(t, err)
+
+			filter := TreeFilter{FromX: 1, ToX: 5, FromY: 1, ToY: 5, MinHeight: 10, MaxHeight: 30}
+			trees, err := repo.GetTreesPage(ctx, estateID, filter, 0, 0, 2)
+			require.NoError(t, err)
+			require.Equal(t, []Tree{
+				{ID: treeIDs[2], EstateID: estateID, X: 4, Y: 1, Height: 30},
+				{ID: treeIDs[1], EstateID: estateID, X: 1, Y: 2, Height: 20},
+			}, trees)
+
+			trees, err = repo.GetTreesPage(ctx, estateID, filter, 1, 2, 2)
+			require.NoError(t, err)
+			require.Equal(t, []Tree{{ID: treeIDs[0], EstateID: estateID, X: 3, Y: 2, Height: 10}}, trees)
+		},
+	},
+	{
+		name: "Trees outside",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 10, 10)
+			treeIDs, err := repo.InsertTrees(ctx, estateID, []Tree{
+				{X: 5, Y: 5, Height: 10},
+				{X: 6, Y: 1, Height: 10},
+				{X: 1, Y: 6, Height: 10},
+			})
+			require.NoError(t, err)
+
+			require.NoError(t, repo.DeleteTreesOutside(ctx, estateID, 5, 5))
+			trees, err := repo.GetAllTreesInEstate(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, []Tree{{ID: treeIDs[0], EstateID: estateID, X: 5, Y: 5, Height: 10}}, trees)
+
+			// the plots are free again
+			_, err = repo.InsertTree(ctx, estateID, 6, 1, 10)
+			require.NoError(t, err)
+		},
+	},
+	{
+		name: "Patrol neighbour trees",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 5, 5)
+			// serpentine, row 1 goes east and row 2 goes west
+			treeIDs, err := repo.InsertTrees(ctx, estateID, []Tree{
+				{X: 2, Y: 1, Height: 10},
+				{X: 4, Y: 1, Height: 10},
+				{X: 1, Y: 2, Height: 10},
+				{X: 4, Y: 2, Height: 10},
+			})
+			require.NoError(t, err)
+
+			prev, next, err := repo.GetPatrolNeighbourTrees(ctx, estateID, 5, 1)
+			require.NoError(t, err)
+			require.Equal(t, treeIDs[1], prev.ID)
+			require.Equal(t, treeIDs[3], next.ID)
+
+			prev, next, err = repo.GetPatrolNeighbourTrees(ctx, estateID, 2, 2)
+			require.NoError(t, err)
+			require.Equal(t, treeIDs[3], prev.ID)
+			require.Equal(t, treeIDs[2], next.ID)
+
+			prev, next, err = repo.GetPatrolNeighbourTrees(ctx, estateID, 1, 1)
+			require.NoError(t, err)
+			require.Nil(t, prev)
+			require.Equal(t, treeIDs[0], next.ID)
+
+			prev, next, err = repo.GetPatrolNeighbourTrees(ctx, estateID, 3, 3)
+			require.NoError(t, err)
+			require.Equal(t, treeIDs[2], prev.ID)
+			require.Nil(t, next)
+		},
+	},
+	{
+		name: "Delete estate",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 10, 10)
+			_, err := repo.InsertTree(ctx, estateID, 1, 1, 10)
+			require.NoError(t, err)
+			_, err = repo.InsertZone(ctx, Zone{EstateID: estateID, Kind: ZoneKindForbidden, Polygon: []Plot{{X: 2, Y: 2}, {X: 3, Y: 3}}})
+			require.NoError(t, err)
+			require.NoError(t, repo.EnqueueRecalculation(ctx, estateID))
+			require.NoError(t, repo.UpdateEstate(ctx, estateID, 1, 10, 10, 10, 22, []RouteStep{{Step: 1, X: 1, Y: 1, Direction: "vu", StepDistance: 11, CurrentDistance: 11, Altitude: 11}}))
+
+			err = repo.DeleteEstate(ctx, estateID, false)
+			require.ErrorIs(t, err, ErrEstateHasTrees)
+			require.ErrorIs(t, err, ErrConflict)
+			_, err = repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			zones, err := repo.GetZones(ctx, estateID)
+			require.NoError(t, err)
+			require.Len(t, zones, 1)
+
+			require.NoError(t, repo.DeleteEstate(ctx, estateID, true))
+			_, err = repo.GetEstateByID(ctx, estateID)
+			require.ErrorIs(t, err, ErrNotFound)
+			trees, err := repo.GetAllTreesInEstate(ctx, estateID)
+			require.NoError(t, err)
+			require.Empty(t, trees)
+			zones, err = repo.GetZones(ctx, estateID)
+			require.NoError(t, err)
+			require.Empty(t, zones)
+			routeSteps, err := repo.GetRouteSteps(ctx, estateID)
+			require.NoError(t, err)
+			require.Empty(t, routeSteps)
+			job, err := repo.ClaimJob(ctx, time.Minute)
+			require.NoError(t, err)
+			require.Nil(t, job)
+		},
+	},
+	{
+		name: "Zone",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 10, 10)
+			first := Zone{EstateID: estateID, Kind: ZoneKindForbidden, Polygon: []Plot{{X: 1, Y: 1}, {X: 3, Y: 1}, {X: 2, Y: 3}}}
+			second := Zone{EstateID: estateID, Kind: ZoneKindMinAltitude, MinAltitude: 30, Polygon: []Plot{{X: 5, Y: 5}, {X: 6, Y: 6}}}
+
+			var err error
+			first.ID, err = repo.InsertZone(ctx, first)
+			require.NoError(t, err)
+			second.ID, err = repo.InsertZone(ctx, second)
+			require.NoError(t, err)
+
+			zones, err := repo.GetZones(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, []Zone{first, second}, zones)
+			estate, err := repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, 2, estate.ZoneCount)
+
+			first.Kind, first.MinAltitude = ZoneKindMinAltitude, 50
+			first.Polygon = []Plot{{X: 7, Y: 7}, {X: 8, Y: 8}}
+			require.NoError(t, repo.UpdateZone(ctx, first))
+			zone, err := repo.GetZoneByID(ctx, estateID, first.ID)
+			require.NoError(t, err)
+			require.Equal(t, first, zone)
+
+			require.NoError(t, repo.DeleteZone(ctx, estateID, second.ID))
+			_, err = repo.GetZoneByID(ctx, estateID, second.ID)
+			require.ErrorIs(t, err, ErrNotFound)
+			estate, err = repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, 1, estate.ZoneCount)
+
+			err = repo.DeleteZone(ctx, estateID, second.ID)
+			require.ErrorIs(t, err, ErrNotFound)
+			err = repo.UpdateZone(ctx, second)
+			require.ErrorIs(t, err, ErrNotFound)
+			_, err = repo.GetZoneByID(ctx, "other_estate_id", first.ID)
+			require.ErrorIs(t, err, ErrNotFound)
+			_, err = repo.InsertZone(ctx, Zone{EstateID: "missing_estate_id", Kind: ZoneKindForbidden, Polygon: first.Polygon})
+			require.ErrorIs(t, err, ErrConflict)
+		},
+	},
+	{
+		name: "Transaction",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 10, 10)
+
+			var treeID string
+			err := repo.WithTx(ctx, func(txRepo Repositorier) error {
+				_, err := txRepo.LockEstate(ctx, estateID)
+				if err != nil {
+					return err
+				}
+
+				// nested transactions join the one running
+				return txRepo.WithTx(ctx, func(txRepo Repositorier) error {
+					var err error
+					treeID, err = txRepo.InsertTree(ctx, estateID, 1, 1, 10)
+					return err
+				})
+			})
+			require.NoError(t, err)
+			_, err = repo.GetTreeByID(ctx, estateID, treeID)
+			require.NoError(t, err)
+
+			errRollback := errors.New("rolled back")
+			err = repo.WithTx(ctx, func(txRepo Repositorier) error {
+				_, err := txRepo.InsertTree(ctx, estateID, 2, 2, 10)
+				if err != nil {
+					return err
+				}
+				err = txRepo.DeleteTree(ctx, treeID)
+				if err != nil {
+					return err
+				}
+				err = txRepo.UpdateEstate(ctx, estateID, 1, 10, 10, 10, 22, nil)
+				if err != nil {
+					return err
+				}
+
+				return errRollback
+			})
+			require.ErrorIs(t, err, errRollback)
+
+			require.Panics(t, func() {
+				repo.WithTx(ctx, func(txRepo Repositorier) error {
+					_, err := txRepo.InsertTree(ctx, estateID, 3, 3, 10)
+					require.NoError(t, err)
+					panic("rolled back")
+				})
+			})
+
+			// only the first tree is stored and the stats are untouched
+			trees, err := repo.GetAllTreesInEstate(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, []Tree{{ID: treeID, EstateID: estateID, X: 1, Y: 1, Height: 10}}, trees)
+			estate, err := repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, 0, estate.Count)
+		},
+	},
+	{
+		name: "Concurrent changes of the estate",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 10, 10)
+
+			const changes = 20
+			var wg sync.WaitGroup
+			errs := make(chan error, 2*changes)
+			for i := 0; i < changes; i++ {
+				wg.Add(2)
+				// every change counts on the estate the previous one left behind
+				go func() {
+					defer wg.Done()
+					errs <- repo.WithTx(ctx, func(txRepo Repositorier) error {
+						estate, err := txRepo.LockEstate(ctx, estateID)
+						if err != nil {
+							return err
+						}
+
+						return txRepo.UpdateEstate(ctx, estateID, estate.Count+1, 0, 0, 0, 0, nil)
+					})
+				}()
+				// a single tree is planted on the plot
+				go func() {
+					defer wg.Done()
+					_, err := repo.InsertTree(ctx, estateID, 1, 1, 10)
+					if !errors.Is(err, ErrConflict) {
+						errs <- err
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				require.NoError(t, err)
+			}
+			estate, err := repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, changes, estate.Count)
+			trees, err := repo.GetAllTreesInEstate(ctx, estateID)
+			require.NoError(t, err)
+			require.Len(t, trees, 1)
+		},
+	},
+	{
+		name: "Job",
+		run: func(t *testing.T, ctx context.Context, repo Repositorier) {
+			estateID := insertEstate(t, ctx, repo, 10, 10)
+
+			job, err := repo.ClaimJob(ctx, time.Minute)
+			require.NoError(t, err)
+			require.Nil(t, job)
+
+			// enqueuing twice still makes a single job
+			require.NoError(t, repo.EnqueueRecalculation(ctx, estateID))
+			require.NoError(t, repo.EnqueueRecalculation(ctx, estateID))
+			estate, err := repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, StatsStatusPending, estate.StatsStatus)
+
+			job, err = repo.ClaimJob(ctx, time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, JobKindRecalculateEstate, job.Kind)
+			require.Equal(t, estateID, job.EstateID)
+			require.Equal(t, 1, job.Attempts)
+
+			// claimed for the lease
+			claimed, err := repo.ClaimJob(ctx, time.Minute)
+			require.NoError(t, err)
+			require.Nil(t, claimed)
+
+			require.NoError(t, repo.RetryJob(ctx, job.ID, time.Now().Add(-time.Hour), "failed"))
+			retried, err := repo.ClaimJob(ctx, time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, job.ID, retried.ID)
+			require.Equal(t, 2, retried.Attempts)
+
+			// enqueued again since it was claimed, the job stays
+			require.NoError(t, repo.EnqueueRecalculation(ctx, estateID))
+			require.NoError(t, repo.DeadLetterJob(ctx, *retried, "failed"))
+			estate, err = repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, StatsStatusPending, estate.StatsStatus)
+
+			job, err = repo.ClaimJob(ctx, time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, retried.ID, job.ID)
+			require.Equal(t, 1, job.Attempts)
+			require.NoError(t, repo.DeadLetterJob(ctx, *job, "failed"))
+			estate, err = repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, StatsStatusFailed, estate.StatsStatus)
+
+			// only the completed job tells the stats are fresh again
+			require.NoError(t, repo.EnqueueRecalculation(ctx, estateID))
+			require.NoError(t, repo.UpdateEstate(ctx, estateID, 0, 0, 0, 0, 0, nil))
+			estate, err = repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, StatsStatusPending, estate.StatsStatus)
+			job, err = repo.ClaimJob(ctx, time.Minute)
+			require.NoError(t, err)
+			require.NoError(t, repo.CompleteJob(ctx, job.ID))
+			estate, err = repo.GetEstateByID(ctx, estateID)
+			require.NoError(t, err)
+			require.Equal(t, StatsStatusFresh, estate.StatsStatus)
+			require.NoError(t, repo.RetryJob(ctx, job.ID, time.Now().Add(-time.Hour), "failed"))
+			job, err = repo.ClaimJob(ctx, time.Minute)
+			require.NoError(t, err)
+			require.Nil(t, job)
+
+			err = repo.EnqueueRecalculation(ctx, "missing_estate_id")
+			require.ErrorIs(t, err, ErrConflict)
+		},
+	},
+}
+
+func insertEstate(t *testing.T, ctx context.Context, repo Repositorier, width, length int) string {
+	estateID, err := repo.InsertEstate(ctx, Estate{Width: width, Length: length, Drone: DefaultDroneProfile()})
+	require.NoError(t, err)
+
+	return estateID
+}
+
+func TestMemoryConformance(t *testing.T) {
+	for _, tc := range conformanceTests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, context.Background(), NewMemory())
+		})
+	}
+}
+
+// TestRepositoryConformance run the conformance tests against the postgres database of
+// TEST_DATABASE_URL, e.g. the one of docker compose. Every table is emptied beforehand.
+func TestRepositoryConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip database tests")
+	}
+	dbDsn := os.Getenv("TEST_DATABASE_URL")
+	if dbDsn == "" {
+		t.Skip("Skip database tests, TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open(driverPostgresDB, dbDsn)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Ping())
+
+	for _, tc := range conformanceTests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := db.ExecContext(ctx, `TRUNCATE estates, trees, route_steps, zones, jobs, dead_jobs`)
+			require.NoError(t, err)
+
+			tc.run(t, ctx, &Repository{db: db})
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lib/pq"
+)
+
+// errors the repository return wrap one of these, check them with errors.Is
+var (
+	// ErrNotFound is returned when the record does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when the change clash with records already stored,
+	// e.g. a tree on a plot which already has one
+	ErrConflict = errors.New("conflict")
+	// ErrInvalid is returned when the database refuse the value stored
+	ErrInvalid = errors.New("invalid")
+	// ErrUnavailable is returned when the database can not be reached or is
+	// overloaded, the same call may succeed later
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// postgres error codes and classes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pqCodeUniqueViolation     = "23505"
+	pqCodeForeignKeyViolation = "23503"
+	pqCodeNotNullViolation    = "23502"
+	pqCodeCheckViolation      = "23514"
+
+	pqClassDataException         = "22"
+	pqClassConnectionException   = "08"
+	pqClassInsufficientResources = "53"
+	pqClassOperatorIntervention  = "57"
+	pqClassTransactionRollback   = "40"
+)
+
+// dbError wrap the database error into the repository error it stands for,
+// the original error is kept in the chain. Other errors are returned as is.
+func dbError(err error) error {
+	if err == nil ||
+		errors.Is(err, ErrNotFound) ||
+		errors.Is(err, ErrConflict) ||
+		errors.Is(err, ErrInvalid) ||
+		errors.Is(err, ErrUnavailable) {
+		return err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == pqCodeUniqueViolation || pqErr.Code == pqCodeForeignKeyViolation:
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case pqErr.Code == pqCodeNotNullViolation || pqErr.Code == pqCodeCheckViolation ||
+			pqErr.Code.Class() == pqClassDataException:
+			return fmt.Errorf("%w: %w", ErrInvalid, err)
+		case pqErr.Code.Class() == pqClassConnectionException ||
+			pqErr.Code.Class() == pqClassInsufficientResources ||
+			pqErr.Code.Class() == pqClassOperatorIntervention ||
+			pqErr.Code.Class() == pqClassTransactionRollback:
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return err
+}
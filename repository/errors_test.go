@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDbError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		expectedErr error
+	}{
+		{
+			name:        "No error",
+			err:         nil,
+			expectedErr: nil,
+		},
+		{
+			name:        "No rows",
+			err:         sql.ErrNoRows,
+			expectedErr: ErrNotFound,
+		},
+		{
+			name:        "Unique violation",
+			err:         &pq.Error{Code: "23505"},
+			expectedErr: ErrConflict,
+		},
+		{
+			name:        "Foreign key violation",
+			err:         &pq.Error{Code: "23503"},
+			expectedErr: ErrConflict,
+		},
+		{
+			name:        "Numeric value out of range",
+			err:         &pq.Error{Code: "22003"},
+			expectedErr: ErrInvalid,
+		},
+		{
+			name:        "Too many connections",
+			err:         &pq.Error{Code: "53300"},
+			expectedErr: ErrUnavailable,
+		},
+		{
+			name:        "Bad connection",
+			err:         driver.ErrBadConn,
+			expectedErr: ErrUnavailable,
+		},
+		{
+			name:        "Already wrapped",
+			err:         ErrEstateHasTrees,
+			expectedErr: ErrEstateHasTrees,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := dbError(tc.err)
+
+			require.ErrorIs(t, err, tc.expectedErr)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	t.Run("Other error", func(t *testing.T) {
+		err := errors.New("database error")
+
+		require.Equal(t, err, dbError(err))
+	})
+}
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/lib/pq"
 
@@ -11,12 +14,53 @@ import (
 
 const (
 	// *** Estate ***
-	queryGetEstateByID = `SELECT estate_id, width, length, count, min, max, median, patrol_distance, patrol_route FROM estates WHERE estate_id = $1`
+	queryGetEstateByID = `SELECT estate_id, width, length, count, min, max, median, patrol_distance, patrol_route, origin_lat, origin_lon, bearing, stats_status, plot_size, monitor_clearance, cruise_altitude_min, max_altitude, zone_count, boundary, created_at FROM estates WHERE estate_id = $1`
+
+	// NO KEY UPDATE conflicts with itself only, trees referring to the estate can still be checked meanwhile
+	queryLockEstate = `SELECT estate_id, width, length, count, min, max, median, patrol_distance, patrol_route, origin_lat, origin_lon, bearing, stats_status, plot_size, monitor_clearance, cruise_altitude_min, max_altitude, zone_count, boundary, created_at FROM estates WHERE estate_id = $1 FOR NO KEY UPDATE`
+
+	// estates after the cursor estate ($1 created_at, $2 estate_id), no cursor for the first page
+	queryGetEstatesPageOldestFirst = `SELECT
+		estate_id, width, length, count, min, max, median, patrol_distance, patrol_route, origin_lat, origin_lon, bearing, stats_status, plot_size, monitor_clearance, cruise_altitude_min, max_altitude, zone_count, boundary, created_at
+	 FROM estates
+	 WHERE $1::timestamp IS NULL OR (created_at, estate_id) > ($1::timestamp, $2)
+	 ORDER BY created_at ASC, estate_id ASC
+	 LIMIT $3`
+
+	queryGetEstatesPageNewestFirst = `SELECT
+		estate_id, width, length, count, min, max, median, patrol_distance, patrol_route, origin_lat, origin_lon, bearing, stats_status, plot_size, monitor_clearance, cruise_altitude_min, max_altitude, zone_count, boundary, created_at
+	 FROM estates
+	 WHERE $1::timestamp IS NULL OR (created_at, estate_id) < ($1::timestamp, $2)
+	 ORDER BY created_at DESC, estate_id DESC
+	 LIMIT $3`
 
 	queryInsertEstate = `
-		INSERT INTO estates (estate_id, width, length)
-		VALUES ($1, $2, $3)
+		INSERT INTO estates (estate_id, width, length, origin_lat, origin_lon, bearing, plot_size, monitor_clearance, cruise_altitude_min, max_altitude, boundary)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
+	queryUpdateEstateLayout = `
+		UPDATE estates
+		SET
+			width = $2,
+			length = $3,
+			origin_lat = $4,
+			origin_lon = $5,
+			bearing = $6,
+			plot_size = $7,
+			monitor_clearance = $8,
+			cruise_altitude_min = $9,
+			max_altitude = $10,
+			boundary = $11,
+			updated_at = now()
+		WHERE
+			estate_id = $1
+	`
+
+	queryDeleteEstate = `DELETE FROM estates WHERE estate_id = $1`
+
+	// patrol_route is the legacy text route, only read for existing rows,
+	// the route is now stored in route_steps. The stats are stored once every
+	// tree is counted, they are fresh until the trees change again.
 	queryUpdateEstateStats = `
 		UPDATE estates
 		SET
@@ -25,7 +69,7 @@ const (
 			max = $4,
 			median = $5,
 			patrol_distance = $6,
-			patrol_route = $7,
+			patrol_route = '',
 			updated_at = now()
 		WHERE
 			estate_id = $1
@@ -37,19 +81,133 @@ const (
 	 FROM trees 
 	 WHERE estate_id = $1`
 
+	queryGetTreeByID = `SELECT
+		tree_id, estate_id, x, y, height
+	 FROM trees
+	 WHERE estate_id = $1 AND tree_id = $2`
+
+	// trees after plot ($8,$9) row by row, the same order as idx_trees_estate_y_x
+	queryGetTreesPage = `SELECT
+		tree_id, estate_id, x, y, height
+	 FROM trees
+	 WHERE estate_id = $1
+		AND x BETWEEN $2 AND $3
+		AND y BETWEEN $4 AND $5
+		AND height BETWEEN $6 AND $7
+		AND (y, x) > ($9, $8)
+	 ORDER BY y, x
+	 LIMIT $10`
+
 	queryInsertTree = `
 		INSERT INTO trees (estate_id, tree_id, x, y, height)
 		VALUES ($1, $2, $3, $4, $5)
 		RETURNING tree_id
 	`
-	queryDeleteTree = `DELETE FROM trees WHERE tree_id = $1`
+	queryUpdateTreeHeight = `UPDATE trees SET height = $2, updated_at = now() WHERE tree_id = $1`
+	queryDeleteTree       = `DELETE FROM trees WHERE tree_id = $1`
+
+	queryDeleteTreesByEstateID = `DELETE FROM trees WHERE estate_id = $1`
+	queryDeleteTreesOutside    = `DELETE FROM trees WHERE estate_id = $1 AND (x > $2 OR y > $3)`
+
+	// trees are ordered following the drone patrol (serpentine), odd row goes
+	// from column start to column end, even row the other way around
+	queryGetPreviousPatrolTree = `SELECT
+		tree_id, estate_id, x, y, height
+	 FROM trees
+	 WHERE estate_id = $1
+		AND (y < $3 OR (y = $3 AND CASE WHEN y % 2 = 1 THEN x < $2 ELSE x > $2 END))
+	 ORDER BY y DESC, CASE WHEN y % 2 = 1 THEN x ELSE -x END DESC
+	 LIMIT 1`
+
+	queryGetNextPatrolTree = `SELECT
+		tree_id, estate_id, x, y, height
+	 FROM trees
+	 WHERE estate_id = $1
+		AND (y > $3 OR (y = $3 AND CASE WHEN y % 2 = 1 THEN x > $2 ELSE x < $2 END))
+	 ORDER BY y ASC, CASE WHEN y % 2 = 1 THEN x ELSE -x END ASC
+	 LIMIT 1`
+
+	queryCountTreesByHeight = `SELECT height, COUNT(*) FROM trees WHERE estate_id = $1 GROUP BY height`
+
+	// *** Route ***
+	queryGetRouteStepsByEstateID = `SELECT
+		step, x, y, direction, step_distance, current_distance, altitude
+	 FROM route_steps
+	 WHERE estate_id = $1
+	 ORDER BY step`
+
+	// steps after the cursor step which travel overlaps [$3, $4] distance
+	queryGetRouteStepsPage = `SELECT
+		step, x, y, direction, step_distance, current_distance, altitude
+	 FROM route_steps
+	 WHERE estate_id = $1
+		AND step > $2
+		AND current_distance >= $3
+		AND current_distance - step_distance <= $4
+	 ORDER BY step
+	 LIMIT $5`
+
+	queryDeleteRouteSteps = `DELETE FROM route_steps WHERE estate_id = $1`
+
+	tableRouteSteps = "route_steps"
 )
 
+// ErrEstateHasTrees is returned when deleting estate which trees are not deleted along
+var ErrEstateHasTrees = fmt.Errorf("%w: estate still has trees", ErrConflict)
+
 // *** Estate ***
 func (rp *Repository) GetEstateByID(ctx context.Context, estateID string) (Estate, error) {
+	return scanEstate(rp.conn().QueryRowContext(ctx, queryGetEstateByID, estateID))
+}
+
+// LockEstate read the estate and lock it until the transaction ends, whoever
+// lock the same estate meanwhile wait for it. Only meaningful within WithTx.
+func (rp *Repository) LockEstate(ctx context.Context, estateID string) (Estate, error) {
+	return scanEstate(rp.conn().QueryRowContext(ctx, queryLockEstate, estateID))
+}
+
+// GetEstatesPage return at most limit estates ordered by creation time, only
+// estates after the estate after (the last one of the previous page), nil for the first page.
+func (rp *Repository) GetEstatesPage(ctx context.Context, after *Estate, newestFirst bool, limit int) ([]Estate, error) {
+	query := queryGetEstatesPageOldestFirst
+	if newestFirst {
+		query = queryGetEstatesPageNewestFirst
+	}
+
+	var afterCreatedAt *time.Time
+	var afterEstateID string
+	if after != nil {
+		afterCreatedAt, afterEstateID = &after.CreatedAt, after.ID
+	}
+
+	rows, err := rp.conn().QueryContext(ctx, query, afterCreatedAt, afterEstateID, limit)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	estates := make([]Estate, 0)
+	for rows.Next() {
+		estate, err := scanEstate(rows)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		estates = append(estates, estate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+
+	return estates, nil
+}
+
+// scanEstate read the estate columns in the order every estate query select them
+func scanEstate(row interface{ Scan(dest ...any) error }) (Estate, error) {
 	var estate Estate
+	var boundary string
 
-	err := rp.db.QueryRowContext(ctx, queryGetEstateByID, estateID).Scan(
+	err := row.Scan(
 		&estate.ID,
 		&estate.Width,
 		&estate.Length,
@@ -59,67 +217,249 @@ func (rp *Repository) GetEstateByID(ctx context.Context, estateID string) (Estat
 		&estate.Median,
 		&estate.PatrolDistance,
 		&estate.PatrolRoute,
+		&estate.OriginLat,
+		&estate.OriginLon,
+		&estate.Bearing,
+		&estate.StatsStatus,
+		&estate.Drone.PlotSize,
+		&estate.Drone.MonitorClearance,
+		&estate.Drone.CruiseAltitudeMin,
+		&estate.Drone.MaxAltitude,
+		&estate.ZoneCount,
+		&boundary,
+		&estate.CreatedAt,
 	)
+	if err != nil {
+		return Estate{}, dbError(err)
+	}
 
-	return estate, err
+	estate.Boundary, err = decodePolygon(boundary)
+	if err != nil {
+		return Estate{}, fmt.Errorf("%w: estate %s boundary: %w", ErrInvalid, estate.ID, err)
+	}
+
+	return estate, nil
 }
 
-func (rp *Repository) InsertEstate(ctx context.Context, width, length int) (string, error) {
+func (rp *Repository) InsertEstate(ctx context.Context, estate Estate) (string, error) {
 	uuidEstateID, err := uuidgen.NewRandom()
 	if err != nil {
 		return "", err
 	}
 
-	_, err = rp.db.ExecContext(
+	_, err = rp.conn().ExecContext(
 		ctx,
 		queryInsertEstate,
 		uuidEstateID.String(),
-		width,
-		length,
+		estate.Width,
+		estate.Length,
+		estate.OriginLat,
+		estate.OriginLon,
+		estate.Bearing,
+		estate.Drone.PlotSize,
+		estate.Drone.MonitorClearance,
+		estate.Drone.CruiseAltitudeMin,
+		estate.Drone.MaxAltitude,
+		encodePolygon(estate.Boundary),
 	)
 
-	return uuidEstateID.String(), err
+	return uuidEstateID.String(), dbError(err)
 }
 
+// UpdateEstateLayout store the estate dimensions and boundary, where it lies on earth and the drone
+// patrolling it, trees outside of the new dimensions must be deleted beforehand.
+func (rp *Repository) UpdateEstateLayout(ctx context.Context, estate Estate) error {
+	_, err := rp.conn().ExecContext(
+		ctx,
+		queryUpdateEstateLayout,
+		estate.ID,
+		estate.Width,
+		estate.Length,
+		estate.OriginLat,
+		estate.OriginLon,
+		estate.Bearing,
+		estate.Drone.PlotSize,
+		estate.Drone.MonitorClearance,
+		estate.Drone.CruiseAltitudeMin,
+		estate.Drone.MaxAltitude,
+		encodePolygon(estate.Boundary),
+	)
+
+	return dbError(err)
+}
+
+// DeleteEstate delete the estate, its patrol route, zones, pending and dead jobs in a single transaction, its
+// trees too when cascade. ErrEstateHasTrees is returned when the estate has trees but not cascade.
+func (rp *Repository) DeleteEstate(ctx context.Context, estateID string, cascade bool) error {
+	return rp.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, queryDeleteRouteSteps, estateID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, queryDeleteJobsByEstateID, estateID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, queryDeleteDeadJobsByEstateID, estateID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, queryDeleteZonesByEstateID, estateID)
+		if err != nil {
+			return err
+		}
+
+		if cascade {
+			_, err = tx.ExecContext(ctx, queryDeleteTreesByEstateID, estateID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, queryDeleteEstate, estateID)
+		// trees still refer to the estate
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqCodeForeignKeyViolation {
+			return ErrEstateHasTrees
+		}
+
+		return err
+	})
+}
+
+// UpdateEstate store the estate stats and replace its patrol route steps in a single transaction.
 func (rp *Repository) UpdateEstate(
 	ctx context.Context,
 	estateID string,
 	count, min, max, median, patrolDistance int,
-	patrolRoute string,
+	patrolRoute []RouteStep,
 ) error {
-	_, err := rp.db.ExecContext(
-		ctx,
-		queryUpdateEstateStats,
-		estateID,
-		count,
-		min,
-		max,
-		median,
-		patrolDistance,
-		patrolRoute,
-	)
+	return rp.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(
+			ctx,
+			queryUpdateEstateStats,
+			estateID,
+			count,
+			min,
+			max,
+			median,
+			patrolDistance,
+		)
+		if err != nil {
+			return err
+		}
 
-	return err
+		_, err = tx.ExecContext(ctx, queryDeleteRouteSteps, estateID)
+		if err != nil {
+			return err
+		}
+
+		if len(patrolRoute) > 0 {
+			return copyRouteSteps(ctx, tx, estateID, patrolRoute)
+		}
+
+		return nil
+	})
+}
+
+// UpdateRouteSteps replace the patrol route steps of the estate in a single transaction,
+// its stats are left as they are.
+func (rp *Repository) UpdateRouteSteps(ctx context.Context, estateID string, patrolRoute []RouteStep) error {
+	return rp.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, queryDeleteRouteSteps, estateID)
+		if err != nil {
+			return err
+		}
+
+		if len(patrolRoute) > 0 {
+			return copyRouteSteps(ctx, tx, estateID, patrolRoute)
+		}
+
+		return nil
+	})
 }
 
 // *** Tree ***
 func (rp *Repository) GetAllTreesInEstate(ctx context.Context, estateID string) ([]Tree, error) {
 	trees := make([]Tree, 0)
 
-	rows, err := rp.db.QueryContext(ctx, queryGetTreeByEstateID, estateID)
+	rows, err := rp.conn().QueryContext(ctx, queryGetTreeByEstateID, estateID)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tree Tree
+		if err := rows.Scan(&tree.ID, &tree.EstateID, &tree.X, &tree.Y, &tree.Height); err != nil {
+			return nil, dbError(err)
+		}
+		trees = append(trees, tree)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+
+	return trees, nil
+}
+
+func (rp *Repository) GetTreeByID(ctx context.Context, estateID, treeID string) (Tree, error) {
+	var tree Tree
+
+	err := rp.conn().QueryRowContext(ctx, queryGetTreeByID, estateID, treeID).Scan(
+		&tree.ID,
+		&tree.EstateID,
+		&tree.X,
+		&tree.Y,
+		&tree.Height,
+	)
+
+	return tree, dbError(err)
+}
+
+// GetTreesPage return at most limit trees matching the filter ordered row by row,
+// only trees after plot (afterX, afterY), (0,0) for the first page.
+func (rp *Repository) GetTreesPage(
+	ctx context.Context,
+	estateID string,
+	filter TreeFilter,
+	afterX, afterY, limit int,
+) ([]Tree, error) {
+	rows, err := rp.conn().QueryContext(
+		ctx,
+		queryGetTreesPage,
+		estateID,
+		filter.FromX,
+		filter.ToX,
+		filter.FromY,
+		filter.ToY,
+		filter.MinHeight,
+		filter.MaxHeight,
+		afterX,
+		afterY,
+		limit,
+	)
 	if err != nil {
-		return nil, err
+		return nil, dbError(err)
 	}
 	defer rows.Close()
 
+	trees := make([]Tree, 0)
 	for rows.Next() {
 		var tree Tree
 		if err := rows.Scan(&tree.ID, &tree.EstateID, &tree.X, &tree.Y, &tree.Height); err != nil {
-			return nil, err
+			return nil, dbError(err)
 		}
 		trees = append(trees, tree)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+
 	return trees, nil
 }
 
@@ -129,7 +469,7 @@ func (rp *Repository) InsertTree(ctx context.Context, estateID string, x, y, hei
 		return "", err
 	}
 
-	_, err = rp.db.ExecContext(
+	_, err = rp.conn().ExecContext(
 		ctx,
 		queryInsertTree,
 		estateID,
@@ -142,20 +482,234 @@ func (rp *Repository) InsertTree(ctx context.Context, estateID string, x, y, hei
 	// extra steps for checking specific db error
 	if err != nil {
 		// Check if the error is due to a unique constraint violation
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqCodeUniqueViolation {
 			// Tree already exists at the specified location, return an error
-			return "", errors.New("tree already exists at the specified location")
+			return "", fmt.Errorf("%w: tree already exists at the specified location", ErrConflict)
 		}
 
-		// Return other errors as is
-		return "", err
+		return "", dbError(err)
 	}
 
 	return uuidTreeID.String(), nil
 }
 
+// InsertTrees stores all the trees in a single transaction, either all of them or none.
+// The returned tree IDs are in the same order as the given trees.
+func (rp *Repository) InsertTrees(ctx context.Context, estateID string, trees []Tree) (treeIDs []string, err error) {
+	err = rp.inTx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, queryInsertTree)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		treeIDs = make([]string, 0, len(trees))
+		for _, tree := range trees {
+			uuidTreeID, err := uuidgen.NewRandom()
+			if err != nil {
+				return err
+			}
+
+			_, err = stmt.ExecContext(
+				ctx,
+				estateID,
+				uuidTreeID.String(),
+				tree.X,
+				tree.Y,
+				tree.Height,
+			)
+			if err != nil {
+				// Check if the error is due to a unique constraint violation
+				if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqCodeUniqueViolation {
+					return fmt.Errorf("%w: tree already exists at the specified location (%d,%d)", ErrConflict, tree.X, tree.Y)
+				}
+
+				return err
+			}
+
+			treeIDs = append(treeIDs, uuidTreeID.String())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return treeIDs, nil
+}
+
+func (rp *Repository) UpdateTreeHeight(ctx context.Context, treeID string, height int) error {
+	_, err := rp.conn().ExecContext(ctx, queryUpdateTreeHeight, treeID, height)
+
+	return dbError(err)
+}
+
 func (rp *Repository) DeleteTree(ctx context.Context, treeID string) error {
-	_, err := rp.db.ExecContext(ctx, queryDeleteTree, treeID)
+	_, err := rp.conn().ExecContext(ctx, queryDeleteTree, treeID)
+
+	return dbError(err)
+}
+
+// DeleteTreesOutside delete the trees of the estate beyond column width or row length
+func (rp *Repository) DeleteTreesOutside(ctx context.Context, estateID string, width, length int) error {
+	_, err := rp.conn().ExecContext(ctx, queryDeleteTreesOutside, estateID, width, length)
+
+	return dbError(err)
+}
+
+// GetPatrolNeighbourTrees return the trees right before and after plot (x,y) in the
+// drone patrol order, nil when there is no such tree.
+func (rp *Repository) GetPatrolNeighbourTrees(ctx context.Context, estateID string, x, y int) (prev, next *Tree, err error) {
+	prev, err = rp.getPatrolTree(ctx, queryGetPreviousPatrolTree, estateID, x, y)
+	if err != nil {
+		return nil, nil, dbError(err)
+	}
+
+	next, err = rp.getPatrolTree(ctx, queryGetNextPatrolTree, estateID, x, y)
+	if err != nil {
+		return nil, nil, dbError(err)
+	}
+
+	return prev, next, nil
+}
+
+func (rp *Repository) getPatrolTree(ctx context.Context, query, estateID string, x, y int) (*Tree, error) {
+	var tree Tree
+
+	err := rp.conn().QueryRowContext(ctx, query, estateID, x, y).Scan(
+		&tree.ID,
+		&tree.EstateID,
+		&tree.X,
+		&tree.Y,
+		&tree.Height,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return &tree, nil
+}
+
+// GetTreeHeightCounts return number of trees in the estate for each tree height.
+func (rp *Repository) GetTreeHeightCounts(ctx context.Context, estateID string) (map[int]int, error) {
+	heightCounts := make(map[int]int)
+
+	rows, err := rp.conn().QueryContext(ctx, queryCountTreesByHeight, estateID)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var height, count int
+		if err := rows.Scan(&height, &count); err != nil {
+			return nil, dbError(err)
+		}
+		heightCounts[height] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+
+	return heightCounts, nil
+}
+
+// *** Route ***
+func (rp *Repository) GetRouteSteps(ctx context.Context, estateID string) ([]RouteStep, error) {
+	rows, err := rp.conn().QueryContext(ctx, queryGetRouteStepsByEstateID, estateID)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return scanRouteSteps(rows)
+}
+
+// GetRouteStepsPage return at most limit steps after the step afterStep,
+// only steps which travel overlaps the distance range [fromDistance, toDistance].
+func (rp *Repository) GetRouteStepsPage(
+	ctx context.Context,
+	estateID string,
+	afterStep, fromDistance, toDistance, limit int,
+) ([]RouteStep, error) {
+	rows, err := rp.conn().QueryContext(
+		ctx,
+		queryGetRouteStepsPage,
+		estateID,
+		afterStep,
+		fromDistance,
+		toDistance,
+		limit,
+	)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return scanRouteSteps(rows)
+}
+
+func scanRouteSteps(rows *sql.Rows) ([]RouteStep, error) {
+	defer rows.Close()
+
+	steps := make([]RouteStep, 0)
+	for rows.Next() {
+		var step RouteStep
+		if err := rows.Scan(
+			&step.Step,
+			&step.X,
+			&step.Y,
+			&step.Direction,
+			&step.StepDistance,
+			&step.CurrentDistance,
+			&step.Altitude,
+		); err != nil {
+			return nil, dbError(err)
+		}
+		steps = append(steps, step)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+
+	return steps, nil
+}
+
+// copyRouteSteps bulk load the steps with COPY, a patrol route of big estate
+// has hundred thousands of steps.
+func copyRouteSteps(ctx context.Context, tx *sql.Tx, estateID string, steps []RouteStep) error {
+	stmt, err := tx.PrepareContext(
+		ctx,
+		pq.CopyIn(tableRouteSteps, "estate_id", "step", "x", "y", "direction", "step_distance", "current_distance", "altitude"),
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, step := range steps {
+		_, err = stmt.ExecContext(
+			ctx,
+			estateID,
+			step.Step,
+			step.X,
+			step.Y,
+			step.Direction,
+			step.StepDistance,
+			step.CurrentDistance,
+			step.Altitude,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// flush the buffered rows
+	_, err = stmt.ExecContext(ctx)
 
 	return err
 }
@@ -3,8 +3,11 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
@@ -21,9 +24,45 @@ func TestGetEstateByID(t *testing.T) {
 		{
 			name: "Valid estate ID",
 			expectedEstate: Estate{
-				ID:     "estate_id_value",
-				Width:  10,
-				Length: 20,
+				ID:        "estate_id_value",
+				Width:     10,
+				Length:    20,
+				CreatedAt: time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Geo-referenced estate",
+			expectedEstate: Estate{
+				ID:        "estate_id_value",
+				Width:     10,
+				Length:    20,
+				OriginLat: floatPtr(-6.2),
+				OriginLon: floatPtr(106.8),
+				Bearing:   45,
+				CreatedAt: time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Estate with drone profile",
+			expectedEstate: Estate{
+				ID:        "estate_id_value",
+				Width:     10,
+				Length:    20,
+				Drone:     DroneProfile{PlotSize: 5, MonitorClearance: 2, CruiseAltitudeMin: 15, MaxAltitude: 60},
+				CreatedAt: time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "Estate with boundary",
+			expectedEstate: Estate{
+				ID:        "estate_id_value",
+				Width:     10,
+				Length:    20,
+				Boundary:  []Plot{{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 20}},
+				CreatedAt: time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
 			},
 			expectedErr: nil,
 		},
@@ -58,6 +97,8 @@ func TestGetEstateByID(t *testing.T) {
 					sqlmock.NewRows(
 						[]string{
 							"estate_id", "width", "length", "count", "min", "max", "median", "patrol_distance", "patrol_route",
+							"origin_lat", "origin_lon", "bearing", "stats_status",
+							"plot_size", "monitor_clearance", "cruise_altitude_min", "max_altitude", "zone_count", "boundary", "created_at",
 						}).
 						AddRow(
 							tc.expectedEstate.ID,
@@ -69,6 +110,17 @@ func TestGetEstateByID(t *testing.T) {
 							tc.expectedEstate.Median,
 							tc.expectedEstate.PatrolDistance,
 							tc.expectedEstate.PatrolRoute,
+							tc.expectedEstate.OriginLat,
+							tc.expectedEstate.OriginLon,
+							tc.expectedEstate.Bearing,
+							tc.expectedEstate.StatsStatus,
+							tc.expectedEstate.Drone.PlotSize,
+							tc.expectedEstate.Drone.MonitorClearance,
+							tc.expectedEstate.Drone.CruiseAltitudeMin,
+							tc.expectedEstate.Drone.MaxAltitude,
+							tc.expectedEstate.ZoneCount,
+							encodePolygon(tc.expectedEstate.Boundary),
+							tc.expectedEstate.CreatedAt,
 						),
 				)
 			}
@@ -77,7 +129,10 @@ func TestGetEstateByID(t *testing.T) {
 			estate, err := repo.GetEstateByID(context.Background(), tc.expectedEstate.ID)
 
 			// Verify the result
-			require.Equal(t, tc.expectedErr, err)
+			require.ErrorIs(t, err, tc.expectedErr)
+			if errors.Is(tc.expectedErr, sql.ErrNoRows) {
+				require.ErrorIs(t, err, ErrNotFound)
+			}
 			require.Equal(t, tc.expectedEstate, estate)
 
 			// Make sure all expectations were met
@@ -86,11 +141,178 @@ func TestGetEstateByID(t *testing.T) {
 	}
 }
 
+func TestLockEstate(t *testing.T) {
+	tests := []struct {
+		name           string
+		expectedEstate Estate
+		expectedErr    error
+	}{
+		{
+			name: "Valid estate ID",
+			expectedEstate: Estate{
+				ID:        "estate_id_value",
+				Width:     10,
+				Length:    20,
+				Count:     3,
+				CreatedAt: time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+			},
+			expectedErr: nil,
+		},
+		{
+			name:           "Estate not found",
+			expectedEstate: Estate{ID: "estate_id_value"},
+			expectedErr:    sql.ErrNoRows,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			mock.ExpectBegin()
+			query := mock.ExpectQuery(`SELECT .* FROM estates WHERE estate_id = \$1 FOR NO KEY UPDATE`).WithArgs("estate_id_value")
+			if tc.expectedErr != nil {
+				query.WillReturnError(tc.expectedErr)
+				mock.ExpectRollback()
+			} else {
+				estate := tc.expectedEstate
+				query.WillReturnRows(sqlmock.NewRows(estateColumns()).AddRow(
+					estate.ID, estate.Width, estate.Length, estate.Count, estate.Min, estate.Max, estate.Median,
+					estate.PatrolDistance, estate.PatrolRoute, estate.OriginLat, estate.OriginLon, estate.Bearing, estate.StatsStatus,
+					estate.Drone.PlotSize, estate.Drone.MonitorClearance, estate.Drone.CruiseAltitudeMin, estate.Drone.MaxAltitude, estate.ZoneCount, encodePolygon(estate.Boundary), estate.CreatedAt,
+				))
+				mock.ExpectCommit()
+			}
+
+			// Call the function under test
+			var estate Estate
+			err = repo.WithTx(context.Background(), func(txRepo Repositorier) error {
+				var err error
+				estate, err = txRepo.LockEstate(context.Background(), "estate_id_value")
+				return err
+			})
+
+			// Verify the result
+			require.ErrorIs(t, err, tc.expectedErr)
+			if errors.Is(tc.expectedErr, sql.ErrNoRows) {
+				require.ErrorIs(t, err, ErrNotFound)
+			}
+			if tc.expectedErr == nil {
+				require.Equal(t, tc.expectedEstate, estate)
+			}
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func estateColumns() []string {
+	return []string{
+		"estate_id", "width", "length", "count", "min", "max", "median", "patrol_distance", "patrol_route",
+		"origin_lat", "origin_lon", "bearing", "stats_status",
+		"plot_size", "monitor_clearance", "cruise_altitude_min", "max_altitude", "zone_count", "boundary", "created_at",
+	}
+}
+
+func TestGetEstatesPage(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	estates := []Estate{
+		{ID: "estate_id_1", Width: 10, Length: 20, CreatedAt: createdAt},
+		{ID: "estate_id_2", Width: 5, Length: 5, Count: 2, Min: 3, Max: 8, Median: 5, PatrolDistance: 280, CreatedAt: createdAt.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name            string
+		after           *Estate
+		newestFirst     bool
+		queryPattern    string
+		expectedArgs    []driver.Value
+		expectedEstates []Estate
+		expectedErr     error
+	}{
+		{
+			name:            "First page oldest first",
+			after:           nil,
+			queryPattern:    `SELECT .* FROM estates .* ORDER BY created_at ASC, estate_id ASC LIMIT \$3`,
+			expectedArgs:    []driver.Value{nil, "", 2},
+			expectedEstates: estates,
+			expectedErr:     nil,
+		},
+		{
+			name:            "Next page newest first",
+			after:           &Estate{ID: "estate_id_3", CreatedAt: createdAt.Add(2 * time.Hour)},
+			newestFirst:     true,
+			queryPattern:    `SELECT .* FROM estates .* ORDER BY created_at DESC, estate_id DESC LIMIT \$3`,
+			expectedArgs:    []driver.Value{createdAt.Add(2 * time.Hour), "estate_id_3", 2},
+			expectedEstates: []Estate{estates[1], estates[0]},
+			expectedErr:     nil,
+		},
+		{
+			name:            "Database error",
+			after:           nil,
+			queryPattern:    `SELECT .* FROM estates .* ORDER BY created_at ASC, estate_id ASC LIMIT \$3`,
+			expectedArgs:    []driver.Value{nil, "", 2},
+			expectedEstates: nil,
+			expectedErr:     errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			if tc.expectedErr != nil {
+				mock.ExpectQuery(tc.queryPattern).WithArgs(tc.expectedArgs...).WillReturnError(tc.expectedErr)
+			} else {
+				rows := sqlmock.NewRows(estateColumns())
+				for _, estate := range tc.expectedEstates {
+					rows.AddRow(
+						estate.ID, estate.Width, estate.Length, estate.Count, estate.Min, estate.Max, estate.Median,
+						estate.PatrolDistance, estate.PatrolRoute, estate.OriginLat, estate.OriginLon, estate.Bearing, estate.StatsStatus,
+						estate.Drone.PlotSize, estate.Drone.MonitorClearance, estate.Drone.CruiseAltitudeMin, estate.Drone.MaxAltitude, estate.ZoneCount, encodePolygon(estate.Boundary), estate.CreatedAt,
+					)
+				}
+				mock.ExpectQuery(tc.queryPattern).WithArgs(tc.expectedArgs...).WillReturnRows(rows)
+			}
+
+			// Call the function under test
+			estates, err := repo.GetEstatesPage(context.Background(), tc.after, tc.newestFirst, 2)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+			require.Equal(t, tc.expectedEstates, estates)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestInsertEstate(t *testing.T) {
 	tests := []struct {
 		name        string
 		width       int
 		length      int
+		originLat   *float64
+		originLon   *float64
+		bearing     float64
+		drone       DroneProfile
+		boundary    []Plot
 		expectedID  string
 		expectedErr error
 	}{
@@ -101,6 +323,32 @@ func TestInsertEstate(t *testing.T) {
 			expectedID:  "lets-pretend-this-is-uuid",
 			expectedErr: nil,
 		},
+		{
+			name:        "Geo-referenced estate",
+			width:       10,
+			length:      20,
+			originLat:   floatPtr(-6.2),
+			originLon:   floatPtr(106.8),
+			bearing:     45,
+			expectedID:  "lets-pretend-this-is-uuid",
+			expectedErr: nil,
+		},
+		{
+			name:        "Estate with drone profile",
+			width:       10,
+			length:      20,
+			drone:       DroneProfile{PlotSize: 5, MonitorClearance: 2, CruiseAltitudeMin: 15, MaxAltitude: 60},
+			expectedID:  "lets-pretend-this-is-uuid",
+			expectedErr: nil,
+		},
+		{
+			name:        "Estate with boundary",
+			width:       10,
+			length:      20,
+			boundary:    []Plot{{X: 1, Y: 1}, {X: 10, Y: 1}, {X: 5, Y: 20}},
+			expectedID:  "lets-pretend-this-is-uuid",
+			expectedErr: nil,
+		},
 		{
 			name:        "Zero width",
 			width:       0,
@@ -149,15 +397,26 @@ func TestInsertEstate(t *testing.T) {
 				db: dbmock,
 			}
 
-			queryPattern := `INSERT INTO estates \(estate_id, width, length\) VALUES \(\$1, \$2, \$3\)`
+			queryPattern := `INSERT INTO estates \(estate_id, width, length, origin_lat, origin_lon, bearing, plot_size, monitor_clearance, cruise_altitude_min, max_altitude, boundary\) VALUES \(\$1, .*, \$11\)`
+			exec := mock.ExpectExec(queryPattern).
+				WithArgs(sqlmock.AnyArg(), tc.width, tc.length, tc.originLat, tc.originLon, tc.bearing,
+					tc.drone.PlotSize, tc.drone.MonitorClearance, tc.drone.CruiseAltitudeMin, tc.drone.MaxAltitude, encodePolygon(tc.boundary))
 			if tc.expectedErr != nil {
-				mock.ExpectExec(queryPattern).WithArgs(sqlmock.AnyArg(), tc.width, tc.length).WillReturnError(tc.expectedErr)
+				exec.WillReturnError(tc.expectedErr)
 			} else {
-				mock.ExpectExec(queryPattern).WithArgs(sqlmock.AnyArg(), tc.width, tc.length).WillReturnResult(sqlmock.NewResult(0, 1))
+				exec.WillReturnResult(sqlmock.NewResult(0, 1))
 			}
 
 			// Call the function under test
-			estateID, err := repo.InsertEstate(context.Background(), tc.width, tc.length)
+			estateID, err := repo.InsertEstate(context.Background(), Estate{
+				Width:     tc.width,
+				Length:    tc.length,
+				OriginLat: tc.originLat,
+				OriginLon: tc.originLon,
+				Bearing:   tc.bearing,
+				Drone:     tc.drone,
+				Boundary:  tc.boundary,
+			})
 
 			// Verify the result
 			require.Equal(t, tc.expectedErr, err)
@@ -170,7 +429,151 @@ func TestInsertEstate(t *testing.T) {
 	}
 }
 
+func TestUpdateEstateLayout(t *testing.T) {
+	tests := []struct {
+		name        string
+		estate      Estate
+		expectedErr error
+	}{
+		{
+			name:        "Valid estate",
+			estate:      Estate{ID: "estate_id_value", Width: 8, Length: 4, OriginLat: floatPtr(-6.2), OriginLon: floatPtr(106.8), Bearing: 45, Drone: DefaultDroneProfile()},
+			expectedErr: nil,
+		},
+		{
+			name:        "Estate with boundary",
+			estate:      Estate{ID: "estate_id_value", Width: 8, Length: 4, Drone: DefaultDroneProfile(), Boundary: []Plot{{X: 1, Y: 1}, {X: 8, Y: 1}, {X: 4, Y: 4}}},
+			expectedErr: nil,
+		},
+		{
+			name:        "Database error",
+			estate:      Estate{ID: "estate_id_value", Width: 8, Length: 4},
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			queryPattern := `UPDATE estates SET width = \$2, length = \$3, origin_lat = \$4, origin_lon = \$5, bearing = \$6, ` +
+				`plot_size = \$7, monitor_clearance = \$8, cruise_altitude_min = \$9, max_altitude = \$10, boundary = \$11`
+			exec := mock.ExpectExec(queryPattern).
+				WithArgs(tc.estate.ID, tc.estate.Width, tc.estate.Length, tc.estate.OriginLat, tc.estate.OriginLon, tc.estate.Bearing,
+					tc.estate.Drone.PlotSize, tc.estate.Drone.MonitorClearance, tc.estate.Drone.CruiseAltitudeMin, tc.estate.Drone.MaxAltitude,
+					encodePolygon(tc.estate.Boundary))
+			if tc.expectedErr != nil {
+				exec.WillReturnError(tc.expectedErr)
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			// Call the function under test
+			err = repo.UpdateEstateLayout(context.Background(), tc.estate)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteEstate(t *testing.T) {
+	tests := []struct {
+		name          string
+		cascade       bool
+		mockDeleteErr error
+		expectedErr   error
+	}{
+		{
+			name:        "Estate without trees",
+			cascade:     false,
+			expectedErr: nil,
+		},
+		{
+			name:        "Estate with its trees",
+			cascade:     true,
+			expectedErr: nil,
+		},
+		{
+			name:          "Estate still has trees",
+			cascade:       false,
+			mockDeleteErr: &pq.Error{Code: "23503"},
+			expectedErr:   ErrEstateHasTrees,
+		},
+		{
+			name:          "Database error",
+			cascade:       true,
+			mockDeleteErr: errors.New("database error"),
+			expectedErr:   errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`DELETE FROM route_steps WHERE estate_id = \$1`).
+				WithArgs("estate_id_value").
+				WillReturnResult(sqlmock.NewResult(0, 10))
+			mock.ExpectExec(`DELETE FROM jobs WHERE estate_id = \$1`).
+				WithArgs("estate_id_value").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`DELETE FROM dead_jobs WHERE estate_id = \$1`).
+				WithArgs("estate_id_value").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec(`DELETE FROM zones WHERE estate_id = \$1`).
+				WithArgs("estate_id_value").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			if tc.cascade {
+				mock.ExpectExec(`DELETE FROM trees WHERE estate_id = \$1`).
+					WithArgs("estate_id_value").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			}
+			exec := mock.ExpectExec(`DELETE FROM estates WHERE estate_id = \$1`).WithArgs("estate_id_value")
+			if tc.mockDeleteErr != nil {
+				exec.WillReturnError(tc.mockDeleteErr)
+				mock.ExpectRollback()
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			// Call the function under test
+			err = repo.DeleteEstate(context.Background(), "estate_id_value", tc.cascade)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestUpdateEstate(t *testing.T) {
+	patrolRoute := []RouteStep{
+		{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 10},
+		{Step: 2, X: 2, Y: 1, Direction: "vu", StepDistance: 6, CurrentDistance: 16, Altitude: 6},
+	}
+
 	tests := []struct {
 		name           string
 		estateID       string
@@ -179,7 +582,9 @@ func TestUpdateEstate(t *testing.T) {
 		max            int
 		median         int
 		patrolDistance int
-		patrolRoute    string
+		patrolRoute    []RouteStep
+		mockUpdateErr  error
+		mockCopyErr    error
 		expectedErr    error
 	}{
 		{
@@ -190,7 +595,18 @@ func TestUpdateEstate(t *testing.T) {
 			max:            10,
 			median:         5,
 			patrolDistance: 100,
-			patrolRoute:    "route",
+			patrolRoute:    patrolRoute,
+			expectedErr:    nil,
+		},
+		{
+			name:           "Valid parameters without route",
+			estateID:       "estate_id_value",
+			count:          5,
+			min:            1,
+			max:            10,
+			median:         5,
+			patrolDistance: 100,
+			patrolRoute:    nil,
 			expectedErr:    nil,
 		},
 		{
@@ -201,7 +617,8 @@ func TestUpdateEstate(t *testing.T) {
 			max:            10,
 			median:         5,
 			patrolDistance: 100,
-			patrolRoute:    "route",
+			patrolRoute:    patrolRoute,
+			mockUpdateErr:  errors.New("estate ID is empty"),
 			expectedErr:    errors.New("estate ID is empty"),
 		},
 		{
@@ -212,7 +629,20 @@ func TestUpdateEstate(t *testing.T) {
 			max:            10,
 			median:         5,
 			patrolDistance: 100,
-			patrolRoute:    "route",
+			patrolRoute:    patrolRoute,
+			mockUpdateErr:  errors.New("database error"),
+			expectedErr:    errors.New("database error"),
+		},
+		{
+			name:           "Database error on route steps",
+			estateID:       "estate_id_value",
+			count:          5,
+			min:            1,
+			max:            10,
+			median:         5,
+			patrolDistance: 100,
+			patrolRoute:    patrolRoute,
+			mockCopyErr:    errors.New("database error"),
 			expectedErr:    errors.New("database error"),
 		},
 	}
@@ -236,19 +666,46 @@ func TestUpdateEstate(t *testing.T) {
 					max = \$4,
 					median = \$5,
 					patrol_distance = \$6,
-					patrol_route = \$7,
+					patrol_route = '',
 					updated_at = now\(\)
 				WHERE
 					estate_id = \$1
 			`
-			if tc.expectedErr != nil {
+			mock.ExpectBegin()
+			if tc.mockUpdateErr != nil {
 				mock.ExpectExec(queryPattern).
-					WithArgs(tc.estateID, tc.count, tc.min, tc.max, tc.median, tc.patrolDistance, tc.patrolRoute).
-					WillReturnError(tc.expectedErr)
+					WithArgs(tc.estateID, tc.count, tc.min, tc.max, tc.median, tc.patrolDistance).
+					WillReturnError(tc.mockUpdateErr)
+				mock.ExpectRollback()
 			} else {
 				mock.ExpectExec(queryPattern).
-					WithArgs(tc.estateID, tc.count, tc.min, tc.max, tc.median, tc.patrolDistance, tc.patrolRoute).
+					WithArgs(tc.estateID, tc.count, tc.min, tc.max, tc.median, tc.patrolDistance).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`DELETE FROM route_steps WHERE estate_id = \$1`).
+					WithArgs(tc.estateID).
 					WillReturnResult(sqlmock.NewResult(0, 1))
+
+				if len(tc.patrolRoute) > 0 {
+					prepare := mock.ExpectPrepare(`COPY "route_steps"`)
+					for idx, step := range tc.patrolRoute {
+						exec := prepare.ExpectExec().
+							WithArgs(tc.estateID, step.Step, step.X, step.Y, step.Direction, step.StepDistance, step.CurrentDistance, step.Altitude)
+						if tc.mockCopyErr != nil && idx == len(tc.patrolRoute)-1 {
+							exec.WillReturnError(tc.mockCopyErr)
+						} else {
+							exec.WillReturnResult(sqlmock.NewResult(0, 1))
+						}
+					}
+					if tc.mockCopyErr == nil {
+						prepare.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, int64(len(tc.patrolRoute))))
+					}
+				}
+
+				if tc.mockCopyErr != nil {
+					mock.ExpectRollback()
+				} else {
+					mock.ExpectCommit()
+				}
 			}
 
 			// Call the function under test
@@ -272,34 +729,41 @@ func TestUpdateEstate(t *testing.T) {
 	}
 }
 
-func TestGetAllTreesInEstate(t *testing.T) {
+func TestUpdateRouteSteps(t *testing.T) {
+	patrolRoute := []RouteStep{
+		{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 10},
+		{Step: 2, X: 2, Y: 1, Direction: "vu", StepDistance: 6, CurrentDistance: 16, Altitude: 6},
+	}
+
 	tests := []struct {
 		name          string
-		estateID      string
-		expectedTrees []Tree
+		patrolRoute   []RouteStep
+		mockDeleteErr error
+		mockCopyErr   error
 		expectedErr   error
 	}{
 		{
-			name:     "Valid estate ID with trees",
-			estateID: "estate_id_value",
-			expectedTrees: []Tree{
-				{ID: "tree_id_1", EstateID: "estate_id_value", X: 1, Y: 2, Height: 10},
-				{ID: "tree_id_2", EstateID: "estate_id_value", X: 3, Y: 4, Height: 12},
-			},
+			name:        "Valid parameters",
+			patrolRoute: patrolRoute,
 			expectedErr: nil,
 		},
 		{
-			name:          "Invalid estate ID",
-			estateID:      "",
-			expectedTrees: nil,
-			expectedErr:   errors.New("estate ID is empty"),
+			name:        "Valid parameters without route",
+			patrolRoute: nil,
+			expectedErr: nil,
 		},
 		{
 			name:          "Database error",
-			estateID:      "estate_id_value",
-			expectedTrees: nil,
+			patrolRoute:   patrolRoute,
+			mockDeleteErr: errors.New("database error"),
 			expectedErr:   errors.New("database error"),
 		},
+		{
+			name:        "Database error on route steps",
+			patrolRoute: patrolRoute,
+			mockCopyErr: errors.New("database error"),
+			expectedErr: errors.New("database error"),
+		},
 	}
 
 	for _, tc := range tests {
@@ -313,13 +777,112 @@ func TestGetAllTreesInEstate(t *testing.T) {
 				db: dbmock,
 			}
 
-			rows := sqlmock.NewRows([]string{"tree_id", "estate_id", "x", "y", "height"})
-			for _, tree := range tc.expectedTrees {
-				rows.AddRow(tree.ID, tree.EstateID, tree.X, tree.Y, tree.Height)
-			}
+			// the stats of the estate are never written
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`DELETE FROM route_steps WHERE estate_id = \$1`).WithArgs("estate_id_value")
+			if tc.mockDeleteErr != nil {
+				exec.WillReturnError(tc.mockDeleteErr)
+				mock.ExpectRollback()
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, 1))
+
+				if len(tc.patrolRoute) > 0 {
+					prepare := mock.ExpectPrepare(`COPY "route_steps"`)
+					for idx, step := range tc.patrolRoute {
+						exec := prepare.ExpectExec().
+							WithArgs("estate_id_value", step.Step, step.X, step.Y, step.Direction, step.StepDistance, step.CurrentDistance, step.Altitude)
+						if tc.mockCopyErr != nil && idx == len(tc.patrolRoute)-1 {
+							exec.WillReturnError(tc.mockCopyErr)
+						} else {
+							exec.WillReturnResult(sqlmock.NewResult(0, 1))
+						}
+					}
+					if tc.mockCopyErr == nil {
+						prepare.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, int64(len(tc.patrolRoute))))
+					}
+				}
+
+				if tc.mockCopyErr != nil {
+					mock.ExpectRollback()
+				} else {
+					mock.ExpectCommit()
+				}
+			}
+
+			// Call the function under test
+			err = repo.UpdateRouteSteps(context.Background(), "estate_id_value", tc.patrolRoute)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetAllTreesInEstate(t *testing.T) {
+	tests := []struct {
+		name          string
+		estateID      string
+		mockRowErr    error
+		expectedTrees []Tree
+		expectedErr   error
+	}{
+		{
+			name:     "Valid estate ID with trees",
+			estateID: "estate_id_value",
+			expectedTrees: []Tree{
+				{ID: "tree_id_1", EstateID: "estate_id_value", X: 1, Y: 2, Height: 10},
+				{ID: "tree_id_2", EstateID: "estate_id_value", X: 3, Y: 4, Height: 12},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:          "Invalid estate ID",
+			estateID:      "",
+			expectedTrees: nil,
+			expectedErr:   errors.New("estate ID is empty"),
+		},
+		{
+			name:          "Database error",
+			estateID:      "estate_id_value",
+			expectedTrees: nil,
+			expectedErr:   errors.New("database error"),
+		},
+		{
+			name:          "Connection lost mid result",
+			estateID:      "estate_id_value",
+			mockRowErr:    errors.New("connection reset"),
+			expectedTrees: nil,
+			expectedErr:   errors.New("connection reset"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			rows := sqlmock.NewRows([]string{"tree_id", "estate_id", "x", "y", "height"})
+			for _, tree := range tc.expectedTrees {
+				rows.AddRow(tree.ID, tree.EstateID, tree.X, tree.Y, tree.Height)
+			}
+			if tc.mockRowErr != nil {
+				// the second tree never arrives, no truncated list is returned
+				rows.AddRow("tree_id_1", tc.estateID, 1, 2, 10).
+					AddRow("tree_id_2", tc.estateID, 3, 4, 12).
+					RowError(1, tc.mockRowErr)
+			}
 
 			queryPattern := `SELECT .* FROM trees WHERE estate_id = \$1`
-			if tc.expectedErr != nil {
+			if tc.expectedErr != nil && tc.mockRowErr == nil {
 				mock.ExpectQuery(queryPattern).WithArgs(tc.estateID).WillReturnError(tc.expectedErr)
 			} else {
 				mock.ExpectQuery(queryPattern).WithArgs(tc.estateID).WillReturnRows(rows)
@@ -338,6 +901,138 @@ func TestGetAllTreesInEstate(t *testing.T) {
 	}
 }
 
+func TestGetTreeByID(t *testing.T) {
+	tests := []struct {
+		name         string
+		estateID     string
+		treeID       string
+		expectedTree Tree
+		expectedErr  error
+	}{
+		{
+			name:         "Valid tree ID",
+			estateID:     "estate_id_value",
+			treeID:       "tree_id_value",
+			expectedTree: Tree{ID: "tree_id_value", EstateID: "estate_id_value", X: 1, Y: 2, Height: 10},
+			expectedErr:  nil,
+		},
+		{
+			name:         "Tree not found in estate",
+			estateID:     "estate_id_value",
+			treeID:       "non_existing_tree_id",
+			expectedTree: Tree{},
+			expectedErr:  sql.ErrNoRows,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			queryPattern := `SELECT .* FROM trees WHERE estate_id = \$1 AND tree_id = \$2`
+			if tc.expectedErr != nil {
+				mock.ExpectQuery(queryPattern).WithArgs(tc.estateID, tc.treeID).WillReturnError(tc.expectedErr)
+			} else {
+				rows := sqlmock.NewRows([]string{"tree_id", "estate_id", "x", "y", "height"}).
+					AddRow(tc.expectedTree.ID, tc.expectedTree.EstateID, tc.expectedTree.X, tc.expectedTree.Y, tc.expectedTree.Height)
+				mock.ExpectQuery(queryPattern).WithArgs(tc.estateID, tc.treeID).WillReturnRows(rows)
+			}
+
+			// Call the function under test
+			tree, err := repo.GetTreeByID(context.Background(), tc.estateID, tc.treeID)
+
+			// Verify the result
+			require.ErrorIs(t, err, tc.expectedErr)
+			if errors.Is(tc.expectedErr, sql.ErrNoRows) {
+				require.ErrorIs(t, err, ErrNotFound)
+			}
+			require.Equal(t, tc.expectedTree, tree)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetTreesPage(t *testing.T) {
+	filter := TreeFilter{FromX: 1, ToX: 10, FromY: 2, ToY: 5, MinHeight: 3, MaxHeight: 30}
+
+	tests := []struct {
+		name          string
+		afterX        int
+		afterY        int
+		expectedTrees []Tree
+		expectedErr   error
+	}{
+		{
+			name:   "First page",
+			afterX: 0,
+			afterY: 0,
+			expectedTrees: []Tree{
+				{ID: "tree_id_1", EstateID: "estate_id_value", X: 4, Y: 2, Height: 10},
+				{ID: "tree_id_2", EstateID: "estate_id_value", X: 1, Y: 3, Height: 12},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:          "Last page",
+			afterX:        1,
+			afterY:        3,
+			expectedTrees: []Tree{},
+			expectedErr:   nil,
+		},
+		{
+			name:          "Database error",
+			afterX:        0,
+			afterY:        0,
+			expectedTrees: nil,
+			expectedErr:   errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			queryPattern := `SELECT .* FROM trees WHERE estate_id = \$1 .* ORDER BY y, x LIMIT \$10`
+			args := []driver.Value{"estate_id_value", 1, 10, 2, 5, 3, 30, tc.afterX, tc.afterY, 2}
+			if tc.expectedErr != nil {
+				mock.ExpectQuery(queryPattern).WithArgs(args...).WillReturnError(tc.expectedErr)
+			} else {
+				rows := sqlmock.NewRows([]string{"tree_id", "estate_id", "x", "y", "height"})
+				for _, tree := range tc.expectedTrees {
+					rows.AddRow(tree.ID, tree.EstateID, tree.X, tree.Y, tree.Height)
+				}
+				mock.ExpectQuery(queryPattern).WithArgs(args...).WillReturnRows(rows)
+			}
+
+			// Call the function under test
+			trees, err := repo.GetTreesPage(context.Background(), "estate_id_value", filter, tc.afterX, tc.afterY, 2)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+			require.Equal(t, tc.expectedTrees, trees)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestInsertTree(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -423,6 +1118,145 @@ func TestInsertTree(t *testing.T) {
 	}
 }
 
+func TestInsertTrees(t *testing.T) {
+	trees := []Tree{
+		{X: 1, Y: 2, Height: 10},
+		{X: 2, Y: 2, Height: 12},
+	}
+
+	tests := []struct {
+		name          string
+		estateID      string
+		trees         []Tree
+		mockExecErrs  []error
+		expectCommit  bool
+		expectedErr   error
+		expectedCount int
+	}{
+		{
+			name:          "Valid trees insertion",
+			estateID:      "estate_id_value",
+			trees:         trees,
+			mockExecErrs:  []error{nil, nil},
+			expectCommit:  true,
+			expectedErr:   nil,
+			expectedCount: 2,
+		},
+		{
+			name:         "Unique constraint violation",
+			estateID:     "estate_id_value",
+			trees:        trees,
+			mockExecErrs: []error{nil, &pq.Error{Code: "23505"}},
+			expectCommit: false,
+			expectedErr:  fmt.Errorf("%w: tree already exists at the specified location (2,2)", ErrConflict),
+		},
+		{
+			name:         "Database error",
+			estateID:     "estate_id_value",
+			trees:        trees,
+			mockExecErrs: []error{errors.New("database error")},
+			expectCommit: false,
+			expectedErr:  errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			queryPattern := `INSERT INTO trees \(estate_id, tree_id, x, y, height\) VALUES \(\$1, \$2, \$3, \$4, \$5\) RETURNING tree_id`
+			mock.ExpectBegin()
+			prepare := mock.ExpectPrepare(queryPattern)
+			for idx, execErr := range tc.mockExecErrs {
+				tree := tc.trees[idx]
+				exec := prepare.ExpectExec().WithArgs(tc.estateID, sqlmock.AnyArg(), tree.X, tree.Y, tree.Height)
+				if execErr != nil {
+					exec.WillReturnError(execErr)
+				} else {
+					exec.WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+			}
+			if tc.expectCommit {
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			// Call the function under test
+			treeIDs, err := repo.InsertTrees(context.Background(), tc.estateID, tc.trees)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+			require.Len(t, treeIDs, tc.expectedCount)
+			for _, treeID := range treeIDs {
+				_, err = uuid.Parse(treeID)
+				require.NoError(t, err)
+			}
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateTreeHeight(t *testing.T) {
+	tests := []struct {
+		name        string
+		treeID      string
+		height      int
+		expectedErr error
+	}{
+		{
+			name:        "Valid tree ID",
+			treeID:      "tree_id_value",
+			height:      12,
+			expectedErr: nil,
+		},
+		{
+			name:        "Database error",
+			treeID:      "tree_id_value",
+			height:      12,
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			queryPattern := `UPDATE trees SET height = \$2, updated_at = now\(\) WHERE tree_id = \$1`
+			if tc.expectedErr != nil {
+				mock.ExpectExec(queryPattern).WithArgs(tc.treeID, tc.height).WillReturnError(tc.expectedErr)
+			} else {
+				mock.ExpectExec(queryPattern).WithArgs(tc.treeID, tc.height).WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			// Call the function under test
+			err = repo.UpdateTreeHeight(context.Background(), tc.treeID, tc.height)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestDeleteTree(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -462,11 +1296,400 @@ func TestDeleteTree(t *testing.T) {
 			// Call the function under test
 			err = repo.DeleteTree(context.Background(), tc.treeID)
 
+			// Verify the result
+			require.ErrorIs(t, err, tc.expectedErr)
+			if errors.Is(tc.expectedErr, sql.ErrNoRows) {
+				require.ErrorIs(t, err, ErrNotFound)
+			}
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteTreesOutside(t *testing.T) {
+	tests := []struct {
+		name        string
+		expectedErr error
+	}{
+		{
+			name:        "Valid estate ID",
+			expectedErr: nil,
+		},
+		{
+			name:        "Database error",
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			queryPattern := `DELETE FROM trees WHERE estate_id = \$1 AND \(x > \$2 OR y > \$3\)`
+			exec := mock.ExpectExec(queryPattern).WithArgs("estate_id_value", 8, 4)
+			if tc.expectedErr != nil {
+				exec.WillReturnError(tc.expectedErr)
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, 3))
+			}
+
+			// Call the function under test
+			err = repo.DeleteTreesOutside(context.Background(), "estate_id_value", 8, 4)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetPatrolNeighbourTrees(t *testing.T) {
+	tests := []struct {
+		name         string
+		estateID     string
+		x            int
+		y            int
+		prevTree     *Tree
+		nextTree     *Tree
+		mockPrevErr  error
+		mockNextErr  error
+		callNextTree bool
+		expectedErr  error
+	}{
+		{
+			name:         "Both neighbours found",
+			estateID:     "estate_id_value",
+			x:            3,
+			y:            2,
+			prevTree:     &Tree{ID: "tree_id_1", EstateID: "estate_id_value", X: 5, Y: 1, Height: 10},
+			nextTree:     &Tree{ID: "tree_id_2", EstateID: "estate_id_value", X: 1, Y: 2, Height: 12},
+			callNextTree: true,
+			expectedErr:  nil,
+		},
+		{
+			name:         "No neighbours",
+			estateID:     "estate_id_value",
+			x:            3,
+			y:            2,
+			mockPrevErr:  sql.ErrNoRows,
+			mockNextErr:  sql.ErrNoRows,
+			callNextTree: true,
+			expectedErr:  nil,
+		},
+		{
+			name:         "Database error on previous tree",
+			estateID:     "estate_id_value",
+			x:            3,
+			y:            2,
+			mockPrevErr:  errors.New("database error"),
+			callNextTree: false,
+			expectedErr:  errors.New("database error"),
+		},
+		{
+			name:         "Database error on next tree",
+			estateID:     "estate_id_value",
+			x:            3,
+			y:            2,
+			prevTree:     &Tree{ID: "tree_id_1", EstateID: "estate_id_value", X: 5, Y: 1, Height: 10},
+			mockNextErr:  errors.New("database error"),
+			callNextTree: true,
+			expectedErr:  errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			expectTree := func(queryPattern string, tree *Tree, mockErr error) {
+				query := mock.ExpectQuery(queryPattern).WithArgs(tc.estateID, tc.x, tc.y)
+				if mockErr != nil {
+					query.WillReturnError(mockErr)
+					return
+				}
+				query.WillReturnRows(
+					sqlmock.NewRows([]string{"tree_id", "estate_id", "x", "y", "height"}).
+						AddRow(tree.ID, tree.EstateID, tree.X, tree.Y, tree.Height),
+				)
+			}
+
+			expectTree(`SELECT .* FROM trees WHERE estate_id = \$1 AND \(y < \$3 .* ORDER BY y DESC`, tc.prevTree, tc.mockPrevErr)
+			if tc.callNextTree {
+				expectTree(`SELECT .* FROM trees WHERE estate_id = \$1 AND \(y > \$3 .* ORDER BY y ASC`, tc.nextTree, tc.mockNextErr)
+			}
+
+			// Call the function under test
+			prevTree, nextTree, err := repo.GetPatrolNeighbourTrees(context.Background(), tc.estateID, tc.x, tc.y)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+			if tc.expectedErr == nil {
+				require.Equal(t, tc.prevTree, prevTree)
+				require.Equal(t, tc.nextTree, nextTree)
+			}
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetTreeHeightCounts(t *testing.T) {
+	tests := []struct {
+		name                 string
+		estateID             string
+		expectedHeightCounts map[int]int
+		expectedErr          error
+	}{
+		{
+			name:                 "Valid estate ID with trees",
+			estateID:             "estate_id_value",
+			expectedHeightCounts: map[int]int{10: 2, 12: 1},
+			expectedErr:          nil,
+		},
+		{
+			name:                 "Valid estate ID without trees",
+			estateID:             "estate_id_value",
+			expectedHeightCounts: map[int]int{},
+			expectedErr:          nil,
+		},
+		{
+			name:                 "Database error",
+			estateID:             "estate_id_value",
+			expectedHeightCounts: nil,
+			expectedErr:          errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			rows := sqlmock.NewRows([]string{"height", "count"})
+			for height, count := range tc.expectedHeightCounts {
+				rows.AddRow(height, count)
+			}
+
+			queryPattern := `SELECT height, COUNT\(\*\) FROM trees WHERE estate_id = \$1 GROUP BY height`
+			if tc.expectedErr != nil {
+				mock.ExpectQuery(queryPattern).WithArgs(tc.estateID).WillReturnError(tc.expectedErr)
+			} else {
+				mock.ExpectQuery(queryPattern).WithArgs(tc.estateID).WillReturnRows(rows)
+			}
+
+			// Call the function under test
+			heightCounts, err := repo.GetTreeHeightCounts(context.Background(), tc.estateID)
+
 			// Verify the result
 			require.Equal(t, tc.expectedErr, err)
+			require.Equal(t, tc.expectedHeightCounts, heightCounts)
 
 			// Make sure all expectations were met
 			require.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
+
+func TestGetRouteSteps(t *testing.T) {
+	tests := []struct {
+		name          string
+		estateID      string
+		expectedSteps []RouteStep
+		expectedErr   error
+	}{
+		{
+			name:     "Valid estate ID with route",
+			estateID: "estate_id_value",
+			expectedSteps: []RouteStep{
+				{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 10},
+				{Step: 2, X: 2, Y: 1, Direction: "vu", StepDistance: 6, CurrentDistance: 16, Altitude: 6},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:          "Valid estate ID without route",
+			estateID:      "estate_id_value",
+			expectedSteps: []RouteStep{},
+			expectedErr:   nil,
+		},
+		{
+			name:          "Database error",
+			estateID:      "estate_id_value",
+			expectedSteps: nil,
+			expectedErr:   errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			rows := sqlmock.NewRows([]string{"step", "x", "y", "direction", "step_distance", "current_distance", "altitude"})
+			for _, step := range tc.expectedSteps {
+				rows.AddRow(step.Step, step.X, step.Y, step.Direction, step.StepDistance, step.CurrentDistance, step.Altitude)
+			}
+
+			queryPattern := `SELECT .* FROM route_steps WHERE estate_id = \$1 ORDER BY step`
+			if tc.expectedErr != nil {
+				mock.ExpectQuery(queryPattern).WithArgs(tc.estateID).WillReturnError(tc.expectedErr)
+			} else {
+				mock.ExpectQuery(queryPattern).WithArgs(tc.estateID).WillReturnRows(rows)
+			}
+
+			// Call the function under test
+			steps, err := repo.GetRouteSteps(context.Background(), tc.estateID)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+			require.Equal(t, tc.expectedSteps, steps)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetRouteStepsPage(t *testing.T) {
+	tests := []struct {
+		name          string
+		estateID      string
+		afterStep     int
+		fromDistance  int
+		toDistance    int
+		limit         int
+		mockRowErr    error
+		expectedSteps []RouteStep
+		expectedErr   error
+	}{
+		{
+			name:         "First page",
+			estateID:     "estate_id_value",
+			afterStep:    0,
+			fromDistance: 0,
+			toDistance:   100,
+			limit:        2,
+			expectedSteps: []RouteStep{
+				{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 10},
+				{Step: 2, X: 2, Y: 1, Direction: "vu", StepDistance: 6, CurrentDistance: 16, Altitude: 6},
+			},
+			expectedErr: nil,
+		},
+		{
+			name:          "Page after the last step",
+			estateID:      "estate_id_value",
+			afterStep:     2,
+			fromDistance:  0,
+			toDistance:    100,
+			limit:         2,
+			expectedSteps: []RouteStep{},
+			expectedErr:   nil,
+		},
+		{
+			name:          "Database error",
+			estateID:      "estate_id_value",
+			afterStep:     0,
+			fromDistance:  0,
+			toDistance:    100,
+			limit:         2,
+			expectedSteps: nil,
+			expectedErr:   errors.New("database error"),
+		},
+		{
+			name:          "Connection lost mid result",
+			estateID:      "estate_id_value",
+			afterStep:     0,
+			fromDistance:  0,
+			toDistance:    100,
+			limit:         2,
+			mockRowErr:    errors.New("connection reset"),
+			expectedSteps: nil,
+			expectedErr:   errors.New("connection reset"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			rows := sqlmock.NewRows([]string{"step", "x", "y", "direction", "step_distance", "current_distance", "altitude"})
+			for _, step := range tc.expectedSteps {
+				rows.AddRow(step.Step, step.X, step.Y, step.Direction, step.StepDistance, step.CurrentDistance, step.Altitude)
+			}
+			if tc.mockRowErr != nil {
+				// the second step never arrives, no truncated page is returned
+				rows.AddRow(1, 1, 1, "ew", 10, 10, 0).
+					AddRow(2, 2, 1, "vu", 6, 16, 6).
+					RowError(1, tc.mockRowErr)
+			}
+
+			queryPattern := `SELECT .* FROM route_steps WHERE estate_id = \$1 AND step > \$2 .* ORDER BY step LIMIT \$5`
+			query := mock.ExpectQuery(queryPattern).
+				WithArgs(tc.estateID, tc.afterStep, tc.fromDistance, tc.toDistance, tc.limit)
+			if tc.expectedErr != nil && tc.mockRowErr == nil {
+				query.WillReturnError(tc.expectedErr)
+			} else {
+				query.WillReturnRows(rows)
+			}
+
+			// Call the function under test
+			steps, err := repo.GetRouteStepsPage(
+				context.Background(),
+				tc.estateID,
+				tc.afterStep,
+				tc.fromDistance,
+				tc.toDistance,
+				tc.limit,
+			)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+			require.Equal(t, tc.expectedSteps, steps)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
@@ -4,7 +4,10 @@
 // interfaces using mockgen. See the Makefile for more information.
 package repository
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // NOTE: Interface definition must be on package who need it.
 // Ideally i want to do it like that, but that will also mean moving the data types
@@ -12,10 +15,35 @@ import "context"
 // TODO: data structure into its own container in appropriate layer
 // TODO: interface define on package needing it, not original package
 type Repositorier interface {
+	WithTx(ctx context.Context, fn func(repo Repositorier) error) error
 	GetEstateByID(ctx context.Context, estateID string) (Estate, error)
-	InsertEstate(ctx context.Context, width, length int) (estateID string, err error)
-	UpdateEstate(ctx context.Context, estateID string, count, min, max, median, patrolDistance int, patrolRoute string) error
+	LockEstate(ctx context.Context, estateID string) (Estate, error)
+	GetEstatesPage(ctx context.Context, after *Estate, newestFirst bool, limit int) ([]Estate, error)
+	InsertEstate(ctx context.Context, estate Estate) (estateID string, err error)
+	UpdateEstateLayout(ctx context.Context, estate Estate) error
+	DeleteEstate(ctx context.Context, estateID string, cascade bool) error
+	UpdateEstate(ctx context.Context, estateID string, count, min, max, median, patrolDistance int, patrolRoute []RouteStep) error
+	UpdateRouteSteps(ctx context.Context, estateID string, patrolRoute []RouteStep) error
 	GetAllTreesInEstate(ctx context.Context, estateID string) ([]Tree, error)
+	GetTreeByID(ctx context.Context, estateID, treeID string) (Tree, error)
+	GetTreesPage(ctx context.Context, estateID string, filter TreeFilter, afterX, afterY, limit int) ([]Tree, error)
 	InsertTree(ctx context.Context, estateID string, x, y, height int) (treeID string, err error)
+	InsertTrees(ctx context.Context, estateID string, trees []Tree) (treeIDs []string, err error)
+	UpdateTreeHeight(ctx context.Context, treeID string, height int) error
 	DeleteTree(ctx context.Context, treeID string) error
+	DeleteTreesOutside(ctx context.Context, estateID string, width, length int) error
+	GetPatrolNeighbourTrees(ctx context.Context, estateID string, x, y int) (prev, next *Tree, err error)
+	GetTreeHeightCounts(ctx context.Context, estateID string) (map[int]int, error)
+	GetZones(ctx context.Context, estateID string) ([]Zone, error)
+	GetZoneByID(ctx context.Context, estateID, zoneID string) (Zone, error)
+	InsertZone(ctx context.Context, zone Zone) (zoneID string, err error)
+	UpdateZone(ctx context.Context, zone Zone) error
+	DeleteZone(ctx context.Context, estateID, zoneID string) error
+	GetRouteSteps(ctx context.Context, estateID string) ([]RouteStep, error)
+	GetRouteStepsPage(ctx context.Context, estateID string, afterStep, fromDistance, toDistance, limit int) ([]RouteStep, error)
+	EnqueueRecalculation(ctx context.Context, estateID string) error
+	ClaimJob(ctx context.Context, lease time.Duration) (*Job, error)
+	CompleteJob(ctx context.Context, jobID string) error
+	RetryJob(ctx context.Context, jobID string, runAt time.Time, lastErr string) error
+	DeadLetterJob(ctx context.Context, job Job, lastErr string) error
 }
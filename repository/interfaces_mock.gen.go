@@ -12,6 +12,7 @@ package repository
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -39,6 +40,63 @@ func (m *MockRepositorier) EXPECT() *MockRepositorierMockRecorder {
 	return m.recorder
 }
 
+// ClaimJob mocks base method.
+func (m *MockRepositorier) ClaimJob(ctx context.Context, lease time.Duration) (*Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimJob", ctx, lease)
+	ret0, _ := ret[0].(*Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimJob indicates an expected call of ClaimJob.
+func (mr *MockRepositorierMockRecorder) ClaimJob(ctx, lease any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimJob", reflect.TypeOf((*MockRepositorier)(nil).ClaimJob), ctx, lease)
+}
+
+// CompleteJob mocks base method.
+func (m *MockRepositorier) CompleteJob(ctx context.Context, jobID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteJob", ctx, jobID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteJob indicates an expected call of CompleteJob.
+func (mr *MockRepositorierMockRecorder) CompleteJob(ctx, jobID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteJob", reflect.TypeOf((*MockRepositorier)(nil).CompleteJob), ctx, jobID)
+}
+
+// DeadLetterJob mocks base method.
+func (m *MockRepositorier) DeadLetterJob(ctx context.Context, job Job, lastErr string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeadLetterJob", ctx, job, lastErr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeadLetterJob indicates an expected call of DeadLetterJob.
+func (mr *MockRepositorierMockRecorder) DeadLetterJob(ctx, job, lastErr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeadLetterJob", reflect.TypeOf((*MockRepositorier)(nil).DeadLetterJob), ctx, job, lastErr)
+}
+
+// DeleteEstate mocks base method.
+func (m *MockRepositorier) DeleteEstate(ctx context.Context, estateID string, cascade bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteEstate", ctx, estateID, cascade)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteEstate indicates an expected call of DeleteEstate.
+func (mr *MockRepositorierMockRecorder) DeleteEstate(ctx, estateID, cascade any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEstate", reflect.TypeOf((*MockRepositorier)(nil).DeleteEstate), ctx, estateID, cascade)
+}
+
 // DeleteTree mocks base method.
 func (m *MockRepositorier) DeleteTree(ctx context.Context, treeID string) error {
 	m.ctrl.T.Helper()
@@ -53,6 +111,48 @@ func (mr *MockRepositorierMockRecorder) DeleteTree(ctx, treeID any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTree", reflect.TypeOf((*MockRepositorier)(nil).DeleteTree), ctx, treeID)
 }
 
+// DeleteTreesOutside mocks base method.
+func (m *MockRepositorier) DeleteTreesOutside(ctx context.Context, estateID string, width, length int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTreesOutside", ctx, estateID, width, length)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTreesOutside indicates an expected call of DeleteTreesOutside.
+func (mr *MockRepositorierMockRecorder) DeleteTreesOutside(ctx, estateID, width, length any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTreesOutside", reflect.TypeOf((*MockRepositorier)(nil).DeleteTreesOutside), ctx, estateID, width, length)
+}
+
+// DeleteZone mocks base method.
+func (m *MockRepositorier) DeleteZone(ctx context.Context, estateID, zoneID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteZone", ctx, estateID, zoneID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteZone indicates an expected call of DeleteZone.
+func (mr *MockRepositorierMockRecorder) DeleteZone(ctx, estateID, zoneID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteZone", reflect.TypeOf((*MockRepositorier)(nil).DeleteZone), ctx, estateID, zoneID)
+}
+
+// EnqueueRecalculation mocks base method.
+func (m *MockRepositorier) EnqueueRecalculation(ctx context.Context, estateID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueRecalculation", ctx, estateID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnqueueRecalculation indicates an expected call of EnqueueRecalculation.
+func (mr *MockRepositorierMockRecorder) EnqueueRecalculation(ctx, estateID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueRecalculation", reflect.TypeOf((*MockRepositorier)(nil).EnqueueRecalculation), ctx, estateID)
+}
+
 // GetAllTreesInEstate mocks base method.
 func (m *MockRepositorier) GetAllTreesInEstate(ctx context.Context, estateID string) ([]Tree, error) {
 	m.ctrl.T.Helper()
@@ -83,19 +183,155 @@ func (mr *MockRepositorierMockRecorder) GetEstateByID(ctx, estateID any) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstateByID", reflect.TypeOf((*MockRepositorier)(nil).GetEstateByID), ctx, estateID)
 }
 
+// GetEstatesPage mocks base method.
+func (m *MockRepositorier) GetEstatesPage(ctx context.Context, after *Estate, newestFirst bool, limit int) ([]Estate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEstatesPage", ctx, after, newestFirst, limit)
+	ret0, _ := ret[0].([]Estate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEstatesPage indicates an expected call of GetEstatesPage.
+func (mr *MockRepositorierMockRecorder) GetEstatesPage(ctx, after, newestFirst, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEstatesPage", reflect.TypeOf((*MockRepositorier)(nil).GetEstatesPage), ctx, after, newestFirst, limit)
+}
+
+// GetPatrolNeighbourTrees mocks base method.
+func (m *MockRepositorier) GetPatrolNeighbourTrees(ctx context.Context, estateID string, x, y int) (*Tree, *Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPatrolNeighbourTrees", ctx, estateID, x, y)
+	ret0, _ := ret[0].(*Tree)
+	ret1, _ := ret[1].(*Tree)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetPatrolNeighbourTrees indicates an expected call of GetPatrolNeighbourTrees.
+func (mr *MockRepositorierMockRecorder) GetPatrolNeighbourTrees(ctx, estateID, x, y any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPatrolNeighbourTrees", reflect.TypeOf((*MockRepositorier)(nil).GetPatrolNeighbourTrees), ctx, estateID, x, y)
+}
+
+// GetRouteSteps mocks base method.
+func (m *MockRepositorier) GetRouteSteps(ctx context.Context, estateID string) ([]RouteStep, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRouteSteps", ctx, estateID)
+	ret0, _ := ret[0].([]RouteStep)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRouteSteps indicates an expected call of GetRouteSteps.
+func (mr *MockRepositorierMockRecorder) GetRouteSteps(ctx, estateID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRouteSteps", reflect.TypeOf((*MockRepositorier)(nil).GetRouteSteps), ctx, estateID)
+}
+
+// GetRouteStepsPage mocks base method.
+func (m *MockRepositorier) GetRouteStepsPage(ctx context.Context, estateID string, afterStep, fromDistance, toDistance, limit int) ([]RouteStep, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRouteStepsPage", ctx, estateID, afterStep, fromDistance, toDistance, limit)
+	ret0, _ := ret[0].([]RouteStep)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRouteStepsPage indicates an expected call of GetRouteStepsPage.
+func (mr *MockRepositorierMockRecorder) GetRouteStepsPage(ctx, estateID, afterStep, fromDistance, toDistance, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRouteStepsPage", reflect.TypeOf((*MockRepositorier)(nil).GetRouteStepsPage), ctx, estateID, afterStep, fromDistance, toDistance, limit)
+}
+
+// GetTreeByID mocks base method.
+func (m *MockRepositorier) GetTreeByID(ctx context.Context, estateID, treeID string) (Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTreeByID", ctx, estateID, treeID)
+	ret0, _ := ret[0].(Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTreeByID indicates an expected call of GetTreeByID.
+func (mr *MockRepositorierMockRecorder) GetTreeByID(ctx, estateID, treeID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTreeByID", reflect.TypeOf((*MockRepositorier)(nil).GetTreeByID), ctx, estateID, treeID)
+}
+
+// GetTreeHeightCounts mocks base method.
+func (m *MockRepositorier) GetTreeHeightCounts(ctx context.Context, estateID string) (map[int]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTreeHeightCounts", ctx, estateID)
+	ret0, _ := ret[0].(map[int]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTreeHeightCounts indicates an expected call of GetTreeHeightCounts.
+func (mr *MockRepositorierMockRecorder) GetTreeHeightCounts(ctx, estateID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTreeHeightCounts", reflect.TypeOf((*MockRepositorier)(nil).GetTreeHeightCounts), ctx, estateID)
+}
+
+// GetTreesPage mocks base method.
+func (m *MockRepositorier) GetTreesPage(ctx context.Context, estateID string, filter TreeFilter, afterX, afterY, limit int) ([]Tree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTreesPage", ctx, estateID, filter, afterX, afterY, limit)
+	ret0, _ := ret[0].([]Tree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTreesPage indicates an expected call of GetTreesPage.
+func (mr *MockRepositorierMockRecorder) GetTreesPage(ctx, estateID, filter, afterX, afterY, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTreesPage", reflect.TypeOf((*MockRepositorier)(nil).GetTreesPage), ctx, estateID, filter, afterX, afterY, limit)
+}
+
+// GetZoneByID mocks base method.
+func (m *MockRepositorier) GetZoneByID(ctx context.Context, estateID, zoneID string) (Zone, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetZoneByID", ctx, estateID, zoneID)
+	ret0, _ := ret[0].(Zone)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetZoneByID indicates an expected call of GetZoneByID.
+func (mr *MockRepositorierMockRecorder) GetZoneByID(ctx, estateID, zoneID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetZoneByID", reflect.TypeOf((*MockRepositorier)(nil).GetZoneByID), ctx, estateID, zoneID)
+}
+
+// GetZones mocks base method.
+func (m *MockRepositorier) GetZones(ctx context.Context, estateID string) ([]Zone, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetZones", ctx, estateID)
+	ret0, _ := ret[0].([]Zone)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetZones indicates an expected call of GetZones.
+func (mr *MockRepositorierMockRecorder) GetZones(ctx, estateID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetZones", reflect.TypeOf((*MockRepositorier)(nil).GetZones), ctx, estateID)
+}
+
 // InsertEstate mocks base method.
-func (m *MockRepositorier) InsertEstate(ctx context.Context, width, length int) (string, error) {
+func (m *MockRepositorier) InsertEstate(ctx context.Context, estate Estate) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "InsertEstate", ctx, width, length)
+	ret := m.ctrl.Call(m, "InsertEstate", ctx, estate)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // InsertEstate indicates an expected call of InsertEstate.
-func (mr *MockRepositorierMockRecorder) InsertEstate(ctx, width, length any) *gomock.Call {
+func (mr *MockRepositorierMockRecorder) InsertEstate(ctx, estate any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertEstate", reflect.TypeOf((*MockRepositorier)(nil).InsertEstate), ctx, width, length)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertEstate", reflect.TypeOf((*MockRepositorier)(nil).InsertEstate), ctx, estate)
 }
 
 // InsertTree mocks base method.
@@ -113,8 +349,67 @@ func (mr *MockRepositorierMockRecorder) InsertTree(ctx, estateID, x, y, height a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertTree", reflect.TypeOf((*MockRepositorier)(nil).InsertTree), ctx, estateID, x, y, height)
 }
 
+// InsertTrees mocks base method.
+func (m *MockRepositorier) InsertTrees(ctx context.Context, estateID string, trees []Tree) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertTrees", ctx, estateID, trees)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertTrees indicates an expected call of InsertTrees.
+func (mr *MockRepositorierMockRecorder) InsertTrees(ctx, estateID, trees any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertTrees", reflect.TypeOf((*MockRepositorier)(nil).InsertTrees), ctx, estateID, trees)
+}
+
+// InsertZone mocks base method.
+func (m *MockRepositorier) InsertZone(ctx context.Context, zone Zone) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertZone", ctx, zone)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertZone indicates an expected call of InsertZone.
+func (mr *MockRepositorierMockRecorder) InsertZone(ctx, zone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertZone", reflect.TypeOf((*MockRepositorier)(nil).InsertZone), ctx, zone)
+}
+
+// LockEstate mocks base method.
+func (m *MockRepositorier) LockEstate(ctx context.Context, estateID string) (Estate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockEstate", ctx, estateID)
+	ret0, _ := ret[0].(Estate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockEstate indicates an expected call of LockEstate.
+func (mr *MockRepositorierMockRecorder) LockEstate(ctx, estateID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockEstate", reflect.TypeOf((*MockRepositorier)(nil).LockEstate), ctx, estateID)
+}
+
+// RetryJob mocks base method.
+func (m *MockRepositorier) RetryJob(ctx context.Context, jobID string, runAt time.Time, lastErr string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryJob", ctx, jobID, runAt, lastErr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryJob indicates an expected call of RetryJob.
+func (mr *MockRepositorierMockRecorder) RetryJob(ctx, jobID, runAt, lastErr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryJob", reflect.TypeOf((*MockRepositorier)(nil).RetryJob), ctx, jobID, runAt, lastErr)
+}
+
 // UpdateEstate mocks base method.
-func (m *MockRepositorier) UpdateEstate(ctx context.Context, estateID string, count, min, max, median, patrolDistance int, patrolRoute string) error {
+func (m *MockRepositorier) UpdateEstate(ctx context.Context, estateID string, count, min, max, median, patrolDistance int, patrolRoute []RouteStep) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "UpdateEstate", ctx, estateID, count, min, max, median, patrolDistance, patrolRoute)
 	ret0, _ := ret[0].(error)
@@ -126,3 +421,73 @@ func (mr *MockRepositorierMockRecorder) UpdateEstate(ctx, estateID, count, min,
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEstate", reflect.TypeOf((*MockRepositorier)(nil).UpdateEstate), ctx, estateID, count, min, max, median, patrolDistance, patrolRoute)
 }
+
+// UpdateEstateLayout mocks base method.
+func (m *MockRepositorier) UpdateEstateLayout(ctx context.Context, estate Estate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEstateLayout", ctx, estate)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateEstateLayout indicates an expected call of UpdateEstateLayout.
+func (mr *MockRepositorierMockRecorder) UpdateEstateLayout(ctx, estate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEstateLayout", reflect.TypeOf((*MockRepositorier)(nil).UpdateEstateLayout), ctx, estate)
+}
+
+// UpdateRouteSteps mocks base method.
+func (m *MockRepositorier) UpdateRouteSteps(ctx context.Context, estateID string, patrolRoute []RouteStep) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRouteSteps", ctx, estateID, patrolRoute)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRouteSteps indicates an expected call of UpdateRouteSteps.
+func (mr *MockRepositorierMockRecorder) UpdateRouteSteps(ctx, estateID, patrolRoute any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRouteSteps", reflect.TypeOf((*MockRepositorier)(nil).UpdateRouteSteps), ctx, estateID, patrolRoute)
+}
+
+// UpdateTreeHeight mocks base method.
+func (m *MockRepositorier) UpdateTreeHeight(ctx context.Context, treeID string, height int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTreeHeight", ctx, treeID, height)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTreeHeight indicates an expected call of UpdateTreeHeight.
+func (mr *MockRepositorierMockRecorder) UpdateTreeHeight(ctx, treeID, height any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTreeHeight", reflect.TypeOf((*MockRepositorier)(nil).UpdateTreeHeight), ctx, treeID, height)
+}
+
+// UpdateZone mocks base method.
+func (m *MockRepositorier) UpdateZone(ctx context.Context, zone Zone) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateZone", ctx, zone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateZone indicates an expected call of UpdateZone.
+func (mr *MockRepositorierMockRecorder) UpdateZone(ctx, zone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateZone", reflect.TypeOf((*MockRepositorier)(nil).UpdateZone), ctx, zone)
+}
+
+// WithTx mocks base method.
+func (m *MockRepositorier) WithTx(ctx context.Context, fn func(Repositorier) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockRepositorierMockRecorder) WithTx(ctx, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockRepositorier)(nil).WithTx), ctx, fn)
+}
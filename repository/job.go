@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/nahwinrajan/testswpro/uuidgen"
+)
+
+const (
+	// a single pending recalculation per estate, enqueuing again while it is
+	// pending give it a fresh start instead
+	queryEnqueueJob = `
+		INSERT INTO jobs (job_id, kind, estate_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind, estate_id) DO UPDATE
+		SET
+			run_at = now(),
+			attempts = 0,
+			last_error = NULL
+	`
+	queryUpdateEstateStatsStatus = `UPDATE estates SET stats_status = $2, updated_at = now() WHERE estate_id = $1`
+
+	// the claimed job is hidden from other workers for $1 seconds, the lease,
+	// it is claimed again once the lease is over without the job being done
+	queryClaimJob = `
+		UPDATE jobs
+		SET
+			attempts = attempts + 1,
+			run_at = now() + make_interval(secs => $1)
+		WHERE job_id = (
+			SELECT job_id FROM jobs
+			WHERE run_at <= now()
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING job_id, kind, estate_id, attempts
+	`
+	queryRetryJob = `UPDATE jobs SET run_at = $2, last_error = $3 WHERE job_id = $1`
+
+	queryCompleteJob          = `DELETE FROM jobs WHERE job_id = $1 RETURNING estate_id`
+	queryDeleteJobsByEstateID = `DELETE FROM jobs WHERE estate_id = $1`
+
+	queryDeleteDeadJobsByEstateID = `DELETE FROM dead_jobs WHERE estate_id = $1`
+
+	// the job is enqueued again when attempts changed since it was claimed
+	queryDeleteExhaustedJob = `DELETE FROM jobs WHERE job_id = $1 AND attempts = $2`
+	queryInsertDeadJob      = `
+		INSERT INTO dead_jobs (job_id, kind, estate_id, attempts, last_error)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+)
+
+// EnqueueRecalculation mark the estate stats pending until a job recalculate them.
+func (rp *Repository) EnqueueRecalculation(ctx context.Context, estateID string) error {
+	uuidJobID, err := uuidgen.NewRandom()
+	if err != nil {
+		return err
+	}
+
+	return rp.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, queryEnqueueJob, uuidJobID.String(), JobKindRecalculateEstate, estateID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, queryUpdateEstateStatsStatus, estateID, StatsStatusPending)
+
+		return err
+	})
+}
+
+// ClaimJob return the job due the earliest which no other worker is running,
+// nil when there is none. The job is claimed for the lease duration.
+func (rp *Repository) ClaimJob(ctx context.Context, lease time.Duration) (*Job, error) {
+	var job Job
+
+	err := rp.conn().QueryRowContext(ctx, queryClaimJob, lease.Seconds()).Scan(
+		&job.ID,
+		&job.Kind,
+		&job.EstateID,
+		&job.Attempts,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return &job, nil
+}
+
+// CompleteJob delete the job which is done and mark the estate stats fresh, in a single
+// transaction. Only the job recalculating the estate tells its stats are fresh again.
+func (rp *Repository) CompleteJob(ctx context.Context, jobID string) error {
+	return rp.inTx(ctx, func(tx *sql.Tx) error {
+		var estateID string
+		err := tx.QueryRowContext(ctx, queryCompleteJob, jobID).Scan(&estateID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, queryUpdateEstateStatsStatus, estateID, StatsStatusFresh)
+
+		return err
+	})
+}
+
+// RetryJob run the failed job again at runAt.
+func (rp *Repository) RetryJob(ctx context.Context, jobID string, runAt time.Time, lastErr string) error {
+	_, err := rp.conn().ExecContext(ctx, queryRetryJob, jobID, runAt, lastErr)
+
+	return dbError(err)
+}
+
+// DeadLetterJob move the job which ran out of attempts to the dead jobs and mark
+// the estate stats failed, in a single transaction. The job stays when it was
+// enqueued again since it was claimed.
+func (rp *Repository) DeadLetterJob(ctx context.Context, job Job, lastErr string) error {
+	return rp.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, queryDeleteExhaustedJob, job.ID, job.Attempts)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil || deleted < 1 {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, queryInsertDeadJob, job.ID, job.Kind, job.EstateID, job.Attempts, lastErr)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, queryUpdateEstateStatsStatus, job.EstateID, StatsStatusFailed)
+
+		return err
+	})
+}
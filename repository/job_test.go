@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnqueueRecalculation(t *testing.T) {
+	tests := []struct {
+		name           string
+		mockEnqueueErr error
+		expectedErr    error
+	}{
+		{
+			name:        "Enqueued",
+			expectedErr: nil,
+		},
+		{
+			name:           "Database error",
+			mockEnqueueErr: errors.New("database error"),
+			expectedErr:    errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`INSERT INTO jobs \(job_id, kind, estate_id\) VALUES \(\$1, \$2, \$3\) ON CONFLICT \(kind, estate_id\) DO UPDATE`).
+				WithArgs(sqlmock.AnyArg(), "recalculate_estate", "estate_id_value")
+			if tc.mockEnqueueErr != nil {
+				exec.WillReturnError(tc.mockEnqueueErr)
+				mock.ExpectRollback()
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE estates SET stats_status = \$2`).
+					WithArgs("estate_id_value", "pending").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			// Call the function under test
+			err = repo.EnqueueRecalculation(context.Background(), "estate_id_value")
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestClaimJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		mockJob     *Job
+		mockErr     error
+		expectedJob *Job
+		expectedErr error
+	}{
+		{
+			name:        "Job due",
+			mockJob:     &Job{ID: "job_id_value", Kind: "recalculate_estate", EstateID: "estate_id_value", Attempts: 2},
+			expectedJob: &Job{ID: "job_id_value", Kind: "recalculate_estate", EstateID: "estate_id_value", Attempts: 2},
+		},
+		{
+			name:        "No job due",
+			expectedJob: nil,
+		},
+		{
+			name:        "Database error",
+			mockErr:     errors.New("database error"),
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			query := mock.ExpectQuery(`UPDATE jobs .* FOR UPDATE SKIP LOCKED \) RETURNING job_id, kind, estate_id, attempts`).
+				WithArgs(float64(300))
+			rows := sqlmock.NewRows([]string{"job_id", "kind", "estate_id", "attempts"})
+			if tc.mockJob != nil {
+				rows.AddRow(tc.mockJob.ID, tc.mockJob.Kind, tc.mockJob.EstateID, tc.mockJob.Attempts)
+			}
+			if tc.mockErr != nil {
+				query.WillReturnError(tc.mockErr)
+			} else {
+				query.WillReturnRows(rows)
+			}
+
+			// Call the function under test
+			job, err := repo.ClaimJob(context.Background(), 5*time.Minute)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+			require.Equal(t, tc.expectedJob, job)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCompleteJob(t *testing.T) {
+	tests := []struct {
+		name          string
+		mockDeleteErr error
+		jobGone       bool
+		expectedErr   error
+	}{
+		{
+			name:        "Job done",
+			expectedErr: nil,
+		},
+		{
+			name:        "Job already gone",
+			jobGone:     true,
+			expectedErr: nil,
+		},
+		{
+			name:          "Database error",
+			mockDeleteErr: errors.New("database error"),
+			expectedErr:   errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			mock.ExpectBegin()
+			query := mock.ExpectQuery(`DELETE FROM jobs WHERE job_id = \$1 RETURNING estate_id`).
+				WithArgs("job_id_value")
+			switch {
+			case tc.mockDeleteErr != nil:
+				query.WillReturnError(tc.mockDeleteErr)
+				mock.ExpectRollback()
+			case tc.jobGone:
+				query.WillReturnRows(sqlmock.NewRows([]string{"estate_id"}))
+				mock.ExpectCommit()
+			default:
+				query.WillReturnRows(sqlmock.NewRows([]string{"estate_id"}).AddRow("estate_id_value"))
+				mock.ExpectExec(`UPDATE estates SET stats_status = \$2`).
+					WithArgs("estate_id_value", "fresh").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			// Call the function under test
+			err = repo.CompleteJob(context.Background(), "job_id_value")
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRetryJob(t *testing.T) {
+	// Create mock database and repository
+	dbmock, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer dbmock.Close()
+
+	repo := Repository{
+		db: dbmock,
+	}
+
+	runAt := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	mock.ExpectExec(`UPDATE jobs SET run_at = \$2, last_error = \$3 WHERE job_id = \$1`).
+		WithArgs("job_id_value", runAt, "database error").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Call the function under test
+	err = repo.RetryJob(context.Background(), "job_id_value", runAt, "database error")
+
+	// Verify the result
+	require.NoError(t, err)
+
+	// Make sure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeadLetterJob(t *testing.T) {
+	job := Job{ID: "job_id_value", Kind: "recalculate_estate", EstateID: "estate_id_value", Attempts: 8}
+
+	tests := []struct {
+		name          string
+		deletedJobs   int64
+		mockInsertErr error
+		expectedErr   error
+	}{
+		{
+			name:        "Moved to dead jobs",
+			deletedJobs: 1,
+		},
+		{
+			name:        "Enqueued again meanwhile",
+			deletedJobs: 0,
+		},
+		{
+			name:          "Database error",
+			deletedJobs:   1,
+			mockInsertErr: errors.New("database error"),
+			expectedErr:   errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`DELETE FROM jobs WHERE job_id = \$1 AND attempts = \$2`).
+				WithArgs("job_id_value", 8).
+				WillReturnResult(sqlmock.NewResult(0, tc.deletedJobs))
+			if tc.deletedJobs > 0 {
+				exec := mock.ExpectExec(`INSERT INTO dead_jobs`).
+					WithArgs("job_id_value", "recalculate_estate", "estate_id_value", 8, "patrol failed")
+				if tc.mockInsertErr != nil {
+					exec.WillReturnError(tc.mockInsertErr)
+				} else {
+					exec.WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec(`UPDATE estates SET stats_status = \$2`).
+						WithArgs("estate_id_value", "failed").
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+			}
+			if tc.expectedErr != nil {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+
+			// Call the function under test
+			err = repo.DeadLetterJob(context.Background(), job, "patrol failed")
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
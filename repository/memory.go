@@ -0,0 +1,941 @@
+// This file contains the in-memory repository implementation, for demos,
+// local development and tests without a database.
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nahwinrajan/testswpro/uuidgen"
+)
+
+// MemoryDSN is the DATABASE_URL selecting the in-memory repository
+const MemoryDSN = "memory://"
+
+// Memory is the Repositorier keeping everything in memory, everything is lost when the process stops.
+// It behaves like Repository, the same constraints are enforced with the same errors. Transactions
+// run one after another while holding the store lock, so LockEstate is merely GetEstateByID.
+type Memory struct {
+	mu   *sync.RWMutex
+	data *memoryData
+	// tx is set on the repository handed over by WithTx, the store lock is then already held
+	tx *memoryTx
+}
+
+// memoryData is what the tables of database.sql store
+type memoryData struct {
+	estates map[string]Estate
+	trees   map[string]Tree
+	// treeAt is the unique (estate_id, x, y) of the trees
+	treeAt     map[memoryPlot]string
+	routeSteps map[string][]RouteStep
+	zones      map[string]memoryZone
+	jobs       map[string]memoryJob
+	// jobOf is the unique (kind, estate_id) of the jobs
+	jobOf    map[memoryJobKey]string
+	deadJobs map[string]memoryJob
+	// seq orders the zones the way they were inserted, like created_at
+	seq int
+}
+
+type memoryPlot struct {
+	estateID string
+	x, y     int
+}
+
+type memoryZone struct {
+	Zone
+	seq int
+}
+
+type memoryJob struct {
+	Job
+	runAt   time.Time
+	lastErr string
+}
+
+type memoryJobKey struct {
+	kind, estateID string
+}
+
+// memoryTx undo the changes of the transaction in reverse order when it is rolled back
+type memoryTx struct {
+	undo []func()
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		mu: &sync.RWMutex{},
+		data: &memoryData{
+			estates:    make(map[string]Estate),
+			trees:      make(map[string]Tree),
+			treeAt:     make(map[memoryPlot]string),
+			routeSteps: make(map[string][]RouteStep),
+			zones:      make(map[string]memoryZone),
+			jobs:       make(map[string]memoryJob),
+			jobOf:      make(map[memoryJobKey]string),
+			deadJobs:   make(map[string]memoryJob),
+		},
+	}
+}
+
+// WithTx run fn with a repository whose every change is rolled back when fn return
+// an error or panic. Nested calls join the transaction already running.
+func (m *Memory) WithTx(ctx context.Context, fn func(repo Repositorier) error) (err error) {
+	if m.tx != nil {
+		return fn(m)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	txRepo := &Memory{mu: m.mu, data: m.data, tx: &memoryTx{}}
+	defer func() {
+		if p := recover(); p != nil {
+			txRepo.tx.rollback(0)
+			panic(p)
+		}
+	}()
+
+	err = fn(txRepo)
+	if err != nil {
+		txRepo.tx.rollback(0)
+	}
+
+	return err
+}
+
+// read run fn holding the store lock for reading, or in the transaction holding it already
+func (m *Memory) read(fn func(data *memoryData) error) error {
+	if m.tx == nil {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+	}
+
+	return fn(m.data)
+}
+
+// write run fn holding the store lock, or in the transaction holding it already.
+// Whatever fn changed is undone when it return an error, either all of it or none is stored.
+func (m *Memory) write(fn func(data *memoryData, tx *memoryTx) error) error {
+	tx := m.tx
+	if tx == nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		tx = &memoryTx{}
+	}
+
+	mark := len(tx.undo)
+	err := fn(m.data, tx)
+	if err != nil {
+		tx.rollback(mark)
+	}
+
+	return err
+}
+
+// rollback undo the changes made since the first mark changes
+func (tx *memoryTx) rollback(mark int) {
+	for i := len(tx.undo) - 1; i >= mark; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = tx.undo[:mark]
+}
+
+// put store value at key, the previous value is put back on rollback
+func put[K comparable, V any](tx *memoryTx, values map[K]V, key K, value V) {
+	keep(tx, values, key)
+	values[key] = value
+}
+
+// remove delete key, the previous value is put back on rollback
+func remove[K comparable, V any](tx *memoryTx, values map[K]V, key K) {
+	keep(tx, values, key)
+	delete(values, key)
+}
+
+func keep[K comparable, V any](tx *memoryTx, values map[K]V, key K) {
+	prev, found := values[key]
+	tx.undo = append(tx.undo, func() {
+		if found {
+			values[key] = prev
+		} else {
+			delete(values, key)
+		}
+	})
+}
+
+// *** Estate ***
+func (m *Memory) GetEstateByID(ctx context.Context, estateID string) (estate Estate, err error) {
+	err = m.read(func(data *memoryData) error {
+		stored, found := data.estates[estateID]
+		if !found {
+			return fmt.Errorf("%w: estate %s", ErrNotFound, estateID)
+		}
+		estate = cloneEstate(stored)
+
+		return nil
+	})
+
+	return estate, err
+}
+
+// LockEstate read the estate, transactions already run one after another.
+func (m *Memory) LockEstate(ctx context.Context, estateID string) (Estate, error) {
+	return m.GetEstateByID(ctx, estateID)
+}
+
+// GetEstatesPage return at most limit estates ordered by creation time, only
+// estates after the estate after (the last one of the previous page), nil for the first page.
+func (m *Memory) GetEstatesPage(ctx context.Context, after *Estate, newestFirst bool, limit int) ([]Estate, error) {
+	estates := make([]Estate, 0)
+	err := m.read(func(data *memoryData) error {
+		// ordered by (created_at, estate_id), newest first the other way around
+		before := func(a, b Estate) bool {
+			cmp := a.CreatedAt.Compare(b.CreatedAt)
+			if cmp == 0 {
+				cmp = strings.Compare(a.ID, b.ID)
+			}
+			if newestFirst {
+				return cmp > 0
+			}
+
+			return cmp < 0
+		}
+
+		for _, estate := range data.estates {
+			if after == nil || before(*after, estate) {
+				estates = append(estates, estate)
+			}
+		}
+		sort.Slice(estates, func(i, j int) bool {
+			return before(estates[i], estates[j])
+		})
+
+		estates = estates[:min(max(limit, 0), len(estates))]
+		for i := range estates {
+			estates[i] = cloneEstate(estates[i])
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return estates, nil
+}
+
+func (m *Memory) InsertEstate(ctx context.Context, estate Estate) (string, error) {
+	uuidEstateID, err := uuidgen.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	// only what the insert stores, the rest is defaulted like the columns are
+	stored := Estate{
+		ID:          uuidEstateID.String(),
+		StatsStatus: StatsStatusFresh,
+		CreatedAt:   time.Now().UTC(),
+	}
+	setEstateLayout(&stored, estate)
+
+	err = m.write(func(data *memoryData, tx *memoryTx) error {
+		put(tx, data.estates, stored.ID, stored)
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return stored.ID, nil
+}
+
+// UpdateEstateLayout store the estate dimensions and boundary, where it lies on earth and the drone
+// patrolling it, trees outside of the new dimensions must be deleted beforehand.
+func (m *Memory) UpdateEstateLayout(ctx context.Context, estate Estate) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		stored, found := data.estates[estate.ID]
+		if !found {
+			return nil
+		}
+
+		setEstateLayout(&stored, estate)
+		put(tx, data.estates, stored.ID, stored)
+
+		return nil
+	})
+}
+
+// setEstateLayout copy what UpdateEstateLayout store from estate to stored
+func setEstateLayout(stored *Estate, estate Estate) {
+	stored.Width = estate.Width
+	stored.Length = estate.Length
+	stored.OriginLat = clonePtr(estate.OriginLat)
+	stored.OriginLon = clonePtr(estate.OriginLon)
+	stored.Bearing = estate.Bearing
+	stored.Drone = estate.Drone
+	stored.Boundary = clonePolygon(estate.Boundary)
+}
+
+// DeleteEstate delete the estate, its patrol route, zones, pending and dead jobs, its trees too
+// when cascade. ErrEstateHasTrees is returned when the estate has trees but not cascade.
+func (m *Memory) DeleteEstate(ctx context.Context, estateID string, cascade bool) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		for treeID, tree := range data.trees {
+			if tree.EstateID != estateID {
+				continue
+			}
+			if !cascade {
+				return ErrEstateHasTrees
+			}
+
+			remove(tx, data.trees, treeID)
+			remove(tx, data.treeAt, memoryPlot{estateID: estateID, x: tree.X, y: tree.Y})
+		}
+
+		for zoneID, zone := range data.zones {
+			if zone.EstateID == estateID {
+				remove(tx, data.zones, zoneID)
+			}
+		}
+
+		for jobID, job := range data.jobs {
+			if job.EstateID == estateID {
+				remove(tx, data.jobs, jobID)
+				remove(tx, data.jobOf, memoryJobKey{kind: job.Kind, estateID: estateID})
+			}
+		}
+
+		for jobID, job := range data.deadJobs {
+			if job.EstateID == estateID {
+				remove(tx, data.deadJobs, jobID)
+			}
+		}
+
+		remove(tx, data.routeSteps, estateID)
+		remove(tx, data.estates, estateID)
+
+		return nil
+	})
+}
+
+// UpdateEstate store the estate stats and replace its patrol route steps.
+func (m *Memory) UpdateEstate(
+	ctx context.Context,
+	estateID string,
+	count, min, max, median, patrolDistance int,
+	patrolRoute []RouteStep,
+) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		stored, found := data.estates[estateID]
+		if !found {
+			// the steps of no estate, like the route_steps foreign key
+			if len(patrolRoute) > 0 {
+				return fmt.Errorf("%w: estate %s does not exist", ErrConflict, estateID)
+			}
+
+			return nil
+		}
+
+		stored.Count = count
+		stored.Min = min
+		stored.Max = max
+		stored.Median = median
+		stored.PatrolDistance = patrolDistance
+		stored.PatrolRoute = ""
+		put(tx, data.estates, estateID, stored)
+
+		return putRouteSteps(data, tx, estateID, patrolRoute)
+	})
+}
+
+// UpdateRouteSteps replace the patrol route steps of the estate, its stats are left as they are.
+func (m *Memory) UpdateRouteSteps(ctx context.Context, estateID string, patrolRoute []RouteStep) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		if _, found := data.estates[estateID]; !found {
+			// the steps of no estate, like the route_steps foreign key
+			if len(patrolRoute) > 0 {
+				return fmt.Errorf("%w: estate %s does not exist", ErrConflict, estateID)
+			}
+
+			return nil
+		}
+
+		return putRouteSteps(data, tx, estateID, patrolRoute)
+	})
+}
+
+// putRouteSteps store the route steps of the estate in step order, a step told twice is a conflict
+// like the route_steps primary key.
+func putRouteSteps(data *memoryData, tx *memoryTx, estateID string, patrolRoute []RouteStep) error {
+	steps := slices.Clone(patrolRoute)
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].Step < steps[j].Step
+	})
+	for i := 1; i < len(steps); i++ {
+		if steps[i].Step == steps[i-1].Step {
+			return fmt.Errorf("%w: route step %d is stored twice", ErrConflict, steps[i].Step)
+		}
+	}
+	put(tx, data.routeSteps, estateID, steps)
+
+	return nil
+}
+
+// cloneEstate return a copy of the estate sharing nothing with it
+func cloneEstate(estate Estate) Estate {
+	estate.OriginLat = clonePtr(estate.OriginLat)
+	estate.OriginLon = clonePtr(estate.OriginLon)
+	estate.Boundary = clonePolygon(estate.Boundary)
+
+	return estate
+}
+
+func clonePtr[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	clone := *value
+
+	return &clone
+}
+
+// clonePolygon return a copy of the polygon, nil for no vertex like decodePolygon
+func clonePolygon(polygon []Plot) []Plot {
+	if len(polygon) == 0 {
+		return nil
+	}
+
+	return slices.Clone(polygon)
+}
+
+// *** Tree ***
+
+// GetAllTreesInEstate return the trees of the estate row by row
+func (m *Memory) GetAllTreesInEstate(ctx context.Context, estateID string) ([]Tree, error) {
+	return m.treesWhere(func(tree Tree) bool {
+		return tree.EstateID == estateID
+	}, -1)
+}
+
+func (m *Memory) GetTreeByID(ctx context.Context, estateID, treeID string) (tree Tree, err error) {
+	err = m.read(func(data *memoryData) error {
+		stored, found := data.trees[treeID]
+		if !found || stored.EstateID != estateID {
+			return fmt.Errorf("%w: tree %s of estate %s", ErrNotFound, treeID, estateID)
+		}
+		tree = stored
+
+		return nil
+	})
+
+	return tree, err
+}
+
+// GetTreesPage return at most limit trees matching the filter ordered row by row,
+// only trees after plot (afterX, afterY), (0,0) for the first page.
+func (m *Memory) GetTreesPage(
+	ctx context.Context,
+	estateID string,
+	filter TreeFilter,
+	afterX, afterY, limit int,
+) ([]Tree, error) {
+	return m.treesWhere(func(tree Tree) bool {
+		return tree.EstateID == estateID &&
+			tree.X >= filter.FromX && tree.X <= filter.ToX &&
+			tree.Y >= filter.FromY && tree.Y <= filter.ToY &&
+			tree.Height >= filter.MinHeight && tree.Height <= filter.MaxHeight &&
+			(tree.Y > afterY || (tree.Y == afterY && tree.X > afterX))
+	}, limit)
+}
+
+// treesWhere return at most limit trees matching, all of them when limit is negative, ordered row by row
+func (m *Memory) treesWhere(match func(tree Tree) bool, limit int) ([]Tree, error) {
+	trees := make([]Tree, 0)
+	err := m.read(func(data *memoryData) error {
+		for _, tree := range data.trees {
+			if match(tree) {
+				trees = append(trees, tree)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(trees, func(i, j int) bool {
+		if trees[i].Y != trees[j].Y {
+			return trees[i].Y < trees[j].Y
+		}
+
+		return trees[i].X < trees[j].X
+	})
+	if limit >= 0 {
+		trees = trees[:min(limit, len(trees))]
+	}
+
+	return trees, nil
+}
+
+func (m *Memory) InsertTree(ctx context.Context, estateID string, x, y, height int) (string, error) {
+	uuidTreeID, err := uuidgen.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	err = m.write(func(data *memoryData, tx *memoryTx) error {
+		return insertTree(data, tx, Tree{ID: uuidTreeID.String(), EstateID: estateID, X: x, Y: y, Height: height})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return uuidTreeID.String(), nil
+}
+
+// InsertTrees stores all the trees, either all of them or none.
+// The returned tree IDs are in the same order as the given trees.
+func (m *Memory) InsertTrees(ctx context.Context, estateID string, trees []Tree) (treeIDs []string, err error) {
+	err = m.write(func(data *memoryData, tx *memoryTx) error {
+		treeIDs = make([]string, 0, len(trees))
+		for _, tree := range trees {
+			uuidTreeID, err := uuidgen.NewRandom()
+			if err != nil {
+				return err
+			}
+
+			err = insertTree(data, tx, Tree{ID: uuidTreeID.String(), EstateID: estateID, X: tree.X, Y: tree.Y, Height: tree.Height})
+			if err != nil {
+				return err
+			}
+
+			treeIDs = append(treeIDs, uuidTreeID.String())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return treeIDs, nil
+}
+
+// insertTree store the tree, ErrConflict is returned when its plot already has a tree
+// or its estate does not exist, like the unique and foreign key of the trees
+func insertTree(data *memoryData, tx *memoryTx, tree Tree) error {
+	if _, found := data.estates[tree.EstateID]; !found {
+		return fmt.Errorf("%w: estate %s does not exist", ErrConflict, tree.EstateID)
+	}
+
+	plot := memoryPlot{estateID: tree.EstateID, x: tree.X, y: tree.Y}
+	if _, found := data.treeAt[plot]; found {
+		return fmt.Errorf("%w: tree already exists at the specified location (%d,%d)", ErrConflict, tree.X, tree.Y)
+	}
+
+	put(tx, data.trees, tree.ID, tree)
+	put(tx, data.treeAt, plot, tree.ID)
+
+	return nil
+}
+
+func (m *Memory) UpdateTreeHeight(ctx context.Context, treeID string, height int) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		tree, found := data.trees[treeID]
+		if !found {
+			return nil
+		}
+
+		tree.Height = height
+		put(tx, data.trees, treeID, tree)
+
+		return nil
+	})
+}
+
+func (m *Memory) DeleteTree(ctx context.Context, treeID string) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		tree, found := data.trees[treeID]
+		if !found {
+			return nil
+		}
+
+		remove(tx, data.trees, treeID)
+		remove(tx, data.treeAt, memoryPlot{estateID: tree.EstateID, x: tree.X, y: tree.Y})
+
+		return nil
+	})
+}
+
+// DeleteTreesOutside delete the trees of the estate beyond column width or row length
+func (m *Memory) DeleteTreesOutside(ctx context.Context, estateID string, width, length int) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		for treeID, tree := range data.trees {
+			if tree.EstateID == estateID && (tree.X > width || tree.Y > length) {
+				remove(tx, data.trees, treeID)
+				remove(tx, data.treeAt, memoryPlot{estateID: estateID, x: tree.X, y: tree.Y})
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetPatrolNeighbourTrees return the trees right before and after plot (x,y) in the
+// drone patrol order, nil when there is no such tree.
+func (m *Memory) GetPatrolNeighbourTrees(ctx context.Context, estateID string, x, y int) (prev, next *Tree, err error) {
+	// odd row goes from column start to column end, even row the other way around
+	before := func(aX, aY, bX, bY int) bool {
+		if aY != bY {
+			return aY < bY
+		}
+		if aY%2 == 1 {
+			return aX < bX
+		}
+
+		return aX > bX
+	}
+
+	err = m.read(func(data *memoryData) error {
+		for _, tree := range data.trees {
+			if tree.EstateID != estateID {
+				continue
+			}
+
+			tree := tree
+			if before(tree.X, tree.Y, x, y) && (prev == nil || before(prev.X, prev.Y, tree.X, tree.Y)) {
+				prev = &tree
+			}
+			if before(x, y, tree.X, tree.Y) && (next == nil || before(tree.X, tree.Y, next.X, next.Y)) {
+				next = &tree
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return prev, next, nil
+}
+
+// GetTreeHeightCounts return number of trees in the estate for each tree height.
+func (m *Memory) GetTreeHeightCounts(ctx context.Context, estateID string) (map[int]int, error) {
+	heightCounts := make(map[int]int)
+	err := m.read(func(data *memoryData) error {
+		for _, tree := range data.trees {
+			if tree.EstateID == estateID {
+				heightCounts[tree.Height]++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return heightCounts, nil
+}
+
+// *** Zone ***
+
+// GetZones return every zone of the estate, the oldest first
+func (m *Memory) GetZones(ctx context.Context, estateID string) ([]Zone, error) {
+	stored := make([]memoryZone, 0)
+	err := m.read(func(data *memoryData) error {
+		for _, zone := range data.zones {
+			if zone.EstateID == estateID {
+				stored = append(stored, zone)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].seq < stored[j].seq
+	})
+	zones := make([]Zone, 0, len(stored))
+	for _, zone := range stored {
+		zones = append(zones, cloneZone(zone.Zone))
+	}
+
+	return zones, nil
+}
+
+func (m *Memory) GetZoneByID(ctx context.Context, estateID, zoneID string) (zone Zone, err error) {
+	err = m.read(func(data *memoryData) error {
+		stored, found := data.zones[zoneID]
+		if !found || stored.EstateID != estateID {
+			return fmt.Errorf("%w: zone %s of estate %s", ErrNotFound, zoneID, estateID)
+		}
+		zone = cloneZone(stored.Zone)
+
+		return nil
+	})
+
+	return zone, err
+}
+
+// InsertZone store the zone and count it in its estate
+func (m *Memory) InsertZone(ctx context.Context, zone Zone) (string, error) {
+	uuidZoneID, err := uuidgen.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	err = m.write(func(data *memoryData, tx *memoryTx) error {
+		estate, found := data.estates[zone.EstateID]
+		if !found {
+			return fmt.Errorf("%w: estate %s does not exist", ErrConflict, zone.EstateID)
+		}
+
+		data.seq++
+		stored := cloneZone(zone)
+		stored.ID = uuidZoneID.String()
+		put(tx, data.zones, stored.ID, memoryZone{Zone: stored, seq: data.seq})
+
+		estate.ZoneCount++
+		put(tx, data.estates, estate.ID, estate)
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return uuidZoneID.String(), nil
+}
+
+// UpdateZone store the kind, min altitude and polygon of the zone,
+// ErrNotFound is returned when the estate has no such zone
+func (m *Memory) UpdateZone(ctx context.Context, zone Zone) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		stored, found := data.zones[zone.ID]
+		if !found || stored.EstateID != zone.EstateID {
+			return fmt.Errorf("%w: zone %s of estate %s", ErrNotFound, zone.ID, zone.EstateID)
+		}
+
+		stored.Kind = zone.Kind
+		stored.MinAltitude = zone.MinAltitude
+		stored.Polygon = clonePolygon(zone.Polygon)
+		put(tx, data.zones, zone.ID, stored)
+
+		return nil
+	})
+}
+
+// DeleteZone delete the zone and uncount it from its estate,
+// ErrNotFound is returned when the estate has no such zone
+func (m *Memory) DeleteZone(ctx context.Context, estateID, zoneID string) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		stored, found := data.zones[zoneID]
+		if !found || stored.EstateID != estateID {
+			return fmt.Errorf("%w: zone %s of estate %s", ErrNotFound, zoneID, estateID)
+		}
+
+		remove(tx, data.zones, zoneID)
+
+		estate := data.estates[estateID]
+		estate.ZoneCount--
+		put(tx, data.estates, estateID, estate)
+
+		return nil
+	})
+}
+
+func cloneZone(zone Zone) Zone {
+	zone.Polygon = clonePolygon(zone.Polygon)
+
+	return zone
+}
+
+// *** Route ***
+func (m *Memory) GetRouteSteps(ctx context.Context, estateID string) ([]RouteStep, error) {
+	return m.routeStepsWhere(estateID, func(step RouteStep) bool {
+		return true
+	}, -1)
+}
+
+// GetRouteStepsPage return at most limit steps after the step afterStep,
+// only steps which travel overlaps the distance range [fromDistance, toDistance].
+func (m *Memory) GetRouteStepsPage(
+	ctx context.Context,
+	estateID string,
+	afterStep, fromDistance, toDistance, limit int,
+) ([]RouteStep, error) {
+	return m.routeStepsWhere(estateID, func(step RouteStep) bool {
+		return step.Step > afterStep &&
+			step.CurrentDistance >= fromDistance &&
+			step.CurrentDistance-step.StepDistance <= toDistance
+	}, limit)
+}
+
+// routeStepsWhere return at most limit steps of the estate matching, all of them when limit is negative
+func (m *Memory) routeStepsWhere(estateID string, match func(step RouteStep) bool, limit int) ([]RouteStep, error) {
+	steps := make([]RouteStep, 0)
+	err := m.read(func(data *memoryData) error {
+		for _, step := range data.routeSteps[estateID] {
+			if limit >= 0 && len(steps) >= limit {
+				break
+			}
+			if match(step) {
+				steps = append(steps, step)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return steps, nil
+}
+
+// *** Job ***
+
+// EnqueueRecalculation mark the estate stats pending until a job recalculate them.
+// A single job is pending per estate, enqueuing again give it a fresh start instead.
+func (m *Memory) EnqueueRecalculation(ctx context.Context, estateID string) error {
+	uuidJobID, err := uuidgen.NewRandom()
+	if err != nil {
+		return err
+	}
+
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		estate, found := data.estates[estateID]
+		if !found {
+			return fmt.Errorf("%w: estate %s does not exist", ErrConflict, estateID)
+		}
+
+		key := memoryJobKey{kind: JobKindRecalculateEstate, estateID: estateID}
+		job, found := data.jobs[data.jobOf[key]]
+		if !found {
+			job = memoryJob{Job: Job{ID: uuidJobID.String(), Kind: JobKindRecalculateEstate, EstateID: estateID}}
+			put(tx, data.jobOf, key, job.ID)
+		}
+		job.runAt = time.Now()
+		job.Attempts = 0
+		job.lastErr = ""
+		put(tx, data.jobs, job.ID, job)
+
+		estate.StatsStatus = StatsStatusPending
+		put(tx, data.estates, estateID, estate)
+
+		return nil
+	})
+}
+
+// ClaimJob return the job due the earliest, nil when there is none.
+// The job is claimed for the lease duration.
+func (m *Memory) ClaimJob(ctx context.Context, lease time.Duration) (claimed *Job, err error) {
+	err = m.write(func(data *memoryData, tx *memoryTx) error {
+		now := time.Now()
+
+		var due *memoryJob
+		for _, job := range data.jobs {
+			if job.runAt.After(now) {
+				continue
+			}
+
+			job := job
+			if due == nil || job.runAt.Before(due.runAt) || (job.runAt.Equal(due.runAt) && job.ID < due.ID) {
+				due = &job
+			}
+		}
+		if due == nil {
+			return nil
+		}
+
+		due.Attempts++
+		due.runAt = now.Add(lease)
+		put(tx, data.jobs, due.ID, *due)
+
+		job := due.Job
+		claimed = &job
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return claimed, nil
+}
+
+// CompleteJob delete the job which is done and mark the estate stats fresh.
+func (m *Memory) CompleteJob(ctx context.Context, jobID string) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		job, found := data.jobs[jobID]
+		if !found {
+			return nil
+		}
+
+		remove(tx, data.jobs, jobID)
+		remove(tx, data.jobOf, memoryJobKey{kind: job.Kind, estateID: job.EstateID})
+
+		estate, found := data.estates[job.EstateID]
+		if found {
+			estate.StatsStatus = StatsStatusFresh
+			put(tx, data.estates, job.EstateID, estate)
+		}
+
+		return nil
+	})
+}
+
+// RetryJob run the failed job again at runAt.
+func (m *Memory) RetryJob(ctx context.Context, jobID string, runAt time.Time, lastErr string) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		job, found := data.jobs[jobID]
+		if found {
+			job.runAt = runAt
+			job.lastErr = lastErr
+			put(tx, data.jobs, jobID, job)
+		}
+
+		return nil
+	})
+}
+
+// DeadLetterJob move the job which ran out of attempts to the dead jobs and mark
+// the estate stats failed. The job stays when it was enqueued again since it was claimed.
+func (m *Memory) DeadLetterJob(ctx context.Context, job Job, lastErr string) error {
+	return m.write(func(data *memoryData, tx *memoryTx) error {
+		stored, found := data.jobs[job.ID]
+		if !found || stored.Attempts != job.Attempts {
+			return nil
+		}
+
+		remove(tx, data.jobs, job.ID)
+		remove(tx, data.jobOf, memoryJobKey{kind: stored.Kind, estateID: stored.EstateID})
+
+		if _, found := data.deadJobs[job.ID]; found {
+			return fmt.Errorf("%w: dead job %s already exists", ErrConflict, job.ID)
+		}
+		put(tx, data.deadJobs, job.ID, memoryJob{Job: job, lastErr: lastErr})
+
+		estate, found := data.estates[job.EstateID]
+		if found {
+			estate.StatsStatus = StatsStatusFailed
+			put(tx, data.estates, job.EstateID, estate)
+		}
+
+		return nil
+	})
+}
@@ -2,6 +2,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"log"
 
@@ -10,10 +11,23 @@ import (
 
 const (
 	driverPostgresDB = "postgres"
+	// below postgres default max_connections of 100
+	maxOpenConns = 50
 )
 
 type Repository struct {
 	db *sql.DB
+	// tx is set on the repository handed over by WithTx, every query then runs in it
+	tx *sql.Tx
+}
+
+// dbConn is what *sql.DB and *sql.Tx have in common, so queries run the same way
+// whether the repository is in a transaction or not
+type dbConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
 }
 
 func New(dbDsn string) *Repository {
@@ -29,7 +43,57 @@ func New(dbDsn string) *Repository {
 		log.Panicf("[NewRepository] failed to established connection with database, err:%+v\n", err)
 	}
 
+	// requests changing the same estate hold their connection while waiting
+	// for the estate lock, the rest wait for a connection instead of failing
+	pgdb.SetMaxOpenConns(maxOpenConns)
+
 	return &Repository{
 		db: pgdb,
 	}
 }
+
+// WithTx run fn with a repository whose every query is in a single transaction,
+// committed when fn succeed and rolled back when it return an error or panic.
+// Nested calls join the transaction already running.
+func (rp *Repository) WithTx(ctx context.Context, fn func(repo Repositorier) error) error {
+	return rp.inTx(ctx, func(tx *sql.Tx) error {
+		return fn(&Repository{db: rp.db, tx: tx})
+	})
+}
+
+func (rp *Repository) conn() dbConn {
+	if rp.tx != nil {
+		return rp.tx
+	}
+
+	return rp.db
+}
+
+// inTx run fn in the repository transaction, or in a transaction of its own
+// when the repository is not in one yet. Database errors are wrapped by dbError.
+func (rp *Repository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	if rp.tx != nil {
+		return dbError(fn(rp.tx))
+	}
+
+	tx, err := rp.db.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError(err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = fn(tx)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return dbError(tx.Commit())
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTx(t *testing.T) {
+	tests := []struct {
+		name          string
+		mockUpdateErr error
+		panics        bool
+		expectedErr   error
+	}{
+		{
+			name:        "Committed",
+			expectedErr: nil,
+		},
+		{
+			name:          "Rolled back on error",
+			mockUpdateErr: errors.New("database error"),
+			expectedErr:   errors.New("database error"),
+		},
+		{
+			name:   "Rolled back on panic",
+			panics: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			// a single transaction, UpdateEstate joins it instead of beginning its own
+			mock.ExpectBegin()
+			mock.ExpectExec(`INSERT INTO trees`).
+				WithArgs("estate_id_value", sqlmock.AnyArg(), 1, 1, 10).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			if !tc.panics {
+				exec := mock.ExpectExec(`UPDATE estates`).WithArgs("estate_id_value", 1, 10, 10, 10, 22)
+				if tc.mockUpdateErr != nil {
+					exec.WillReturnError(tc.mockUpdateErr)
+				} else {
+					exec.WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec(`DELETE FROM route_steps WHERE estate_id = \$1`).
+						WithArgs("estate_id_value").
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+			}
+			if tc.expectedErr != nil || tc.panics {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+
+			// Call the function under test
+			withTx := func() error {
+				return repo.WithTx(context.Background(), func(txRepo Repositorier) error {
+					_, err := txRepo.InsertTree(context.Background(), "estate_id_value", 1, 1, 10)
+					if err != nil {
+						return err
+					}
+					if tc.panics {
+						panic("patrol failed")
+					}
+
+					return txRepo.UpdateEstate(context.Background(), "estate_id_value", 1, 10, 10, 10, 22, nil)
+				})
+			}
+
+			// Verify the result
+			if tc.panics {
+				require.PanicsWithValue(t, "patrol failed", func() { withTx() })
+			} else {
+				require.Equal(t, tc.expectedErr, withTx())
+			}
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
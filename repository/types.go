@@ -1,6 +1,8 @@
 // This file contains types that are used in the repository layer.
 package repository
 
+import "time"
+
 type Estate struct {
 	ID             string `db:"estate_id"`
 	Width          int    `db:"width"`
@@ -11,6 +13,82 @@ type Estate struct {
 	Median         int    `db:"median"`
 	PatrolDistance int    `db:"patrol_distance"`
 	PatrolRoute    string `db:"patrol_route"`
+	// origin is the coordinate of the center of plot (1,1), nil when the estate is not geo-referenced
+	OriginLat *float64 `db:"origin_lat"`
+	OriginLon *float64 `db:"origin_lon"`
+	Bearing   float64  `db:"bearing"`
+	// StatsStatus tells whether the stats and patrol count every tree, see StatsStatusFresh
+	StatsStatus string `db:"stats_status"`
+	// Drone is the drone patrolling the estate, see DefaultDroneProfile
+	Drone DroneProfile
+	// ZoneCount is the number of zones of the estate, Zones are only read when there are some
+	ZoneCount int    `db:"zone_count"`
+	Zones     []Zone `db:"-"`
+	// Boundary is the shape of the estate within Width x Length, the plots of the estate are
+	// the plots which center is inside the polygon or on its boundary. Nil when the estate
+	// is the whole rectangle.
+	Boundary  []Plot    `db:"boundary"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// DroneProfile is what the drone patrolling the estate is capable of and allowed to,
+// distances and altitudes are in meters
+type DroneProfile struct {
+	// PlotSize is the distance between the centers of two neighbour plots
+	PlotSize int `db:"plot_size"`
+	// MonitorClearance is how high above the tree top the drone monitors the tree
+	MonitorClearance int `db:"monitor_clearance"`
+	// CruiseAltitudeMin is the lowest altitude the drone monitors a tree from,
+	// the drone still takes off from and lands on the ground
+	CruiseAltitudeMin int `db:"cruise_altitude_min"`
+	// MaxAltitude is the highest altitude the drone is allowed to, trees too tall
+	// to be monitored with the clearance are monitored from it
+	MaxAltitude int `db:"max_altitude"`
+}
+
+// DefaultDroneProfile is the drone of the estates created without one: 10 meters plots,
+// monitoring 1 meter above the tree top, right from the ground up to 120 meters
+func DefaultDroneProfile() DroneProfile {
+	return DroneProfile{
+		PlotSize:          10,
+		MonitorClearance:  1,
+		CruiseAltitudeMin: 0,
+		MaxAltitude:       120,
+	}
+}
+
+const (
+	// StatsStatusFresh the stats and patrol count every tree of the estate
+	StatsStatusFresh = "fresh"
+	// StatsStatusPending the trees changed, a job recalculating the stats and patrol is due
+	StatsStatusPending = "pending"
+	// StatsStatusFailed the job recalculating the stats and patrol ran out of attempts
+	StatsStatusFailed = "failed"
+)
+
+// zone kinds, see Zone
+const (
+	// ZoneKindForbidden the drone never flies over the zone, it flies around it
+	ZoneKindForbidden = "forbidden"
+	// ZoneKindMinAltitude the drone flies over the zone at MinAltitude at least
+	ZoneKindMinAltitude = "min-altitude"
+)
+
+// Zone is an area of the estate the drone keeps out of or flies high enough over,
+// e.g. a building or a power line. The plots of the zone are the plots which center
+// is inside Polygon or on its boundary.
+type Zone struct {
+	ID          string `db:"zone_id"`
+	EstateID    string `db:"estate_id"`
+	Kind        string `db:"kind"`
+	MinAltitude int    `db:"min_altitude"`
+	// Polygon vertices are plots of the estate in order along the boundary
+	Polygon []Plot
+}
+
+// Plot is plot (X,Y) of the estate
+type Plot struct {
+	X, Y int
 }
 
 type Tree struct {
@@ -20,3 +98,31 @@ type Tree struct {
 	Y        int    `db:"y"`
 	Height   int    `db:"height"`
 }
+
+// TreeFilter narrow down the trees to plots and heights inside the ranges, inclusive on both ends
+type TreeFilter struct {
+	FromX, ToX           int
+	FromY, ToY           int
+	MinHeight, MaxHeight int
+}
+
+type RouteStep struct {
+	Step            int    `db:"step"`
+	X               int    `db:"x"`
+	Y               int    `db:"y"`
+	Direction       string `db:"direction"`
+	StepDistance    int    `db:"step_distance"`
+	CurrentDistance int    `db:"current_distance"`
+	Altitude        int    `db:"altitude"`
+}
+
+// JobKindRecalculateEstate recalculate the stats and patrol of the estate from all its trees
+const JobKindRecalculateEstate = "recalculate_estate"
+
+type Job struct {
+	ID       string `db:"job_id"`
+	Kind     string `db:"kind"`
+	EstateID string `db:"estate_id"`
+	// Attempts count the attempt running the job too
+	Attempts int `db:"attempts"`
+}
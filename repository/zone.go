@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nahwinrajan/testswpro/uuidgen"
+)
+
+const (
+	// *** Zone ***
+	queryGetZonesByEstateID = `SELECT
+		zone_id, estate_id, kind, min_altitude, polygon
+	 FROM zones
+	 WHERE estate_id = $1
+	 ORDER BY created_at, zone_id`
+
+	queryGetZoneByID = `SELECT
+		zone_id, estate_id, kind, min_altitude, polygon
+	 FROM zones
+	 WHERE estate_id = $1 AND zone_id = $2`
+
+	queryInsertZone = `
+		INSERT INTO zones (zone_id, estate_id, kind, min_altitude, polygon)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	queryUpdateZone = `
+		UPDATE zones
+		SET
+			kind = $3,
+			min_altitude = $4,
+			polygon = $5,
+			updated_at = now()
+		WHERE
+			estate_id = $1 AND zone_id = $2
+	`
+	queryDeleteZone = `DELETE FROM zones WHERE estate_id = $1 AND zone_id = $2`
+
+	queryDeleteZonesByEstateID = `DELETE FROM zones WHERE estate_id = $1`
+
+	// zone_count tells the patrol whether the zones of the estate must be read at all
+	queryAddEstateZoneCount = `UPDATE estates SET zone_count = zone_count + $2, updated_at = now() WHERE estate_id = $1`
+)
+
+// GetZones return every zone of the estate, the oldest first
+func (rp *Repository) GetZones(ctx context.Context, estateID string) ([]Zone, error) {
+	rows, err := rp.conn().QueryContext(ctx, queryGetZonesByEstateID, estateID)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	zones := make([]Zone, 0)
+	for rows.Next() {
+		zone, err := scanZone(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+
+	return zones, nil
+}
+
+func (rp *Repository) GetZoneByID(ctx context.Context, estateID, zoneID string) (Zone, error) {
+	return scanZone(rp.conn().QueryRowContext(ctx, queryGetZoneByID, estateID, zoneID))
+}
+
+// scanZone read the zone columns in the order every zone query select them
+func scanZone(row interface{ Scan(dest ...any) error }) (Zone, error) {
+	var zone Zone
+	var polygon string
+
+	err := row.Scan(&zone.ID, &zone.EstateID, &zone.Kind, &zone.MinAltitude, &polygon)
+	if err != nil {
+		return Zone{}, dbError(err)
+	}
+
+	zone.Polygon, err = decodePolygon(polygon)
+	if err != nil {
+		return Zone{}, fmt.Errorf("%w: zone %s: %w", ErrInvalid, zone.ID, err)
+	}
+
+	return zone, nil
+}
+
+// InsertZone store the zone and count it in its estate in a single transaction
+func (rp *Repository) InsertZone(ctx context.Context, zone Zone) (string, error) {
+	uuidZoneID, err := uuidgen.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	err = rp.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(
+			ctx,
+			queryInsertZone,
+			uuidZoneID.String(),
+			zone.EstateID,
+			zone.Kind,
+			zone.MinAltitude,
+			encodePolygon(zone.Polygon),
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, queryAddEstateZoneCount, zone.EstateID, 1)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return uuidZoneID.String(), nil
+}
+
+// UpdateZone store the kind, min altitude and polygon of the zone,
+// ErrNotFound is returned when the estate has no such zone
+func (rp *Repository) UpdateZone(ctx context.Context, zone Zone) error {
+	result, err := rp.conn().ExecContext(
+		ctx,
+		queryUpdateZone,
+		zone.EstateID,
+		zone.ID,
+		zone.Kind,
+		zone.MinAltitude,
+		encodePolygon(zone.Polygon),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+
+	return dbError(requireAffected(result))
+}
+
+// DeleteZone delete the zone and uncount it from its estate in a single transaction,
+// ErrNotFound is returned when the estate has no such zone
+func (rp *Repository) DeleteZone(ctx context.Context, estateID, zoneID string) error {
+	return rp.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, queryDeleteZone, estateID, zoneID)
+		if err != nil {
+			return err
+		}
+
+		err = requireAffected(result)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, queryAddEstateZoneCount, estateID, -1)
+		return err
+	})
+}
+
+// requireAffected return sql.ErrNoRows when the statement changed no row
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected < 1 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// encodePolygon write the polygon vertices as "x,y;x,y;..."
+func encodePolygon(polygon []Plot) string {
+	vertices := make([]string, len(polygon))
+	for i, vertex := range polygon {
+		vertices[i] = strconv.Itoa(vertex.X) + "," + strconv.Itoa(vertex.Y)
+	}
+
+	return strings.Join(vertices, ";")
+}
+
+// decodePolygon read the polygon vertices written by encodePolygon
+func decodePolygon(text string) ([]Plot, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	vertices := strings.Split(text, ";")
+	polygon := make([]Plot, len(vertices))
+	for i, vertex := range vertices {
+		strX, strY, found := strings.Cut(vertex, ",")
+		x, errX := strconv.Atoi(strX)
+		y, errY := strconv.Atoi(strY)
+		if !found || errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid polygon vertex %q", vertex)
+		}
+		polygon[i] = Plot{X: x, Y: y}
+	}
+
+	return polygon, nil
+}
@@ -0,0 +1,339 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func zoneColumns() []string {
+	return []string{"zone_id", "estate_id", "kind", "min_altitude", "polygon"}
+}
+
+func TestGetZones(t *testing.T) {
+	tests := []struct {
+		name          string
+		rows          [][]any
+		mockErr       error
+		mockRowErr    error
+		expectedZones []Zone
+		expectedErr   error
+	}{
+		{
+			name: "Estate with zones",
+			rows: [][]any{
+				{"zone_id_1", "estate_id_value", "forbidden", 0, "2,2;4,2;4,3;2,3"},
+				{"zone_id_2", "estate_id_value", "min-altitude", 30, "1,5;3,7;5,5"},
+			},
+			expectedZones: []Zone{
+				{
+					ID:       "zone_id_1",
+					EstateID: "estate_id_value",
+					Kind:     ZoneKindForbidden,
+					Polygon:  []Plot{{X: 2, Y: 2}, {X: 4, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 3}},
+				},
+				{
+					ID:          "zone_id_2",
+					EstateID:    "estate_id_value",
+					Kind:        ZoneKindMinAltitude,
+					MinAltitude: 30,
+					Polygon:     []Plot{{X: 1, Y: 5}, {X: 3, Y: 7}, {X: 5, Y: 5}},
+				},
+			},
+		},
+		{
+			name:          "Estate without zones",
+			expectedZones: []Zone{},
+		},
+		{
+			name:        "Invalid polygon",
+			rows:        [][]any{{"zone_id_1", "estate_id_value", "forbidden", 0, "2,2;4"}},
+			expectedErr: ErrInvalid,
+		},
+		{
+			name:        "Database error",
+			mockErr:     errors.New("database error"),
+			expectedErr: errors.New("database error"),
+		},
+		{
+			// the second zone never arrives, no truncated list is returned
+			name: "Connection lost mid result",
+			rows: [][]any{
+				{"zone_id_1", "estate_id_value", "forbidden", 0, "2,2;4,2;4,3;2,3"},
+				{"zone_id_2", "estate_id_value", "min-altitude", 30, "1,5;3,7;5,5"},
+			},
+			mockRowErr:  errors.New("connection reset"),
+			expectedErr: errors.New("connection reset"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			query := mock.ExpectQuery(`SELECT .* FROM zones WHERE estate_id = \$1 ORDER BY created_at, zone_id`).WithArgs("estate_id_value")
+			if tc.mockErr != nil {
+				query.WillReturnError(tc.mockErr)
+			} else {
+				rows := sqlmock.NewRows(zoneColumns())
+				for _, row := range tc.rows {
+					rows.AddRow(row[0], row[1], row[2], row[3], row[4])
+				}
+				if tc.mockRowErr != nil {
+					rows.RowError(1, tc.mockRowErr)
+				}
+				query.WillReturnRows(rows)
+			}
+
+			// Call the function under test
+			zones, err := repo.GetZones(context.Background(), "estate_id_value")
+
+			// Verify the result
+			if errors.Is(tc.expectedErr, ErrInvalid) {
+				require.ErrorIs(t, err, ErrInvalid)
+			} else {
+				require.Equal(t, tc.expectedErr, err)
+			}
+			require.Equal(t, tc.expectedZones, zones)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetZoneByID(t *testing.T) {
+	tests := []struct {
+		name         string
+		expectedZone Zone
+		expectedErr  error
+	}{
+		{
+			name: "Valid zone ID",
+			expectedZone: Zone{
+				ID:       "zone_id_value",
+				EstateID: "estate_id_value",
+				Kind:     ZoneKindForbidden,
+				Polygon:  []Plot{{X: 2, Y: 2}, {X: 4, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 3}},
+			},
+		},
+		{
+			name:        "Zone not found",
+			expectedErr: sql.ErrNoRows,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			query := mock.ExpectQuery(`SELECT .* FROM zones WHERE estate_id = \$1 AND zone_id = \$2`).WithArgs("estate_id_value", "zone_id_value")
+			if tc.expectedErr != nil {
+				query.WillReturnError(tc.expectedErr)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows(zoneColumns()).
+					AddRow("zone_id_value", "estate_id_value", "forbidden", 0, "2,2;4,2;4,3;2,3"))
+			}
+
+			// Call the function under test
+			zone, err := repo.GetZoneByID(context.Background(), "estate_id_value", "zone_id_value")
+
+			// Verify the result
+			require.ErrorIs(t, err, tc.expectedErr)
+			if errors.Is(tc.expectedErr, sql.ErrNoRows) {
+				require.ErrorIs(t, err, ErrNotFound)
+			}
+			require.Equal(t, tc.expectedZone, zone)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestInsertZone(t *testing.T) {
+	zone := Zone{
+		EstateID:    "estate_id_value",
+		Kind:        ZoneKindMinAltitude,
+		MinAltitude: 30,
+		Polygon:     []Plot{{X: 1, Y: 5}, {X: 3, Y: 7}, {X: 5, Y: 5}},
+	}
+
+	tests := []struct {
+		name        string
+		mockErr     error
+		expectedErr error
+	}{
+		{
+			name: "Valid zone insertion",
+		},
+		{
+			name:        "Database error",
+			mockErr:     errors.New("database error"),
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			mock.ExpectBegin()
+			exec := mock.ExpectExec(`INSERT INTO zones \(zone_id, estate_id, kind, min_altitude, polygon\) VALUES \(\$1, \$2, \$3, \$4, \$5\)`).
+				WithArgs(sqlmock.AnyArg(), "estate_id_value", "min-altitude", 30, "1,5;3,7;5,5")
+			if tc.mockErr != nil {
+				exec.WillReturnError(tc.mockErr)
+				mock.ExpectRollback()
+			} else {
+				exec.WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE estates SET zone_count = zone_count \+ \$2`).
+					WithArgs("estate_id_value", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			// Call the function under test
+			zoneID, err := repo.InsertZone(context.Background(), zone)
+
+			// Verify the result
+			require.Equal(t, tc.expectedErr, err)
+			if tc.expectedErr == nil {
+				_, err = uuid.Parse(zoneID)
+				require.NoError(t, err)
+			}
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpdateZone(t *testing.T) {
+	zone := Zone{
+		ID:       "zone_id_value",
+		EstateID: "estate_id_value",
+		Kind:     ZoneKindForbidden,
+		Polygon:  []Plot{{X: 2, Y: 2}, {X: 4, Y: 2}, {X: 4, Y: 3}, {X: 2, Y: 3}},
+	}
+
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		expectedErr  error
+	}{
+		{
+			name:         "Valid zone update",
+			rowsAffected: 1,
+		},
+		{
+			name:         "Zone not found",
+			rowsAffected: 0,
+			expectedErr:  ErrNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			mock.ExpectExec(`UPDATE zones SET .* WHERE estate_id = \$1 AND zone_id = \$2`).
+				WithArgs("estate_id_value", "zone_id_value", "forbidden", 0, "2,2;4,2;4,3;2,3").
+				WillReturnResult(sqlmock.NewResult(0, tc.rowsAffected))
+
+			// Call the function under test
+			err = repo.UpdateZone(context.Background(), zone)
+
+			// Verify the result
+			require.ErrorIs(t, err, tc.expectedErr)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteZone(t *testing.T) {
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		expectedErr  error
+	}{
+		{
+			name:         "Valid zone deletion",
+			rowsAffected: 1,
+		},
+		{
+			name:         "Zone not found",
+			rowsAffected: 0,
+			expectedErr:  ErrNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create mock database and repository
+			dbmock, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer dbmock.Close()
+
+			repo := Repository{
+				db: dbmock,
+			}
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`DELETE FROM zones WHERE estate_id = \$1 AND zone_id = \$2`).
+				WithArgs("estate_id_value", "zone_id_value").
+				WillReturnResult(sqlmock.NewResult(0, tc.rowsAffected))
+			if tc.expectedErr != nil {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec(`UPDATE estates SET zone_count = zone_count \+ \$2`).
+					WithArgs("estate_id_value", -1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			// Call the function under test
+			err = repo.DeleteZone(context.Background(), "estate_id_value", "zone_id_value")
+
+			// Verify the result
+			require.ErrorIs(t, err, tc.expectedErr)
+
+			// Make sure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Package routecodec read and write the legacy text encoding of the patrol route
+// stored in estates.patrol_route, each step is "step_#,x,y,direction,step_distance,current_distance;".
+package routecodec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nahwinrajan/testswpro/repository"
+)
+
+const (
+	stepSeparator  = ";"
+	fieldSeparator = ","
+
+	// stepStrFormat: step_#,x,y,direction,step_distance,current_distance
+	stepStrFormat = "%d,%d,%d,%s,%d,%d;"
+
+	directionVU = "vu" // up adjusting for tree height
+	directionVD = "vd" // down adjusting for tree height
+)
+
+// Decode parse the legacy text route into steps. The step_distance written for
+// horizontal moves was not reliable, so it is derived from current_distance instead.
+// The legacy route has no altitude, it is followed from the vertical moves.
+func Decode(text string) ([]repository.RouteStep, error) {
+	text = strings.TrimSuffix(text, stepSeparator)
+	if len(text) == 0 {
+		return nil, nil
+	}
+
+	strSteps := strings.Split(text, stepSeparator)
+	steps := make([]repository.RouteStep, 0, len(strSteps))
+
+	var prevDistance, altitude int
+	for _, strStep := range strSteps {
+		strs := strings.Split(strStep, fieldSeparator)
+		if len(strs) != 6 {
+			return nil, fmt.Errorf("invalid route step: %q", strStep)
+		}
+
+		values := make([]int, 0, 5)
+		for _, idx := range []int{0, 1, 2, 4, 5} {
+			value, err := strconv.Atoi(strs[idx])
+			if err != nil {
+				return nil, fmt.Errorf("invalid route step: %q, err:%w", strStep, err)
+			}
+			values = append(values, value)
+		}
+
+		stepDistance := values[4] - prevDistance
+		switch strs[3] {
+		case directionVU:
+			altitude += stepDistance
+		case directionVD:
+			altitude -= stepDistance
+		}
+
+		steps = append(steps, repository.RouteStep{
+			Step:            values[0],
+			X:               values[1],
+			Y:               values[2],
+			Direction:       strs[3],
+			StepDistance:    stepDistance,
+			CurrentDistance: values[4],
+			Altitude:        altitude,
+		})
+		prevDistance = values[4]
+	}
+
+	return steps, nil
+}
+
+// Encode write the steps into the legacy text route.
+func Encode(steps []repository.RouteStep) string {
+	var strb strings.Builder
+	for _, step := range steps {
+		fmt.Fprintf(&strb, stepStrFormat, step.Step, step.X, step.Y, step.Direction, step.StepDistance, step.CurrentDistance)
+	}
+
+	return strb.String()
+}
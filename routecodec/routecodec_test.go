@@ -0,0 +1,83 @@
+package routecodec
+
+import (
+	"testing"
+
+	"github.com/nahwinrajan/testswpro/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		expectedSteps []repository.RouteStep
+		expectedError bool
+	}{
+		{
+			name: "Legacy route with unreliable step distance",
+			text: "1,1,1,ew,0,10;2,2,1,vu,6,16;3,2,1,ew,6,26;4,2,2,sn,10,36;",
+			expectedSteps: []repository.RouteStep{
+				{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 10},
+				{Step: 2, X: 2, Y: 1, Direction: "vu", StepDistance: 6, CurrentDistance: 16, Altitude: 6},
+				{Step: 3, X: 2, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 26, Altitude: 6},
+				{Step: 4, X: 2, Y: 2, Direction: "sn", StepDistance: 10, CurrentDistance: 36, Altitude: 6},
+			},
+		},
+		{
+			name: "Altitude follows vertical moves",
+			text: "1,1,1,vu,5,5;2,1,1,ew,10,15;3,2,1,vd,3,18;4,2,1,ew,10,28;5,3,1,--,0,28;",
+			expectedSteps: []repository.RouteStep{
+				{Step: 1, X: 1, Y: 1, Direction: "vu", StepDistance: 5, CurrentDistance: 5, Altitude: 5},
+				{Step: 2, X: 1, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 15, Altitude: 5},
+				{Step: 3, X: 2, Y: 1, Direction: "vd", StepDistance: 3, CurrentDistance: 18, Altitude: 2},
+				{Step: 4, X: 2, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 28, Altitude: 2},
+				{Step: 5, X: 3, Y: 1, Direction: "--", StepDistance: 0, CurrentDistance: 28, Altitude: 2},
+			},
+		},
+		{
+			name:          "Empty route",
+			text:          "",
+			expectedSteps: nil,
+		},
+		{
+			name:          "Missing fields",
+			text:          "1,1,1,ew;",
+			expectedError: true,
+		},
+		{
+			name:          "Not a number",
+			text:          "1,1,1,ew,0,ten;",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			steps, err := Decode(tc.text)
+
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedSteps, steps)
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	steps := []repository.RouteStep{
+		{Step: 1, X: 1, Y: 1, Direction: "ew", StepDistance: 30, CurrentDistance: 30},
+		{Step: 2, X: 4, Y: 1, Direction: "vu", StepDistance: 10, CurrentDistance: 40, Altitude: 10},
+		{Step: 3, X: 4, Y: 1, Direction: "ew", StepDistance: 10, CurrentDistance: 50, Altitude: 10},
+	}
+
+	text := Encode(steps)
+	require.Equal(t, "1,1,1,ew,30,30;2,4,1,vu,10,40;3,4,1,ew,10,50;", text)
+
+	decodedSteps, err := Decode(text)
+	require.NoError(t, err)
+	require.Equal(t, steps, decodedSteps)
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nahwinrajan/testswpro/generated"
+	"github.com/stretchr/testify/require"
+)
+
+const defaultApiURL = "http://localhost:8080"
+
+// TestConcurrentTreeInserts plant hundreds of trees in parallel, the estate
+// metadata must end up the same as planting all of them at once.
+// Needs the API running, e.g. with docker compose up.
+func TestConcurrentTreeInserts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skip API tests")
+	}
+
+	apiURL := os.Getenv("API_URL")
+	if apiURL == "" {
+		apiURL = defaultApiURL
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(apiURL + "/estate?limit=1")
+	if err != nil {
+		t.Skipf("Skip API tests, API not reachable at %s, err:%s", apiURL, err)
+	}
+	resp.Body.Close()
+
+	// one tree on every plot of the estate, planted in random order
+	const width, length = 20, 15
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	trees := make([]generated.CreateTreeRequestBody, 0, width*length)
+	for y := 1; y <= length; y++ {
+		for x := 1; x <= width; x++ {
+			trees = append(trees, generated.CreateTreeRequestBody{X: x, Y: y, Height: 1 + random.Intn(30)})
+		}
+	}
+	random.Shuffle(len(trees), func(i, j int) { trees[i], trees[j] = trees[j], trees[i] })
+
+	parallelEstateID := createEstate(t, client, apiURL, width, length)
+
+	var wg sync.WaitGroup
+	statusCodes := make(chan int, len(trees))
+	for _, tree := range trees {
+		wg.Add(1)
+		go func(tree generated.CreateTreeRequestBody) {
+			defer wg.Done()
+
+			resp, err := postJSON(client, apiURL+"/estate/"+parallelEstateID+"/tree", tree)
+			if err != nil {
+				statusCodes <- 0
+				return
+			}
+			resp.Body.Close()
+			statusCodes <- resp.StatusCode
+		}(tree)
+	}
+	wg.Wait()
+	close(statusCodes)
+
+	for statusCode := range statusCodes {
+		require.Equal(t, http.StatusCreated, statusCode)
+	}
+
+	// the batch patrol the estate from scratch
+	batchEstateID := createEstate(t, client, apiURL, width, length)
+	resp, err = postJSON(client, apiURL+"/estate/"+batchEstateID+"/trees:batch", trees)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	heights := make([]int, 0, len(trees))
+	for _, tree := range trees {
+		heights = append(heights, tree.Height)
+	}
+	sort.Ints(heights)
+	median := heights[len(heights)/2]
+	if len(heights)%2 == 0 {
+		median = (heights[len(heights)/2-1] + heights[len(heights)/2]) / 2
+	}
+
+	// the batch is recalculated in the background
+	parallelStats := freshStats(t, client, apiURL, parallelEstateID)
+	batchStats := freshStats(t, client, apiURL, batchEstateID)
+	require.Equal(t, generated.EstateStatsResponse{
+		Count:       len(trees),
+		Min:         heights[0],
+		Max:         heights[len(heights)-1],
+		Median:      median,
+		StatsStatus: generated.Fresh,
+	}, parallelStats)
+	require.Equal(t, batchStats, parallelStats)
+
+	var parallelPlan, batchPlan generated.EstateDronePlanResponse
+	getJSON(t, client, apiURL+"/estate/"+parallelEstateID+"/drone-plan", &parallelPlan)
+	getJSON(t, client, apiURL+"/estate/"+batchEstateID+"/drone-plan", &batchPlan)
+	require.Equal(t, batchPlan.Distance, parallelPlan.Distance)
+}
+
+// freshStats wait for the estate stats to be recalculated and return them.
+func freshStats(t *testing.T, client *http.Client, apiURL, estateID string) generated.EstateStatsResponse {
+	var stats generated.EstateStatsResponse
+	require.Eventually(t, func() bool {
+		getJSON(t, client, apiURL+"/estate/"+estateID+"/stats", &stats)
+		return stats.StatsStatus == generated.Fresh
+	}, time.Minute, 100*time.Millisecond, "stats of estate_id:%s are not recalculated", estateID)
+
+	return stats
+}
+
+func createEstate(t *testing.T, client *http.Client, apiURL string, width, length int) string {
+	resp, err := postJSON(client, apiURL+"/estate", generated.CreateEstateRequestBody{Width: width, Length: length})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var estate generated.CreateEstateResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&estate))
+
+	return estate.Id
+}
+
+func postJSON(client *http.Client, url string, payload any) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload, err:%w", err)
+	}
+
+	return client.Post(url, "application/json", bytes.NewReader(body))
+}
+
+func getJSON(t *testing.T, client *http.Client, url string, result any) {
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(result))
+}